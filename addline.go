@@ -0,0 +1,82 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// taxCategories are the UNCL5305 VAT category codes allowed by EN16931.
+var taxCategories = map[string]bool{
+	"S": true, "Z": true, "E": true, "AE": true, "K": true,
+	"G": true, "O": true, "L": true, "M": true, "B": true,
+}
+
+// AddLine checks the line and appends it to the invoice. Next to the checks
+// of Check, the line needs a quantity (unless AllowZeroQuantity is set), a
+// tax rate between 0 and 100 and a known tax category. All problems are
+// returned together and the line isn't added then.
+func (inv *Invoice) AddLine(line InvoiceLine) error {
+	err := line.checkNew(len(inv.Lines), inv.currency())
+	if err != nil {
+		return err
+	}
+	inv.Lines = append(inv.Lines, line)
+	return nil
+}
+
+// AddStandardLine adds a line of qty items at price without VAT, with
+// category S at vatRate, or category Z (zero rated) when vatRate is 0.
+func (inv *Invoice) AddStandardLine(name string, qty, price, vatRate float64) error {
+	return inv.AddLine(standardLine(name, qty, price, vatRate))
+}
+
+// AddLine checks the line and appends it to the credit note, like
+// Invoice.AddLine.
+func (cn *CreditNote) AddLine(line InvoiceLine) error {
+	err := line.checkNew(len(cn.Lines), cn.currency())
+	if err != nil {
+		return err
+	}
+	cn.Lines = append(cn.Lines, line)
+	return nil
+}
+
+// AddStandardLine adds a line to the credit note, like
+// Invoice.AddStandardLine.
+func (cn *CreditNote) AddStandardLine(name string, qty, price, vatRate float64) error {
+	return cn.AddLine(standardLine(name, qty, price, vatRate))
+}
+
+func standardLine(name string, qty, price, vatRate float64) InvoiceLine {
+	line := InvoiceLine{
+		Name:          name,
+		Quantity:      qty,
+		Price:         price,
+		TaxPercentage: vatRate,
+		TaxCategoryID: "S",
+	}
+	if vatRate == 0 {
+		line.TaxCategoryID = "Z"
+		line.TaxCategoryName = "Zero rated"
+	}
+	return line
+}
+
+// checkNew reports the problems of a line added as line i.
+func (line InvoiceLine) checkNew(i int, currency string) error {
+	errs := line.check(i, currency)
+	if line.Name != "" && strings.TrimSpace(line.Name) == "" {
+		errs = append(errs, fmt.Errorf("Lines[%d].Name: required", i))
+	}
+	if line.Quantity == 0 && !line.AllowZeroQuantity {
+		errs = append(errs, fmt.Errorf("Lines[%d].Quantity: must not be zero", i))
+	}
+	if line.TaxPercentage < 0 || line.TaxPercentage > 100 {
+		errs = append(errs, fmt.Errorf("Lines[%d].TaxPercentage: %v is not between 0 and 100", i, line.TaxPercentage))
+	}
+	if line.TaxCategoryID != "" && !taxCategories[line.TaxCategoryID] {
+		errs = append(errs, fmt.Errorf("Lines[%d].TaxCategoryID %q: not a UNCL5305 VAT category", i, line.TaxCategoryID))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+package ubl_test
+
+import (
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestAddLine(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = nil
+	if err := inv.AddStandardLine("Consulting", 8, 95, 21); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.AddStandardLine("Book", 1, 20, 0); err != nil {
+		t.Fatal(err)
+	}
+	err := inv.AddLine(ubl.InvoiceLine{Name: "Sample", Price: 0, TaxPercentage: 21, AllowZeroQuantity: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Lines[0].TaxCategoryID != "S" || inv.Lines[1].TaxCategoryID != "Z" {
+		t.Errorf("expected categories S and Z, got %+v", inv.Lines)
+	}
+	generateAndValidate(t, &inv)
+
+	err = inv.AddLine(ubl.InvoiceLine{Name: " ", Price: 10, TaxPercentage: 121, TaxCategoryID: "X", UnitCode: "BOX"})
+	want := `Lines[3].UnitCode: unit code "BOX": not in UNECE Rec 20 or Rec 21
+Lines[3].Name: required
+Lines[3].Quantity: must not be zero
+Lines[3].TaxPercentage: 121 is not between 0 and 100
+Lines[3].TaxCategoryID "X": not a UNCL5305 VAT category`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected the line errors, got %v", err)
+	}
+	if len(inv.Lines) != 3 {
+		t.Errorf("expected the bad line not to be added, got %d lines", len(inv.Lines))
+	}
+
+	cn := newTestCreditNote()
+	if err := cn.AddStandardLine("Return", 0, 10, 21); err == nil {
+		t.Error("expected an error for a credit note line without quantity")
+	}
+}
@@ -0,0 +1,141 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// AllowanceCharge is a document level allowance (BG-20) or charge (BG-21).
+//
+// The amount is either given directly or computed as Percentage of
+// BaseAmount. When BaseAmount is zero, the sum of the line amounts is used, so
+// percentages never compound on other allowances or charges.
+//
+// Without TaxCategoryID the allowance or charge is split over the VAT
+// categories of the lines, in proportion to their line amounts. Each part
+// keeps the percentage, with its share of the base amount. With TaxCategoryID
+// the category and rate must be those of a line.
+type AllowanceCharge struct {
+	Charge        bool    `json:"charge,omitempty"`        // false for an allowance, true for a charge
+	Reason        string  `json:"reason,omitempty"`        // BT-97/BT-104, required when ReasonCode is empty
+	ReasonCode    string  `json:"reasonCode,omitempty"`    // BT-98/BT-105, required when Reason is empty
+	Amount        float64 `json:"amount"`                  // BT-92/BT-99, ignored when Percentage is set
+	Percentage    float64 `json:"percentage,omitempty"`    // Optional: BT-94/BT-101
+	BaseAmount    float64 `json:"baseAmount,omitempty"`    // Optional: BT-93/BT-100, defaults to the line total
+	TaxCategoryID string  `json:"taxCategoryID,omitempty"` // Optional: BT-95/BT-102
+	TaxPercentage float64 `json:"taxPercentage,omitempty"` // Optional: BT-96/BT-103
+}
+
+func (ac AllowanceCharge) kind() string {
+	if ac.Charge {
+		return "charge"
+	}
+	return "allowance"
+}
+
+func (ac AllowanceCharge) taxKey() taxKey {
+	rate := ac.TaxPercentage
+	if zeroRateCategories[ac.TaxCategoryID] {
+		rate = 0
+	}
+	return taxKey{Rate: rate, CategoryID: ac.TaxCategoryID}
+}
+
+// resolveAllowanceCharges checks the allowances and charges and computes their
+// amounts. Percentages are applied first, on the line total, and the result is
+// rounded before it is used anywhere else. Allowances and charges without a
+// tax category are split over the given keys, in proportion to lineTotals,
+// the base amount too. Amounts are rounded to the given number of decimals of
+// the currency.
+func resolveAllowanceCharges(acs []AllowanceCharge, lineTotal money, keys []taxKey, lineTotals []money, decimals int) ([]AllowanceCharge, error) {
+	var resolved []AllowanceCharge
+	var errs []error
+	for i, ac := range acs {
+		switch {
+		case ac.Amount < 0:
+			errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: negative %s amount %.2f", i, ac.kind(), ac.Amount))
+			continue
+		case ac.Percentage < 0:
+			errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: negative %s percentage %.2f", i, ac.kind(), ac.Percentage))
+			continue
+		case ac.BaseAmount < 0:
+			errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: negative %s base amount %.2f", i, ac.kind(), ac.BaseAmount))
+			continue
+		case ac.Reason == "" && ac.ReasonCode == "":
+			errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: %s reason or reason code required", i, ac.kind()))
+			continue
+		}
+
+		var amount money
+		if ac.Percentage != 0 {
+			if ac.BaseAmount == 0 {
+				ac.BaseAmount = lineTotal.float(decimals)
+			}
+			amount = toMoney(ac.BaseAmount*ac.Percentage/100, decimals)
+		} else {
+			amount = toMoney(ac.Amount, decimals)
+			ac.BaseAmount = 0
+		}
+		ac.Amount = amount.float(decimals)
+
+		if ac.TaxCategoryID != "" {
+			if !slices.Contains(keys, ac.taxKey()) {
+				errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: %s in tax category %s at %v%%, which no line has", i, ac.kind(), ac.TaxCategoryID, ac.taxKey().Rate))
+				continue
+			}
+			resolved = append(resolved, ac)
+			continue
+		}
+
+		if lineTotal == 0 {
+			errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: %s without tax category needs lines to split over", i, ac.kind()))
+			continue
+		}
+
+		// The amount and the base amount are split in the same proportion,
+		// with the rounding difference on the last part, so the parts add up
+		// to the whole and each is about its percentage of its base.
+		base := toMoney(ac.BaseAmount, decimals)
+		remaining, remainingBase := amount, base
+		for k, key := range keys {
+			part := ac
+			part.TaxCategoryID = key.CategoryID
+			part.TaxPercentage = key.Rate
+			share, baseShare := remaining, remainingBase
+			if k < len(keys)-1 {
+				share = amount.share(lineTotals[k], lineTotal)
+				baseShare = base.share(lineTotals[k], lineTotal)
+				remaining -= share
+				remainingBase -= baseShare
+			}
+			part.Amount = share.float(decimals)
+			part.BaseAmount = baseShare.float(decimals)
+			if share != 0 {
+				resolved = append(resolved, part)
+			}
+		}
+	}
+
+	return resolved, errors.Join(errs...)
+}
+
+func (ac AllowanceCharge) xml(currency, taxScheme string) XMLAllowanceCharge {
+	key := ac.taxKey()
+	x := XMLAllowanceCharge{
+		ChargeIndicator:           ac.Charge,
+		AllowanceChargeReasonCode: ac.ReasonCode,
+		AllowanceChargeReason:     ac.Reason,
+		MultiplierFactorNumeric:   ac.Percentage,
+		Amount:                    XMLAmount{Value: ac.Amount, CurrencyID: currency},
+		TaxCategory: XMLTaxCategory{
+			ID:        key.CategoryID,
+			Percent:   taxPercent(key.CategoryID, key.Rate),
+			TaxScheme: XMLTaxScheme{ID: taxScheme},
+		},
+	}
+	if ac.Percentage != 0 {
+		x.BaseAmount = &XMLAmount{Value: ac.BaseAmount, CurrencyID: currency}
+	}
+	return x
+}
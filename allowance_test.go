@@ -0,0 +1,165 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestAllowanceCharges(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 10, Price: 100, TaxPercentage: 21, Name: "Widget"},
+		{Quantity: 5, Price: 100, TaxPercentage: 6, Name: "Book"},
+	}
+	inv.AllowanceCharges = []ubl.AllowanceCharge{
+		{Reason: "Volume discount", ReasonCode: "95", Percentage: 10},
+		{Charge: true, Reason: "Freight", Amount: 25, TaxCategoryID: "S", TaxPercentage: 21},
+	}
+
+	xmlBytes := generateAndValidate(t, &inv)
+	doc, err := inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals := doc.Totals()
+	if totals.LineExtension != 1500 || totals.AllowanceTotal != 150 || totals.ChargeTotal != 25 ||
+		totals.TaxExclusive != 1375 || totals.Tax != 221.25 || totals.Payable != 1596.25 {
+		t.Errorf("unexpected totals %+v", totals)
+	}
+
+	want := []ubl.TaxBreakdown{
+		{CategoryID: "S", Percent: 21, LineAmount: 1000, AllowanceAmount: 100, ChargeAmount: 25, TaxableAmount: 925, TaxAmount: 194.25},
+		{CategoryID: "S", Percent: 6, LineAmount: 500, AllowanceAmount: 50, TaxableAmount: 450, TaxAmount: 27},
+	}
+	if len(totals.Breakdown) != len(want) {
+		t.Fatalf("expected %d breakdown entries, got %+v", len(want), totals.Breakdown)
+	}
+	for i := range want {
+		if totals.Breakdown[i] != want[i] {
+			t.Errorf("breakdown %d: expected %+v, got %+v", i, want[i], totals.Breakdown[i])
+		}
+	}
+
+	out := compact(xmlBytes)
+	for _, want := range []string{
+		`<cbc:AllowanceTotalAmount currencyID="EUR">150.00</cbc:AllowanceTotalAmount><cbc:ChargeTotalAmount currencyID="EUR">25.00</cbc:ChargeTotalAmount>`,
+		`<cbc:ChargeIndicator>false</cbc:ChargeIndicator><cbc:AllowanceChargeReasonCode>95</cbc:AllowanceChargeReasonCode><cbc:AllowanceChargeReason>Volume discount</cbc:AllowanceChargeReason><cbc:MultiplierFactorNumeric>10</cbc:MultiplierFactorNumeric><cbc:Amount currencyID="EUR">100.00</cbc:Amount><cbc:BaseAmount currencyID="EUR">1000.00</cbc:BaseAmount>`,
+		`<cbc:MultiplierFactorNumeric>10</cbc:MultiplierFactorNumeric><cbc:Amount currencyID="EUR">50.00</cbc:Amount><cbc:BaseAmount currencyID="EUR">500.00</cbc:BaseAmount>`,
+		`<cbc:ChargeIndicator>true</cbc:ChargeIndicator><cbc:AllowanceChargeReason>Freight</cbc:AllowanceChargeReason><cbc:Amount currencyID="EUR">25.00</cbc:Amount>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+}
+
+// TestAllowanceChargeEN16931Example computes the document level allowance and
+// charge of the EN 16931 examples in the Peppol BIS Billing 3.0
+// documentation: a discount of 10% of 2000.00 and 100.00 freight, both at 25%.
+func TestAllowanceChargeEN16931Example(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 10, Price: 150, TaxPercentage: 25, Name: "Item A"},
+		{Quantity: 5, Price: 100, TaxPercentage: 25, Name: "Item B"},
+	}
+	inv.AllowanceCharges = []ubl.AllowanceCharge{
+		{Reason: "Discount", ReasonCode: "95", Percentage: 10, BaseAmount: 2000, TaxCategoryID: "S", TaxPercentage: 25},
+		{Charge: true, Reason: "Freight service", ReasonCode: "FC", Amount: 100, TaxCategoryID: "S", TaxPercentage: 25},
+	}
+	out := compact(generateAndValidate(t, &inv))
+	totals, err := inv.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.LineExtension != 2000 || totals.AllowanceTotal != 200 || totals.ChargeTotal != 100 ||
+		totals.TaxExclusive != 1900 || totals.Tax != 475 || totals.TaxInclusive != 2375 || totals.Payable != 2375 {
+		t.Errorf("unexpected totals %+v", totals)
+	}
+	for _, want := range []string{
+		`<cbc:AllowanceChargeReasonCode>95</cbc:AllowanceChargeReasonCode><cbc:AllowanceChargeReason>Discount</cbc:AllowanceChargeReason><cbc:MultiplierFactorNumeric>10</cbc:MultiplierFactorNumeric><cbc:Amount currencyID="EUR">200.00</cbc:Amount><cbc:BaseAmount currencyID="EUR">2000.00</cbc:BaseAmount>`,
+		`<cbc:AllowanceChargeReasonCode>FC</cbc:AllowanceChargeReasonCode><cbc:AllowanceChargeReason>Freight service</cbc:AllowanceChargeReason><cbc:Amount currencyID="EUR">100.00</cbc:Amount>`,
+		`<cac:TaxSubtotal><cbc:TaxableAmount currencyID="EUR">1900.00</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">475.00</cbc:TaxAmount>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	// the same discount without a category, over lines at 25% and 12%: each
+	// part keeps the percentage with its share of the base
+	inv.Lines[1].TaxPercentage = 12
+	inv.AllowanceCharges = inv.AllowanceCharges[:1]
+	inv.AllowanceCharges[0].TaxCategoryID, inv.AllowanceCharges[0].TaxPercentage = "", 0
+	inv.AllowanceCharges[0].BaseAmount = 1999.99
+	totals, err = inv.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ubl.AllowanceCharge{
+		{Reason: "Discount", ReasonCode: "95", Amount: 150, Percentage: 10, BaseAmount: 1499.99, TaxCategoryID: "S", TaxPercentage: 25},
+		{Reason: "Discount", ReasonCode: "95", Amount: 50, Percentage: 10, BaseAmount: 500, TaxCategoryID: "S", TaxPercentage: 12},
+	}
+	if len(totals.AllowanceCharges) != len(want) || totals.AllowanceCharges[0] != want[0] || totals.AllowanceCharges[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, totals.AllowanceCharges)
+	}
+	generateAndValidate(t, &inv)
+}
+
+func TestAllowanceChargePercentageWithCategory(t *testing.T) {
+	inv := newTestInvoice()
+	inv.AllowanceCharges = []ubl.AllowanceCharge{
+		{Reason: "Early payment", Percentage: 2.5, BaseAmount: 400, TaxCategoryID: "S", TaxPercentage: 21},
+	}
+
+	out := compact(generateAndValidate(t, &inv))
+	want := `<cbc:MultiplierFactorNumeric>2.5</cbc:MultiplierFactorNumeric><cbc:Amount currencyID="EUR">10.00</cbc:Amount><cbc:BaseAmount currencyID="EUR">400.00</cbc:BaseAmount>`
+	if !strings.Contains(out, want) {
+		t.Errorf("expected %s in output", want)
+	}
+}
+
+func TestAllowanceChargeErrors(t *testing.T) {
+	tests := []struct {
+		ac   ubl.AllowanceCharge
+		want string
+	}{
+		{ubl.AllowanceCharge{Reason: "Discount", Amount: -10}, "AllowanceCharges[0]: negative allowance amount -10.00"},
+		{ubl.AllowanceCharge{Charge: true, Reason: "Fee", Percentage: -5}, "AllowanceCharges[0]: negative charge percentage -5.00"},
+		{ubl.AllowanceCharge{Amount: 10}, "AllowanceCharges[0]: allowance reason or reason code required"},
+		{ubl.AllowanceCharge{Charge: true, Reason: "Freight", Amount: 10, TaxCategoryID: "S", TaxPercentage: 6}, "AllowanceCharges[0]: charge in tax category S at 6%, which no line has"},
+	}
+	for _, tt := range tests {
+		inv := newTestInvoice()
+		inv.AllowanceCharges = []ubl.AllowanceCharge{tt.ac}
+		_, err := inv.Generate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error %q, got %v", tt.want, err)
+		}
+	}
+}
+
+func TestAllowanceZeroTaxableAmount(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 2, Price: 49.95, TaxPercentage: 21, Name: "Widget"},
+		{Quantity: 3, Price: 20, TaxPercentage: 6, Name: "Book"},
+	}
+	inv.AllowanceCharges = []ubl.AllowanceCharge{
+		{Reason: "Free books", Amount: 60, TaxCategoryID: "S", TaxPercentage: 6},
+		{Charge: true, Reason: "Packing", Amount: 1.25, TaxCategoryID: "S", TaxPercentage: 21},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cac:TaxSubtotal><cbc:TaxableAmount currencyID="EUR">101.15</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">21.24</cbc:TaxAmount>`,
+		`<cac:TaxSubtotal><cbc:TaxableAmount currencyID="EUR">0.00</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">0.00</cbc:TaxAmount>`,
+		`<cac:TaxTotal><cbc:TaxAmount currencyID="EUR">21.24</cbc:TaxAmount>`,
+		`<cbc:PayableAmount currencyID="EUR">122.39</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ubl
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestAmountMarshalXML(t *testing.T) {
+	for _, c := range []struct {
+		value    float64
+		currency string
+		want     string
+	}{
+		{0, "EUR", "0.00"},
+		{0.005, "EUR", "0.01"},
+		{100.30000000000001, "EUR", "100.30"},
+		{1210, "EUR", "1210.00"},
+		{1e6, "EUR", "1000000.00"},
+		{-0.004, "EUR", "0.00"},
+		{-12.345, "EUR", "-12.35"},
+		{2.675, "EUR", "2.68"},
+		{9.995, "EUR", "10.00"},
+		{-0.995, "EUR", "-1.00"},
+		{123456789012345.67, "EUR", "123456789012345.67"},
+		{1e21, "EUR", "1000000000000000000000.00"},
+		{1234.5, "JPY", "1235"},
+		{12.3456, "BHD", "12.346"},
+		{999.5, "JPY", "1000"},
+	} {
+		out, err := xml.Marshal(XMLAmount{Value: c.value, CurrencyID: c.currency})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `<XMLAmount currencyID="` + c.currency + `">` + c.want + `</XMLAmount>`
+		if string(out) != want {
+			t.Errorf("%v %s: expected %s, got %s", c.value, c.currency, want, out)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package ubl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// defaultMaxAttachmentBytes is the largest attachment accepted without
+// MaxAttachmentBytes. Access points commonly refuse documents of 5 to 20 MB
+// after the base64 expansion.
+const defaultMaxAttachmentBytes = 10 << 20
+
+// attachmentLimit returns the size limit for attachments given
+// MaxAttachmentBytes: the default for 0, no limit (-1) when negative.
+func attachmentLimit(maxBytes int) int {
+	switch {
+	case maxBytes == 0:
+		return defaultMaxAttachmentBytes
+	case maxBytes < 0:
+		return -1
+	}
+	return maxBytes
+}
+
+// checkAttachmentSize checks an attachment of size bytes against
+// MaxAttachmentBytes.
+func checkAttachmentSize(size, maxBytes int) error {
+	if limit := attachmentLimit(maxBytes); limit >= 0 && size > limit {
+		return fmt.Errorf("%d bytes exceeds the limit of %d", size, limit)
+	}
+	return nil
+}
+
+// extensionMimeCodes are the MIME types Peppol allows for attachments, by
+// file extension.
+var extensionMimeCodes = map[string]string{
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".csv":  "text/csv",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+}
+
+// containerMimeCodes are the types http.DetectContentType gives for the
+// extensions it can't tell apart: spreadsheets are zip files, CSV is text.
+var containerMimeCodes = map[string]string{
+	".xlsx": "application/zip",
+	".ods":  "application/zip",
+	".csv":  "text/plain",
+}
+
+// cleanFilename returns the base name of filename, without the directories
+// of a Unix or Windows path, with control characters and non-ASCII bytes
+// percent-escaped. A cleaned name stays the same when cleaned again.
+func cleanFilename(filename string) (string, error) {
+	base := path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if base == "." || base == ".." || base == "/" {
+		return "", fmt.Errorf("filename %q: no file name", filename)
+	}
+	var b strings.Builder
+	for i := range len(base) {
+		c := base[i]
+		if c < 0x20 || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// attachmentMimeCode returns the MIME type of an attachment given the one
+// detected from its data. A known extension must match the data, e.g. PDF
+// data can't be named .xlsx, and makes a detected container type precise.
+func attachmentMimeCode(filename, detected string) (string, error) {
+	ext := strings.ToLower(path.Ext(filename))
+	want, ok := extensionMimeCodes[ext]
+	switch {
+	case !ok || want == detected:
+		return detected, nil
+	case containerMimeCodes[ext] == detected:
+		return want, nil
+	}
+	return "", fmt.Errorf("filename %q: extension %s doesn't match the %s data", filename, ext, detected)
+}
+
+// pdfAttachment is the PDF rendition of a document, given as a file or as
+// base64 data.
+type pdfAttachment struct {
+	documentID  string
+	filename    string
+	data        string // base64, read from filename when empty
+	description string
+	maxBytes    int
+}
+
+// documentReferences returns the cac:AdditionalDocumentReference element for
+// the PDF, or nil without a PDF. A PDF read from file gets
+// defaultDescription when it has no description.
+func (a pdfAttachment) documentReferences(defaultDescription string) ([]XMLDocumentReference, error) {
+	mime := "application/pdf"
+	data := a.data
+	description := a.description
+	var rawData []byte
+	filename := a.filename
+	if filename != "" {
+		var err error
+		filename, err = cleanFilename(filename)
+		if err != nil {
+			return nil, fmt.Errorf("add attachment failed: %w", err)
+		}
+	}
+	switch {
+	case data != "":
+		err := checkAttachmentSize(base64Size(data), a.maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("add attachment failed: PDF data of %w", err)
+		}
+	case a.filename != "":
+		raw, err := os.ReadFile(a.filename)
+		if err != nil {
+			return nil, fmt.Errorf("add attachment failed: %w", err)
+		}
+		err = checkAttachmentSize(len(raw), a.maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("add attachment %q failed: %w", a.filename, err)
+		}
+		mime, _, _ = strings.Cut(http.DetectContentType(raw), ";")
+		// base64 encoded while marshalling
+		rawData = raw
+		if description == "" {
+			description = defaultDescription
+		}
+	default:
+		return nil, nil
+	}
+	if filename != "" {
+		var err error
+		mime, err = attachmentMimeCode(filename, mime)
+		if err != nil {
+			return nil, fmt.Errorf("add attachment failed: %w", err)
+		}
+	}
+
+	return []XMLDocumentReference{{
+		ID:                  XMLIdentifier{Value: a.documentID},
+		DocumentDescription: description,
+		Attachment: []XMLAttachment{
+			{EmbeddedDocumentBinaryObject: &XMLEmbeddedDocumentBinaryObject{
+				Value:    data,
+				Data:     rawData,
+				MimeCode: mime,
+				Filename: filename,
+			}},
+		},
+	}}, nil
+}
+
+// AddAttachmentFromBytes embeds data as a supporting document, e.g. a PDF
+// rendered in memory. The MIME type is detected from the data and must match
+// the extension of filename, of which only the base name is kept. Empty data
+// and data larger than MaxAttachmentBytes are refused.
+func (inv *Invoice) AddAttachmentFromBytes(data []byte, filename, description string) error {
+	a, err := newAttachment(data, filename, description, inv.MaxAttachmentBytes)
+	if err != nil {
+		return err
+	}
+	inv.Attachments = append(inv.Attachments, a)
+	return nil
+}
+
+// AddAttachmentFromBytes embeds data as a supporting document, like
+// Invoice.AddAttachmentFromBytes.
+func (cn *CreditNote) AddAttachmentFromBytes(data []byte, filename, description string) error {
+	a, err := newAttachment(data, filename, description, cn.MaxAttachmentBytes)
+	if err != nil {
+		return err
+	}
+	cn.Attachments = append(cn.Attachments, a)
+	return nil
+}
+
+// AddAttachmentFromReader embeds the contents of r as a supporting document,
+// like AddAttachmentFromBytes, without reading it into memory more than once.
+// The MIME type is detected from the first 512 bytes.
+func (inv *Invoice) AddAttachmentFromReader(r io.Reader, filename, description string) error {
+	a, err := readAttachment(r, filename, description, inv.MaxAttachmentBytes)
+	if err != nil {
+		return err
+	}
+	inv.Attachments = append(inv.Attachments, a)
+	return nil
+}
+
+// AddAttachmentFromReader embeds the contents of r as a supporting document,
+// like Invoice.AddAttachmentFromReader.
+func (cn *CreditNote) AddAttachmentFromReader(r io.Reader, filename, description string) error {
+	a, err := readAttachment(r, filename, description, cn.MaxAttachmentBytes)
+	if err != nil {
+		return err
+	}
+	cn.Attachments = append(cn.Attachments, a)
+	return nil
+}
+
+// AddExternalReference adds a supporting document by reference: the
+// document stays at uri, an absolute URL, instead of being embedded.
+func (inv *Invoice) AddExternalReference(id, uri, description string) error {
+	a, err := newExternalReference(id, uri, description)
+	if err != nil {
+		return err
+	}
+	inv.Attachments = append(inv.Attachments, a)
+	return nil
+}
+
+// AddExternalReference adds a supporting document by reference, like
+// Invoice.AddExternalReference.
+func (cn *CreditNote) AddExternalReference(id, uri, description string) error {
+	a, err := newExternalReference(id, uri, description)
+	if err != nil {
+		return err
+	}
+	cn.Attachments = append(cn.Attachments, a)
+	return nil
+}
+
+func newExternalReference(id, uri, description string) (Attachment, error) {
+	if id == "" {
+		return Attachment{}, errors.New("add external reference failed: id required")
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add external reference %q failed: %w", id, err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return Attachment{}, fmt.Errorf("add external reference %q failed: URI %q is not an absolute URL", id, uri)
+	}
+	return Attachment{ID: id, Description: description, URI: uri}, nil
+}
+
+func readAttachment(r io.Reader, filename, description string, maxBytes int) (Attachment, error) {
+	if filename == "" {
+		return Attachment{}, errors.New("add attachment failed: filename required")
+	}
+	filename, err := cleanFilename(filename)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add attachment failed: %w", err)
+	}
+	limit := attachmentLimit(maxBytes)
+	size := sizeHint(r)
+	src := r
+	if limit >= 0 {
+		size = min(size, limit)
+		// one byte more than allowed tells a too large attachment apart
+		src = io.LimitReader(r, int64(limit)+1)
+	}
+	var buf bytes.Buffer
+	// room for the check at EOF, so a known size isn't read twice
+	buf.Grow(size + bytes.MinRead)
+	_, err = buf.ReadFrom(src)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add attachment %q failed: %w", filename, err)
+	}
+	if buf.Len() == 0 {
+		return Attachment{}, fmt.Errorf("add attachment %q failed: no data", filename)
+	}
+	if limit >= 0 && buf.Len() > limit {
+		// count the rest without keeping it, to report the actual size
+		skipped, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return Attachment{}, fmt.Errorf("add attachment %q failed: %w", filename, err)
+		}
+		return Attachment{}, fmt.Errorf("add attachment %q failed: %w", filename, checkAttachmentSize(buf.Len()+int(skipped), limit))
+	}
+
+	mime, _, _ := strings.Cut(http.DetectContentType(buf.Bytes()), ";")
+	mime, err = attachmentMimeCode(filename, mime)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add attachment failed: %w", err)
+	}
+	return Attachment{
+		ID:          filename,
+		Description: description,
+		Filename:    filename,
+		MimeCode:    mime,
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// sizeHint returns the number of bytes r will probably give, e.g. the size
+// of a file, or 0 when it can't tell.
+func sizeHint(r io.Reader) int {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return r.Len()
+	case interface{ Stat() (fs.FileInfo, error) }:
+		info, err := r.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			return int(info.Size())
+		}
+	}
+	return 0
+}
+
+func newAttachment(data []byte, filename, description string, maxBytes int) (Attachment, error) {
+	switch {
+	case filename == "":
+		return Attachment{}, errors.New("add attachment failed: filename required")
+	case len(data) == 0:
+		return Attachment{}, fmt.Errorf("add attachment %q failed: no data", filename)
+	}
+	filename, err := cleanFilename(filename)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add attachment failed: %w", err)
+	}
+	err = checkAttachmentSize(len(data), maxBytes)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add attachment %q failed: %w", filename, err)
+	}
+	// DetectContentType adds parameters like "; charset=utf-8"
+	mime, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	mime, err = attachmentMimeCode(filename, mime)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("add attachment failed: %w", err)
+	}
+	return Attachment{
+		ID:          filename,
+		Description: description,
+		Filename:    filename,
+		MimeCode:    mime,
+		Data:        data,
+	}, nil
+}
+
+// attachmentReferences returns the cac:AdditionalDocumentReference elements
+// of the supporting documents. A document without data or URI is a plain
+// reference, e.g. an invoiced object identifier (DocumentTypeCode 130).
+func attachmentReferences(attachments []Attachment) []XMLDocumentReference {
+	var refs []XMLDocumentReference
+	for _, a := range attachments {
+		ref := XMLDocumentReference{
+			ID:                  XMLIdentifier{Value: a.ID, SchemeID: a.IDScheme},
+			DocumentTypeCode:    a.DocumentTypeCode,
+			DocumentDescription: a.Description,
+		}
+		switch {
+		case a.URI != "":
+			ref.Attachment = []XMLAttachment{{ExternalReference: &XMLExternalReference{URI: a.URI}}}
+		case a.size() > 0:
+			// Check refused a filename that can't be cleaned
+			filename, _ := cleanFilename(a.Filename)
+			ref.Attachment = []XMLAttachment{{EmbeddedDocumentBinaryObject: &XMLEmbeddedDocumentBinaryObject{
+				Data:     a.Data,
+				MimeCode: a.MimeCode,
+				Filename: filename,
+			}}}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// size returns the number of bytes of the attachment, 0 for an external
+// reference.
+func (a Attachment) size() int {
+	return len(a.Data)
+}
+
+// base64Size returns the number of bytes the base64 data decodes to.
+func base64Size(data string) int {
+	return base64.StdEncoding.DecodedLen(len(data)) - (len(data) - len(strings.TrimRight(data, "=")))
+}
+
+// MarshalXML writes the base64 data in chunks, encoding raw Data on the fly,
+// so a large attachment is never held in memory as a whole base64 string.
+func (o XMLEmbeddedDocumentBinaryObject) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "mimeCode"}, Value: o.MimeCode},
+		xml.Attr{Name: xml.Name{Local: "filename"}, Value: o.Filename})
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	chunk := make([]byte, 32<<10)
+	// a multiple of 3 bytes encodes without padding, so the chunks join up
+	for data := o.Data; len(data) > 0; {
+		raw := data[:min(len(data), len(chunk)/4*3)]
+		base64.StdEncoding.Encode(chunk, raw)
+		err = e.EncodeToken(xml.CharData(chunk[:base64.StdEncoding.EncodedLen(len(raw))]))
+		if err != nil {
+			return err
+		}
+		data = data[len(raw):]
+	}
+	for data := o.Value; data != ""; {
+		n := copy(chunk, data)
+		err = e.EncodeToken(xml.CharData(chunk[:n]))
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return e.EncodeToken(start.End())
+}
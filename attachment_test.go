@@ -0,0 +1,370 @@
+package ubl_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestAddAttachmentFromBytes(t *testing.T) {
+	pdf, err := os.ReadFile("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	inv := newTestInvoice()
+	if err := inv.AddAttachmentFromBytes(pdf, "timesheet.pdf", "Timesheet March"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.AddAttachmentFromBytes(png, "site.png", "Photo of the site"); err != nil {
+		t.Fatal(err)
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:ID>timesheet.pdf</cbc:ID><cbc:DocumentDescription>Timesheet March</cbc:DocumentDescription><cac:Attachment><cbc:EmbeddedDocumentBinaryObject mimeCode="application/pdf" filename="timesheet.pdf">`,
+		`<cbc:EmbeddedDocumentBinaryObject mimeCode="image/png" filename="site.png">iVBORw0KGgoAAAANSUhEUg==</cbc:EmbeddedDocumentBinaryObject>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	doc, err := inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := doc.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 || !bytes.Equal(attachments[0].Data, pdf) || !bytes.Equal(attachments[1].Data, png) {
+		t.Errorf("expected both attachments back, got %d attachments", len(attachments))
+	}
+
+	cn := newTestCreditNote()
+	cn.MaxAttachmentBytes = 1000
+	for _, c := range []struct {
+		data     []byte
+		filename string
+		want     string
+	}{
+		{nil, "empty.pdf", `add attachment "empty.pdf" failed: no data`},
+		{pdf, "big.pdf", `add attachment "big.pdf" failed: 8807 bytes exceeds the limit of 1000`},
+		{png, "", "add attachment failed: filename required"},
+	} {
+		err := cn.AddAttachmentFromBytes(c.data, c.filename, "")
+		if err == nil || !strings.HasPrefix(err.Error(), c.want) {
+			t.Errorf("%s: expected %q, got %v", c.filename, c.want, err)
+		}
+	}
+	if len(cn.Attachments) != 0 {
+		t.Errorf("expected no attachments added, got %d", len(cn.Attachments))
+	}
+}
+
+func TestAddAttachmentFromReader(t *testing.T) {
+	pdf, err := os.ReadFile("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	inv := newTestInvoice()
+	if err := inv.AddAttachmentFromReader(f, "scan.pdf", "Signed delivery note"); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Attachments[0].MimeCode != "application/pdf" || !bytes.Equal(inv.Attachments[0].Data, pdf) {
+		t.Errorf("expected the PDF as raw data, got %q", inv.Attachments[0].MimeCode)
+	}
+	generateAndValidate(t, &inv)
+
+	var buf bytes.Buffer
+	if err := inv.GenerateTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), xmlBytes) {
+		t.Error("expected GenerateTo to write the same bytes as Generate")
+	}
+	doc, err := inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := doc.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 1 || !bytes.Equal(attachments[0].Data, pdf) {
+		t.Error("expected the PDF back from the generated document")
+	}
+
+	cn := newTestCreditNote()
+	cn.MaxAttachmentBytes = 8806
+	err = cn.AddAttachmentFromReader(bytes.NewReader(pdf), "scan.pdf", "")
+	if err == nil || err.Error() != `add attachment "scan.pdf" failed: 8807 bytes exceeds the limit of 8806` {
+		t.Errorf("expected an error for a too large attachment, got %v", err)
+	}
+	err = cn.AddAttachmentFromReader(strings.NewReader(""), "empty.pdf", "")
+	if err == nil || err.Error() != `add attachment "empty.pdf" failed: no data` {
+		t.Errorf("expected an error for an empty attachment, got %v", err)
+	}
+	cn.MaxAttachmentBytes = 8807
+	if err := cn.AddAttachmentFromReader(bytes.NewReader(pdf), "scan.pdf", ""); err != nil {
+		t.Errorf("expected an attachment of exactly the limit, got %v", err)
+	}
+	var cnBuf bytes.Buffer
+	if err := cn.GenerateCreditNoteTo(&cnBuf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cnBuf.String(), `filename="scan.pdf"`) {
+		t.Error("expected the attachment in the credit note")
+	}
+}
+
+func TestAttachmentFilenames(t *testing.T) {
+	pdf, err := os.ReadFile("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xlsx := []byte("PK\x03\x04\x14\x00\x06\x00\x08\x00\x00\x00!\x00")
+
+	inv := newTestInvoice()
+	for _, c := range []struct {
+		data     []byte
+		filename string
+		want     string
+	}{
+		{pdf, `C:\Users\an\Documents\timesheet.pdf`, `mimeCode="application/pdf" filename="timesheet.pdf"`},
+		{pdf, "../../etc/passwd", `mimeCode="application/pdf" filename="passwd"`},
+		{pdf, "facture-été-🧾.pdf", `filename="facture-%C3%A9t%C3%A9-%F0%9F%A7%BE.pdf"`},
+		{pdf, "time\tsheet 100%.pdf", `filename="time%09sheet 100%.pdf"`},
+		{xlsx, "hours.XLSX", `mimeCode="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" filename="hours.XLSX"`},
+	} {
+		if err := inv.AddAttachmentFromBytes(c.data, c.filename, ""); err != nil {
+			t.Fatal(err)
+		}
+		xmlBytes := compact(generateAndValidate(t, &inv))
+		if !strings.Contains(xmlBytes, c.want) {
+			t.Errorf("%q: expected %s in output", c.filename, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		data     []byte
+		filename string
+		want     string
+	}{
+		{pdf, "report.xlsx", `add attachment failed: filename "report.xlsx": extension .xlsx doesn't match the application/pdf data`},
+		{xlsx, "hours.pdf", `add attachment failed: filename "hours.pdf": extension .pdf doesn't match the application/zip data`},
+		{pdf, `..\..`, `add attachment failed: filename "..\\..": no file name`},
+	} {
+		err := inv.AddAttachmentFromReader(bytes.NewReader(c.data), c.filename, "")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: expected %q, got %v", c.filename, c.want, err)
+		}
+	}
+
+	cn := newTestCreditNote()
+	cn.Attachments = []ubl.Attachment{{ID: "report", Filename: "report.xlsx", MimeCode: "application/pdf", Data: pdf}}
+	_, err = cn.GenerateCreditNote()
+	if err == nil || err.Error() != `Attachments[0]: filename "report.xlsx": extension .xlsx doesn't match the application/pdf data` {
+		t.Errorf("expected an error for a PDF named .xlsx, got %v", err)
+	}
+}
+
+func TestAttachmentDocumentTypeCode(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	inv := newTestInvoice()
+	inv.Attachments = []ubl.Attachment{
+		{ID: "SUB-2025-0117", IDScheme: "ABZ", DocumentTypeCode: "130", Description: "Subscription"},
+		{ID: "site.png", Filename: "site.png", MimeCode: "image/png", Data: png},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cac:AdditionalDocumentReference><cbc:ID schemeID="ABZ">SUB-2025-0117</cbc:ID><cbc:DocumentTypeCode>130</cbc:DocumentTypeCode><cbc:DocumentDescription>Subscription</cbc:DocumentDescription></cac:AdditionalDocumentReference>`,
+		`<cac:AdditionalDocumentReference><cbc:ID>site.png</cbc:ID><cac:Attachment>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+}
+
+func TestMaxAttachmentBytes(t *testing.T) {
+	pdf, err := os.ReadFile("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := newTestInvoice()
+	inv.MaxAttachmentBytes = 8000
+	inv.PdfInvoiceFilename = "invoice_test.pdf"
+	_, err = inv.Generate()
+	if err == nil || err.Error() != `add attachment "invoice_test.pdf" failed: 8807 bytes exceeds the limit of 8000` {
+		t.Errorf("expected an error for a too large PDF, got %v", err)
+	}
+	inv.PdfInvoiceFilename = ""
+	inv.PdfInvoiceData = base64.StdEncoding.EncodeToString(pdf)
+	_, err = inv.Generate()
+	if err == nil || err.Error() != `add attachment failed: PDF data of 8807 bytes exceeds the limit of 8000` {
+		t.Errorf("expected an error for too large PDF data, got %v", err)
+	}
+	inv.PdfInvoiceData = ""
+	inv.Attachments = []ubl.Attachment{{ID: "scan", Filename: "scan.pdf", MimeCode: "application/pdf", Data: pdf}}
+	err = inv.Check()
+	if err == nil || err.Error() != `Attachments[0] "scan": 8807 bytes exceeds the limit of 8000` {
+		t.Errorf("expected an error for a too large attachment, got %v", err)
+	}
+
+	inv.MaxAttachmentBytes = -1
+	inv.Attachments = nil
+	if err := inv.AddAttachmentFromReader(io.MultiReader(bytes.NewReader(pdf), strings.NewReader(strings.Repeat(" ", 11<<20))), "large.pdf", ""); err != nil {
+		t.Errorf("expected no limit, got %v", err)
+	}
+	if err := inv.Check(); err != nil {
+		t.Errorf("expected no limit, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	large := make([]byte, 10<<20+1)
+	err = cn.AddAttachmentFromReader(bytes.NewReader(large), "large.bin", "")
+	if err == nil || err.Error() != `add attachment "large.bin" failed: 10485761 bytes exceeds the limit of 10485760` {
+		t.Errorf("expected the default limit of 10 MB, got %v", err)
+	}
+	err = cn.AddAttachmentFromBytes(large, "large.bin", "")
+	if err == nil || err.Error() != `add attachment "large.bin" failed: 10485761 bytes exceeds the limit of 10485760` {
+		t.Errorf("expected the default limit of 10 MB, got %v", err)
+	}
+}
+
+func TestAddExternalReference(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	inv := newTestInvoice()
+	if err := inv.AddAttachmentFromBytes(png, "site.png", "Photo of the site"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.AddExternalReference("TS-2025-03", "https://example.com/timesheets/2025-03.pdf", "Timesheet March"); err != nil {
+		t.Fatal(err)
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:EmbeddedDocumentBinaryObject mimeCode="image/png" filename="site.png">iVBORw0KGgoAAAANSUhEUg==</cbc:EmbeddedDocumentBinaryObject>`,
+		`<cbc:ID>TS-2025-03</cbc:ID><cbc:DocumentDescription>Timesheet March</cbc:DocumentDescription><cac:Attachment><cac:ExternalReference><cbc:URI>https://example.com/timesheets/2025-03.pdf</cbc:URI></cac:ExternalReference></cac:Attachment>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	doc, err := inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := doc.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 || attachments[1].URI != "https://example.com/timesheets/2025-03.pdf" || attachments[1].Data != nil {
+		t.Errorf("expected the embedded attachment and the external reference back, got %+v", attachments)
+	}
+
+	cn := newTestCreditNote()
+	for _, c := range []struct {
+		id, uri string
+		want    string
+	}{
+		{"", "https://example.com/a.pdf", "add external reference failed: id required"},
+		{"A", "timesheets/a.pdf", `add external reference "A" failed: URI "timesheets/a.pdf" is not an absolute URL`},
+		{"B", "https://exa mple.com/", `add external reference "B" failed: parse`},
+	} {
+		err := cn.AddExternalReference(c.id, c.uri, "")
+		if err == nil || !strings.HasPrefix(err.Error(), c.want) {
+			t.Errorf("%s: expected %q, got %v", c.uri, c.want, err)
+		}
+	}
+	if len(cn.Attachments) != 0 {
+		t.Errorf("expected no attachments added, got %d", len(cn.Attachments))
+	}
+}
+
+// BenchmarkAttachment generates an invoice with a 15 MB scan, read into
+// memory or streamed in, to a byte slice or to a writer. The base64 encoding
+// is only produced while writing, so GenerateTo needs little more than the
+// scan itself.
+func BenchmarkAttachment(b *testing.B) {
+	scan := filepath.Join(b.TempDir(), "scan.pdf")
+	data := bytes.Repeat([]byte("%PDF-1.4 scanned page\n"), 15<<20/22)
+	if err := os.WriteFile(scan, data, 0o600); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("FromBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inv := newTestInvoice()
+			inv.MaxAttachmentBytes = 20 << 20
+			data, err := os.ReadFile(scan)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := inv.AddAttachmentFromBytes(data, "scan.pdf", ""); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := inv.Generate(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("FromBytesTo", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inv := newTestInvoice()
+			inv.MaxAttachmentBytes = 20 << 20
+			data, err := os.ReadFile(scan)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := inv.AddAttachmentFromBytes(data, "scan.pdf", ""); err != nil {
+				b.Fatal(err)
+			}
+			if err := inv.GenerateTo(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("FromReaderTo", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inv := newTestInvoice()
+			inv.MaxAttachmentBytes = 20 << 20
+			f, err := os.Open(scan)
+			if err != nil {
+				b.Fatal(err)
+			}
+			err = inv.AddAttachmentFromReader(f, "scan.pdf", "")
+			f.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := inv.GenerateTo(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
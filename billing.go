@@ -0,0 +1,45 @@
+package ubl
+
+import (
+	"fmt"
+	"time"
+)
+
+// NewPrepaymentInvoice returns an Invoice with type code 386 (prepayment
+// invoice). Fill in the rest as for any invoice; a prepayment invoice usually
+// has no delivery information.
+func NewPrepaymentInvoice() *Invoice {
+	return &Invoice{InvoiceTypeCode: "386"}
+}
+
+// NewCorrectiveInvoice returns an Invoice with type code 384 (corrected
+// invoice) that refers to the invoice it corrects.
+func NewCorrectiveInvoice(originalID string, originalDate time.Time) *Invoice {
+	return &Invoice{
+		InvoiceTypeCode:     "384",
+		OriginalInvoiceID:   originalID,
+		OriginalInvoiceDate: &originalDate,
+	}
+}
+
+// billingReference returns the cac:BillingReference to a preceding invoice
+// (BG-3), or nil without one.
+func billingReference(id string, date *time.Time) []XMLBillingReference {
+	if id == "" {
+		return nil
+	}
+	ref := XMLBillingReference{InvoiceDocumentReference: XMLInvoiceDocumentReference{ID: id}}
+	if date != nil {
+		ref.InvoiceDocumentReference.IssueDate = date.Format("2006-01-02")
+	}
+	return []XMLBillingReference{ref}
+}
+
+// checkInvoiceTypeCode checks that a corrected invoice (384) refers to the
+// invoice it corrects.
+func checkInvoiceTypeCode(code, originalID string) error {
+	if code == "384" && originalID == "" {
+		return fmt.Errorf("corrected invoice (384): OriginalInvoiceID required")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+// withTestParties copies the parties and lines of the test invoice onto inv.
+func withTestParties(inv *ubl.Invoice) {
+	base := newTestInvoice()
+	inv.ID = base.ID
+	inv.SupplierName = base.SupplierName
+	inv.SupplierVat = base.SupplierVat
+	inv.SupplierPeppolID = base.SupplierPeppolID
+	inv.SupplierAddress = base.SupplierAddress
+	inv.CustomerName = base.CustomerName
+	inv.CustomerVat = base.CustomerVat
+	inv.CustomerPeppolID = base.CustomerPeppolID
+	inv.CustomerAddress = base.CustomerAddress
+	inv.Iban = base.Iban
+	inv.Lines = base.Lines
+}
+
+func TestPrepaymentInvoice(t *testing.T) {
+	inv := ubl.NewPrepaymentInvoice()
+	withTestParties(inv)
+	xmlBytes := compact(generateAndValidate(t, inv))
+	if !strings.Contains(xmlBytes, "<cbc:InvoiceTypeCode>386</cbc:InvoiceTypeCode>") {
+		t.Error("expected type code 386")
+	}
+	if strings.Contains(xmlBytes, "cac:BillingReference") {
+		t.Error("expected no BillingReference on a prepayment invoice")
+	}
+}
+
+func TestCorrectiveInvoice(t *testing.T) {
+	inv := ubl.NewCorrectiveInvoice("INV-12344", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	withTestParties(inv)
+	xmlBytes := compact(generateAndValidate(t, inv))
+	for _, want := range []string{
+		"<cbc:InvoiceTypeCode>384</cbc:InvoiceTypeCode>",
+		"</cac:OrderReference><cac:BillingReference><cac:InvoiceDocumentReference><cbc:ID>INV-12344</cbc:ID><cbc:IssueDate>2024-03-01</cbc:IssueDate></cac:InvoiceDocumentReference></cac:BillingReference>",
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	plain := newTestInvoice()
+	plain.InvoiceTypeCode = "384"
+	_, err := plain.Generate()
+	if err == nil || err.Error() != "corrected invoice (384): OriginalInvoiceID required" {
+		t.Errorf("expected a missing reference error, got %v", err)
+	}
+}
+
+func TestCreditNoteBillingReference(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cn := newTestCreditNote()
+	cn.OriginalInvoiceID = "INV-12345"
+	cn.OriginalInvoiceDate = &date
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "</cac:OrderReference><cac:BillingReference><cac:InvoiceDocumentReference><cbc:ID>INV-12345</cbc:ID><cbc:IssueDate>2024-03-01</cbc:IssueDate></cac:InvoiceDocumentReference></cac:BillingReference>"
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in output", want)
+	}
+	if len(cn.Warnings()) != 0 {
+		t.Errorf("expected no warnings, got %v", cn.Warnings())
+	}
+	v, err := validate.NewCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	err = v.ValidateBytes(cnBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cn = newTestCreditNote()
+	_, err = cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cn.Warnings()) != 1 || !strings.Contains(cn.Warnings()[0], "without OriginalInvoiceID") {
+		t.Errorf("expected a missing reference warning, got %v", cn.Warnings())
+	}
+
+	cn.RequireOriginalInvoice = true
+	_, err = cn.GenerateCreditNote()
+	if err == nil || err.Error() != "credit note: OriginalInvoiceID required" {
+		t.Errorf("expected a missing reference error, got %v", err)
+	}
+}
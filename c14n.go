@@ -0,0 +1,209 @@
+package ubl
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// GenerateCanonical generates the invoice like Generate in Exclusive XML
+// Canonicalization form (https://www.w3.org/TR/xml-exc-c14n/, without
+// comments), the input of an XML signature: UTF-8 without XML declaration,
+// sorted attributes and namespace declarations on the elements that use
+// them. The output is the same for the same invoice, see Now.
+func (inv *Invoice) GenerateCanonical() ([]byte, error) {
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		return nil, err
+	}
+	return canonicalDocument(doc)
+}
+
+// GenerateCreditNoteCanonical generates the credit note in Exclusive XML
+// Canonicalization form, like Invoice.GenerateCanonical.
+func (cn *CreditNote) GenerateCreditNoteCanonical() ([]byte, error) {
+	doc, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return nil, err
+	}
+	return canonicalDocument(doc)
+}
+
+func canonicalDocument(doc *Document) ([]byte, error) {
+	doc.XMLDeclaration = XMLDeclaration{Omit: true}
+	data, err := doc.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	err = canonicalize(&buf, bytes.NewReader(data), c14nOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("xml canonicalization failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// c14nOptions select the part of the document to canonicalize, as XML
+// signature references do.
+type c14nOptions struct {
+	apex    func(name xml.Name, attrs []xml.Attr) bool // Optional: only the first element it matches is written, with its content
+	exclude func(name xml.Name, attrs []xml.Attr) bool // Optional: the elements it matches are left out with their content, as the enveloped signature transform does
+}
+
+// canonicalize writes the UTF-8 document read from r to w in Exclusive XML
+// Canonicalization form. The prefixes are kept as written: a namespace is
+// declared on each element that uses its prefix, unless the nearest output
+// ancestor already declared it the same. The options get the element names
+// with their namespace and the attributes as written.
+func canonicalize(w io.Writer, r io.Reader, opts c14nOptions) error {
+	type scope struct {
+		declared map[string]string // prefix to namespace, as in the input
+		rendered map[string]string // prefix to namespace, as in the output
+	}
+	// the xml prefix is never declared
+	xmlNamespace := "http://www.w3.org/XML/1998/namespace"
+	stack := []scope{{
+		declared: map[string]string{"": "", "xml": xmlNamespace},
+		rendered: map[string]string{"": "", "xml": xmlNamespace},
+	}}
+	unrendered := stack[0].rendered
+
+	// the depth of the apex once found, and of the excluded element being
+	// skipped
+	apex, excluded := 0, 0
+	if opts.apex == nil {
+		apex = 1
+	}
+
+	bw := bufio.NewWriter(w)
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			current := scope{declared: parent.declared, rendered: parent.rendered}
+			var attrs []xml.Attr
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					current.declared = withPrefix(current.declared, "", attr.Value)
+				case attr.Name.Space == "xmlns":
+					current.declared = withPrefix(current.declared, attr.Name.Local, attr.Value)
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+			name := xml.Name{Space: current.declared[t.Name.Space], Local: t.Name.Local}
+			switch {
+			case excluded > 0:
+			case opts.exclude != nil && opts.exclude(name, attrs):
+				excluded = len(stack)
+			case apex == 0 && opts.apex(name, attrs):
+				apex = len(stack)
+				// the apex has no output ancestor
+				current.rendered = unrendered
+			}
+			if excluded > 0 || apex == 0 {
+				stack = append(stack, current)
+				continue
+			}
+
+			// the prefixes the element and its attributes visibly use
+			used := []string{t.Name.Space}
+			for _, attr := range attrs {
+				if attr.Name.Space != "" && !slices.Contains(used, attr.Name.Space) {
+					used = append(used, attr.Name.Space)
+				}
+			}
+			slices.Sort(used)
+			bw.WriteString("<" + rawName(t.Name))
+			for _, prefix := range used {
+				namespace, ok := current.declared[prefix]
+				if !ok {
+					return fmt.Errorf("prefix %q of %s: not declared", prefix, t.Name.Local)
+				}
+				if rendered, ok := current.rendered[prefix]; ok && rendered == namespace {
+					continue
+				}
+				current.rendered = withPrefix(current.rendered, prefix, namespace)
+				if prefix == "" {
+					bw.WriteString(` xmlns="` + c14nAttributeEscaper.Replace(namespace) + `"`)
+				} else {
+					bw.WriteString(` xmlns:` + prefix + `="` + c14nAttributeEscaper.Replace(namespace) + `"`)
+				}
+			}
+
+			// unqualified attributes first, then by namespace and local name
+			slices.SortFunc(attrs, func(a, b xml.Attr) int {
+				aSpace, bSpace := "", ""
+				if a.Name.Space != "" {
+					aSpace = current.declared[a.Name.Space]
+				}
+				if b.Name.Space != "" {
+					bSpace = current.declared[b.Name.Space]
+				}
+				return cmp.Or(strings.Compare(aSpace, bSpace), strings.Compare(a.Name.Local, b.Name.Local))
+			})
+			for _, attr := range attrs {
+				bw.WriteString(" " + rawName(attr.Name) + `="` + c14nAttributeEscaper.Replace(attr.Value) + `"`)
+			}
+			bw.WriteString(">")
+			stack = append(stack, current)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			switch {
+			case excluded > 0:
+				if excluded == len(stack) {
+					excluded = 0
+				}
+			case apex > 0:
+				bw.WriteString("</" + rawName(t.Name) + ">")
+				if apex == len(stack) && opts.apex != nil {
+					return bw.Flush()
+				}
+			}
+		case xml.CharData:
+			// text outside the root element isn't part of the document
+			if len(stack) > 1 && excluded == 0 && apex > 0 {
+				c14nTextEscaper.WriteString(bw, string(t))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// withPrefix returns a copy of the namespace map with prefix set, so the
+// map of the parent element stays the same.
+func withPrefix(namespaces map[string]string, prefix, namespace string) map[string]string {
+	namespaces = maps.Clone(namespaces)
+	namespaces[prefix] = namespace
+	return namespaces
+}
+
+// rawName returns the name with its prefix as read by RawToken.
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// c14nTextEscaper and c14nAttributeEscaper escape as canonical XML requires.
+var (
+	c14nTextEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	c14nAttributeEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
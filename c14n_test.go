@@ -0,0 +1,64 @@
+package ubl_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/verscheures/ubl"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGenerateCanonical(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Now = func() time.Time { return time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC) }
+	inv.CustomerName = `Smith & "Sons" <Ltd>`
+	inv.XMLDeclaration = ubl.XMLDeclaration{Encoding: "ISO-8859-1"}
+	if err := inv.AddAttachmentFromBytes([]byte("%PDF-1.4"), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := inv.GenerateCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const golden = "testdata/canonical_invoice.xml"
+	if *update {
+		if err := os.WriteFile(golden, canonical, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(canonical, want) {
+		t.Errorf("expected the canonical form of %s, run go test -update after checking the difference", golden)
+	}
+
+	again, err := inv.GenerateCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, canonical) {
+		t.Error("expected the same bytes on every run")
+	}
+	if !utf8.Valid(canonical) || !bytes.HasPrefix(canonical, []byte(`<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2">`)) {
+		t.Errorf("expected UTF-8 without declaration, got %.80q", canonical)
+	}
+
+	cn := newTestCreditNote()
+	cn.Namespaces = ubl.XMLNamespaces{Document: "cn"}
+	cnCanonical, err := cn.GenerateCreditNoteCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(cnCanonical), `<cn:CreditNote xmlns:cn="urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2">`+"\n  "+`<cbc:CustomizationID xmlns:cbc=`) {
+		t.Errorf("expected each namespace declared where it's used, got %.200q", cnCanonical)
+	}
+}
@@ -0,0 +1,186 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// checkCalculation checks the totals against the EN16931 calculation rules
+// the generator is responsible for, given the line amounts they were
+// computed from. The totals are rounded step by step, so a violation means a
+// bug or a pathological float input; the receiver would reject the document
+// anyway, so it's better to fail naming the rule.
+func (t Totals) checkCalculation(lineAmounts []float64, decimals int) error {
+	var errs []error
+	check := func(rule string, expected, actual float64, format string, args ...any) {
+		if roundTo(expected, decimals) == roundTo(actual, decimals) {
+			return
+		}
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{rule}, args...)...))
+	}
+
+	var lineSum float64
+	for _, amount := range lineAmounts {
+		lineSum += amount
+	}
+	check("BR-CO-10", lineSum, t.LineExtension,
+		"sum of %d lines %.*f is not the line extension amount %.*f", len(lineAmounts), decimals, lineSum, decimals, t.LineExtension)
+	check("BR-CO-13", t.LineExtension-t.AllowanceTotal+t.ChargeTotal, t.TaxExclusive,
+		"lines %.*f - allowances %.*f + charges %.*f is not the tax exclusive amount %.*f",
+		decimals, t.LineExtension, decimals, t.AllowanceTotal, decimals, t.ChargeTotal, decimals, t.TaxExclusive)
+
+	var taxSum float64
+	for _, row := range t.Breakdown {
+		taxSum += row.TaxAmount
+	}
+	check("BR-CO-14", taxSum, t.Tax,
+		"sum of the VAT breakdown %.*f is not the VAT total %.*f", decimals, taxSum, decimals, t.Tax)
+	check("BR-CO-15", t.TaxExclusive+t.Tax, t.TaxInclusive,
+		"tax exclusive amount %.*f + VAT %.*f is not the tax inclusive amount %.*f",
+		decimals, t.TaxExclusive, decimals, t.Tax, decimals, t.TaxInclusive)
+
+	// BR-CO-17 allows one minor unit of difference, the extra millionth
+	// absorbs the float noise
+	unit := math.Pow10(-decimals)
+	for _, row := range t.Breakdown {
+		expected := row.TaxableAmount * row.Percent / 100
+		if math.Abs(expected-row.TaxAmount) > unit+unit/1e6 {
+			errs = append(errs, fmt.Errorf("BR-CO-17: category %s at %v%%: %.*f of %.*f is not the VAT amount %.*f",
+				row.CategoryID, row.Percent, decimals, expected, decimals, row.TaxableAmount, decimals, row.TaxAmount))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ArithmeticFinding describes one EN16931 calculation rule (BR-CO-10 to
+// BR-CO-17) that does not hold for the amounts of a document.
+type ArithmeticFinding struct {
+	Rule     string  // e.g. "BR-CO-13"
+	Expected float64 // value recomputed from the other amounts
+	Actual   float64 // value found in the document
+	Message  string
+}
+
+// Delta is the difference between the amount in the document and the
+// recomputed amount.
+func (f ArithmeticFinding) Delta() float64 {
+	// 4 decimals covers the minor units of every currency
+	return roundTo(f.Actual-f.Expected, 4)
+}
+
+func (f ArithmeticFinding) String() string {
+	return f.Message
+}
+
+// DocumentAmounts are the amounts of an invoice or credit note that the
+// calculation rules relate, as they are written in the document. The
+// optional totals are nil when the document doesn't have them.
+type DocumentAmounts struct {
+	Currency       string
+	Lines          []float64 // BT-131 of every line
+	Allowances     []float64 // BT-92 of every document level allowance
+	Charges        []float64 // BT-99 of every document level charge
+	Subtotals      []TaxSubtotalAmounts
+	Tax            float64 // BT-110, the VAT total with the breakdown
+	LineExtension  float64
+	AllowanceTotal *float64
+	ChargeTotal    *float64
+	TaxExclusive   float64
+	TaxInclusive   float64
+	Prepaid        *float64
+	Rounding       *float64
+	Payable        float64
+}
+
+// TaxSubtotalAmounts are the amounts of a VAT breakdown entry. Percent is
+// nil for a category without rate, e.g. O.
+type TaxSubtotalAmounts struct {
+	CategoryID string
+	Percent    *float64
+	Taxable    float64
+	Tax        float64
+}
+
+// CheckAmounts recomputes the EN16931 calculation rules for the amounts of a
+// document and reports every rule that is off, with the exact delta. It
+// computes in minor units of the currency like Generate, so the documents it
+// writes have no findings. BR-CO-17 allows one minor unit of difference.
+func CheckAmounts(a DocumentAmounts) []ArithmeticFinding {
+	decimals := minorUnits(a.Currency)
+	m := func(amount float64) money { return toMoney(amount, decimals) }
+	opt := func(amount *float64) money {
+		if amount == nil {
+			return 0
+		}
+		return m(*amount)
+	}
+	f := func(amount money) string { return formatAmount(amount.float(decimals), decimals) }
+
+	var findings []ArithmeticFinding
+	check := func(rule string, expected, actual money, format string, args ...any) {
+		delta := actual - expected
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta == 0 || rule == "BR-CO-17" && delta == 1 {
+			return
+		}
+		findings = append(findings, ArithmeticFinding{
+			Rule:     rule,
+			Expected: expected.float(decimals),
+			Actual:   actual.float(decimals),
+			Message:  fmt.Sprintf("%s off by %s: ", rule, f(delta)) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	var lineSum money
+	for _, line := range a.Lines {
+		lineSum += m(line)
+	}
+	check("BR-CO-10", lineSum, m(a.LineExtension),
+		"sum of %d lines %s ≠ lineExtension %s", len(a.Lines), f(lineSum), f(m(a.LineExtension)))
+
+	var allowances, charges money
+	for _, amount := range a.Allowances {
+		allowances += m(amount)
+	}
+	for _, amount := range a.Charges {
+		charges += m(amount)
+	}
+	if a.AllowanceTotal != nil || allowances != 0 {
+		check("BR-CO-11", allowances, opt(a.AllowanceTotal),
+			"sum of allowances %s ≠ allowanceTotal %s", f(allowances), f(opt(a.AllowanceTotal)))
+	}
+	if a.ChargeTotal != nil || charges != 0 {
+		check("BR-CO-12", charges, opt(a.ChargeTotal),
+			"sum of charges %s ≠ chargeTotal %s", f(charges), f(opt(a.ChargeTotal)))
+	}
+
+	check("BR-CO-13", m(a.LineExtension)-opt(a.AllowanceTotal)+opt(a.ChargeTotal), m(a.TaxExclusive),
+		"lines %s − allowances %s + charges %s ≠ taxExclusive %s",
+		f(m(a.LineExtension)), f(opt(a.AllowanceTotal)), f(opt(a.ChargeTotal)), f(m(a.TaxExclusive)))
+
+	if len(a.Subtotals) > 0 {
+		var subtotalSum money
+		for _, st := range a.Subtotals {
+			subtotalSum += m(st.Tax)
+			if st.Percent == nil {
+				continue
+			}
+			check("BR-CO-17", m(st.Taxable).percent(*st.Percent), m(st.Tax),
+				"category %s taxable %s × %v%% ≠ tax %s", st.CategoryID, f(m(st.Taxable)), *st.Percent, f(m(st.Tax)))
+		}
+		check("BR-CO-14", subtotalSum, m(a.Tax),
+			"sum of %d subtotals %s ≠ taxAmount %s", len(a.Subtotals), f(subtotalSum), f(m(a.Tax)))
+	}
+
+	check("BR-CO-15", m(a.TaxExclusive)+m(a.Tax), m(a.TaxInclusive),
+		"taxExclusive %s + tax %s ≠ taxInclusive %s", f(m(a.TaxExclusive)), f(m(a.Tax)), f(m(a.TaxInclusive)))
+
+	check("BR-CO-16", m(a.TaxInclusive)-opt(a.Prepaid)+opt(a.Rounding), m(a.Payable),
+		"taxInclusive %s − prepaid %s + rounding %s ≠ payable %s",
+		f(m(a.TaxInclusive)), f(opt(a.Prepaid)), f(opt(a.Rounding)), f(m(a.Payable)))
+
+	return findings
+}
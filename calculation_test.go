@@ -0,0 +1,75 @@
+package ubl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckCalculation(t *testing.T) {
+	totals := Totals{
+		LineExtension: 100,
+		TaxExclusive:  100,
+		Tax:           21,
+		TaxInclusive:  121,
+		Breakdown:     []TaxBreakdown{{CategoryID: "S", Percent: 21, TaxableAmount: 100, TaxAmount: 21}},
+	}
+	if err := totals.checkCalculation([]float64{60, 40}, 2); err != nil {
+		t.Errorf("expected consistent totals, got %v", err)
+	}
+	totals.Breakdown[0].TaxAmount = 21.01
+	if err := totals.checkCalculation([]float64{60, 40}, 2); err == nil || !strings.HasPrefix(err.Error(), "BR-CO-14: ") {
+		t.Errorf("expected only BR-CO-14 within the BR-CO-17 tolerance, got %v", err)
+	}
+
+	totals = Totals{
+		LineExtension: 100.01,
+		TaxExclusive:  100,
+		Tax:           21.03,
+		TaxInclusive:  121,
+		Breakdown:     []TaxBreakdown{{CategoryID: "S", Percent: 21, TaxableAmount: 100, TaxAmount: 21.03}},
+	}
+	want := `BR-CO-10: sum of 2 lines 100.00 is not the line extension amount 100.01
+BR-CO-13: lines 100.01 - allowances 0.00 + charges 0.00 is not the tax exclusive amount 100.00
+BR-CO-15: tax exclusive amount 100.00 + VAT 21.03 is not the tax inclusive amount 121.00
+BR-CO-17: category S at 21%: 21.00 of 100.00 is not the VAT amount 21.03`
+	if err := totals.checkCalculation([]float64{60, 40}, 2); err == nil || err.Error() != want {
+		t.Errorf("expected the violated rules, got %v", err)
+	}
+}
+
+func TestCheckAmounts(t *testing.T) {
+	rate := 6.0
+	a := DocumentAmounts{
+		Currency:      "EUR",
+		Lines:         []float64{4.75},
+		Subtotals:     []TaxSubtotalAmounts{{CategoryID: "S", Percent: &rate, Taxable: 4.75, Tax: 0.29}},
+		Tax:           0.29,
+		LineExtension: 4.75,
+		TaxExclusive:  4.75,
+		TaxInclusive:  5.04,
+		Payable:       5.04,
+	}
+	if findings := CheckAmounts(a); len(findings) != 0 {
+		t.Errorf("expected no findings for 0.285 rounded half up, got %v", findings)
+	}
+
+	// BR-CO-17 allows a minor unit of difference, the other rules none
+	a.Subtotals[0].Tax, a.Tax, a.TaxInclusive, a.Payable = 0.28, 0.28, 5.03, 5.03
+	if findings := CheckAmounts(a); len(findings) != 0 {
+		t.Errorf("expected no findings for a minor unit off, got %v", findings)
+	}
+	a.Subtotals[0].Tax = 0.27
+	findings := CheckAmounts(a)
+	want := []string{
+		"BR-CO-17 off by 0.02: category S taxable 4.75 × 6% ≠ tax 0.27",
+		"BR-CO-14 off by 0.01: sum of 1 subtotals 0.27 ≠ taxAmount 0.28",
+	}
+	if len(findings) != len(want) || findings[0].Message != want[0] || findings[1].Message != want[1] {
+		t.Errorf("expected %q, got %v", want, findings)
+	}
+
+	a = DocumentAmounts{Currency: "JPY", Lines: []float64{1000}, LineExtension: 1000, TaxExclusive: 1001, TaxInclusive: 1001, Payable: 1001}
+	if findings := CheckAmounts(a); len(findings) != 1 || findings[0].Message != "BR-CO-13 off by 1: lines 1000 − allowances 0 + charges 0 ≠ taxExclusive 1001" {
+		t.Errorf("expected a BR-CO-13 finding in yen, got %v", findings)
+	}
+}
@@ -0,0 +1,243 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// requiredFields are the fields Generate can't do without, shared by
+// invoices and credit notes.
+type requiredFields struct {
+	ID                 string
+	Currency           string
+	TaxSchemeID        string
+	SupplierName       string
+	SupplierVat        string
+	SupplierPeppolID   string
+	SupplierScheme     string
+	SupplierValue      string
+	SupplierAddress    Address
+	CustomerName       string
+	CustomerVat        string
+	CustomerPeppolID   string
+	CustomerScheme     string
+	CustomerValue      string
+	CustomerAddress    Address
+	DeliveryAddress    *Address
+	DeliveryDate       *time.Time
+	PeriodStart        *time.Time
+	PeriodEnd          *time.Time
+	Lines              []InvoiceLine
+	AllowanceCharges   []AllowanceCharge
+	Attachments        []Attachment
+	MaxAttachmentBytes int
+	Extensions         []Extension
+}
+
+// Check reports all missing and malformed fields of the invoice at once, as
+// an errors.Join of one error per field. Generate runs it first.
+func (inv *Invoice) Check() error {
+	return requiredFields{
+		ID:                 inv.ID,
+		Currency:           inv.Currency,
+		TaxSchemeID:        inv.TaxSchemeID,
+		SupplierName:       inv.SupplierName,
+		SupplierVat:        inv.SupplierVat,
+		SupplierPeppolID:   inv.SupplierPeppolID,
+		SupplierScheme:     inv.SupplierEndpointScheme,
+		SupplierValue:      inv.SupplierEndpointValue,
+		SupplierAddress:    inv.SupplierAddress,
+		CustomerName:       inv.CustomerName,
+		CustomerVat:        inv.CustomerVat,
+		CustomerPeppolID:   inv.CustomerPeppolID,
+		CustomerScheme:     inv.CustomerEndpointScheme,
+		CustomerValue:      inv.CustomerEndpointValue,
+		CustomerAddress:    inv.CustomerAddress,
+		DeliveryAddress:    inv.DeliveryAddress,
+		DeliveryDate:       inv.ActualDeliveryDate,
+		PeriodStart:        inv.InvoicePeriodStart,
+		PeriodEnd:          inv.InvoicePeriodEnd,
+		Lines:              inv.Lines,
+		AllowanceCharges:   inv.AllowanceCharges,
+		Attachments:        inv.Attachments,
+		MaxAttachmentBytes: inv.MaxAttachmentBytes,
+		Extensions:         inv.Extensions,
+	}.check()
+}
+
+// Check reports all missing and malformed fields of the credit note at once,
+// like Invoice.Check. GenerateCreditNote runs it first.
+func (cn *CreditNote) Check() error {
+	return requiredFields{
+		ID:                 cn.ID,
+		Currency:           cn.Currency,
+		TaxSchemeID:        cn.TaxSchemeID,
+		SupplierName:       cn.SupplierName,
+		SupplierVat:        cn.SupplierVat,
+		SupplierPeppolID:   cn.SupplierPeppolID,
+		SupplierScheme:     cn.SupplierEndpointScheme,
+		SupplierValue:      cn.SupplierEndpointValue,
+		SupplierAddress:    cn.SupplierAddress,
+		CustomerName:       cn.CustomerName,
+		CustomerVat:        cn.CustomerVat,
+		CustomerPeppolID:   cn.CustomerPeppolID,
+		CustomerScheme:     cn.CustomerEndpointScheme,
+		CustomerValue:      cn.CustomerEndpointValue,
+		CustomerAddress:    cn.CustomerAddress,
+		DeliveryAddress:    cn.DeliveryAddress,
+		DeliveryDate:       cn.ActualDeliveryDate,
+		PeriodStart:        cn.InvoicePeriodStart,
+		PeriodEnd:          cn.InvoicePeriodEnd,
+		Lines:              cn.Lines,
+		AllowanceCharges:   cn.AllowanceCharges,
+		Attachments:        cn.Attachments,
+		MaxAttachmentBytes: cn.MaxAttachmentBytes,
+		Extensions:         cn.Extensions,
+	}.check()
+}
+
+func (f requiredFields) check() error {
+	var errs []error
+	required := func(name, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s: required", name))
+		}
+	}
+
+	required("ID", f.ID)
+	if f.Currency != "" && !isUpperAlpha(f.Currency, 3) {
+		errs = append(errs, fmt.Errorf("Currency %q: not an ISO 4217 code", f.Currency))
+	}
+	if strings.ContainsFunc(f.TaxSchemeID, unicode.IsSpace) {
+		errs = append(errs, fmt.Errorf("TaxSchemeID %q: white space in the tax scheme, leave it empty for VAT", f.TaxSchemeID))
+	}
+	for _, party := range []struct {
+		prefix   string
+		name     string
+		peppolID string
+		scheme   string
+		value    string
+		address  Address
+	}{
+		{"Supplier", f.SupplierName, f.SupplierPeppolID, f.SupplierScheme, f.SupplierValue, f.SupplierAddress},
+		{"Customer", f.CustomerName, f.CustomerPeppolID, f.CustomerScheme, f.CustomerValue, f.CustomerAddress},
+	} {
+		required(party.prefix+"Name", party.name)
+		_, err := endpointID(party.prefix+"PeppolID", party.peppolID, party.scheme, party.value)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		required(party.prefix+"Address.CountryCode", party.address.CountryCode)
+		if party.address.CountryCode != "" && !isUpperAlpha(party.address.CountryCode, 2) {
+			errs = append(errs, fmt.Errorf("%sAddress.CountryCode %q: not an ISO 3166-1 alpha-2 code", party.prefix, party.address.CountryCode))
+		}
+	}
+
+	if field := categoryUse("AE", f.Lines, f.AllowanceCharges); field != "" && f.CustomerVat == "" {
+		errs = append(errs, fmt.Errorf("CustomerVat: required for reverse charge, %s has tax category AE (BR-AE-02)", field))
+	}
+	if field := categoryUse("G", f.Lines, f.AllowanceCharges); field != "" && f.SupplierVat == "" {
+		errs = append(errs, fmt.Errorf("SupplierVat: required for export, %s has tax category G (BR-G-02)", field))
+	}
+	if field := categoryUse("K", f.Lines, f.AllowanceCharges); field != "" {
+		errs = append(errs, f.checkIntraCommunity(field)...)
+	}
+	errs = append(errs, checkOutsideScope(f.Lines, f.AllowanceCharges)...)
+
+	for i, a := range f.Attachments {
+		err := checkAttachmentSize(a.size(), f.MaxAttachmentBytes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Attachments[%d] %q: %w", i, a.ID, err))
+		}
+		if a.Filename == "" || a.size() == 0 {
+			continue
+		}
+		filename, err := cleanFilename(a.Filename)
+		if err == nil {
+			_, err = attachmentMimeCode(filename, a.MimeCode)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Attachments[%d]: %w", i, err))
+		}
+	}
+
+	for i, e := range f.Extensions {
+		if err := e.check(); err != nil {
+			errs = append(errs, fmt.Errorf("Extensions[%d]: %w", i, err))
+		}
+	}
+
+	if len(f.Lines) == 0 {
+		errs = append(errs, errors.New("Lines: at least one line required"))
+	}
+	lineIDs := map[string]int{}
+	for i, line := range f.Lines {
+		id := line.id(i)
+		if first, ok := lineIDs[id]; ok {
+			errs = append(errs, fmt.Errorf("Lines[%d]: line ID %q already used by Lines[%d]", i, id, first))
+		} else {
+			lineIDs[id] = i
+		}
+		errs = append(errs, line.check(i, f.Currency)...)
+	}
+	return errors.Join(errs...)
+}
+
+// check reports the problems of line i on its own.
+func (line InvoiceLine) check(i int, currency string) []error {
+	var errs []error
+	if line.Name == "" {
+		errs = append(errs, fmt.Errorf("Lines[%d].Name: required", i))
+	}
+	err := line.checkTaxExemption()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Lines[%d]: %w", i, err))
+	}
+	if line.Quantity < 0 && line.Price < 0 {
+		errs = append(errs, fmt.Errorf("Lines[%d]: negative quantity and negative price, negate only the quantity", i))
+	}
+	_, err = unitCode(line.UnitCode)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Lines[%d].UnitCode: %w", i, err))
+	}
+	_, err = commodityClassifications(line.Classifications)
+	for _, err := range unwrapJoined(err) {
+		errs = append(errs, fmt.Errorf("Lines[%d].%w", i, err))
+	}
+	_, err = additionalItemProperties(line.Attributes)
+	for _, err := range unwrapJoined(err) {
+		errs = append(errs, fmt.Errorf("Lines[%d].%w", i, err))
+	}
+	_, err = linePrice(line, currency)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Lines[%d].GrossPrice: %w", i, err))
+	}
+	return errs
+}
+
+// isUpperAlpha reports whether s consists of n letters A-Z.
+func isUpperAlpha(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// unwrapJoined returns the errors joined in err, or err itself.
+func unwrapJoined(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,57 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestCheck(t *testing.T) {
+	inv := newTestInvoice()
+	err := inv.Check()
+	if err != nil {
+		t.Fatalf("expected a complete invoice, got %v", err)
+	}
+
+	inv = ubl.Invoice{
+		SupplierPeppolID: "BE0123456789",
+		SupplierAddress:  ubl.Address{CountryCode: "be"},
+		Currency:         "euro",
+	}
+	err = inv.Check()
+	if err == nil {
+		t.Fatal("expected an error for an empty invoice")
+	}
+	for _, want := range []string{
+		"ID: required",
+		`Currency "euro": not an ISO 4217 code`,
+		"SupplierName: required",
+		`SupplierPeppolID "BE0123456789": expected scheme:identifier, e.g. 0208:0123456789`,
+		`SupplierAddress.CountryCode "be": not an ISO 3166-1 alpha-2 code`,
+		"CustomerName: required",
+		"CustomerPeppolID: required",
+		"CustomerAddress.CountryCode: required",
+		"Lines: at least one line required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 9 {
+		t.Errorf("expected 9 joined errors, got %v", err)
+	}
+
+	_, genErr := inv.Generate()
+	if genErr == nil || genErr.Error() != err.Error() {
+		t.Errorf("expected Generate to fail with the Check errors, got %v", genErr)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines = append(cn.Lines, ubl.InvoiceLine{Quantity: 1, Price: 10, TaxPercentage: 21})
+	_, err = cn.GenerateCreditNote()
+	if err == nil || !strings.Contains(err.Error(), "Lines[1].Name: required") {
+		t.Errorf("expected a missing line name, got %v", err)
+	}
+}
@@ -0,0 +1,676 @@
+package ubl
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// The namespaces of the UN/CEFACT Cross Industry Invoice D16B.
+const (
+	ciiNamespace = "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"
+	ramNamespace = "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100"
+	udtNamespace = "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100"
+	qdtNamespace = "urn:un:unece:uncefact:data:standard:QualifiedDataType:100"
+)
+
+// ciiEN16931CustomizationID is the specification identifier (BT-24) of
+// EN16931 itself, used in CII for the Peppol BIS default that only applies
+// to UBL.
+const ciiEN16931CustomizationID = "urn:cen.eu:en16931:2017"
+
+// GenerateCII generates the invoice in the UN/CEFACT Cross Industry Invoice
+// syntax (CII D16B) with the EN16931 syntax binding, as used by Factur-X,
+// ZUGFeRD and XRechnung. It maps the document Generate writes, so the
+// parties, VAT breakdown, lines and totals are the same as in the UBL.
+// Extensions have no place in CII and are left out. The Peppol BIS
+// CustomizationID becomes the plain EN16931 one, urn:cen.eu:en16931:2017.
+func (inv *Invoice) GenerateCII() ([]byte, error) {
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		return nil, err
+	}
+	return marshalCII(doc)
+}
+
+// GenerateCreditNoteCII generates the credit note in CII, like
+// Invoice.GenerateCII, with document type code 381.
+func (cn *CreditNote) GenerateCreditNoteCII() ([]byte, error) {
+	doc, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return nil, err
+	}
+	return marshalCII(doc)
+}
+
+func marshalCII(doc *Document) ([]byte, error) {
+	x, refs := ciiDocument(doc)
+	return marshalDocument(x, refs, xmlOutput{declaration: doc.XMLDeclaration})
+}
+
+// ciiInvoice is rsm:CrossIndustryInvoice.
+type ciiInvoice struct {
+	XMLName     xml.Name             `xml:"rsm:CrossIndustryInvoice"`
+	Rsm         string               `xml:"xmlns:rsm,attr"`
+	Ram         string               `xml:"xmlns:ram,attr"`
+	Udt         string               `xml:"xmlns:udt,attr"`
+	Qdt         string               `xml:"xmlns:qdt,attr"`
+	Context     ciiDocumentContext   `xml:"rsm:ExchangedDocumentContext"`
+	Document    ciiExchangedDocument `xml:"rsm:ExchangedDocument"`
+	Transaction ciiTradeTransaction  `xml:"rsm:SupplyChainTradeTransaction"`
+}
+
+type ciiDocumentContext struct {
+	BusinessProcess *ciiID `xml:"ram:BusinessProcessSpecifiedDocumentContextParameter,omitempty"`
+	Guideline       ciiID  `xml:"ram:GuidelineSpecifiedDocumentContextParameter"`
+}
+
+type ciiID struct {
+	ID string `xml:"ram:ID"`
+}
+
+type ciiExchangedDocument struct {
+	ID            string      `xml:"ram:ID"`
+	TypeCode      string      `xml:"ram:TypeCode"`
+	IssueDateTime ciiDateTime `xml:"ram:IssueDateTime"`
+	IncludedNote  []ciiNote   `xml:"ram:IncludedNote"`
+}
+
+type ciiNote struct {
+	Content string `xml:"ram:Content"`
+}
+
+// ciiDateTime is a date in format 102, YYYYMMDD.
+type ciiDateTime struct {
+	DateTimeString ciiDateString `xml:"udt:DateTimeString"`
+}
+
+// ciiFormattedDateTime is a date in format 102 of the qualified data types.
+type ciiFormattedDateTime struct {
+	DateTimeString ciiDateString `xml:"qdt:DateTimeString"`
+}
+
+type ciiDateString struct {
+	Value  string `xml:",chardata"`
+	Format string `xml:"format,attr"`
+}
+
+type ciiTradeTransaction struct {
+	Lines      []ciiLineItem       `xml:"ram:IncludedSupplyChainTradeLineItem"`
+	Agreement  ciiHeaderAgreement  `xml:"ram:ApplicableHeaderTradeAgreement"`
+	Delivery   ciiHeaderDelivery   `xml:"ram:ApplicableHeaderTradeDelivery"`
+	Settlement ciiHeaderSettlement `xml:"ram:ApplicableHeaderTradeSettlement"`
+}
+
+type ciiHeaderAgreement struct {
+	BuyerReference       string                  `xml:"ram:BuyerReference,omitempty"`
+	Seller               ciiTradeParty           `xml:"ram:SellerTradeParty"`
+	Buyer                ciiTradeParty           `xml:"ram:BuyerTradeParty"`
+	SellerOrder          *ciiReferencedDocument  `xml:"ram:SellerOrderReferencedDocument,omitempty"`
+	BuyerOrder           *ciiReferencedDocument  `xml:"ram:BuyerOrderReferencedDocument,omitempty"`
+	AdditionalReferenced []ciiReferencedDocument `xml:"ram:AdditionalReferencedDocument"`
+}
+
+type ciiTradeParty struct {
+	ID                []string              `xml:"ram:ID"`
+	GlobalID          []ciiIdentifier       `xml:"ram:GlobalID"`
+	Name              string                `xml:"ram:Name,omitempty"`
+	Description       string                `xml:"ram:Description,omitempty"`
+	LegalOrganization *ciiLegalOrganization `xml:"ram:SpecifiedLegalOrganization,omitempty"`
+	Contact           *ciiContact           `xml:"ram:DefinedTradeContact,omitempty"`
+	PostalAddress     *ciiAddress           `xml:"ram:PostalTradeAddress,omitempty"`
+	URI               *ciiURI               `xml:"ram:URIUniversalCommunication,omitempty"`
+	TaxRegistration   []ciiTaxRegistration  `xml:"ram:SpecifiedTaxRegistration"`
+}
+
+type ciiIdentifier struct {
+	Value    string `xml:",chardata"`
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+}
+
+type ciiLegalOrganization struct {
+	ID                  *ciiIdentifier `xml:"ram:ID,omitempty"`
+	TradingBusinessName string         `xml:"ram:TradingBusinessName,omitempty"`
+}
+
+type ciiContact struct {
+	PersonName string     `xml:"ram:PersonName,omitempty"`
+	Telephone  *ciiNumber `xml:"ram:TelephoneUniversalCommunication,omitempty"`
+	Email      *ciiURIID  `xml:"ram:EmailURIUniversalCommunication,omitempty"`
+}
+
+type ciiNumber struct {
+	CompleteNumber string `xml:"ram:CompleteNumber"`
+}
+
+type ciiURIID struct {
+	URIID string `xml:"ram:URIID"`
+}
+
+type ciiURI struct {
+	URIID ciiIdentifier `xml:"ram:URIID"`
+}
+
+type ciiAddress struct {
+	PostcodeCode           string `xml:"ram:PostcodeCode,omitempty"`
+	LineOne                string `xml:"ram:LineOne,omitempty"`
+	LineTwo                string `xml:"ram:LineTwo,omitempty"`
+	LineThree              string `xml:"ram:LineThree,omitempty"`
+	CityName               string `xml:"ram:CityName,omitempty"`
+	CountryID              string `xml:"ram:CountryID"`
+	CountrySubDivisionName string `xml:"ram:CountrySubDivisionName,omitempty"`
+}
+
+type ciiTaxRegistration struct {
+	ID ciiIdentifier `xml:"ram:ID"`
+}
+
+type ciiReferencedDocument struct {
+	IssuerAssignedID       string                           `xml:"ram:IssuerAssignedID,omitempty"`
+	URIID                  string                           `xml:"ram:URIID,omitempty"`
+	LineID                 string                           `xml:"ram:LineID,omitempty"`
+	TypeCode               string                           `xml:"ram:TypeCode,omitempty"`
+	Name                   string                           `xml:"ram:Name,omitempty"`
+	AttachmentBinary       *XMLEmbeddedDocumentBinaryObject `xml:"ram:AttachmentBinaryObject,omitempty"`
+	ReferenceTypeCode      string                           `xml:"ram:ReferenceTypeCode,omitempty"`
+	FormattedIssueDateTime *ciiFormattedDateTime            `xml:"ram:FormattedIssueDateTime,omitempty"`
+}
+
+type ciiHeaderDelivery struct {
+	ShipTo      *ciiTradeParty `xml:"ram:ShipToTradeParty,omitempty"`
+	ActualEvent *ciiEvent      `xml:"ram:ActualDeliverySupplyChainEvent,omitempty"`
+}
+
+type ciiEvent struct {
+	OccurrenceDateTime ciiDateTime `xml:"ram:OccurrenceDateTime"`
+}
+
+type ciiHeaderSettlement struct {
+	CreditorReferenceID string                  `xml:"ram:CreditorReferenceID,omitempty"`
+	InvoiceCurrencyCode string                  `xml:"ram:InvoiceCurrencyCode"`
+	PaymentMeans        []ciiPaymentMeans       `xml:"ram:SpecifiedTradeSettlementPaymentMeans"`
+	Tax                 []ciiTradeTax           `xml:"ram:ApplicableTradeTax"`
+	BillingPeriod       *ciiPeriod              `xml:"ram:BillingSpecifiedPeriod,omitempty"`
+	AllowanceCharge     []ciiAllowanceCharge    `xml:"ram:SpecifiedTradeAllowanceCharge"`
+	PaymentTerms        *ciiPaymentTerms        `xml:"ram:SpecifiedTradePaymentTerms,omitempty"`
+	Summation           ciiHeaderSummation      `xml:"ram:SpecifiedTradeSettlementHeaderMonetarySummation"`
+	InvoiceReferenced   []ciiReferencedDocument `xml:"ram:InvoiceReferencedDocument"`
+}
+
+type ciiPaymentMeans struct {
+	TypeCode                 string               `xml:"ram:TypeCode"`
+	Information              string               `xml:"ram:Information,omitempty"`
+	PayerDebtorAccount       *ciiFinancialAccount `xml:"ram:PayerPartyDebtorFinancialAccount,omitempty"`
+	PayeeCreditorAccount     *ciiFinancialAccount `xml:"ram:PayeePartyCreditorFinancialAccount,omitempty"`
+	PayeeCreditorInstitution *ciiInstitution      `xml:"ram:PayeeSpecifiedCreditorFinancialInstitution,omitempty"`
+}
+
+type ciiFinancialAccount struct {
+	IBANID      string `xml:"ram:IBANID"`
+	AccountName string `xml:"ram:AccountName,omitempty"`
+}
+
+type ciiInstitution struct {
+	BICID string `xml:"ram:BICID"`
+}
+
+type ciiTradeTax struct {
+	CalculatedAmount      string `xml:"ram:CalculatedAmount,omitempty"`
+	TypeCode              string `xml:"ram:TypeCode"`
+	ExemptionReason       string `xml:"ram:ExemptionReason,omitempty"`
+	BasisAmount           string `xml:"ram:BasisAmount,omitempty"`
+	CategoryCode          string `xml:"ram:CategoryCode"`
+	ExemptionReasonCode   string `xml:"ram:ExemptionReasonCode,omitempty"`
+	RateApplicablePercent string `xml:"ram:RateApplicablePercent,omitempty"`
+}
+
+type ciiPeriod struct {
+	Start *ciiDateTime `xml:"ram:StartDateTime,omitempty"`
+	End   *ciiDateTime `xml:"ram:EndDateTime,omitempty"`
+}
+
+type ciiAllowanceCharge struct {
+	ChargeIndicator    ciiIndicator `xml:"ram:ChargeIndicator"`
+	CalculationPercent string       `xml:"ram:CalculationPercent,omitempty"`
+	BasisAmount        string       `xml:"ram:BasisAmount,omitempty"`
+	ActualAmount       string       `xml:"ram:ActualAmount"`
+	ReasonCode         string       `xml:"ram:ReasonCode,omitempty"`
+	Reason             string       `xml:"ram:Reason,omitempty"`
+	CategoryTradeTax   *ciiTradeTax `xml:"ram:CategoryTradeTax,omitempty"`
+}
+
+type ciiIndicator struct {
+	Indicator bool `xml:"udt:Indicator"`
+}
+
+type ciiPaymentTerms struct {
+	Description          string       `xml:"ram:Description,omitempty"`
+	DueDate              *ciiDateTime `xml:"ram:DueDateDateTime,omitempty"`
+	DirectDebitMandateID string       `xml:"ram:DirectDebitMandateID,omitempty"`
+}
+
+type ciiHeaderSummation struct {
+	LineTotalAmount      string    `xml:"ram:LineTotalAmount"`
+	ChargeTotalAmount    string    `xml:"ram:ChargeTotalAmount,omitempty"`
+	AllowanceTotalAmount string    `xml:"ram:AllowanceTotalAmount,omitempty"`
+	TaxBasisTotalAmount  string    `xml:"ram:TaxBasisTotalAmount"`
+	TaxTotalAmount       ciiAmount `xml:"ram:TaxTotalAmount"`
+	RoundingAmount       string    `xml:"ram:RoundingAmount,omitempty"`
+	GrandTotalAmount     string    `xml:"ram:GrandTotalAmount"`
+	DuePayableAmount     string    `xml:"ram:DuePayableAmount"`
+}
+
+// ciiAmount is an amount with its currency, which CII only gives for the
+// total VAT.
+type ciiAmount struct {
+	Value      string `xml:",chardata"`
+	CurrencyID string `xml:"currencyID,attr"`
+}
+
+type ciiLineItem struct {
+	Document   ciiLineDocument   `xml:"ram:AssociatedDocumentLineDocument"`
+	Product    ciiProduct        `xml:"ram:SpecifiedTradeProduct"`
+	Agreement  ciiLineAgreement  `xml:"ram:SpecifiedLineTradeAgreement"`
+	Delivery   ciiLineDelivery   `xml:"ram:SpecifiedLineTradeDelivery"`
+	Settlement ciiLineSettlement `xml:"ram:SpecifiedLineTradeSettlement"`
+}
+
+type ciiLineDocument struct {
+	LineID       string    `xml:"ram:LineID"`
+	IncludedNote []ciiNote `xml:"ram:IncludedNote"`
+}
+
+type ciiProduct struct {
+	GlobalID       *ciiIdentifier      `xml:"ram:GlobalID,omitempty"`
+	Name           string              `xml:"ram:Name"`
+	Description    string              `xml:"ram:Description,omitempty"`
+	Characteristic []ciiCharacteristic `xml:"ram:ApplicableProductCharacteristic"`
+	Classification []ciiClassification `xml:"ram:DesignatedProductClassification"`
+}
+
+type ciiCharacteristic struct {
+	Description string `xml:"ram:Description"`
+	Value       string `xml:"ram:Value"`
+}
+
+type ciiClassification struct {
+	ClassCode XMLClassificationCode `xml:"ram:ClassCode"`
+}
+
+type ciiLineAgreement struct {
+	BuyerOrder *ciiReferencedDocument `xml:"ram:BuyerOrderReferencedDocument,omitempty"`
+	GrossPrice *ciiTradePrice         `xml:"ram:GrossPriceProductTradePrice,omitempty"`
+	NetPrice   ciiTradePrice          `xml:"ram:NetPriceProductTradePrice"`
+}
+
+type ciiTradePrice struct {
+	ChargeAmount    string              `xml:"ram:ChargeAmount"`
+	AllowanceCharge *ciiAllowanceCharge `xml:"ram:AppliedTradeAllowanceCharge,omitempty"`
+}
+
+type ciiLineDelivery struct {
+	BilledQuantity XMLQuantity `xml:"ram:BilledQuantity"`
+}
+
+type ciiLineSettlement struct {
+	Tax                  ciiTradeTax            `xml:"ram:ApplicableTradeTax"`
+	Summation            ciiLineSummation       `xml:"ram:SpecifiedTradeSettlementLineMonetarySummation"`
+	AdditionalReferenced *ciiReferencedDocument `xml:"ram:AdditionalReferencedDocument,omitempty"`
+	AccountingAccount    *ciiID                 `xml:"ram:ReceivableSpecifiedTradeAccountingAccount,omitempty"`
+}
+
+type ciiLineSummation struct {
+	LineTotalAmount string `xml:"ram:LineTotalAmount"`
+}
+
+// ciiDocument maps the UBL document onto CII and returns it with the
+// document references of its attachments.
+func ciiDocument(doc *Document) (*ciiInvoice, []XMLDocumentReference) {
+	var x XMLInvoice
+	typeCode := "380"
+	if cn := doc.CreditNote; cn != nil {
+		// the credit note has the same elements, bar the due date
+		x = XMLInvoice{
+			CustomizationID: cn.CustomizationID, ProfileID: cn.ProfileID, ID: cn.ID,
+			IssueDate: cn.IssueDate, Note: cn.Note, DocumentCurrency: cn.DocumentCurrency,
+			BuyerReference: cn.BuyerReference, InvoicePeriod: cn.InvoicePeriod,
+			OrderReference: cn.OrderReference, BillingReference: cn.BillingReference,
+			AdditionalDocumentReference: cn.AdditionalDocumentReference,
+			SupplierParty:               cn.SupplierParty, CustomerParty: cn.CustomerParty,
+			Delivery: cn.Delivery, PaymentMeans: cn.PaymentMeans, PaymentTerms: cn.PaymentTerms,
+			AllowanceCharge: cn.AllowanceCharge, TaxTotal: cn.TaxTotal,
+			LegalMonetaryTotal: cn.LegalMonetaryTotal,
+		}
+		for _, line := range cn.CreditNoteLines {
+			x.InvoiceLines = append(x.InvoiceLines, XMLInvoiceLine{
+				ID: line.ID, Note: line.Note, InvoicedQuantity: line.CreditedQuantity,
+				LineExtensionAmount: line.LineExtensionAmount, AccountingCost: line.AccountingCost,
+				OrderLineReference: line.OrderLineReference, DocumentReference: line.DocumentReference,
+				Item: line.Item, Price: line.Price,
+			})
+		}
+		typeCode = cn.CreditNoteTypeCode
+	} else {
+		x = *doc.Invoice
+		typeCode = x.InvoiceTypeCode
+	}
+	amount := func(a XMLAmount) string {
+		return formatAmount(a.Value, minorUnits(x.DocumentCurrency))
+	}
+
+	customizationID := x.CustomizationID
+	if customizationID == PeppolBilling30CustomizationID {
+		customizationID = ciiEN16931CustomizationID
+	}
+	c := &ciiInvoice{
+		Rsm: ciiNamespace, Ram: ramNamespace, Udt: udtNamespace, Qdt: qdtNamespace,
+		Context: ciiDocumentContext{Guideline: ciiID{ID: customizationID}},
+		Document: ciiExchangedDocument{
+			ID:            x.ID,
+			TypeCode:      typeCode,
+			IssueDateTime: ciiDate(x.IssueDate),
+		},
+	}
+	if x.ProfileID != "" {
+		c.Context.BusinessProcess = &ciiID{ID: x.ProfileID}
+	}
+	for _, note := range x.Note {
+		c.Document.IncludedNote = append(c.Document.IncludedNote, ciiNote{Content: note})
+	}
+
+	t := &c.Transaction
+	for _, line := range x.InvoiceLines {
+		t.Lines = append(t.Lines, ciiLine(line, amount))
+	}
+
+	t.Agreement = ciiHeaderAgreement{
+		BuyerReference: x.BuyerReference,
+		Seller:         ciiParty(x.SupplierParty.Party),
+		Buyer:          ciiParty(x.CustomerParty.Party),
+	}
+	if ref := x.OrderReference; ref != nil {
+		if ref.SalesOrderID != "" {
+			t.Agreement.SellerOrder = &ciiReferencedDocument{IssuerAssignedID: ref.SalesOrderID}
+		}
+		if ref.ID != "" && ref.ID != "NA" {
+			t.Agreement.BuyerOrder = &ciiReferencedDocument{IssuerAssignedID: ref.ID}
+		}
+	}
+	for _, ref := range x.AdditionalDocumentReference {
+		d := ciiReferencedDocument{
+			IssuerAssignedID: ref.ID.Value,
+			TypeCode:         "916",
+			Name:             ref.DocumentDescription,
+		}
+		if ref.DocumentTypeCode == "130" || ref.DocumentTypeCode == "50" {
+			d.TypeCode = ref.DocumentTypeCode
+			d.ReferenceTypeCode = ref.ID.SchemeID
+		}
+		for _, a := range ref.Attachment {
+			if a.ExternalReference != nil {
+				d.URIID = a.ExternalReference.URI
+			}
+			d.AttachmentBinary = a.EmbeddedDocumentBinaryObject
+		}
+		t.Agreement.AdditionalReferenced = append(t.Agreement.AdditionalReferenced, d)
+	}
+
+	if d := x.Delivery; d != nil {
+		if d.DeliveryLocation != nil || d.DeliveryParty != nil {
+			shipTo := &ciiTradeParty{}
+			if d.DeliveryParty != nil {
+				shipTo.Name = d.DeliveryParty.PartyName
+			}
+			if loc := d.DeliveryLocation; loc != nil {
+				if loc.ID != nil {
+					shipTo.addID(*loc.ID)
+				}
+				if loc.Address != nil {
+					shipTo.PostalAddress = ciiPostalAddress(*loc.Address)
+				}
+			}
+			t.Delivery.ShipTo = shipTo
+		}
+		if d.ActualDeliveryDate != "" {
+			t.Delivery.ActualEvent = &ciiEvent{OccurrenceDateTime: ciiDate(d.ActualDeliveryDate)}
+		}
+	}
+
+	s := &t.Settlement
+	s.InvoiceCurrencyCode = x.DocumentCurrency
+	for _, id := range x.SupplierParty.Party.PartyIdentification {
+		if id.ID.SchemeID == "SEPA" {
+			s.CreditorReferenceID = id.ID.Value
+		}
+	}
+	var mandateID string
+	for _, pm := range x.PaymentMeans {
+		m := ciiPaymentMeans{TypeCode: pm.PaymentMeansCode.Value, Information: pm.PaymentMeansCode.Name}
+		if a := pm.PayeeFinancialAccount; a != nil {
+			m.PayeeCreditorAccount = &ciiFinancialAccount{IBANID: a.ID, AccountName: a.Name}
+			if a.FinancialInstitutionBranch != nil {
+				m.PayeeCreditorInstitution = &ciiInstitution{BICID: a.FinancialInstitutionBranch.ID}
+			}
+		}
+		if mandate := pm.PaymentMandate; mandate != nil {
+			mandateID = mandate.ID
+			if mandate.PayerFinancialAccount != nil {
+				m.PayerDebtorAccount = &ciiFinancialAccount{IBANID: mandate.PayerFinancialAccount.ID}
+			}
+		}
+		s.PaymentMeans = append(s.PaymentMeans, m)
+	}
+	for _, sub := range x.TaxTotal.TaxSubtotal {
+		tax := ciiTax(sub.TaxCategory)
+		tax.CalculatedAmount, tax.BasisAmount = amount(sub.TaxAmount), amount(sub.TaxableAmount)
+		s.Tax = append(s.Tax, tax)
+	}
+	if p := x.InvoicePeriod; p != nil {
+		s.BillingPeriod = &ciiPeriod{}
+		if p.StartDate != "" {
+			start := ciiDate(p.StartDate)
+			s.BillingPeriod.Start = &start
+		}
+		if p.EndDate != "" {
+			end := ciiDate(p.EndDate)
+			s.BillingPeriod.End = &end
+		}
+	}
+	for _, ac := range x.AllowanceCharge {
+		tax := ciiTax(ac.TaxCategory)
+		a := ciiAllowanceCharge{
+			ChargeIndicator:  ciiIndicator{Indicator: ac.ChargeIndicator},
+			ActualAmount:     amount(ac.Amount),
+			ReasonCode:       ac.AllowanceChargeReasonCode,
+			Reason:           ac.AllowanceChargeReason,
+			CategoryTradeTax: &tax,
+		}
+		if ac.MultiplierFactorNumeric != 0 {
+			a.CalculationPercent = strconv.FormatFloat(ac.MultiplierFactorNumeric, 'f', -1, 64)
+		}
+		if ac.BaseAmount != nil {
+			a.BasisAmount = amount(*ac.BaseAmount)
+		}
+		s.AllowanceCharge = append(s.AllowanceCharge, a)
+	}
+	if x.PaymentTerms != nil || x.DueDate != "" || mandateID != "" {
+		s.PaymentTerms = &ciiPaymentTerms{DirectDebitMandateID: mandateID}
+		if x.PaymentTerms != nil {
+			s.PaymentTerms.Description = strings.Join(x.PaymentTerms.Note, "\n")
+		}
+		if x.DueDate != "" {
+			due := ciiDate(x.DueDate)
+			s.PaymentTerms.DueDate = &due
+		}
+	}
+	total := x.LegalMonetaryTotal
+	s.Summation = ciiHeaderSummation{
+		LineTotalAmount:     amount(total.LineExtensionAmount),
+		TaxBasisTotalAmount: amount(total.TaxExclusiveAmount),
+		TaxTotalAmount:      ciiAmount{Value: amount(x.TaxTotal.TaxAmount), CurrencyID: x.DocumentCurrency},
+		GrandTotalAmount:    amount(total.TaxInclusiveAmount),
+		DuePayableAmount:    amount(total.PayableAmount),
+	}
+	if total.ChargeTotalAmount != nil {
+		s.Summation.ChargeTotalAmount = amount(*total.ChargeTotalAmount)
+	}
+	if total.PayableRoundingAmount != nil {
+		s.Summation.RoundingAmount = amount(*total.PayableRoundingAmount)
+	}
+	if total.AllowanceTotalAmount != nil {
+		s.Summation.AllowanceTotalAmount = amount(*total.AllowanceTotalAmount)
+	}
+	for _, ref := range x.BillingReference {
+		d := ciiReferencedDocument{IssuerAssignedID: ref.InvoiceDocumentReference.ID}
+		if ref.InvoiceDocumentReference.IssueDate != "" {
+			d.FormattedIssueDateTime = &ciiFormattedDateTime{DateTimeString: ciiDate(ref.InvoiceDocumentReference.IssueDate).DateTimeString}
+		}
+		s.InvoiceReferenced = append(s.InvoiceReferenced, d)
+	}
+	return c, x.AdditionalDocumentReference
+}
+
+// ciiLine maps an invoice line.
+func ciiLine(line XMLInvoiceLine, amount func(XMLAmount) string) ciiLineItem {
+	item := line.Item
+	l := ciiLineItem{
+		Document: ciiLineDocument{LineID: line.ID},
+		Product: ciiProduct{
+			Name:        item.Name,
+			Description: item.Description,
+		},
+		Agreement: ciiLineAgreement{
+			NetPrice: ciiTradePrice{ChargeAmount: strconv.FormatFloat(line.Price.PriceAmount.Value, 'f', -1, 64)},
+		},
+		Delivery: ciiLineDelivery{BilledQuantity: line.InvoicedQuantity},
+		Settlement: ciiLineSettlement{
+			Tax:       ciiTax(item.ClassifiedTaxCategory),
+			Summation: ciiLineSummation{LineTotalAmount: amount(line.LineExtensionAmount)},
+		},
+	}
+	if line.Note != "" {
+		l.Document.IncludedNote = []ciiNote{{Content: line.Note}}
+	}
+	if id := item.StandardItemIdentification; id != nil {
+		l.Product.GlobalID = &ciiIdentifier{Value: id.ID.Value, SchemeID: id.ID.SchemeID}
+	}
+	for _, p := range item.AdditionalItemProperty {
+		l.Product.Characteristic = append(l.Product.Characteristic, ciiCharacteristic{Description: p.Name, Value: p.Value})
+	}
+	for _, cc := range item.CommodityClassification {
+		l.Product.Classification = append(l.Product.Classification, ciiClassification{ClassCode: cc.ItemClassificationCode})
+	}
+	if ref := line.OrderLineReference; ref != nil {
+		l.Agreement.BuyerOrder = &ciiReferencedDocument{LineID: ref.LineID}
+	}
+	if ac := line.Price.AllowanceCharge; ac != nil {
+		l.Agreement.GrossPrice = &ciiTradePrice{
+			ChargeAmount: strconv.FormatFloat(ac.BaseAmount.Value, 'f', -1, 64),
+			AllowanceCharge: &ciiAllowanceCharge{
+				ChargeIndicator: ciiIndicator{Indicator: ac.ChargeIndicator},
+				ActualAmount:    strconv.FormatFloat(ac.Amount.Value, 'f', -1, 64),
+			},
+		}
+	}
+	if ref := line.DocumentReference; ref != nil {
+		l.Settlement.AdditionalReferenced = &ciiReferencedDocument{
+			IssuerAssignedID:  ref.ID.Value,
+			TypeCode:          ref.DocumentTypeCode,
+			ReferenceTypeCode: ref.ID.SchemeID,
+		}
+	}
+	if line.AccountingCost != "" {
+		l.Settlement.AccountingAccount = &ciiID{ID: line.AccountingCost}
+	}
+	return l
+}
+
+// ciiParty maps a seller or buyer.
+func ciiParty(p XMLParty) ciiTradeParty {
+	legal := p.PartyLegalEntity
+	party := ciiTradeParty{
+		Name:          legal.RegistrationName,
+		Description:   legal.CompanyLegalForm,
+		PostalAddress: ciiPostalAddress(p.PostalAddress),
+	}
+	for _, id := range p.PartyIdentification {
+		// the creditor identifier is a settlement reference in CII
+		if id.ID.SchemeID != "SEPA" {
+			party.addID(id.ID)
+		}
+	}
+	if legal.CompanyID != nil || p.PartyName != "" && p.PartyName != legal.RegistrationName {
+		party.LegalOrganization = &ciiLegalOrganization{}
+		if legal.CompanyID != nil {
+			party.LegalOrganization.ID = &ciiIdentifier{Value: legal.CompanyID.Value, SchemeID: legal.CompanyID.SchemeID}
+		}
+		if p.PartyName != legal.RegistrationName {
+			party.LegalOrganization.TradingBusinessName = p.PartyName
+		}
+	}
+	if c := p.Contact; c != nil {
+		party.Contact = &ciiContact{PersonName: c.Name}
+		if c.Telephone != "" {
+			party.Contact.Telephone = &ciiNumber{CompleteNumber: c.Telephone}
+		}
+		if c.ElectronicMail != "" {
+			party.Contact.Email = &ciiURIID{URIID: c.ElectronicMail}
+		}
+	}
+	if p.EndpointID.Value != "" {
+		party.URI = &ciiURI{URIID: ciiIdentifier{Value: p.EndpointID.Value, SchemeID: p.EndpointID.SchemeID}}
+	}
+	for _, reg := range p.PartyTaxScheme {
+		// VA is a VAT number, FC a tax number of the seller's country
+		scheme := "FC"
+		if reg.TaxScheme.ID == "VAT" {
+			scheme = "VA"
+		}
+		party.TaxRegistration = append(party.TaxRegistration, ciiTaxRegistration{ID: ciiIdentifier{Value: reg.CompanyID, SchemeID: scheme}})
+	}
+	return party
+}
+
+// addID adds a party identifier: with a scheme as global identifier, else
+// as the identifier of the seller or buyer.
+func (p *ciiTradeParty) addID(id XMLIdentifier) {
+	if id.SchemeID == "" {
+		p.ID = append(p.ID, id.Value)
+		return
+	}
+	p.GlobalID = append(p.GlobalID, ciiIdentifier{Value: id.Value, SchemeID: id.SchemeID})
+}
+
+func ciiPostalAddress(a XMLPostalAddress) *ciiAddress {
+	addr := &ciiAddress{
+		PostcodeCode:           a.PostalZone,
+		LineOne:                a.StreetName,
+		LineTwo:                a.AdditionalStreetName,
+		CityName:               a.CityName,
+		CountryID:              a.Country.IdentificationCode,
+		CountrySubDivisionName: a.CountrySubentity,
+	}
+	if a.AddressLine != nil {
+		addr.LineThree = a.AddressLine.Line
+	}
+	return addr
+}
+
+// ciiTax maps a VAT category, without amounts.
+func ciiTax(cat XMLTaxCategory) ciiTradeTax {
+	tax := ciiTradeTax{
+		TypeCode:            cat.TaxScheme.ID,
+		ExemptionReason:     cat.TaxExemptionReason,
+		CategoryCode:        cat.ID,
+		ExemptionReasonCode: cat.TaxExemptionReasonCode,
+	}
+	if cat.Percent != nil {
+		tax.RateApplicablePercent = strconv.FormatFloat(*cat.Percent, 'f', -1, 64)
+	}
+	return tax
+}
+
+// ciiDate converts a UBL date, YYYY-MM-DD, to format 102.
+func ciiDate(date string) ciiDateTime {
+	return ciiDateTime{DateTimeString: ciiDateString{Value: strings.ReplaceAll(date, "-", ""), Format: "102"}}
+}
@@ -0,0 +1,183 @@
+package ubl_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+// ciiNamespaces are the namespaces of the elements of a CII invoice.
+var ciiNamespaces = map[string]bool{
+	"urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100":                       true,
+	"urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100": true,
+	"urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100":                        true,
+	"urn:un:unece:uncefact:data:standard:QualifiedDataType:100":                          true,
+}
+
+// ciiInvoice is the part of a CII invoice the tests check, by namespace.
+type ciiInvoice struct {
+	XMLName   xml.Name
+	Guideline string `xml:"ExchangedDocumentContext>GuidelineSpecifiedDocumentContextParameter>ID"`
+	ID        string `xml:"ExchangedDocument>ID"`
+	TypeCode  string `xml:"ExchangedDocument>TypeCode"`
+	IssueDate string `xml:"ExchangedDocument>IssueDateTime>DateTimeString"`
+	Lines     []struct {
+		LineID    string  `xml:"AssociatedDocumentLineDocument>LineID"`
+		Name      string  `xml:"SpecifiedTradeProduct>Name"`
+		Quantity  xmlUnit `xml:"SpecifiedLineTradeDelivery>BilledQuantity"`
+		NetPrice  string  `xml:"SpecifiedLineTradeAgreement>NetPriceProductTradePrice>ChargeAmount"`
+		Category  string  `xml:"SpecifiedLineTradeSettlement>ApplicableTradeTax>CategoryCode"`
+		LineTotal string  `xml:"SpecifiedLineTradeSettlement>SpecifiedTradeSettlementLineMonetarySummation>LineTotalAmount"`
+	} `xml:"SupplyChainTradeTransaction>IncludedSupplyChainTradeLineItem"`
+	Agreement struct {
+		Seller struct {
+			Name     string   `xml:"Name"`
+			Endpoint xmlValue `xml:"URIUniversalCommunication>URIID"`
+			VAT      xmlValue `xml:"SpecifiedTaxRegistration>ID"`
+			Country  string   `xml:"PostalTradeAddress>CountryID"`
+		} `xml:"SellerTradeParty"`
+		BuyerName string `xml:"BuyerTradeParty>Name"`
+	} `xml:"SupplyChainTradeTransaction>ApplicableHeaderTradeAgreement"`
+	Settlement struct {
+		Currency string `xml:"InvoiceCurrencyCode"`
+		IBAN     string `xml:"SpecifiedTradeSettlementPaymentMeans>PayeePartyCreditorFinancialAccount>IBANID"`
+		Tax      []struct {
+			Calculated string `xml:"CalculatedAmount"`
+			Basis      string `xml:"BasisAmount"`
+			Category   string `xml:"CategoryCode"`
+			Rate       string `xml:"RateApplicablePercent"`
+		} `xml:"ApplicableTradeTax"`
+		DueDate   string `xml:"SpecifiedTradePaymentTerms>DueDateDateTime>DateTimeString"`
+		Summation struct {
+			LineTotal  string   `xml:"LineTotalAmount"`
+			Allowances string   `xml:"AllowanceTotalAmount"`
+			TaxBasis   string   `xml:"TaxBasisTotalAmount"`
+			TaxTotal   xmlValue `xml:"TaxTotalAmount"`
+			GrandTotal string   `xml:"GrandTotalAmount"`
+			DuePayable string   `xml:"DuePayableAmount"`
+		} `xml:"SpecifiedTradeSettlementHeaderMonetarySummation"`
+		Original string `xml:"InvoiceReferencedDocument>IssuerAssignedID"`
+	} `xml:"SupplyChainTradeTransaction>ApplicableHeaderTradeSettlement"`
+}
+
+type xmlUnit struct {
+	Value    string `xml:",chardata"`
+	UnitCode string `xml:"unitCode,attr"`
+}
+
+func TestGenerateCII(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Now = func() time.Time { return time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC) }
+	inv.AllowanceCharges = []ubl.AllowanceCharge{{Reason: "Volume discount", Amount: 50, TaxPercentage: 21}}
+	inv.Lines = append(inv.Lines, ubl.InvoiceLine{Quantity: 2.5, UnitCode: "HUR", Price: 80.125, TaxPercentage: 6, Name: "Consulting"})
+	if err := inv.AddAttachmentFromBytes([]byte("%PDF-1.4 timesheet"), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	xmlBytes := generateAndValidate(t, &inv)
+	data, err := inv.GenerateCII()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got ciiInvoice
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+	var want struct {
+		ID            string   `xml:"ID"`
+		TaxAmount     xmlValue `xml:"TaxTotal>TaxAmount"`
+		LineExtension string   `xml:"LegalMonetaryTotal>LineExtensionAmount"`
+		Allowances    string   `xml:"LegalMonetaryTotal>AllowanceTotalAmount"`
+		TaxExclusive  string   `xml:"LegalMonetaryTotal>TaxExclusiveAmount"`
+		TaxInclusive  string   `xml:"LegalMonetaryTotal>TaxInclusiveAmount"`
+		Payable       string   `xml:"LegalMonetaryTotal>PayableAmount"`
+		TaxableAmount []string `xml:"TaxTotal>TaxSubtotal>TaxableAmount"`
+		SubtotalTax   []string `xml:"TaxTotal>TaxSubtotal>TaxAmount"`
+		LineAmounts   []string `xml:"InvoiceLine>LineExtensionAmount"`
+	}
+	if err := xml.Unmarshal(xmlBytes, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.XMLName != (xml.Name{Space: "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100", Local: "CrossIndustryInvoice"}) {
+		t.Errorf("expected rsm:CrossIndustryInvoice, got %v", got.XMLName)
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && !ciiNamespaces[start.Name.Space] {
+			t.Errorf("expected %s in a CII namespace, got %q", start.Name.Local, start.Name.Space)
+		}
+	}
+	s := got.Settlement
+	for _, c := range []struct{ name, got, want string }{
+		{"guideline", got.Guideline, "urn:cen.eu:en16931:2017"},
+		{"ID", got.ID, want.ID},
+		{"type code", got.TypeCode, "380"},
+		{"issue date", got.IssueDate, "20250310"},
+		{"due date", s.DueDate, "20250409"},
+		{"seller", got.Agreement.Seller.Name, inv.SupplierName},
+		{"seller endpoint", got.Agreement.Seller.Endpoint.SchemeID + ":" + got.Agreement.Seller.Endpoint.Value, "9925:BE0123456789"},
+		{"seller VAT", got.Agreement.Seller.VAT.SchemeID + " " + got.Agreement.Seller.VAT.Value, "VA BE0123456789"},
+		{"seller country", got.Agreement.Seller.Country, "BE"},
+		{"buyer", got.Agreement.BuyerName, inv.CustomerName},
+		{"currency", s.Currency, "EUR"},
+		{"IBAN", s.IBAN, inv.Iban},
+		{"line total", s.Summation.LineTotal, want.LineExtension},
+		{"allowances", s.Summation.Allowances, want.Allowances},
+		{"tax basis", s.Summation.TaxBasis, want.TaxExclusive},
+		{"tax total", s.Summation.TaxTotal.Value, want.TaxAmount.Value},
+		{"tax currency", s.Summation.TaxTotal.CurrencyID, "EUR"},
+		{"grand total", s.Summation.GrandTotal, want.TaxInclusive},
+		{"due payable", s.Summation.DuePayable, want.Payable},
+		{"line 2 quantity", got.Lines[1].Quantity.Value + " " + got.Lines[1].Quantity.UnitCode, "2.5 HUR"},
+		{"line 2 price", got.Lines[1].NetPrice, "80.125"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, c.got)
+		}
+	}
+	if len(s.Tax) != len(want.TaxableAmount) || len(got.Lines) != len(want.LineAmounts) {
+		t.Fatalf("expected %d VAT categories and %d lines, got %+v", len(want.TaxableAmount), len(want.LineAmounts), got)
+	}
+	for i, tax := range s.Tax {
+		if tax.Basis != want.TaxableAmount[i] || tax.Calculated != want.SubtotalTax[i] || tax.Category != "S" {
+			t.Errorf("VAT category %d: expected %s and %s, got %+v", i, want.TaxableAmount[i], want.SubtotalTax[i], tax)
+		}
+	}
+	for i, line := range got.Lines {
+		if line.LineTotal != want.LineAmounts[i] || line.Category != "S" {
+			t.Errorf("line %d: expected %s, got %+v", i+1, want.LineAmounts[i], line)
+		}
+	}
+	if s.Summation.DuePayable != "1363.08" {
+		t.Errorf("expected the totals of the invoice, got %+v", s.Summation)
+	}
+}
+
+func TestGenerateCreditNoteCII(t *testing.T) {
+	original := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	cn := newTestCreditNote()
+	cn.OriginalInvoiceID, cn.OriginalInvoiceDate = "INV-12345", &original
+	data, err := cn.GenerateCreditNoteCII()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ciiInvoice
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TypeCode != "381" || got.Settlement.Original != "INV-12345" || got.Settlement.Summation.DuePayable != "1210.00" || got.Settlement.DueDate != "" {
+		t.Errorf("expected a credit note for INV-12345, got %+v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// uncl7143 are the item type identification codes (UNCL7143) allowed by
+// Peppol BIS 3.0 as classification list.
+var uncl7143 = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		AA AB AC AD AE AF AG AH AI AJ AK AL AM AN AO AP AQ AR AS AT AU AV AW
+		AX AY AZ BA BB BC BD BE BF BG BH BI BJ BK BL BM BN BO BP BQ BR BS BT
+		BU BV BW BX BY BZ CC CG CL CR CV DR DW EC EF EMD EN FS GB GMN GN GS
+		HS IB IN IS IT IZ MA MF MN MP NB ON PD PL PO PV QS RC RN RU RY SA SG
+		SK SN SRS SRT SRU SRV SRW SRX SRY SRZ SS SSA SSB SSC SSD SSE SSF SSG
+		SSH SSI SSJ SSK SSL SSM SSN SSO SSP SSQ SSR SSS SST SSU SSV SSW SSX SSY
+		SSZ ST STA STB STC STD STE STF STG STH STI STJ STK STL STM STN STO STP
+		STQ STR STS STT STU STV STW STX STY STZ SUA SUB SUC SUD SUE SUF SUG SUH
+		SUI SUJ SUK SUL SUM TG TSN TSO TSP TSQ TSR TSS TST TSU UA UP VN VP VS
+		VX ZZZ`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// ItemClassification classifies the item of a line (BT-158), e.g. a CPV code
+// with list "STI" or a UNSPSC code with list "TST".
+type ItemClassification struct {
+	Code          string `json:"code"`
+	ListID        string `json:"listID"`                  // UNCL7143 code of the classification scheme (BT-158-1)
+	ListVersionID string `json:"listVersionID,omitempty"` // Optional: version of the scheme (BT-158-2)
+}
+
+// commodityClassifications returns the cac:CommodityClassification elements
+// of a line, or all problems with the classifications.
+func commodityClassifications(classifications []ItemClassification) ([]XMLCommodityClassification, error) {
+	var result []XMLCommodityClassification
+	var errs []error
+	for i, c := range classifications {
+		if c.Code == "" {
+			errs = append(errs, fmt.Errorf("Classifications[%d]: code required", i))
+		}
+		if !uncl7143[c.ListID] {
+			errs = append(errs, fmt.Errorf("Classifications[%d]: list ID %q not in UNCL7143", i, c.ListID))
+		}
+		result = append(result, XMLCommodityClassification{
+			ItemClassificationCode: XMLClassificationCode{
+				Value:         c.Code,
+				ListID:        c.ListID,
+				ListVersionID: c.ListVersionID,
+			},
+		})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
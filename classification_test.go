@@ -0,0 +1,44 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestClassifications(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines[0].Classifications = []ubl.ItemClassification{
+		{Code: "09348000", ListID: "STI"},
+		{Code: "43211503", ListID: "TST", ListVersionID: "19.0501"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:CommodityClassification><cbc:ItemClassificationCode listID="STI">09348000</cbc:ItemClassificationCode></cac:CommodityClassification>` +
+		`<cac:CommodityClassification><cbc:ItemClassificationCode listID="TST" listVersionID="19.0501">43211503</cbc:ItemClassificationCode></cac:CommodityClassification>` +
+		`<cac:ClassifiedTaxCategory>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv.Lines[0].Classifications = []ubl.ItemClassification{{Code: "09348000", ListID: "CPV"}, {ListID: "STI"}}
+	_, err := inv.Generate()
+	for _, want := range []string{
+		`Lines[0].Classifications[0]: list ID "CPV" not in UNCL7143`,
+		"Lines[0].Classifications[1]: code required",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].Classifications = []ubl.ItemClassification{{Code: "09348000", ListID: "STI"}}
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:ItemClassificationCode listID="STI">09348000</cbc:ItemClassificationCode>`) {
+		t.Error("expected the credit note classification")
+	}
+}
@@ -0,0 +1,77 @@
+package ubl
+
+import (
+	"encoding/xml"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currencyMinorUnits are the ISO 4217 currencies that don't have 2 decimals.
+var currencyMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// minorUnits returns the number of decimals amounts in the currency have.
+func minorUnits(currency string) int {
+	if n, ok := currencyMinorUnits[currency]; ok {
+		return n
+	}
+	return 2
+}
+
+// roundTo rounds an amount to the given number of decimals, half away from
+// zero, so negative amounts round like their positive counterparts. Negative
+// zero (e.g. the tax of a negative line at 0%) becomes 0, so no "-0" ends up
+// in the output.
+func roundTo(amount float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	r := math.Round(amount*scale) / scale
+	if r == 0 {
+		return 0
+	}
+	return r
+}
+
+// formatAmount formats amount in plain notation with exactly the given
+// number of decimals. It rounds the shortest decimal representation of the
+// float half away from zero, so 2.675 becomes "2.68" and large amounts keep
+// all their digits, where scaling the float would lose them.
+func formatAmount(amount float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(amount), 'f', -1, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	roundUp := len(frac) > decimals && frac[decimals] >= '5'
+	if len(frac) > decimals {
+		frac = frac[:decimals]
+	}
+	digits := []byte(whole + frac + strings.Repeat("0", decimals-len(frac)))
+	if roundUp {
+		i := len(digits) - 1
+		for ; i >= 0 && digits[i] == '9'; i-- {
+			digits[i] = '0'
+		}
+		if i < 0 {
+			digits = append([]byte{'1'}, digits...)
+		} else {
+			digits[i]++
+		}
+	}
+	s = string(digits)
+	if decimals > 0 {
+		s = s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+	}
+	if amount < 0 && strings.Trim(s, "0.") != "" {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalXML writes the amount with exactly the minor units of its currency,
+// e.g. "1210.00" for EUR and "1210" for JPY, never in scientific notation.
+func (a XMLAmount) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "currencyID"}, Value: a.CurrencyID})
+	return e.EncodeElement(formatAmount(a.Value, minorUnits(a.CurrencyID)), start)
+}
@@ -0,0 +1,44 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestCurrencyMinorUnits(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Currency = "JPY"
+	inv.Lines = []ubl.InvoiceLine{{Quantity: 3, Price: 1234.5, TaxPercentage: 10, Name: "Product A"}}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:TaxableAmount currencyID="JPY">3704</cbc:TaxableAmount><cbc:TaxAmount currencyID="JPY">370</cbc:TaxAmount>`,
+		`<cbc:PayableAmount currencyID="JPY">4074</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv = newTestInvoice()
+	inv.Currency = "BHD"
+	inv.Lines = []ubl.InvoiceLine{{Quantity: 3, Price: 12.3456, TaxPercentage: 10, Name: "Product A"}}
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:TaxableAmount currencyID="BHD">37.037</cbc:TaxableAmount><cbc:TaxAmount currencyID="BHD">3.704</cbc:TaxAmount>`,
+		`<cbc:PayableAmount currencyID="BHD">40.741</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	view, err := inv.ViewModel("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Payable != "BHD\u00a040.741" {
+		t.Errorf("expected the payable amount with 3 decimals, got %q", view.Payable)
+	}
+}
@@ -0,0 +1,128 @@
+package ubl
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// XMLDeclaration controls the <?xml ...?> declaration of the generated
+// document. The zero value writes version 1.0 in UTF-8, like xml.Header.
+type XMLDeclaration struct {
+	Omit       bool   `json:"omit,omitempty"`       // Optional: write no declaration, e.g. to concatenate documents
+	Encoding   string `json:"encoding,omitempty"`   // Optional: "UTF-8" (default), "ISO-8859-1" or "US-ASCII"; other characters are written as character references
+	Standalone bool   `json:"standalone,omitempty"` // Optional: add standalone="yes"
+}
+
+// maxRune returns the largest character the encoding writes as is.
+func (d XMLDeclaration) maxRune() (rune, error) {
+	switch strings.ToUpper(d.Encoding) {
+	case "", "UTF-8":
+		return utf8.MaxRune, nil
+	case "ISO-8859-1":
+		return 0xff, nil
+	case "US-ASCII":
+		return 0x7f, nil
+	}
+	return 0, fmt.Errorf("XMLDeclaration.Encoding %q: not supported, use UTF-8, ISO-8859-1 or US-ASCII", d.Encoding)
+}
+
+// header returns the declaration with its trailing newline, or "" with Omit.
+func (d XMLDeclaration) header() string {
+	if d.Omit {
+		return ""
+	}
+	header := `<?xml version="1.0" encoding="` + strings.ToUpper(cmp.Or(d.Encoding, "UTF-8")) + `"`
+	if d.Standalone {
+		header += ` standalone="yes"`
+	}
+	return header + "?>\n"
+}
+
+// writer returns w wrapped to write in the declared encoding. The encoder
+// gives UTF-8; for a single byte encoding the characters above maxRune
+// become character references, which is fine as the document has them in
+// text and attribute values only.
+func (d XMLDeclaration) writer(w io.Writer) (io.Writer, error) {
+	maxRune, err := d.maxRune()
+	if err != nil {
+		return nil, err
+	}
+	if maxRune == utf8.MaxRune {
+		return w, nil
+	}
+	return &charsetWriter{w: w, maxRune: maxRune}, nil
+}
+
+// charsetWriter converts UTF-8 to a single byte encoding of the first
+// maxRune+1 code points.
+type charsetWriter struct {
+	w       io.Writer
+	maxRune rune
+	partial []byte // start of a character split over writes
+	buf     []byte
+}
+
+func (c *charsetWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(c.partial) > 0 {
+		p = append(c.partial, p...)
+		c.partial = nil
+	}
+	c.buf = c.buf[:0]
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		if r == utf8.RuneError && !utf8.FullRune(p) {
+			c.partial = append(c.partial, p...)
+			break
+		}
+		if r <= c.maxRune {
+			c.buf = append(c.buf, byte(r))
+		} else {
+			c.buf = fmt.Appendf(c.buf, "&#x%X;", r)
+		}
+		p = p[size:]
+	}
+	_, err := c.w.Write(c.buf)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// charsetReader decodes the single byte encodings XMLDeclaration writes, for
+// xml.Decoder.CharsetReader.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToUpper(label) {
+	case "ISO-8859-1", "US-ASCII":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		for _, b := range data {
+			buf.WriteRune(rune(b))
+		}
+		return &buf, nil
+	}
+	return nil, fmt.Errorf("encoding %q: not supported", label)
+}
+
+// declarationEncoding returns the encoding declared by an XML document, ""
+// without declaration.
+func declarationEncoding(data []byte) string {
+	decl, ok := bytes.CutPrefix(data, []byte("<?xml "))
+	if !ok {
+		return ""
+	}
+	decl, _, _ = bytes.Cut(decl, []byte("?>"))
+	_, encoding, ok := bytes.Cut(decl, []byte(`encoding="`))
+	if !ok {
+		return "UTF-8"
+	}
+	encoding, _, _ = bytes.Cut(encoding, []byte(`"`))
+	return string(encoding)
+}
@@ -0,0 +1,123 @@
+package ubl_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestXMLDeclaration(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := generateAndValidate(t, &inv)
+	if !bytes.HasPrefix(xmlBytes, []byte(xml.Header+"<Invoice ")) {
+		t.Errorf("expected xml.Header by default, got %.60q", xmlBytes)
+	}
+
+	inv.XMLDeclaration = ubl.XMLDeclaration{Standalone: true}
+	xmlBytes = generateAndValidate(t, &inv)
+	if !bytes.HasPrefix(xmlBytes, []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n<Invoice ")) {
+		t.Errorf("expected a standalone declaration, got %.80q", xmlBytes)
+	}
+
+	cn := newTestCreditNote()
+	cn.XMLDeclaration = ubl.XMLDeclaration{Omit: true}
+	var buf bytes.Buffer
+	if err := cn.GenerateCreditNoteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "<CreditNote ") {
+		t.Errorf("expected no declaration, got %.40q", buf.String())
+	}
+	doc, err := cn.GenerateCreditNoteDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbdh, err := doc.SBDH("7c1f0f34-5d2e-4b4a-9a1e-3f5b2c6d8e90", time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sbdh), "</StandardBusinessDocumentHeader><CreditNote ") {
+		t.Error("expected the credit note right after the header")
+	}
+
+	inv.XMLDeclaration = ubl.XMLDeclaration{Encoding: "UTF-16"}
+	_, err = inv.Generate()
+	if err == nil || err.Error() != `XMLDeclaration.Encoding "UTF-16": not supported, use UTF-8, ISO-8859-1 or US-ASCII` {
+		t.Errorf("expected an error for UTF-16, got %v", err)
+	}
+}
+
+func TestXMLDeclarationEncoding(t *testing.T) {
+	const name = "Société Générale € 東京"
+	for _, c := range []struct {
+		encoding string
+		want     string
+	}{
+		{"ISO-8859-1", "Soci\xe9t\xe9 G\xe9n\xe9rale &#x20AC; &#x6771;&#x4EAC;"},
+		{"US-ASCII", "Soci&#xE9;t&#xE9; G&#xE9;n&#xE9;rale &#x20AC; &#x6771;&#x4EAC;"},
+	} {
+		inv := newTestInvoice()
+		inv.CustomerName = name
+		inv.XMLDeclaration = ubl.XMLDeclaration{Encoding: c.encoding}
+		xmlBytes := generateAndValidate(t, &inv)
+		if !bytes.HasPrefix(xmlBytes, []byte(`<?xml version="1.0" encoding="`+c.encoding+`"?>`)) {
+			t.Errorf("%s: expected the declared encoding, got %.60q", c.encoding, xmlBytes)
+		}
+		if !bytes.Contains(xmlBytes, []byte(c.want)) {
+			t.Errorf("%s: expected %q in output", c.encoding, c.want)
+		}
+		if c.encoding == "US-ASCII" && bytes.ContainsFunc(xmlBytes, func(r rune) bool { return r > unicode.MaxASCII }) {
+			t.Errorf("%s: expected only ASCII", c.encoding)
+		}
+		if c.encoding == "ISO-8859-1" && utf8.Valid(xmlBytes) {
+			t.Errorf("%s: expected single byte characters", c.encoding)
+		}
+
+		var buf bytes.Buffer
+		if err := inv.GenerateTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), xmlBytes) {
+			t.Errorf("%s: expected GenerateTo to write the same bytes as Generate", c.encoding)
+		}
+
+		// decoding with the declared encoding gives back the name
+		var doc struct {
+			Name string `xml:"AccountingCustomerParty>Party>PartyLegalEntity>RegistrationName"`
+		}
+		dec := xml.NewDecoder(bytes.NewReader(xmlBytes))
+		dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+			data, err := io.ReadAll(input)
+			var runes []rune
+			for _, b := range data {
+				runes = append(runes, rune(b))
+			}
+			return strings.NewReader(string(runes)), err
+		}
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Name != name {
+			t.Errorf("%s: expected %q back, got %q", c.encoding, name, doc.Name)
+		}
+
+		gen, err := inv.GenerateDocument()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gen.Attachments(); err != nil {
+			t.Errorf("%s: expected the document to parse, got %v", c.encoding, err)
+		}
+		_, err = gen.SBDH("7c1f0f34-5d2e-4b4a-9a1e-3f5b2c6d8e90", time.Now())
+		if err == nil || err.Error() != "sbdh: document encoded in "+c.encoding+", the header needs UTF-8" {
+			t.Errorf("%s: expected an error for the SBDH, got %v", c.encoding, err)
+		}
+	}
+}
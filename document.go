@@ -0,0 +1,212 @@
+package ubl
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+)
+
+// Document is the low-level model of an invoice or credit note, with all the
+// UBL elements the package knows about. BuildDocument returns the document
+// Generate writes: change it for what the Invoice fields don't cover and
+// write it with Marshal. The model follows the output of the package and may
+// change between versions.
+//
+// A Document round-trips through encoding/xml: xml.Marshal writes the
+// Invoice or CreditNote element and xml.Unmarshal reads it back, with any
+// namespace prefixes. Elements the model doesn't have are skipped.
+type Document struct {
+	Invoice        *XMLInvoice    // the invoice, nil for a credit note
+	CreditNote     *XMLCreditNote // the credit note, nil for an invoice
+	XMLDeclaration XMLDeclaration // Optional: the <?xml ...?> declaration, defaults to version 1.0 in UTF-8
+	Namespaces     XMLNamespaces  // Optional: namespace prefixes, defaults to the document namespace as default namespace, "cac" and "cbc"
+}
+
+// BuildDocument checks and builds the invoice like Generate and returns its
+// model, without marshalling it.
+func (inv *Invoice) BuildDocument() (*Document, error) {
+	doc, err := inv.build()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Invoice: doc, XMLDeclaration: inv.XMLDeclaration, Namespaces: inv.Namespaces}, nil
+}
+
+// BuildCreditNoteDocument checks and builds the credit note like
+// GenerateCreditNote and returns its model, without marshalling it.
+func (cn *CreditNote) BuildCreditNoteDocument() (*Document, error) {
+	doc, err := cn.build()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{CreditNote: doc, XMLDeclaration: cn.XMLDeclaration, Namespaces: cn.Namespaces}, nil
+}
+
+// Marshal returns the document as indented XML with its declaration, the
+// way Generate writes it.
+func (d *Document) Marshal() ([]byte, error) {
+	root, refs, out, err := d.root()
+	if err != nil {
+		return nil, err
+	}
+	return marshalDocument(root, refs, out)
+}
+
+// writeTo writes the document like Marshal to w.
+func (d *Document) writeTo(w io.Writer) error {
+	root, _, out, err := d.root()
+	if err != nil {
+		return err
+	}
+	return writeDocument(w, root, out)
+}
+
+// root returns the Invoice or CreditNote element with its namespace
+// declarations set, its document references and the output options.
+func (d *Document) root() (any, []XMLDocumentReference, xmlOutput, error) {
+	switch {
+	case d.Invoice != nil && d.CreditNote != nil:
+		return nil, nil, xmlOutput{}, errors.New("document: both Invoice and CreditNote set")
+	case d.Invoice != nil:
+		x := d.Invoice
+		x.Xmlns, x.Cac, x.Cbc = invoiceNamespace, cacNamespace, cbcNamespace
+		x.Ext = extensionsNamespace(x.UBLExtensions)
+		return x, x.AdditionalDocumentReference, xmlOutput{invoiceNamespace, d.XMLDeclaration, d.Namespaces}, nil
+	case d.CreditNote != nil:
+		x := d.CreditNote
+		x.Xmlns, x.Cac, x.Cbc = creditNoteNamespace, cacNamespace, cbcNamespace
+		x.Ext = extensionsNamespace(x.UBLExtensions)
+		return x, x.AdditionalDocumentReference, xmlOutput{creditNoteNamespace, d.XMLDeclaration, d.Namespaces}, nil
+	}
+	return nil, nil, xmlOutput{}, errors.New("document: Invoice or CreditNote required")
+}
+
+// MarshalXML writes the Invoice or CreditNote element with the usual
+// prefixes, whatever the name of start.
+func (d Document) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	root, _, _, err := d.root()
+	if err != nil {
+		return err
+	}
+	return e.Encode(root)
+}
+
+// UnmarshalXML reads an Invoice or CreditNote element into the model.
+func (d *Document) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var root any
+	switch start.Name {
+	case xml.Name{Space: invoiceNamespace, Local: "Invoice"}:
+		d.Invoice, d.CreditNote = &XMLInvoice{}, nil
+		root = d.Invoice
+	case xml.Name{Space: creditNoteNamespace, Local: "CreditNote"}:
+		d.Invoice, d.CreditNote = nil, &XMLCreditNote{}
+		root = d.CreditNote
+	default:
+		return fmt.Errorf("document: %s in namespace %q is no UBL invoice or credit note", start.Name.Local, start.Name.Space)
+	}
+	tokens := &prefixedTokens{dec: dec, start: &start, prefixes: ublPrefixes}
+	err := xml.NewTokenDecoder(tokens).Decode(root)
+	if err != nil {
+		return err
+	}
+	_, _, _, err = d.root()
+	return err
+}
+
+// prefixedTokens reads the tokens of an element the way the model names
+// them: the elements in the namespaces of prefixes, the UBL ones for a
+// document, get their usual prefix in the local name, as in the struct tags,
+// whatever the prefix of the input. Elements and attributes in other
+// namespaces, e.g. in the content of an extension, keep a prefix declared for their namespace, and the
+// declarations of those namespaces are kept.
+type prefixedTokens struct {
+	dec      *xml.Decoder
+	start    *xml.StartElement   // returned first
+	prefixes map[string]string   // the namespaces of the struct tags to their prefix, e.g. ublPrefixes
+	scope    []map[string]string // per open element, the namespaces in scope to their prefix
+	done     bool
+}
+
+// ublPrefixes are the prefixes of the UBL namespaces in the struct tags.
+var ublPrefixes = map[string]string{
+	invoiceNamespace:    "",
+	creditNoteNamespace: "",
+	cacNamespace:        "cac",
+	cbcNamespace:        "cbc",
+	extNamespace:        "ext",
+}
+
+func (p *prefixedTokens) Token() (xml.Token, error) {
+	if p.done {
+		return nil, io.EOF
+	}
+	var tok xml.Token
+	if p.start != nil {
+		tok, p.start = *p.start, nil
+	} else {
+		var err error
+		tok, err = p.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		scope := map[string]string{}
+		if len(p.scope) > 0 {
+			scope = p.scope[len(p.scope)-1]
+		}
+		var attrs []xml.Attr
+		for _, attr := range t.Attr {
+			prefix, ok := "", attr.Name.Space == "" && attr.Name.Local == "xmlns"
+			if attr.Name.Space == "xmlns" {
+				prefix, ok = attr.Name.Local, true
+			}
+			if !ok {
+				continue
+			}
+			// the map of the parent element stays the same
+			scope = maps.Clone(scope)
+			scope[attr.Value] = prefix
+			if _, ok := p.prefixes[attr.Value]; !ok {
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: rawName(attr.Name)}, Value: attr.Value})
+			}
+		}
+		for _, attr := range t.Attr {
+			if attr.Name.Space != "xmlns" && !(attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: p.prefixedName(attr.Name, scope)}, Value: attr.Value})
+			}
+		}
+		p.scope = append(p.scope, scope)
+		return xml.StartElement{Name: xml.Name{Local: p.prefixedName(t.Name, scope)}, Attr: attrs}, nil
+	case xml.EndElement:
+		name := p.prefixedName(t.Name, p.scope[len(p.scope)-1])
+		p.scope = p.scope[:len(p.scope)-1]
+		p.done = len(p.scope) == 0
+		return xml.EndElement{Name: xml.Name{Local: name}}, nil
+	}
+	return tok, nil
+}
+
+// prefixedName returns the name with the prefix of its namespace in the
+// local name.
+func (p *prefixedTokens) prefixedName(name xml.Name, scope map[string]string) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	prefix, ok := p.prefixes[name.Space]
+	if !ok {
+		prefix, ok = scope[name.Space]
+	}
+	switch {
+	case name.Space == "http://www.w3.org/XML/1998/namespace":
+		prefix = "xml"
+	case !ok:
+		// an undeclared prefix, left unresolved by the decoder
+		prefix = name.Space
+	}
+	return rawName(xml.Name{Space: prefix, Local: name.Local})
+}
@@ -0,0 +1,98 @@
+package ubl_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestBuildDocument(t *testing.T) {
+	inv := newTestInvoice()
+	if err := inv.AddAttachmentFromBytes([]byte("%PDF-1.4"), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	want := generateAndValidate(t, &inv)
+
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := must(doc.Marshal()); !bytes.Equal(got, want) {
+		t.Error("expected Marshal to write the same bytes as Generate")
+	}
+
+	doc.Invoice.Note = append(doc.Invoice.Note, "Delivered to dock 4")
+	doc.Invoice.InvoiceLines[0].AccountingCost = "4217:2323:2323"
+	data := must(doc.Marshal())
+	for _, s := range []string{
+		"<cbc:Note>Delivered to dock 4</cbc:Note>",
+		"<cbc:AccountingCost>4217:2323:2323</cbc:AccountingCost>",
+	} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("expected %s in output", s)
+		}
+	}
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	if err := v.ValidateBytes(data); err != nil {
+		t.Error(err)
+	}
+
+	for _, c := range []struct {
+		doc  ubl.Document
+		want string
+	}{
+		{ubl.Document{}, "document: Invoice or CreditNote required"},
+		{ubl.Document{Invoice: &ubl.XMLInvoice{}, CreditNote: &ubl.XMLCreditNote{}}, "document: both Invoice and CreditNote set"},
+	} {
+		_, err := c.doc.Marshal()
+		if err == nil || err.Error() != c.want {
+			t.Errorf("expected %q, got %v", c.want, err)
+		}
+	}
+}
+
+func TestDocumentRoundTrip(t *testing.T) {
+	inv := newTestInvoice()
+	if err := inv.AddAttachmentFromBytes(bytes.Repeat([]byte("%PDF-1.4 "), 10000), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	inv.Extensions = []ubl.Extension{{ID: "routing", Content: []byte(testExtension)}}
+	cn := newTestCreditNote()
+	cn.Namespaces = ubl.XMLNamespaces{Document: "cn", CAC: "a", CBC: "b"}
+
+	for name, build := range map[string]func() (*ubl.Document, error){
+		"invoice":     inv.BuildDocument,
+		"credit note": cn.BuildCreditNoteDocument,
+	} {
+		doc, err := build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := must(doc.Marshal())
+
+		// from the output with its prefixes, and from xml.Marshal
+		for _, data := range [][]byte{want, must(xml.Marshal(doc))} {
+			var got ubl.Document
+			if err := xml.Unmarshal(data, &got); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got.Namespaces = doc.Namespaces
+			if !bytes.Equal(must(got.Marshal()), want) {
+				t.Errorf("%s: expected the same document after a round trip, got %s", name, must(got.Marshal()))
+			}
+		}
+	}
+
+	var doc ubl.Document
+	err := xml.Unmarshal([]byte(`<Order xmlns="urn:oasis:names:specification:ubl:schema:xsd:Order-2"/>`), &doc)
+	if err == nil || err.Error() != `document: Order in namespace "urn:oasis:names:specification:ubl:schema:xsd:Order-2" is no UBL invoice or credit note` {
+		t.Errorf("expected an error for an order, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package ubl
+
+import (
+	"maps"
+	"strconv"
+)
+
+// EffectiveValues returns the values that went into the last generated
+// invoice, after the defaults were applied (issue date, currency, tax
+// categories, payment means, ...). The keys are stable paths like
+// "IssueDate" or "Lines[0].TaxCategory.ID", so the map can be logged as is.
+// Each payment means has its own keys, e.g. "PaymentMeans[1].AccountID"; the
+// first is also under "PaymentMeans.Code", "PaymentMeans.Name" and
+// "PaymentMeans.AccountID", the keys from before there could be several.
+// It is nil before the first Generate.
+func (inv *Invoice) EffectiveValues() map[string]any {
+	return inv.effective.values()
+}
+
+// EffectiveValues returns the values that went into the last generated
+// credit note, like Invoice.EffectiveValues.
+func (cn *CreditNote) EffectiveValues() map[string]any {
+	return cn.effective.values()
+}
+
+// effectiveValues are kept from Generate until EffectiveValues asks for
+// them. The lines stay in a slice until then: in the map, six boxed values
+// with their keys per line would weigh more than the generated document.
+type effectiveValues struct {
+	document map[string]any
+	lines    []effectiveLine
+}
+
+type effectiveLine struct {
+	name        string
+	quantity    float64
+	unitCode    string
+	amount      float64
+	taxCategory string
+	percent     float64
+}
+
+func (e effectiveValues) values() map[string]any {
+	if e.document == nil {
+		return nil
+	}
+	m := maps.Clone(e.document)
+	for i, line := range e.lines {
+		prefix := "Lines[" + strconv.Itoa(i) + "]."
+		m[prefix+"Name"] = line.name
+		m[prefix+"Quantity"] = line.quantity
+		m[prefix+"UnitCode"] = line.unitCode
+		m[prefix+"LineExtensionAmount"] = line.amount
+		m[prefix+"TaxCategory.ID"] = line.taxCategory
+		m[prefix+"TaxCategory.Percent"] = line.percent
+	}
+	return m
+}
+
+func (x *XMLInvoice) effectiveValues() effectiveValues {
+	m := map[string]any{
+		"CustomizationID":      x.CustomizationID,
+		"ProfileID":            x.ProfileID,
+		"ID":                   x.ID,
+		"UUID":                 x.UUID,
+		"IssueDate":            x.IssueDate,
+		"DueDate":              x.DueDate,
+		"TypeCode":             x.InvoiceTypeCode,
+		"DocumentCurrencyCode": x.DocumentCurrency,
+		"BuyerReference":       x.BuyerReference,
+	}
+	putDocumentValues(m, x.InvoicePeriod, x.OrderReference, x.SupplierParty.Party, x.CustomerParty.Party, x.PaymentMeans, x.TaxTotal, x.LegalMonetaryTotal)
+	putBillingReferenceValues(m, x.BillingReference)
+	lines := make([]effectiveLine, len(x.InvoiceLines))
+	for i, line := range x.InvoiceLines {
+		lines[i] = newEffectiveLine(line.InvoicedQuantity, line.LineExtensionAmount, line.Item)
+	}
+	return effectiveValues{m, lines}
+}
+
+func (x *XMLCreditNote) effectiveValues() effectiveValues {
+	m := map[string]any{
+		"CustomizationID":      x.CustomizationID,
+		"ProfileID":            x.ProfileID,
+		"ID":                   x.ID,
+		"UUID":                 x.UUID,
+		"IssueDate":            x.IssueDate,
+		"TypeCode":             x.CreditNoteTypeCode,
+		"DocumentCurrencyCode": x.DocumentCurrency,
+		"BuyerReference":       x.BuyerReference,
+	}
+	putDocumentValues(m, x.InvoicePeriod, x.OrderReference, x.SupplierParty.Party, x.CustomerParty.Party, x.PaymentMeans, x.TaxTotal, x.LegalMonetaryTotal)
+	putBillingReferenceValues(m, x.BillingReference)
+	lines := make([]effectiveLine, len(x.CreditNoteLines))
+	for i, line := range x.CreditNoteLines {
+		lines[i] = newEffectiveLine(line.CreditedQuantity, line.LineExtensionAmount, line.Item)
+	}
+	return effectiveValues{m, lines}
+}
+
+func putDocumentValues(m map[string]any, period *XMLInvoicePeriod, orderRef *XMLOrderReference, supplier, customer XMLParty, means []XMLPaymentMeans, taxTotal XMLTaxTotal, mt XMLMonetaryTotal) {
+	if period != nil {
+		m["InvoicePeriod.StartDate"] = period.StartDate
+		m["InvoicePeriod.EndDate"] = period.EndDate
+	}
+	if orderRef != nil {
+		m["OrderReference.ID"] = orderRef.ID
+		m["OrderReference.SalesOrderID"] = orderRef.SalesOrderID
+	}
+	putPartyValues(m, "Supplier", supplier)
+	putPartyValues(m, "Customer", customer)
+
+	for i, pm := range means {
+		prefix := "PaymentMeans[" + strconv.Itoa(i) + "]."
+		m[prefix+"Code"] = pm.PaymentMeansCode.Value
+		m[prefix+"Name"] = pm.PaymentMeansCode.Name
+		if pm.PayeeFinancialAccount != nil {
+			m[prefix+"AccountID"] = pm.PayeeFinancialAccount.ID
+		}
+		if pm.PaymentMandate != nil {
+			m[prefix+"MandateID"] = pm.PaymentMandate.ID
+		}
+	}
+	// aliases for the keys of the single payment means of older versions
+	for _, key := range []string{"Code", "Name", "AccountID"} {
+		if v, ok := m["PaymentMeans[0]."+key]; ok {
+			m["PaymentMeans."+key] = v
+		}
+	}
+
+	for i, st := range taxTotal.TaxSubtotal {
+		prefix := "TaxSubtotals[" + strconv.Itoa(i) + "]."
+		m[prefix+"TaxCategory.ID"] = st.TaxCategory.ID
+		m[prefix+"TaxCategory.Percent"] = st.TaxCategory.rate()
+		m[prefix+"TaxCategory.TaxExemptionReasonCode"] = st.TaxCategory.TaxExemptionReasonCode
+		m[prefix+"TaxableAmount"] = st.TaxableAmount.Value
+		m[prefix+"TaxAmount"] = st.TaxAmount.Value
+	}
+
+	m["Totals.LineExtensionAmount"] = mt.LineExtensionAmount.Value
+	m["Totals.TaxExclusiveAmount"] = mt.TaxExclusiveAmount.Value
+	m["Totals.TaxAmount"] = taxTotal.TaxAmount.Value
+	m["Totals.TaxInclusiveAmount"] = mt.TaxInclusiveAmount.Value
+	m["Totals.PayableAmount"] = mt.PayableAmount.Value
+	if mt.AllowanceTotalAmount != nil {
+		m["Totals.AllowanceTotalAmount"] = mt.AllowanceTotalAmount.Value
+	}
+	if mt.ChargeTotalAmount != nil {
+		m["Totals.ChargeTotalAmount"] = mt.ChargeTotalAmount.Value
+	}
+	if mt.PayableRoundingAmount != nil {
+		m["Totals.PayableRoundingAmount"] = mt.PayableRoundingAmount.Value
+	}
+}
+
+func putBillingReferenceValues(m map[string]any, refs []XMLBillingReference) {
+	for i, ref := range refs {
+		prefix := "BillingReference[" + strconv.Itoa(i) + "]."
+		m[prefix+"ID"] = ref.InvoiceDocumentReference.ID
+		m[prefix+"IssueDate"] = ref.InvoiceDocumentReference.IssueDate
+	}
+}
+
+func putPartyValues(m map[string]any, prefix string, p XMLParty) {
+	m[prefix+".EndpointID"] = p.EndpointID.SchemeID + ":" + p.EndpointID.Value
+	m[prefix+".Name"] = p.PartyName
+	m[prefix+".RegistrationName"] = p.PartyLegalEntity.RegistrationName
+	m[prefix+".CountryCode"] = p.PostalAddress.Country.IdentificationCode
+	for i, ts := range p.PartyTaxScheme {
+		ps := prefix + ".TaxScheme[" + strconv.Itoa(i) + "]."
+		m[ps+"CompanyID"] = ts.CompanyID
+		m[ps+"ID"] = ts.TaxScheme.ID
+	}
+}
+
+func newEffectiveLine(quantity XMLQuantity, amount XMLAmount, item XMLItem) effectiveLine {
+	return effectiveLine{
+		name:        item.Name,
+		quantity:    quantity.Value,
+		unitCode:    quantity.UnitCode,
+		amount:      amount.Value,
+		taxCategory: item.ClassifiedTaxCategory.ID,
+		percent:     item.ClassifiedTaxCategory.rate(),
+	}
+}
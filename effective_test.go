@@ -0,0 +1,67 @@
+package ubl_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveValues(t *testing.T) {
+	inv := newTestInvoice()
+	if inv.EffectiveValues() != nil {
+		t.Error("expected no effective values before Generate")
+	}
+
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := compact(xmlBytes)
+	values := inv.EffectiveValues()
+
+	// Spot-check the defaulted values against the XML.
+	checks := []struct {
+		key  string
+		want string
+	}{
+		{"IssueDate", "<cbc:IssueDate>%v</cbc:IssueDate>"},
+		{"DocumentCurrencyCode", "<cbc:DocumentCurrencyCode>%v</cbc:DocumentCurrencyCode>"},
+		{"PaymentMeans[0].Code", "<cbc:PaymentMeansCode>%v</cbc:PaymentMeansCode>"},
+		{"Lines[0].TaxCategory.ID", "<cac:ClassifiedTaxCategory><cbc:ID>%v</cbc:ID>"},
+		{"Lines[0].UnitCode", `<cbc:InvoicedQuantity unitCode="%v">`},
+		{"Totals.PayableAmount", `<cbc:PayableAmount currencyID="EUR">%.2f</cbc:PayableAmount>`},
+	}
+	for _, c := range checks {
+		v, ok := values[c.key]
+		if !ok {
+			t.Errorf("missing key %s", c.key)
+			continue
+		}
+		if want := fmt.Sprintf(c.want, v); !strings.Contains(doc, want) {
+			t.Errorf("%s: %v not found in the XML as %s", c.key, v, want)
+		}
+	}
+	if values["DocumentCurrencyCode"] != "EUR" || values["PaymentMeans[0].Code"] != "30" || values["Lines[0].TaxCategory.ID"] != "S" {
+		t.Errorf("unexpected defaults %v", values)
+	}
+
+	for _, key := range []string{"Code", "Name", "AccountID"} {
+		if v, ok := values["PaymentMeans."+key]; !ok || v != values["PaymentMeans[0]."+key] {
+			t.Errorf("expected PaymentMeans.%s to be that of the first payment means, got %v", key, v)
+		}
+	}
+
+	values["ID"] = "changed"
+	if inv.EffectiveValues()["ID"] != "INV-12345" {
+		t.Error("expected EffectiveValues to return a copy")
+	}
+
+	cn := newTestCreditNote()
+	_, err = cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := cn.EffectiveValues(); v["ID"] != "CN-12345" || v["TypeCode"] != "381" {
+		t.Errorf("unexpected credit note values %v", v)
+	}
+}
@@ -0,0 +1,159 @@
+package ubl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const extNamespace = "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2"
+
+// xmlNamespace is the namespace of the xml prefix, which is always declared.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Extension is a UBL extension, e.g. a signature container required by a
+// national infrastructure. Peppol BIS doesn't allow extensions.
+type Extension struct {
+	ID      string `json:"id,omitempty"`      // Optional: identifies the extension
+	Content []byte `json:"content,omitempty"` // the XML inside ext:ExtensionContent, written as is: one element in its own namespace
+}
+
+// XMLUBLExtensions is ext:UBLExtensions.
+type XMLUBLExtensions struct {
+	UBLExtension []XMLUBLExtension `xml:"ext:UBLExtension"`
+}
+
+// XMLUBLExtension is ext:UBLExtension.
+type XMLUBLExtension struct {
+	ID               string              `xml:"cbc:ID,omitempty"`
+	ExtensionURI     string              `xml:"ext:ExtensionURI,omitempty"`
+	ExtensionContent XMLExtensionContent `xml:"ext:ExtensionContent"`
+}
+
+// XMLExtensionContent is ext:ExtensionContent, the content of an Extension.
+type XMLExtensionContent struct {
+	Content []byte `xml:",innerxml"`
+}
+
+// ublExtensions returns the ext:UBLExtensions element, or nil without
+// extensions.
+func ublExtensions(extensions []Extension) *XMLUBLExtensions {
+	if len(extensions) == 0 {
+		return nil
+	}
+	ext := &XMLUBLExtensions{}
+	for _, e := range extensions {
+		ext.UBLExtension = append(ext.UBLExtension, XMLUBLExtension{
+			ID:               e.ID,
+			ExtensionContent: XMLExtensionContent{e.Content},
+		})
+	}
+	return ext
+}
+
+// extensionsNamespace returns the ext namespace to declare on the root
+// element when there are extensions.
+func extensionsNamespace(extensions *XMLUBLExtensions) string {
+	if extensions == nil {
+		return ""
+	}
+	return extNamespace
+}
+
+// UnmarshalXML reads the content back as XML, keeping the prefixes of the
+// input. Empty elements are written with an end tag.
+func (c *XMLExtensionContent) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	for depth := 0; ; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			// the names are prefixed by Document.UnmarshalXML
+			t.Name.Space = ""
+			tok = t
+		case xml.EndElement:
+			if depth == 0 {
+				err = enc.Flush()
+				c.Content = buf.Bytes()
+				return err
+			}
+			depth--
+			t.Name.Space = ""
+			tok = t
+		}
+		err = enc.EncodeToken(tok)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// check checks that the content is a single well-formed element that
+// declares its namespace, as the schema requires, and that every prefix in it
+// is declared. The decoder leaves an undeclared prefix in Name.Space, so the
+// namespaces in scope are tracked to tell the two apart.
+func (e Extension) check() error {
+	dec := xml.NewDecoder(bytes.NewReader(e.Content))
+	var elements, depth int
+	scopes := [][]string{{xmlNamespace}}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("content: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				elements++
+				switch t.Name.Space {
+				case "":
+					return fmt.Errorf("content: element %s has no namespace", t.Name.Local)
+				case extNamespace:
+					return fmt.Errorf("content: element %s in the extension namespace itself", t.Name.Local)
+				}
+			}
+			scope := scopes[len(scopes)-1]
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					scope = append(scope[:len(scope):len(scope)], attr.Value)
+				}
+			}
+			scopes = append(scopes, scope)
+			if t.Name.Space != "" && !slices.Contains(scope, t.Name.Space) {
+				return fmt.Errorf("content: element %s has the undeclared prefix %s", t.Name.Local, t.Name.Space)
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space != "" && attr.Name.Space != "xmlns" && !slices.Contains(scope, attr.Name.Space) {
+					return fmt.Errorf("content: attribute %s has the undeclared prefix %s", attr.Name.Local, attr.Name.Space)
+				}
+			}
+			depth++
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(t)) > 0 {
+				return errors.New("content: text outside the element")
+			}
+		case xml.ProcInst:
+			if t.Target == "xml" {
+				return errors.New("content: XML declaration not allowed")
+			}
+		}
+	}
+	if elements != 1 {
+		return fmt.Errorf("content: %d elements, one required", elements)
+	}
+	return nil
+}
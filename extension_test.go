@@ -0,0 +1,109 @@
+package ubl_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+const testExtension = `<r:Routing xmlns:r="urn:example:routing"><r:Channel xmlns:m="urn:example:meta" m:priority="high" xml:lang="en">EDI</r:Channel></r:Routing>`
+
+func TestExtensions(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Extensions = []ubl.Extension{
+		{ID: "routing", Content: []byte(testExtension)},
+		{Content: []byte(`<Custom xmlns="urn:example:custom"><Value a="1">x &amp; y</Value></Custom>`)},
+	}
+	xmlBytes := generateAndValidate(t, &inv)
+	for _, s := range []string{
+		` xmlns:ext="urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2">` + "\n  <ext:UBLExtensions>\n    <ext:UBLExtension>\n      <cbc:ID>routing</cbc:ID>\n      <ext:ExtensionContent>" + testExtension + "</ext:ExtensionContent>",
+		`<ext:ExtensionContent><Custom xmlns="urn:example:custom"><Value a="1">x &amp; y</Value></Custom></ext:ExtensionContent>`,
+		"</ext:UBLExtensions>\n  <cbc:CustomizationID>",
+	} {
+		if !bytes.Contains(xmlBytes, []byte(s)) {
+			t.Errorf("expected %s in output", s)
+		}
+	}
+
+	// the extension content keeps its namespaces with other prefixes
+	want := resolvedTokens(t, xmlBytes)
+	inv.Namespaces = ubl.XMLNamespaces{Document: "inv", CBC: "bc"}
+	rewritten := generateAndValidate(t, &inv)
+	if got := resolvedTokens(t, rewritten); !slices.Equal(got, want) {
+		t.Errorf("expected the same namespaced elements with other prefixes, got %s", rewritten)
+	}
+	inv.Namespaces = ubl.XMLNamespaces{CAC: "ext"}
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `Namespaces.CAC "ext": already the prefix of the UBL extensions` {
+		t.Errorf("expected an error for the ext prefix, got %v", err)
+	}
+	inv.Namespaces = ubl.XMLNamespaces{}
+
+	canonical, err := inv.GenerateCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(canonical, []byte(`<r:Routing xmlns:r="urn:example:routing"><r:Channel xmlns:m="urn:example:meta" xml:lang="en" m:priority="high">`)) {
+		t.Error("expected the namespaces declared where they're used in canonical form")
+	}
+
+	cn := newTestCreditNote()
+	cn.Extensions = inv.Extensions[:1]
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	if err := v.ValidateBytes(cnBytes); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Contains(cnBytes, []byte("<ext:ExtensionContent>"+testExtension)) {
+		t.Error("expected the extension in the credit note")
+	}
+
+	inv = newTestInvoice()
+	if bytes.Contains(must(inv.Generate()), []byte("ext")) {
+		t.Error("expected no extension namespace without extensions")
+	}
+}
+
+func TestExtensionsCheck(t *testing.T) {
+	for _, c := range []struct {
+		content string
+		want    string
+	}{
+		{"", "Extensions[0]: content: 0 elements, one required"},
+		{`<a:A xmlns:a="urn:a"/><a:B xmlns:a="urn:a"/>`, "Extensions[0]: content: 2 elements, one required"},
+		{"<Custom/>", "Extensions[0]: content: element Custom has no namespace"},
+		{`<a:A xmlns:a="urn:a">`, "Extensions[0]: content: XML syntax error on line 1: unexpected EOF"},
+		{`text <a:A xmlns:a="urn:a"/>`, "Extensions[0]: content: text outside the element"},
+		{`<?xml version="1.0"?><a:A xmlns:a="urn:a"/>`, "Extensions[0]: content: XML declaration not allowed"},
+		{`<sig:Signature xmlns="urn:a"/>`, "Extensions[0]: content: element Signature has the undeclared prefix sig"},
+		{`<a:A xmlns:a="urn:a"><a:B/><sig:Value/></a:A>`, "Extensions[0]: content: element Value has the undeclared prefix sig"},
+		{`<a:A xmlns:a="urn:a"><b:B xmlns:b="urn:b"/><b:C/></a:A>`, "Extensions[0]: content: element C has the undeclared prefix b"},
+		{`<a:A xmlns:a="urn:a" sig:id="1"/>`, "Extensions[0]: content: attribute id has the undeclared prefix sig"},
+		{`<ext:UBLExtension xmlns:ext="urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2"/>`, "Extensions[0]: content: element UBLExtension in the extension namespace itself"},
+	} {
+		inv := newTestInvoice()
+		inv.Extensions = []ubl.Extension{{Content: []byte(c.content)}}
+		err := inv.Check()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: expected %q, got %v", c.content, c.want, err)
+		}
+	}
+
+	// prefixes declared on an ancestor and the xml prefix are in scope
+	inv := newTestInvoice()
+	inv.Extensions = []ubl.Extension{{Content: []byte(`<a:A xmlns:a="urn:a" xml:lang="en"><b:B xmlns:b="urn:b" b:x="1"><a:C/></b:B></a:A>`)}}
+	if err := inv.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,332 @@
+package ubl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DocumentType is the UBL root element of a document.
+type DocumentType string
+
+const (
+	DocumentTypeInvoice    DocumentType = "Invoice"
+	DocumentTypeCreditNote DocumentType = "CreditNote"
+)
+
+// Totals are the document level amounts (BG-22), together with the VAT
+// breakdown (BG-23) and the document allowances and charges as they were
+// computed.
+type Totals struct {
+	Currency         string
+	LineExtension    float64
+	AllowanceTotal   float64
+	ChargeTotal      float64
+	TaxExclusive     float64
+	Tax              float64
+	TaxInclusive     float64
+	Rounding         float64 // BT-114, for lines that include VAT, see InvoiceLine.PriceIncludesTax
+	Payable          float64
+	Breakdown        []TaxBreakdown
+	AllowanceCharges []AllowanceCharge
+}
+
+// TaxBreakdown is the VAT breakdown of one category and rate. The taxable
+// amount is the line amount minus the allowances plus the charges.
+type TaxBreakdown struct {
+	CategoryID      string
+	Percent         float64
+	LineAmount      float64
+	AllowanceAmount float64
+	ChargeAmount    float64
+	TaxableAmount   float64
+	TaxAmount       float64
+}
+
+// Attachment is a document embedded in an AdditionalDocumentReference.
+// Data is base64 encoded only while the document is written.
+type Attachment struct {
+	ID               string `json:"id"`
+	IDScheme         string `json:"idScheme,omitempty"`         // Optional: schemeID of the ID, e.g. as agreed with the buyer
+	DocumentTypeCode string `json:"documentTypeCode,omitempty"` // Optional: UNCL1001 code of the document, e.g. "130"
+	Description      string `json:"description,omitempty"`
+	Filename         string `json:"filename,omitempty"`
+	MimeCode         string `json:"mimeCode,omitempty"`
+	Data             []byte `json:"data,omitempty"`
+	URI              string `json:"uri,omitempty"` // the location of an external reference, which has no Data
+}
+
+// GeneratedDocument is the immutable result of a generation: the XML bytes
+// together with the metadata downstream code usually needs, so the XML
+// doesn't have to be parsed again.
+type GeneratedDocument struct {
+	data            []byte
+	docType         DocumentType
+	id              string
+	issueDate       string
+	totals          Totals
+	fingerprint     string
+	customizationID string
+	profileID       string
+	senderID        string
+	receiverID      string
+	senderCountry   string
+}
+
+func newGeneratedDocument(data []byte, docType DocumentType, id, issueDate string, totals Totals, customizationID, profileID, senderID, receiverID, senderCountry string) GeneratedDocument {
+	sum := sha256.Sum256(data)
+	return GeneratedDocument{
+		data:            data,
+		docType:         docType,
+		id:              id,
+		issueDate:       issueDate,
+		totals:          totals,
+		fingerprint:     hex.EncodeToString(sum[:]),
+		customizationID: customizationID,
+		profileID:       profileID,
+		senderID:        senderID,
+		receiverID:      receiverID,
+		senderCountry:   senderCountry,
+	}
+}
+
+// GenerateDocument generates the invoice like Generate and returns it with its
+// metadata.
+func (inv *Invoice) GenerateDocument() (GeneratedDocument, error) {
+	document, err := inv.BuildDocument()
+	if err != nil {
+		return GeneratedDocument{}, err
+	}
+	data, err := document.Marshal()
+	if err != nil {
+		return GeneratedDocument{}, err
+	}
+	doc := document.Invoice
+	return newGeneratedDocument(data, DocumentTypeInvoice, doc.ID, doc.IssueDate,
+		inv.totals,
+		doc.CustomizationID, doc.ProfileID, doc.SupplierParty.Party.EndpointID.participantID(), doc.CustomerParty.Party.EndpointID.participantID(),
+		inv.SupplierAddress.CountryCode), nil
+}
+
+// GenerateCreditNoteDocument generates the credit note like
+// GenerateCreditNote and returns it with its metadata.
+func (cn *CreditNote) GenerateCreditNoteDocument() (GeneratedDocument, error) {
+	document, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return GeneratedDocument{}, err
+	}
+	data, err := document.Marshal()
+	if err != nil {
+		return GeneratedDocument{}, err
+	}
+	doc := document.CreditNote
+	return newGeneratedDocument(data, DocumentTypeCreditNote, doc.ID, doc.IssueDate,
+		cn.totals,
+		doc.CustomizationID, doc.ProfileID, doc.SupplierParty.Party.EndpointID.participantID(), doc.CustomerParty.Party.EndpointID.participantID(),
+		cn.SupplierAddress.CountryCode), nil
+}
+
+// Bytes returns a copy of the XML document.
+func (d GeneratedDocument) Bytes() []byte {
+	return bytes.Clone(d.data)
+}
+
+func (d GeneratedDocument) Type() DocumentType {
+	return d.docType
+}
+
+func (d GeneratedDocument) ID() string {
+	return d.id
+}
+
+// IssueDate returns the issue date as written in the document (YYYY-MM-DD).
+func (d GeneratedDocument) IssueDate() string {
+	return d.issueDate
+}
+
+// Totals returns the totals of the document, with copies of the breakdown
+// and the allowances and charges.
+func (d GeneratedDocument) Totals() Totals {
+	totals := d.totals
+	totals.Breakdown = slices.Clone(totals.Breakdown)
+	totals.AllowanceCharges = slices.Clone(totals.AllowanceCharges)
+	return totals
+}
+
+// Fingerprint returns the hex encoded SHA-256 of the XML document.
+func (d GeneratedDocument) Fingerprint() string {
+	return d.fingerprint
+}
+
+func (d GeneratedDocument) CustomizationID() string {
+	return d.customizationID
+}
+
+func (d GeneratedDocument) ProfileID() string {
+	return d.profileID
+}
+
+// Validate validates the document again, e.g. with a *validate.Validate.
+func (d GeneratedDocument) Validate(v interface{ ValidateBytes([]byte) error }) error {
+	return v.ValidateBytes(d.data)
+}
+
+// Attachments returns the embedded documents, decoded, and the external
+// references.
+func (d GeneratedDocument) Attachments() ([]Attachment, error) {
+	var doc struct {
+		AdditionalDocumentReference []struct {
+			ID struct {
+				Value    string `xml:",chardata"`
+				SchemeID string `xml:"schemeID,attr"`
+			} `xml:"ID"`
+			DocumentTypeCode    string `xml:"DocumentTypeCode"`
+			DocumentDescription string `xml:"DocumentDescription"`
+			Attachment          []struct {
+				EmbeddedDocumentBinaryObject struct {
+					Value    string `xml:",chardata"`
+					MimeCode string `xml:"mimeCode,attr"`
+					Filename string `xml:"filename,attr"`
+				} `xml:"EmbeddedDocumentBinaryObject"`
+				URI string `xml:"ExternalReference>URI"`
+			} `xml:"Attachment"`
+		} `xml:"AdditionalDocumentReference"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(d.data))
+	dec.CharsetReader = charsetReader
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("xml unmarshal failed: %w", err)
+	}
+
+	var attachments []Attachment
+	for _, ref := range doc.AdditionalDocumentReference {
+		for _, a := range ref.Attachment {
+			if a.URI != "" {
+				attachments = append(attachments, Attachment{ID: ref.ID.Value, IDScheme: ref.ID.SchemeID, DocumentTypeCode: ref.DocumentTypeCode, Description: ref.DocumentDescription, URI: a.URI})
+				continue
+			}
+			obj := a.EmbeddedDocumentBinaryObject
+			if obj.Value == "" {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(obj.Value)
+			if err != nil {
+				return nil, fmt.Errorf("decode attachment %v: %w", obj.Filename, err)
+			}
+			attachments = append(attachments, Attachment{
+				ID:               ref.ID.Value,
+				IDScheme:         ref.ID.SchemeID,
+				DocumentTypeCode: ref.DocumentTypeCode,
+				Description:      ref.DocumentDescription,
+				Filename:         obj.Filename,
+				MimeCode:         obj.MimeCode,
+				Data:             data,
+			})
+		}
+	}
+	return attachments, nil
+}
+
+// Standard Business Document Header as used by the Peppol transport
+// infrastructure: https://docs.peppol.eu/edelivery/envelope/
+type xmlSBD struct {
+	XMLName xml.Name `xml:"StandardBusinessDocument"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Header  xmlSBDH  `xml:"StandardBusinessDocumentHeader"`
+	Inner   []byte   `xml:",innerxml"`
+}
+
+type xmlSBDH struct {
+	HeaderVersion          string                `xml:"HeaderVersion"`
+	Sender                 xmlSBDHParty          `xml:"Sender"`
+	Receiver               xmlSBDHParty          `xml:"Receiver"`
+	DocumentIdentification xmlSBDHIdentification `xml:"DocumentIdentification"`
+	Scopes                 []xmlSBDHScope        `xml:"BusinessScope>Scope"`
+}
+
+type xmlSBDHParty struct {
+	Identifier xmlSBDHIdentifier `xml:"Identifier"`
+}
+
+type xmlSBDHIdentifier struct {
+	Value     string `xml:",chardata"`
+	Authority string `xml:"Authority,attr"`
+}
+
+type xmlSBDHIdentification struct {
+	Standard            string `xml:"Standard"`
+	TypeVersion         string `xml:"TypeVersion"`
+	InstanceIdentifier  string `xml:"InstanceIdentifier"`
+	Type                string `xml:"Type"`
+	CreationDateAndTime string `xml:"CreationDateAndTime"`
+}
+
+type xmlSBDHScope struct {
+	Type               string `xml:"Type"`
+	InstanceIdentifier string `xml:"InstanceIdentifier"`
+	Identifier         string `xml:"Identifier,omitempty"`
+}
+
+// SBDH wraps the document in a Peppol Standard Business Document Header.
+// instanceID must uniquely identify this transmission.
+func (d GeneratedDocument) SBDH(instanceID string, created time.Time) ([]byte, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("sbdh: instance identifier is required")
+	}
+
+	if encoding := declarationEncoding(d.data); encoding != "" && !strings.EqualFold(encoding, "UTF-8") {
+		return nil, fmt.Errorf("sbdh: document encoded in %s, the header needs UTF-8", encoding)
+	}
+	// the wrapped document must not carry its own XML declaration
+	inner := d.data
+	if bytes.HasPrefix(inner, []byte("<?xml ")) {
+		_, inner, _ = bytes.Cut(inner, []byte("?>\n"))
+	}
+
+	standard := "urn:oasis:names:specification:ubl:schema:xsd:" + string(d.docType) + "-2"
+	sbd := xmlSBD{
+		Xmlns: "http://www.unece.org/cefact/namespaces/StandardBusinessDocumentHeader",
+		Header: xmlSBDH{
+			HeaderVersion: "1.0",
+			Sender:        xmlSBDHParty{Identifier: xmlSBDHIdentifier{Value: d.senderID, Authority: "iso6523-actorid-upis"}},
+			Receiver:      xmlSBDHParty{Identifier: xmlSBDHIdentifier{Value: d.receiverID, Authority: "iso6523-actorid-upis"}},
+			DocumentIdentification: xmlSBDHIdentification{
+				Standard:            standard,
+				TypeVersion:         "2.1",
+				InstanceIdentifier:  instanceID,
+				Type:                string(d.docType),
+				CreationDateAndTime: created.Format(time.RFC3339),
+			},
+			Scopes: []xmlSBDHScope{
+				{
+					Type:               "DOCUMENTID",
+					InstanceIdentifier: standard + "::" + string(d.docType) + "##" + d.customizationID + "::2.1",
+					Identifier:         "busdox-docid-qns",
+				},
+				{
+					Type:               "PROCESSID",
+					InstanceIdentifier: d.profileID,
+					Identifier:         "cenbii-procid-ubl",
+				},
+				{
+					Type:               "COUNTRY_C1",
+					InstanceIdentifier: d.senderCountry,
+				},
+			},
+		},
+		Inner: inner,
+	}
+
+	output, err := xml.MarshalIndent(sbd, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("xml marshal failed: %w", err)
+	}
+	return []byte(xml.Header + string(output)), nil
+}
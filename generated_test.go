@@ -0,0 +1,182 @@
+package ubl_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestGenerateDocument(t *testing.T) {
+	inv := newTestInvoice()
+	inv.PdfInvoiceFilename = "invoice_test.pdf"
+
+	doc, err := inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := doc.Bytes()
+	sum := sha256.Sum256(data)
+	if doc.Fingerprint() != hex.EncodeToString(sum[:]) {
+		t.Errorf("fingerprint %v does not match the bytes", doc.Fingerprint())
+	}
+
+	var parsed struct {
+		ID                 string `xml:"ID"`
+		IssueDate          string `xml:"IssueDate"`
+		ProfileID          string `xml:"ProfileID"`
+		DocumentCurrency   string `xml:"DocumentCurrencyCode"`
+		LegalMonetaryTotal struct {
+			PayableAmount float64 `xml:"PayableAmount"`
+		} `xml:"LegalMonetaryTotal"`
+	}
+	err = xml.Unmarshal(data, &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Type() != ubl.DocumentTypeInvoice {
+		t.Errorf("expected type Invoice, got %v", doc.Type())
+	}
+	if doc.ID() != parsed.ID || doc.IssueDate() != parsed.IssueDate || doc.ProfileID() != parsed.ProfileID {
+		t.Errorf("metadata %v/%v/%v does not match the bytes %+v", doc.ID(), doc.IssueDate(), doc.ProfileID(), parsed)
+	}
+	totals := doc.Totals()
+	if totals.Payable != parsed.LegalMonetaryTotal.PayableAmount || totals.Payable != 1210 || totals.Currency != parsed.DocumentCurrency {
+		t.Errorf("totals %+v do not match the bytes %+v", totals, parsed)
+	}
+
+	// the document can't be modified through the returned bytes
+	data[0] = 'X'
+	if doc.Bytes()[0] == 'X' {
+		t.Error("modifying the returned bytes changed the document")
+	}
+
+	// nor through the returned totals
+	inv.AllowanceCharges = []ubl.AllowanceCharge{{Amount: 10, Reason: "Discount"}}
+	doc, err = inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals = doc.Totals()
+	totals.Breakdown[0].TaxAmount = 0
+	totals.AllowanceCharges[0].Amount = 0
+	totals = doc.Totals()
+	if totals.Breakdown[0].TaxAmount == 0 || totals.AllowanceCharges[0].Amount != 10 {
+		t.Errorf("modifying the returned totals changed the document: %+v", totals)
+	}
+
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	err = doc.Validate(v)
+	if err != nil {
+		t.Error(err)
+	}
+
+	attachments, err := doc.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf, err := os.ReadFile("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 1 || !bytes.Equal(attachments[0].Data, pdf) || attachments[0].Filename != "invoice_test.pdf" {
+		t.Errorf("expected the pdf as the only attachment, got %d attachments", len(attachments))
+	}
+
+	sbdh, err := doc.SBDH("c4a1f1a2-1d2b-4c1e-9a8f-0d2f4f1c3e21", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<Identifier Authority="iso6523-actorid-upis">9925:BE0123456789</Identifier>`,
+		`<Identifier Authority="iso6523-actorid-upis">9925:BE9876543210</Identifier>`,
+		`<CreationDateAndTime>2025-01-02T03:04:05Z</CreationDateAndTime>`,
+		`<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"`,
+	} {
+		if !strings.Contains(string(sbdh), want) {
+			t.Errorf("expected %s in the SBDH", want)
+		}
+	}
+	if strings.Count(string(sbdh), "<?xml") != 1 {
+		t.Error("expected exactly one XML declaration in the SBDH")
+	}
+}
+
+func TestTotals(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = append(inv.Lines,
+		ubl.InvoiceLine{Quantity: 3, Price: 9.99, TaxPercentage: 6, Name: "Book"},
+		ubl.InvoiceLine{Quantity: 1, Price: 12.5, TaxCategoryID: "Z", TaxCategoryName: "Zero rated", Name: "Newspaper"})
+	inv.AllowanceCharges = []ubl.AllowanceCharge{{Percentage: 5, Reason: "Loyalty discount"}}
+
+	totals, err := inv.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		TaxTotal struct {
+			TaxAmount   float64 `xml:"TaxAmount"`
+			TaxSubtotal []struct {
+				TaxableAmount float64 `xml:"TaxableAmount"`
+				TaxAmount     float64 `xml:"TaxAmount"`
+				TaxCategory   struct {
+					ID      string  `xml:"ID"`
+					Percent float64 `xml:"Percent"`
+				} `xml:"TaxCategory"`
+			} `xml:"TaxSubtotal"`
+		} `xml:"TaxTotal"`
+		LegalMonetaryTotal struct {
+			LineExtensionAmount  float64 `xml:"LineExtensionAmount"`
+			TaxExclusiveAmount   float64 `xml:"TaxExclusiveAmount"`
+			TaxInclusiveAmount   float64 `xml:"TaxInclusiveAmount"`
+			AllowanceTotalAmount float64 `xml:"AllowanceTotalAmount"`
+			PayableAmount        float64 `xml:"PayableAmount"`
+		} `xml:"LegalMonetaryTotal"`
+	}
+	err = xml.Unmarshal(generateAndValidate(t, &inv), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := parsed.LegalMonetaryTotal
+	if totals.LineExtension != mt.LineExtensionAmount || totals.TaxExclusive != mt.TaxExclusiveAmount ||
+		totals.TaxInclusive != mt.TaxInclusiveAmount || totals.AllowanceTotal != mt.AllowanceTotalAmount ||
+		totals.Payable != mt.PayableAmount || totals.Tax != parsed.TaxTotal.TaxAmount {
+		t.Errorf("totals %+v do not match the XML %+v", totals, mt)
+	}
+	if len(totals.Breakdown) != len(parsed.TaxTotal.TaxSubtotal) {
+		t.Fatalf("expected %d breakdown rows, got %d", len(parsed.TaxTotal.TaxSubtotal), len(totals.Breakdown))
+	}
+	for i, row := range totals.Breakdown {
+		st := parsed.TaxTotal.TaxSubtotal[i]
+		if row.CategoryID != st.TaxCategory.ID || row.Percent != st.TaxCategory.Percent ||
+			row.TaxableAmount != st.TaxableAmount || row.TaxAmount != st.TaxAmount {
+			t.Errorf("breakdown row %d %+v does not match the XML %+v", i, row, st)
+		}
+	}
+
+	cn := newTestCreditNote()
+	cnTotals, err := cn.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := cn.GenerateCreditNoteDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnTotals.Payable != doc.Totals().Payable || cnTotals.Tax != doc.Totals().Tax {
+		t.Errorf("credit note totals %+v do not match the generated %+v", cnTotals, doc.Totals())
+	}
+}
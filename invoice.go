@@ -3,56 +3,144 @@
 // This is needed for Peppol: https://docs.peppol.eu/poacc/billing/3.0/
 // Specification: https://docs.peppol.eu/poacc/billing/3.0/syntax/ubl-invoice/tree/
 // The result can be validated with the validate package.
+//
+// An Invoice or CreditNote value must not be used by several goroutines at
+// the same time: Generate records its warnings and totals on it. Distinct
+// values can be generated in parallel without limit, the package keeps no
+// mutable state of its own.
 package ubl
 
 import (
+	"cmp"
 	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"math"
-	"net/http"
-	"os"
+	"io"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Invoice struct {
-	xml                   *xmlInvoice
-	ID                    string
-	CustomizationID       string
-	ProfileID             string
-	SupplierName          string
-	SupplierVat           string
-	SupplierPeppolID      string
-	SupplierAddress       Address
-	CustomerName          string
-	CustomerVat           string
-	CustomerPeppolID      string
-	CustomerAddress       Address
-	DeliveryAddress       *Address   // Optional: required for intra-community supply (BT-80)
-	ActualDeliveryDate    *time.Time // Optional: required for intra-community supply (BT-72)
-	InvoicePeriodStart    *time.Time // Optional: alternative to delivery date for IC supply (BG-14)
-	InvoicePeriodEnd      *time.Time // Optional: alternative to delivery date for IC supply (BG-14)
-	Iban                  string
-	Bic                   string
-	Note                  string
-	Lines                 []InvoiceLine
-	PdfInvoiceFilename    string
-	PdfInvoiceData        string
-	PdfInvoiceDescription string
+	ID                       string                      `json:"id"`
+	UUID                     string                      `json:"uuid,omitempty"`                   // Optional: universally unique identifier of the document, filled in when generating with GenerateUUID
+	GenerateUUID             bool                        `json:"generateUUID,omitempty"`           // Optional: generate a random UUID when UUID is empty
+	CustomizationID          string                      `json:"customizationID,omitempty"`        // Optional: defaults to PeppolBilling30CustomizationID
+	ProfileID                string                      `json:"profileID,omitempty"`              // Optional: defaults to PeppolBilling30ProfileID
+	NoDefaultSpecification   bool                        `json:"noDefaultSpecification,omitempty"` // Optional: leave empty CustomizationID and ProfileID empty
+	Profile                  Profile                     `json:"profile,omitempty"`                // Optional: CIUS with its default CustomizationID and extra rules, defaults to ProfilePeppol
+	BuyerReference           string                      `json:"buyerReference,omitempty"`         // Optional: buyer reference (BT-10), the Leitweg-ID for XRechnung
+	IncludeUBLBEReference    bool                        `json:"includeUBLBEReference,omitempty"`  // Optional: add the UBL.BE document reference outside ProfileUBLBE, with or without attachments
+	UBLBEDescription         string                      `json:"ublBEDescription,omitempty"`       // Optional: DocumentDescription of the UBL.BE reference, defaults to "CommercialInvoice"
+	SelfBilling              bool                        `json:"selfBilling,omitempty"`            // Optional: issued by the buyer on behalf of the supplier, which stays AccountingSupplierParty
+	Now                      func() time.Time            `json:"-"`                                // Optional: clock for the issue and due date, defaults to time.Now; pin it for reproducible output
+	InvoiceTypeCode          string                      `json:"invoiceTypeCode,omitempty"`        // Optional: UNCL1001 code (BT-3), defaults to 380 (389 with SelfBilling)
+	OriginalInvoiceID        string                      `json:"originalInvoiceID,omitempty"`      // Optional: preceding invoice reference (BT-25), required for type 384
+	OriginalInvoiceDate      *time.Time                  `json:"originalInvoiceDate,omitempty"`    // Optional: preceding invoice issue date (BT-26)
+	SupplierName             string                      `json:"supplierName"`
+	SupplierTradingName      string                      `json:"supplierTradingName,omitempty"` // Optional: seller trading name (BT-28), defaults to SupplierName
+	SupplierVat              string                      `json:"supplierVat"`
+	SupplierPeppolID         string                      `json:"supplierPeppolID"`
+	SupplierEndpointScheme   string                      `json:"supplierEndpointScheme,omitempty"` // Optional: EAS code of the supplier endpoint (BT-34-1), with SupplierEndpointValue
+	SupplierEndpointValue    string                      `json:"supplierEndpointValue,omitempty"`  // Optional: supplier endpoint identifier (BT-34), overrides SupplierPeppolID
+	SupplierAddress          Address                     `json:"supplierAddress"`
+	SupplierContact          Contact                     `json:"supplierContact,omitempty"`          // Optional: seller contact (BG-6)
+	SupplierLegalForm        string                      `json:"supplierLegalForm,omitempty"`        // Optional: seller additional legal information (BT-33)
+	SupplierCompanyID        string                      `json:"supplierCompanyID,omitempty"`        // Optional: seller legal registration identifier (BT-30), e.g. the KBO number
+	SupplierCompanyIDScheme  string                      `json:"supplierCompanyIDScheme,omitempty"`  // Optional: scheme of SupplierCompanyID, e.g. "0208"
+	SupplierAdditionalIDs    []PartyID                   `json:"supplierAdditionalIDs,omitempty"`    // Optional: seller identifiers (BT-29), e.g. a GLN
+	SupplierTaxRegistrations []TaxRegistration           `json:"supplierTaxRegistrations,omitempty"` // Optional: seller tax registrations other than VAT (BT-32)
+	CustomerName             string                      `json:"customerName"`
+	CustomerTradingName      string                      `json:"customerTradingName,omitempty"` // Optional: buyer trading name (BT-45), defaults to CustomerName
+	CustomerVat              string                      `json:"customerVat"`
+	CustomerPeppolID         string                      `json:"customerPeppolID"`
+	CustomerEndpointScheme   string                      `json:"customerEndpointScheme,omitempty"` // Optional: EAS code of the customer endpoint (BT-49-1), with CustomerEndpointValue
+	CustomerEndpointValue    string                      `json:"customerEndpointValue,omitempty"`  // Optional: customer endpoint identifier (BT-49), overrides CustomerPeppolID
+	CustomerAddress          Address                     `json:"customerAddress"`
+	CustomerAdditionalIDs    []PartyID                   `json:"customerAdditionalIDs,omitempty"`    // Optional: buyer identifiers (BT-46), e.g. a GLN
+	DeliveryAddress          *Address                    `json:"deliveryAddress,omitempty"`          // Optional: required for intra-community supply (BT-80)
+	ActualDeliveryDate       *time.Time                  `json:"actualDeliveryDate,omitempty"`       // Optional: required for intra-community supply (BT-72)
+	DeliveryLocationID       string                      `json:"deliveryLocationID,omitempty"`       // Optional: deliver-to location identifier (BT-71), e.g. a GLN
+	DeliveryLocationIDScheme string                      `json:"deliveryLocationIDScheme,omitempty"` // Optional: scheme of DeliveryLocationID, e.g. "0088"
+	DeliveryPartyName        string                      `json:"deliveryPartyName,omitempty"`        // Optional: deliver-to party name (BT-70)
+	InvoicePeriodStart       *time.Time                  `json:"invoicePeriodStart,omitempty"`       // Optional: alternative to delivery date for IC supply (BG-14)
+	InvoicePeriodEnd         *time.Time                  `json:"invoicePeriodEnd,omitempty"`         // Optional: alternative to delivery date for IC supply (BG-14)
+	Iban                     string                      `json:"iban,omitempty"`
+	Bic                      string                      `json:"bic,omitempty"`
+	AccountName              string                      `json:"accountName,omitempty"`       // Optional: payment account name (BT-85), e.g. the account holder
+	BankAccounts             []BankAccount               `json:"bankAccounts,omitempty"`      // Optional: accounts the buyer can choose from; overrides Iban, Bic and AccountName
+	DirectDebit              *DirectDebit                `json:"directDebit,omitempty"`       // Optional: collect by direct debit instead of credit transfer
+	PaymentMeansCode         string                      `json:"paymentMeansCode,omitempty"`  // Optional: UNCL4461 code (BT-81), e.g. "58" for SEPA credit transfer; defaults to "30" with an IBAN
+	PaymentMeansName         string                      `json:"paymentMeansName,omitempty"`  // Optional: payment means text (BT-82)
+	Note                     string                      `json:"note,omitempty"`              // Optional: payment terms (BT-20); line breaks start a new cbc:Note
+	PaymentTermsNotes        []string                    `json:"paymentTermsNotes,omitempty"` // Optional: more payment terms, e.g. "2% discount if paid within 10 days"
+	Currency                 string                      `json:"currency,omitempty"`          // Optional: document currency code (BT-5), defaults to EUR
+	TaxSchemeID              string                      `json:"taxSchemeID,omitempty"`       // Optional: tax scheme of the parties and tax categories, e.g. "GST"; defaults to "VAT"
+	Lines                    []InvoiceLine               `json:"lines"`
+	AllowanceCharges         []AllowanceCharge           `json:"allowanceCharges,omitempty"`      // Optional: document level allowances (BG-20) and charges (BG-21)
+	OrderReferenceID         string                      `json:"orderReferenceID,omitempty"`      // Optional: shortcut for OrderReference.PurchaseOrderID
+	OrderReference           *OrderRef                   `json:"orderReference,omitempty"`        // Optional: order reference (BT-13/BT-14), defaults to the invoice ID
+	RequireOrderReference    bool                        `json:"requireOrderReference,omitempty"` // Optional: fail instead of warning when a line has an OrderLineID without purchase order reference
+	RequireSameExemption     bool                        `json:"requireSameExemption,omitempty"`  // Optional: fail instead of warning when lines of one VAT category and rate have different exemption reasons
+	PdfInvoiceFilename       string                      `json:"pdfInvoiceFilename,omitempty"`
+	PdfInvoiceData           string                      `json:"pdfInvoiceData,omitempty"`
+	PdfInvoiceDescription    string                      `json:"pdfInvoiceDescription,omitempty"`
+	Attachments              []Attachment                `json:"attachments,omitempty"`        // Optional: supporting documents (BG-24) after the PDF, see AddAttachmentFromBytes and AddExternalReference
+	Extensions               []Extension                 `json:"extensions,omitempty"`         // Optional: UBL extensions, written verbatim in ext:UBLExtensions before all other elements
+	MaxAttachmentBytes       int                         `json:"maxAttachmentBytes,omitempty"` // Optional: largest attachment or PDF before base64 encoding; defaults to 10 MB, negative for no limit
+	TextFilters              []TextFilter                `json:"-"`                            // Optional: applied to free-text fields before generation
+	ReceiverQuirks           ReceiverQuirks              `json:"-"`                            // Optional: receiver specific tweaks, applied just before marshalling
+	BeforeMarshal            func(doc *XMLInvoice) error `json:"-"`                            // Optional: changes the low-level model after the ReceiverQuirks; an error aborts generation
+	XMLDeclaration           XMLDeclaration              `json:"xmlDeclaration,omitempty"`     // Optional: the <?xml ...?> declaration, defaults to version 1.0 in UTF-8
+	Namespaces               XMLNamespaces               `json:"namespaces,omitempty"`         // Optional: namespace prefixes, defaults to the document namespace as default namespace, "cac" and "cbc"
+	warnings                 []string
+	totals                   Totals
+	effective                effectiveValues
 }
 
 type InvoiceLine struct {
-	Quantity           float64
-	Price              float64
-	TaxPercentage      float64
-	TaxCategoryID      string
-	TaxCategoryName    string
-	TaxExemptionReason string // Optional: required for category K (BT-120/121)
-	TaxExemptionCode   string // Optional: exemption reason code (BT-121)
+	Quantity             float64              `json:"quantity"`
+	Price                float64              `json:"price"`
+	TaxPercentage        float64              `json:"taxPercentage"`
+	TaxCategoryID        string               `json:"taxCategoryID"`
+	TaxCategoryName      string               `json:"taxCategoryName,omitempty"`
+	TaxExemptionReason   string               `json:"taxExemptionReason,omitempty"`   // Optional: VAT exemption reason (BT-120), for categories E, AE, K, G and O
+	TaxExemptionCode     string               `json:"taxExemptionCode,omitempty"`     // Optional: VATEX exemption reason code (BT-121), for the same categories
+	LineID               string               `json:"lineID,omitempty"`               // Optional: invoice line identifier (BT-126), defaults to the line number
+	UnitCode             string               `json:"unitCode,omitempty"`             // Optional: UNECE Rec 20/21 unit of measure (BT-130), e.g. "HUR" or "KGM"; defaults to "ZZ"
+	Note                 string               `json:"note,omitempty"`                 // Optional: invoice line note (BT-127), e.g. "replacement under warranty"
+	AccountingCost       string               `json:"accountingCost,omitempty"`       // Optional: buyer accounting reference (BT-133), e.g. a cost centre
+	OrderLineID          string               `json:"orderLineID,omitempty"`          // Optional: referenced purchase order line (BT-132), needs a purchase order reference
+	ObjectID             string               `json:"objectID,omitempty"`             // Optional: invoice line object identifier (BT-128), e.g. a timesheet
+	ObjectIDScheme       string               `json:"objectIDScheme,omitempty"`       // Optional: UNCL1153 scheme of ObjectID (BT-128-1)
+	StandardItemID       string               `json:"standardItemID,omitempty"`       // Optional: item standard identifier (BT-157), e.g. a GTIN
+	StandardItemIDScheme string               `json:"standardItemIDScheme,omitempty"` // Optional: scheme of StandardItemID, defaults to "0160" (GTIN)
+	Classifications      []ItemClassification `json:"classifications,omitempty"`      // Optional: item classifications (BT-158), e.g. CPV or UNSPSC codes
+	Attributes           []ItemAttribute      `json:"attributes,omitempty"`           // Optional: item attributes (BG-32), e.g. colour or size
+	GrossPrice           float64              `json:"grossPrice,omitempty"`           // Optional: item gross price (BT-148), Price is then the net price after PriceDiscount
+	PriceDiscount        float64              `json:"priceDiscount,omitempty"`        // Optional: item price discount (BT-147), GrossPrice - PriceDiscount must equal Price
+	PriceIncludesTax     bool                 `json:"priceIncludesTax,omitempty"`     // Optional: Price, GrossPrice and PriceDiscount include VAT, the net prices are derived from the rate
+	AllowZeroQuantity    bool                 `json:"allowZeroQuantity,omitempty"`    // Optional: let AddLine accept a quantity of 0, e.g. for an informative line
+
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// id returns the line identifier: LineID, or the line number for index i.
+func (line InvoiceLine) id(i int) string {
+	if line.LineID != "" {
+		return line.LineID
+	}
+	return strconv.Itoa(i + 1)
+}
 
-	Name        string
-	Description string
+// ItemAttribute is an item attribute (BG-32), e.g. Name "Colour" and Value
+// "Black".
+type ItemAttribute struct {
+	Name  string `json:"name"`  // BT-160
+	Value string `json:"value"` // BT-161
 }
 
 type taxKey struct {
@@ -60,11 +148,304 @@ type taxKey struct {
 	CategoryID string
 }
 
+// OrderRef references the buyer's purchase order (BT-13) and the seller's
+// sales order (BT-14).
+type OrderRef struct {
+	PurchaseOrderID string `json:"purchaseOrderID"`
+	SalesOrderID    string `json:"salesOrderID,omitempty"`
+}
+
+// TaxRegistration is a tax registration other than VAT (BT-32), e.g. the
+// Italian fiscal code.
+type TaxRegistration struct {
+	CompanyID string `json:"companyID"`
+	SchemeID  string `json:"schemeID"`
+}
+
+// PartyID is an additional party identifier, e.g. a GLN with scheme "0088".
+type PartyID struct {
+	Value    string `json:"value"`
+	SchemeID string `json:"schemeID"`
+}
+
+type Contact struct {
+	Name  string `json:"name,omitempty"`
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
 type Address struct {
-	StreetName  string
-	CityName    string
-	PostalZone  string
-	CountryCode string
+	StreetName   string `json:"streetName"`
+	StreetName2  string `json:"streetName2,omitempty"`  // Optional: additional street name (BT-36)
+	AddressLine3 string `json:"addressLine3,omitempty"` // Optional: address line 3 (BT-162)
+	CityName     string `json:"cityName"`
+	PostalZone   string `json:"postalZone"`
+	Region       string `json:"region,omitempty"` // Optional: country subdivision (BT-39)
+	CountryCode  string `json:"countryCode"`
+}
+
+func (a Address) xml() XMLPostalAddress {
+	addr := XMLPostalAddress{
+		StreetName:           a.StreetName,
+		AdditionalStreetName: a.StreetName2,
+		CityName:             a.CityName,
+		PostalZone:           a.PostalZone,
+		CountrySubentity:     a.Region,
+		Country:              XMLCountry{IdentificationCode: a.CountryCode},
+	}
+	if a.AddressLine3 != "" {
+		addr.AddressLine = &XMLAddressLine{Line: a.AddressLine3}
+	}
+	return addr
+}
+
+// xml returns the cac:Contact element, or nil when no field is filled
+func (c Contact) xml() *XMLContact {
+	if c.Name == "" && c.Phone == "" && c.Email == "" {
+		return nil
+	}
+	return &XMLContact{
+		Name:           c.Name,
+		Telephone:      c.Phone,
+		ElectronicMail: c.Email,
+	}
+}
+
+// partyIdentifications returns the cac:PartyIdentification elements
+func partyIdentifications(ids []PartyID) []XMLPartyIdentification {
+	var result []XMLPartyIdentification
+	for _, id := range ids {
+		result = append(result, XMLPartyIdentification{
+			ID: XMLIdentifier{Value: id.Value, SchemeID: id.SchemeID},
+		})
+	}
+	return result
+}
+
+// tradingName returns the cac:PartyName name: the trading name, or the legal
+// name when there is none
+func tradingName(tradingName, legalName string) string {
+	if tradingName != "" {
+		return tradingName
+	}
+	return legalName
+}
+
+// companyID returns the cbc:CompanyID element, or nil when id is empty
+func companyID(id, scheme string) *XMLIdentifier {
+	if id == "" {
+		return nil
+	}
+	return &XMLIdentifier{Value: id, SchemeID: scheme}
+}
+
+// delivery returns the cac:Delivery element, or nil when there is no
+// delivery information
+func delivery(address *Address, date *time.Time, locationID, locationIDScheme, partyName string) *XMLDelivery {
+	if address == nil && date == nil && locationID == "" && partyName == "" {
+		return nil
+	}
+
+	d := &XMLDelivery{}
+	if date != nil {
+		d.ActualDeliveryDate = date.Format("2006-01-02")
+	}
+	if address != nil || locationID != "" {
+		d.DeliveryLocation = &XMLDeliveryLocation{}
+		if locationID != "" {
+			d.DeliveryLocation.ID = &XMLIdentifier{Value: locationID, SchemeID: locationIDScheme}
+		}
+		if address != nil {
+			addr := address.xml()
+			d.DeliveryLocation.Address = &addr
+		}
+	}
+	if partyName != "" {
+		d.DeliveryParty = &XMLDeliveryParty{PartyName: partyName}
+	}
+	return d
+}
+
+// supplierTaxSchemes returns the seller cac:PartyTaxScheme elements: the VAT
+// identifier (BT-31) first, then the other tax registrations (BT-32). Sellers
+// without a VAT identifier are only allowed when no VAT is charged: all lines
+// must then be exempt (E) or not subject to VAT (O).
+func supplierTaxSchemes(vat, countryCode, taxScheme string, registrations []TaxRegistration, lines []InvoiceLine) ([]XMLPartyTaxScheme, error) {
+	var schemes []XMLPartyTaxScheme
+	if vat != "" {
+		schemes = append(schemes, XMLPartyTaxScheme{
+			CompanyID: cleanVATIdentifier(vat, countryCode),
+			TaxScheme: XMLTaxScheme{ID: taxScheme},
+		})
+	} else {
+		for i, line := range lines {
+			categoryID := line.TaxCategoryID
+			if categoryID == "" {
+				categoryID = "S"
+			}
+			if categoryID != "O" && categoryID != "E" {
+				return nil, fmt.Errorf("supplier without VAT identifier: line %d has tax category %s, only O or E allowed", i+1, categoryID)
+			}
+		}
+	}
+
+	for i, reg := range registrations {
+		if reg.CompanyID == "" || reg.SchemeID == "" {
+			return nil, fmt.Errorf("supplier tax registration %d: company ID and scheme ID required", i+1)
+		}
+		if reg.SchemeID == taxScheme {
+			return nil, fmt.Errorf("supplier tax registration %d: use SupplierVat for the VAT identifier", i+1)
+		}
+		schemes = append(schemes, XMLPartyTaxScheme{
+			CompanyID: reg.CompanyID,
+			TaxScheme: XMLTaxScheme{ID: reg.SchemeID},
+		})
+	}
+	return schemes, nil
+}
+
+// paymentTerms returns the cac:PaymentTerms element with one cbc:Note per
+// line of the notes, Note first, or nil when there are none.
+func paymentTerms(note string, notes []string) *XMLPaymentTerms {
+	var lines []string
+	for _, n := range append([]string{note}, notes...) {
+		for _, line := range strings.Split(n, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return &XMLPaymentTerms{Note: lines}
+}
+
+// invoicePeriod returns the cac:InvoicePeriod element, or nil when neither
+// bound is given. Either bound may be given alone.
+func invoicePeriod(start, end *time.Time) (*XMLInvoicePeriod, error) {
+	if start == nil && end == nil {
+		return nil, nil
+	}
+	if start != nil && end != nil && end.Before(*start) {
+		return nil, fmt.Errorf("invoice period: end %s before start %s", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+
+	p := &XMLInvoicePeriod{}
+	if start != nil {
+		p.StartDate = start.Format("2006-01-02")
+	}
+	if end != nil {
+		p.EndDate = end.Format("2006-01-02")
+	}
+	return p, nil
+}
+
+// orderReference returns the cac:OrderReference element. Without a reference
+// the document ID is used, as before. The shortcut is the purchase order ID
+// of a reference without one and must match it otherwise.
+func orderReference(ref *OrderRef, shortcut, documentID string) (*XMLOrderReference, error) {
+	if ref == nil {
+		if shortcut != "" {
+			return &XMLOrderReference{ID: shortcut}, nil
+		}
+		return &XMLOrderReference{ID: documentID}, nil
+	}
+
+	purchaseOrderID := cmp.Or(ref.PurchaseOrderID, shortcut)
+	if shortcut != "" && purchaseOrderID != shortcut {
+		return nil, fmt.Errorf("order reference: purchase order ID %q differs from OrderReferenceID %q", purchaseOrderID, shortcut)
+	}
+	if purchaseOrderID == "" && ref.SalesOrderID == "" {
+		return nil, fmt.Errorf("order reference: purchase order ID or sales order ID required")
+	}
+
+	xmlRef := &XMLOrderReference{
+		ID:           purchaseOrderID,
+		SalesOrderID: ref.SalesOrderID,
+	}
+	// cbc:ID is mandatory, Peppol uses "NA" when only the sales order is known
+	if xmlRef.ID == "" {
+		xmlRef.ID = "NA"
+	}
+	return xmlRef, nil
+}
+
+// orderLineReference returns the cac:OrderLineReference element, or nil
+// without a line ID.
+func orderLineReference(lineID string) *XMLOrderLineReference {
+	if lineID == "" {
+		return nil
+	}
+	return &XMLOrderLineReference{LineID: lineID}
+}
+
+// lineObjectReference returns the cac:DocumentReference of the line object
+// identifier (type 130), or nil without an ID.
+func lineObjectReference(id, scheme string) *XMLLineDocumentReference {
+	if id == "" {
+		return nil
+	}
+	return &XMLLineDocumentReference{
+		ID:               XMLIdentifier{Value: id, SchemeID: scheme},
+		DocumentTypeCode: "130",
+	}
+}
+
+// standardItemIdentification returns the cac:StandardItemIdentification
+// element, or nil without an ID. The scheme defaults to GTIN (0160).
+func standardItemIdentification(id, scheme string) *XMLItemIdentification {
+	if id == "" {
+		return nil
+	}
+	if scheme == "" {
+		scheme = "0160"
+	}
+	return &XMLItemIdentification{ID: XMLIdentifier{Value: id, SchemeID: scheme}}
+}
+
+// additionalItemProperties returns the cac:AdditionalItemProperty elements,
+// or all attributes with an empty name or value.
+func additionalItemProperties(attributes []ItemAttribute) ([]XMLItemProperty, error) {
+	var result []XMLItemProperty
+	var errs []error
+	for i, a := range attributes {
+		if a.Name == "" {
+			errs = append(errs, fmt.Errorf("Attributes[%d]: name required", i))
+		}
+		if a.Value == "" {
+			errs = append(errs, fmt.Errorf("Attributes[%d]: value required", i))
+		}
+		result = append(result, XMLItemProperty{Name: a.Name, Value: a.Value})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// checkOrderLineReferences reports the lines that reference a purchase order
+// line (BT-132) while the document has no purchase order reference (BT-13):
+// as warnings, or as error when required.
+func checkOrderLineReferences(lines []InvoiceLine, ref *OrderRef, shortcut string, require bool) ([]string, error) {
+	if shortcut != "" || ref != nil && ref.PurchaseOrderID != "" {
+		return nil, nil
+	}
+	var warnings []string
+	var errs []error
+	for i, line := range lines {
+		if line.OrderLineID == "" {
+			continue
+		}
+		msg := fmt.Sprintf("line %d: OrderLineID %q without purchase order reference", i+1, line.OrderLineID)
+		warnings = append(warnings, msg)
+		errs = append(errs, errors.New(msg))
+	}
+	if require {
+		return nil, errors.Join(errs...)
+	}
+	return warnings, nil
 }
 
 // cleanVATIdentifier ensures VAT identifier has proper ISO 3166-1 alpha-2 country prefix
@@ -95,663 +476,1020 @@ func cleanVATIdentifier(vatID, countryCode string) string {
 	return vatID
 }
 
+// Generate generates the invoice as a UBL 2.1 XML document.
+//
+// The output is reproducible: the same invoice with the same Now clock gives
+// the same bytes, on every run and platform. The VAT breakdown is ordered by
+// category and rate, lines, allowances and attachments keep their order and
+// amounts are written with the decimals of their currency. A UUID made for
+// GenerateUUID is kept in UUID, so generating again doesn't change it.
 func (inv *Invoice) Generate() ([]byte, error) {
-	inv.xml = &xmlInvoice{
-		Xmlns:            "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
-		Cac:              "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
-		Cbc:              "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
-		CustomizationID:  inv.CustomizationID,
-		ProfileID:        inv.ProfileID,
-		IssueDate:        time.Now().Format("2006-01-02"),
-		DueDate:          time.Now().AddDate(0, 0, 30).Format("2006-01-02"),
-		InvoiceTypeCode:  "380",
-		DocumentCurrency: "EUR",
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		return nil, err
+	}
+	return doc.Marshal()
+}
+
+// GenerateTo generates the invoice like Generate and writes it to w. Large
+// attachments are written straight from their encoding, so no extra copy of
+// the document is made.
+func (inv *Invoice) GenerateTo(w io.Writer) error {
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		return err
+	}
+	return doc.writeTo(w)
+}
+
+// build returns the XML model of the invoice. Every call starts from
+// scratch, so the invoice can be changed and generated again.
+func (inv *Invoice) build() (*XMLInvoice, error) {
+	inv.warnings = nil
+	err := inv.Check()
+	if err != nil {
+		return nil, err
+	}
+	err = documentUUID(&inv.UUID, inv.GenerateUUID)
+	if err != nil {
+		return nil, err
+	}
+	customizationID, profileID, err := specification(inv.CustomizationID, inv.ProfileID, inv.Profile, inv.SelfBilling, inv.NoDefaultSpecification)
+	if err != nil {
+		return nil, err
+	}
+	now := inv.now()
+	doc := &XMLInvoice{
+		UBLExtensions:    ublExtensions(inv.Extensions),
+		CustomizationID:  customizationID,
+		ProfileID:        profileID,
+		IssueDate:        now.Format("2006-01-02"),
+		DueDate:          now.AddDate(0, 0, 30).Format("2006-01-02"),
+		InvoiceTypeCode:  invoiceTypeCode(inv.InvoiceTypeCode, inv.SelfBilling),
+		DocumentCurrency: inv.currency(),
+		BuyerReference:   inv.BuyerReference,
 		ID:               inv.ID,
-		OrderReference:   inv.ID,
+		UUID:             inv.UUID,
+	}
+
+	orderRef, err := orderReference(inv.OrderReference, inv.OrderReferenceID, inv.ID)
+	if err != nil {
+		return nil, err
+	}
+	doc.OrderReference = orderRef
+	orderLineWarnings, err := checkOrderLineReferences(inv.Lines, inv.OrderReference, inv.OrderReferenceID, inv.RequireOrderReference)
+	if err != nil {
+		return nil, err
+	}
+	inv.warnings = append(inv.warnings, orderLineWarnings...)
+	exemptionWarnings, err := checkExemptionReasons(inv.Lines, inv.RequireSameExemption)
+	if err != nil {
+		return nil, err
+	}
+	inv.warnings = append(inv.warnings, exemptionWarnings...)
+
+	err = checkInvoiceTypeCode(doc.InvoiceTypeCode, inv.OriginalInvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	doc.BillingReference = billingReference(inv.OriginalInvoiceID, inv.OriginalInvoiceDate)
+
+	supplierEndpoint, err := endpointID("SupplierPeppolID", inv.SupplierPeppolID, inv.SupplierEndpointScheme, inv.SupplierEndpointValue)
+	if err != nil {
+		return nil, err
+	}
+	customerEndpoint, err := endpointID("CustomerPeppolID", inv.CustomerPeppolID, inv.CustomerEndpointScheme, inv.CustomerEndpointValue)
+	if err != nil {
+		return nil, err
 	}
 
 	// Clean and validate VAT identifiers
-	supplierVat := cleanVATIdentifier(inv.SupplierVat, inv.SupplierAddress.CountryCode)
-	customerVat := cleanVATIdentifier(inv.CustomerVat, inv.CustomerAddress.CountryCode)
-
-	inv.xml.SupplierParty = xmlSupplierParty{
-		Party: xmlParty{
-			EndpointID: xmlEndpointID{
-				Value:    inv.SupplierPeppolID[5:],
-				SchemeID: inv.SupplierPeppolID[0:4],
-			},
-			PartyName:        inv.SupplierName,
-			RegistrationName: inv.SupplierName,
-			PartyTaxScheme: xmlPartyTaxScheme{
-				CompanyID: supplierVat,
-				TaxScheme: xmlTaxScheme{
-					ID: "VAT",
-				},
+	supplierTaxSchemes, err := supplierTaxSchemes(inv.SupplierVat, inv.SupplierAddress.CountryCode, inv.taxScheme(), inv.SupplierTaxRegistrations, inv.Lines)
+	if err != nil {
+		return nil, err
+	}
+	customerVat, customerTaxScheme, err := customerTaxIdentifier(inv.CustomerVat, inv.CustomerAddress.CountryCode)
+	if err != nil {
+		return nil, err
+	}
+	if inv.TaxSchemeID != "" {
+		customerTaxScheme = inv.TaxSchemeID
+	}
+
+	doc.SupplierParty = XMLSupplierParty{
+		Party: XMLParty{
+			EndpointID:          supplierEndpoint,
+			PartyIdentification: append(partyIdentifications(inv.SupplierAdditionalIDs), creditorIdentification(inv.DirectDebit)...),
+			PartyName:           tradingName(inv.SupplierTradingName, inv.SupplierName),
+			PartyLegalEntity: XMLPartyLegalEntity{
+				RegistrationName: inv.SupplierName,
+				CompanyID:        companyID(inv.SupplierCompanyID, inv.SupplierCompanyIDScheme),
+				CompanyLegalForm: inv.SupplierLegalForm,
 			},
+			PartyTaxScheme: supplierTaxSchemes,
 		},
 	}
 
-	inv.xml.SupplierParty.Party.PostalAddress = xmlPostalAddress{
-		StreetName: inv.SupplierAddress.StreetName,
-		CityName:   inv.SupplierAddress.CityName,
-		PostalZone: inv.SupplierAddress.PostalZone,
-		Country:    xmlCountry{IdentificationCode: inv.SupplierAddress.CountryCode},
-	}
+	doc.SupplierParty.Party.Contact = inv.SupplierContact.xml()
 
-	inv.xml.CustomerParty.Party.PostalAddress = xmlPostalAddress{
-		StreetName: inv.CustomerAddress.StreetName,
-		CityName:   inv.CustomerAddress.CityName,
-		PostalZone: inv.CustomerAddress.PostalZone,
-		Country:    xmlCountry{IdentificationCode: inv.CustomerAddress.CountryCode},
-	}
+	doc.SupplierParty.Party.PostalAddress = inv.SupplierAddress.xml()
 
-	inv.xml.CustomerParty = xmlCustomerParty{
-		Party: xmlParty{
-			EndpointID: xmlEndpointID{
-				Value:    inv.CustomerPeppolID[5:],
-				SchemeID: inv.CustomerPeppolID[0:4],
+	doc.CustomerParty = XMLCustomerParty{
+		Party: XMLParty{
+			EndpointID:          customerEndpoint,
+			PartyIdentification: partyIdentifications(inv.CustomerAdditionalIDs),
+			PartyName:           tradingName(inv.CustomerTradingName, inv.CustomerName),
+			PartyLegalEntity: XMLPartyLegalEntity{
+				RegistrationName: inv.CustomerName,
 			},
-			PartyName:        inv.CustomerName,
-			RegistrationName: inv.CustomerName,
-			PartyTaxScheme: xmlPartyTaxScheme{
+			PartyTaxScheme: []XMLPartyTaxScheme{{
 				CompanyID: customerVat,
-				TaxScheme: xmlTaxScheme{
-					ID: "VAT",
-				},
-			},
-			PostalAddress: xmlPostalAddress{
-				Country: xmlCountry{
-					IdentificationCode: inv.CustomerAddress.CountryCode,
+				TaxScheme: XMLTaxScheme{
+					ID: customerTaxScheme,
 				},
-			},
+			}},
+			PostalAddress: inv.CustomerAddress.xml(),
 		},
 	}
 
 	// Add delivery information if provided (required for intra-community supply)
-	if inv.DeliveryAddress != nil {
-		inv.xml.Delivery = &xmlDelivery{
-			ActualDeliveryDate: "",
-			DeliveryLocation: xmlDeliveryLocation{
-				Address: xmlPostalAddress{
-					StreetName: inv.DeliveryAddress.StreetName,
-					CityName:   inv.DeliveryAddress.CityName,
-					PostalZone: inv.DeliveryAddress.PostalZone,
-					Country: xmlCountry{
-						IdentificationCode: inv.DeliveryAddress.CountryCode,
-					},
-				},
-			},
-		}
-	}
+	doc.Delivery = delivery(inv.DeliveryAddress, inv.ActualDeliveryDate, inv.DeliveryLocationID, inv.DeliveryLocationIDScheme, inv.DeliveryPartyName)
 
-	// Add actual delivery date if provided
-	if inv.ActualDeliveryDate != nil {
-		if inv.xml.Delivery == nil {
-			inv.xml.Delivery = &xmlDelivery{}
-		}
-		inv.xml.Delivery.ActualDeliveryDate = inv.ActualDeliveryDate.Format("2006-01-02")
+	// Add invoicing period if provided (alternative to delivery date)
+	doc.InvoicePeriod, err = invoicePeriod(inv.InvoicePeriodStart, inv.InvoicePeriodEnd)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add invoicing period if provided (alternative to delivery date)
-	if inv.InvoicePeriodStart != nil && inv.InvoicePeriodEnd != nil {
-		inv.xml.InvoicePeriod = &xmlInvoicePeriod{
-			StartDate: inv.InvoicePeriodStart.Format("2006-01-02"),
-			EndDate:   inv.InvoicePeriodEnd.Format("2006-01-02"),
-		}
+	if inv.DirectDebit != nil {
+		doc.PaymentMeans, err = directDebitPaymentMeans(inv.PaymentMeansCode, inv.PaymentMeansName, *inv.DirectDebit, inv.Iban, inv.BankAccounts)
+	} else {
+		doc.PaymentMeans, err = paymentMeans(inv.PaymentMeansCode, inv.PaymentMeansName, bankAccounts(inv.Iban, inv.Bic, inv.AccountName, inv.BankAccounts))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	inv.xml.PaymentMeans = xmlPaymentMeans{
-		PaymentMeansCode: "1",
-		PayeeFinancialAccount: xmlFinancialAccount{
-			ID: inv.Iban,
-			FinancialInstitutionBranch: xmlFinancialInstitutionBranch{
-				ID: inv.Bic,
-			},
-		},
+	doc.PaymentTerms = paymentTerms(inv.Note, inv.PaymentTermsNotes)
+
+	err = inv.Profile.check(profileDocument{
+		BuyerReference: inv.BuyerReference,
+		Supplier:       &doc.SupplierParty.Party,
+		Customer:       &doc.CustomerParty.Party,
+		PaymentMeans:   doc.PaymentMeans,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Only include PaymentTerms if Note is not empty
-	if inv.Note != "" {
-		inv.xml.PaymentTerms = xmlPaymentTerms{
-			Note: inv.Note,
-		}
+	err = inv.addLines(doc)
+	if err != nil {
+		return nil, err
 	}
 
-	inv.addLines()
+	pdfReferences, err := pdfAttachment{
+		documentID:  inv.ID,
+		filename:    inv.PdfInvoiceFilename,
+		data:        inv.PdfInvoiceData,
+		description: inv.PdfInvoiceDescription,
+		maxBytes:    inv.MaxAttachmentBytes,
+	}.documentReferences("Invoice")
+	if err != nil {
+		return nil, err
+	}
+	doc.AdditionalDocumentReference = ublBEReference(inv.Profile, inv.IncludeUBLBEReference, inv.UBLBEDescription, "CommercialInvoice")
+	doc.AdditionalDocumentReference = append(doc.AdditionalDocumentReference, pdfReferences...)
+	doc.AdditionalDocumentReference = append(doc.AdditionalDocumentReference, attachmentReferences(inv.Attachments)...)
+	if len(inv.TextFilters) > 0 {
+		inv.warnings = append(inv.warnings, applyTextFilters(inv.TextFilters, doc.freeText())...)
+	}
 
-	if inv.PdfInvoiceFilename != "" && inv.PdfInvoiceData == "" {
-		err := inv.addAttachmentFromFile(inv.PdfInvoiceFilename, "Invoice")
+	quirkWarnings, err := applyQuirks(inv.ReceiverQuirks, doc.quirkDocument(customerEndpoint.participantID()))
+	if err != nil {
+		return nil, err
+	}
+	inv.warnings = append(inv.warnings, quirkWarnings...)
+	if inv.BeforeMarshal != nil {
+		err = inv.BeforeMarshal(doc)
 		if err != nil {
-			return nil, fmt.Errorf("add attachment failed: %w", err)
+			return nil, fmt.Errorf("before marshal: %w", err)
 		}
 	}
-	if inv.PdfInvoiceData != "" {
-		err := inv.addAttachmentFromData(inv.PdfInvoiceData, "application/pdf", inv.PdfInvoiceFilename, inv.PdfInvoiceDescription)
-		if err != nil {
-			return nil, fmt.Errorf("add attachment from data: %w", err)
+	inv.effective = doc.effectiveValues()
+
+	return doc, nil
+}
+
+// xmlOutput are the options for writing a document.
+type xmlOutput struct {
+	namespace   string // of the Invoice or CreditNote element
+	declaration XMLDeclaration
+	namespaces  XMLNamespaces
+}
+
+// marshalDocument returns the indented XML document with its declaration.
+// The first chunk of the buffer is sized for the base64 encoding of the
+// attachments in refs, so it doesn't grow by doubling past them. Documents
+// that need more, e.g. with many lines, continue in new chunks.
+func marshalDocument(doc any, refs []XMLDocumentReference, out xmlOutput) ([]byte, error) {
+	size := 64 << 10
+	for _, ref := range refs {
+		for _, a := range ref.Attachment {
+			if obj := a.EmbeddedDocumentBinaryObject; obj != nil {
+				size += len(obj.Value) + base64.StdEncoding.EncodedLen(len(obj.Data))
+			}
 		}
 	}
-	output, err := xml.MarshalIndent(inv.xml, "", "  ")
+	buf := chunkedBuffer{chunks: [][]byte{make([]byte, 0, size)}}
+	err := writeDocument(&buf, doc, out)
 	if err != nil {
-		return nil, fmt.Errorf("xml marshal failed: %w", err)
+		return nil, err
 	}
-	return []byte(xml.Header + string(output)), nil
+	return buf.Bytes(), nil
+}
+
+// chunkedBuffer is a buffer that grows by adding chunks instead of copying
+// itself into a buffer twice its size. Bytes copies the chunks once, unless
+// there is only one.
+type chunkedBuffer struct {
+	chunks [][]byte
+	size   int
 }
 
-func (inv *Invoice) addAttachmentFromFile(filename, description string) error {
-	data, err := os.ReadFile(filename)
+func (b *chunkedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		last := &b.chunks[len(b.chunks)-1]
+		if len(*last) == cap(*last) {
+			b.chunks = append(b.chunks, make([]byte, 0, min(max(b.size/4, 64<<10), 4<<20)))
+			continue
+		}
+		c := min(len(p), cap(*last)-len(*last))
+		*last = append(*last, p[:c]...)
+		b.size += c
+		p = p[c:]
+	}
+	return n, nil
+}
+
+func (b *chunkedBuffer) Bytes() []byte {
+	if len(b.chunks) == 1 {
+		return b.chunks[0]
+	}
+	data := make([]byte, 0, b.size)
+	for _, chunk := range b.chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+// writeDocument writes the indented XML document with its declaration to w,
+// without holding a copy of it. With namespace prefixes, the marshalled
+// document is piped through XMLNamespaces.rewrite.
+func writeDocument(w io.Writer, doc any, out xmlOutput) error {
+	w, err := out.declaration.writer(w)
 	if err != nil {
 		return err
 	}
+	if out.namespaces != (XMLNamespaces{}) {
+		err = out.namespaces.check()
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, out.declaration.header())
+	if err != nil {
+		return fmt.Errorf("xml marshal failed: %w", err)
+	}
+	if out.namespaces == (XMLNamespaces{}) {
+		return encodeDocument(w, doc)
+	}
 
-	mime := http.DetectContentType(data)
-	// using base64 encoding for the embedded binary content
-	encoded := base64.StdEncoding.EncodeToString(data)
-
-	return inv.addAttachmentFromData(encoded, mime, filename, description)
+	pr, pw := io.Pipe()
+	encoded := make(chan error, 1)
+	go func() {
+		err := encodeDocument(pw, doc)
+		pw.CloseWithError(err)
+		encoded <- err
+	}()
+	err = out.namespaces.rewrite(w, pr, out.namespace)
+	// stops the encoder when the rewrite failed
+	pr.CloseWithError(err)
+	encodeErr := <-encoded
+	// a failed encoder fails the rewrite with its own error
+	if err != nil && err != encodeErr {
+		return fmt.Errorf("xml namespaces failed: %w", err)
+	}
+	return encodeErr
 }
 
-func (inv *Invoice) addAttachmentFromData(encodedData, mime, filename, description string) error {
-	inv.xml.AdditionalDocumentReference = []xmlDocumentReference{
-		{
-			ID:                  "UBL.BE",
-			DocumentDescription: "CommercialInvoice",
-		},
-		{
-			ID:                  inv.ID,
-			DocumentDescription: description,
-			Attachment: []xmlAttachment{
-				{xmlEmbeddedDocumentBinaryObject{
-					Value:    encodedData,
-					MimeCode: mime,
-					Filename: filename,
-				}},
-			},
-		},
+// encodeDocument writes the indented XML document without declaration.
+func encodeDocument(w io.Writer, doc any) error {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err := enc.Encode(doc)
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("xml marshal failed: %w", err)
 	}
-
 	return nil
 }
 
-func round(amount float64) float64 {
-	return math.Round(amount*100) / 100
+// Warnings returns the non-fatal remarks of the last Generate, e.g. the
+// changes made by TextFilters and the ReceiverQuirks applied.
+func (inv *Invoice) Warnings() []string {
+	return inv.warnings
+}
+
+// Totals computes the totals of the invoice with the same rounding as
+// Generate, without generating it, e.g. to show the amount due before the
+// document is sent.
+func (inv *Invoice) Totals() (Totals, error) {
+	totals, _, _, err := calculateTaxTotals(inv.Lines, inv.AllowanceCharges, inv.currency(), inv.taxScheme())
+	return totals, err
 }
 
 type taxSummary struct {
-	key     taxKey
-	taxable float64
-	tax     float64
-	catName string
+	key        taxKey
+	lines      money
+	allowances money
+	charges    money
+	taxable    money
+	tax        money
+	catName    string
+
+	exemptionCode string
+	exemption     string
 }
 
-func calculateTaxTotals(lines []InvoiceLine) (lineTotal float64, taxTotal float64, subtotals []xmlTaxSubtotal) {
+// calculateTaxTotals computes the document totals and the VAT breakdown. The
+// order is: line amounts, document allowances and charges (percentages are
+// applied and rounded first), the taxable amount per category and finally the
+// tax per category from that taxable amount. The result is checked against
+// the calculation rules before it is returned.
+func calculateTaxTotals(lines []InvoiceLine, allowanceCharges []AllowanceCharge, currency, taxScheme string) (totals Totals, subtotals []XMLTaxSubtotal, xmlAllowanceCharges []XMLAllowanceCharge, err error) {
+	decimals := minorUnits(currency)
 	summaries := make(map[taxKey]*taxSummary)
-
-	for _, line := range lines {
-		lineAmount := round(line.Quantity * line.Price)
-
-		// Default to "S" (Standard rated) if not specified
-		categoryID := line.TaxCategoryID
-		if categoryID == "" {
-			categoryID = "S"
-		}
-		categoryName := line.TaxCategoryName
-		if categoryName == "" {
-			categoryName = "Standard rated"
+	var keys []taxKey
+	summary := func(key taxKey, catName string) *taxSummary {
+		if summaries[key] == nil {
+			summaries[key] = &taxSummary{key: key, catName: catName}
+			keys = append(keys, key)
 		}
+		return summaries[key]
+	}
 
-		// For intra-community supply (K) and reverse charge (AE), enforce 0% tax rate
-		taxRate := line.TaxPercentage
-		if categoryID == "K" || categoryID == "AE" {
-			taxRate = 0
+	var lineExtension, allowanceTotal, chargeTotal, tax money
+	firstLines := map[taxKey]int{}
+	var lineFloats []float64
+	amounts, rounding := lineAmounts(lines, decimals)
+	for i, line := range lines {
+		lineAmount := amounts[i]
+		lineFloats = append(lineFloats, lineAmount.float(decimals))
+		cat := line.taxCategory()
+		key := taxKey{Rate: cat.rate(), CategoryID: cat.ID}
+
+		lineExtension += lineAmount
+
+		s := summary(key, cat.Name)
+		s.lines += lineAmount
+
+		// the VAT breakdown has one exemption reason per category and rate,
+		// that of the first line (see checkExemptionReasons)
+		if _, ok := firstLines[key]; !ok {
+			firstLines[key] = i
+			s.exemptionCode = cat.TaxExemptionReasonCode
+			s.exemption = cat.TaxExemptionReason
 		}
+	}
 
-		tax := round(lineAmount * taxRate / 100)
-
-		key := taxKey{Rate: taxRate, CategoryID: categoryID}
+	sortTaxKeys(keys)
+	lineTotals := make([]money, len(keys))
+	for i, key := range keys {
+		lineTotals[i] = summaries[key].lines
+	}
 
-		lineTotal = round(lineTotal + lineAmount)
-		taxTotal = round(taxTotal + tax)
+	totals.Currency = currency
+	totals.AllowanceCharges, err = resolveAllowanceCharges(allowanceCharges, lineExtension, keys, lineTotals, decimals)
+	if err != nil {
+		return
+	}
 
-		if summaries[key] == nil {
-			summaries[key] = &taxSummary{key: key, catName: categoryName}
+	for _, ac := range totals.AllowanceCharges {
+		s := summary(ac.taxKey(), "")
+		amount := toMoney(ac.Amount, decimals)
+		if ac.Charge {
+			s.charges += amount
+			chargeTotal += amount
+		} else {
+			s.allowances += amount
+			allowanceTotal += amount
 		}
-		summaries[key].taxable = round(summaries[key].taxable + lineAmount)
-		summaries[key].tax = round(summaries[key].tax + tax)
+		xmlAllowanceCharges = append(xmlAllowanceCharges, ac.xml(currency, taxScheme))
 	}
 
-	for _, summary := range summaries {
-		taxCat := xmlTaxCategory{
+	sortTaxKeys(keys)
+	for _, key := range keys {
+		summary := summaries[key]
+		summary.taxable = summary.lines - summary.allowances + summary.charges
+		summary.tax = summary.taxable.percent(summary.key.Rate)
+		tax += summary.tax
+
+		totals.Breakdown = append(totals.Breakdown, TaxBreakdown{
+			CategoryID:      summary.key.CategoryID,
+			Percent:         summary.key.Rate,
+			LineAmount:      summary.lines.float(decimals),
+			AllowanceAmount: summary.allowances.float(decimals),
+			ChargeAmount:    summary.charges.float(decimals),
+			TaxableAmount:   summary.taxable.float(decimals),
+			TaxAmount:       summary.tax.float(decimals),
+		})
+
+		taxCat := XMLTaxCategory{
 			ID:        summary.key.CategoryID,
 			Name:      summary.catName,
-			Percent:   summary.key.Rate,
-			TaxScheme: xmlTaxScheme{ID: "VAT"},
-		}
-
-		// For intra-community supply (K), add exemption reason code
-		if summary.key.CategoryID == "K" {
-			taxCat.TaxExemptionReasonCode = "VATEX-EU-IC"
-			taxCat.TaxExemptionReason = "Intra-community supply"
+			Percent:   taxPercent(summary.key.CategoryID, summary.key.Rate),
+			TaxScheme: XMLTaxScheme{ID: taxScheme},
 		}
 
-		// For reverse charge (AE), add exemption reason code
-		if summary.key.CategoryID == "AE" {
-			taxCat.TaxExemptionReasonCode = "VATEX-EU-AE"
-			taxCat.TaxExemptionReason = "Reverse charge"
+		taxCat.TaxExemptionReasonCode = summary.exemptionCode
+		taxCat.TaxExemptionReason = summary.exemption
+		// a category only used by allowances and charges gets the usual reason
+		if summary.exemptionCode == "" && summary.exemption == "" {
+			defaults := taxExemptionDefaults[summary.key.CategoryID]
+			taxCat.TaxExemptionReasonCode = defaults.code
+			taxCat.TaxExemptionReason = defaults.reason
 		}
 
-		subtotals = append(subtotals, xmlTaxSubtotal{
-			TaxableAmount: xmlAmount{Value: round(summary.taxable), CurrencyID: "EUR"},
-			TaxAmount:     xmlAmount{Value: round(summary.tax), CurrencyID: "EUR"},
+		subtotals = append(subtotals, XMLTaxSubtotal{
+			TaxableAmount: XMLAmount{Value: summary.taxable.float(decimals), CurrencyID: currency},
+			TaxAmount:     XMLAmount{Value: summary.tax.float(decimals), CurrencyID: currency},
 			TaxCategory:   taxCat,
 		})
 	}
 
+	taxExclusive := lineExtension - allowanceTotal + chargeTotal
+	totals.LineExtension = lineExtension.float(decimals)
+	totals.AllowanceTotal = allowanceTotal.float(decimals)
+	totals.ChargeTotal = chargeTotal.float(decimals)
+	totals.TaxExclusive = taxExclusive.float(decimals)
+	totals.Tax = tax.float(decimals)
+	totals.TaxInclusive = (taxExclusive + tax).float(decimals)
+	// the rounding amount only settles lines that include VAT as a whole,
+	// allowances and charges are net amounts
+	if len(totals.AllowanceCharges) == 0 {
+		totals.Rounding = rounding.float(decimals)
+	}
+	totals.Payable = (taxExclusive + tax + toMoney(totals.Rounding, decimals)).float(decimals)
+
+	err = totals.checkCalculation(lineFloats, decimals)
 	return
 }
 
-func (inv *Invoice) addLines() {
-	for i, line := range inv.Lines {
-		lineAmount := round(line.Quantity * line.Price)
-		tax := round(lineAmount * line.TaxPercentage / 100)
-
-		// Default to "S" (Standard rated) if not specified
-		categoryID := line.TaxCategoryID
-		if categoryID == "" {
-			categoryID = "S"
-		}
-		categoryName := line.TaxCategoryName
-		if categoryName == "" {
-			categoryName = "Standard rated"
+// sortTaxKeys orders the VAT breakdown by category and rate.
+func sortTaxKeys(keys []taxKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CategoryID != keys[j].CategoryID {
+			return keys[i].CategoryID < keys[j].CategoryID
 		}
+		return keys[i].Rate > keys[j].Rate
+	})
+}
 
-		// For intra-community supply (K) and reverse charge (AE), enforce 0% tax rate
-		taxRate := line.TaxPercentage
-		if categoryID == "K" || categoryID == "AE" {
-			taxRate = 0
-			tax = 0
+// monetaryTotal returns the cac:LegalMonetaryTotal element for the totals.
+func (t Totals) monetaryTotal(currency string) XMLMonetaryTotal {
+	mt := XMLMonetaryTotal{
+		LineExtensionAmount: XMLAmount{Value: t.LineExtension, CurrencyID: currency},
+		TaxExclusiveAmount:  XMLAmount{Value: t.TaxExclusive, CurrencyID: currency},
+		TaxInclusiveAmount:  XMLAmount{Value: t.TaxInclusive, CurrencyID: currency},
+		PayableAmount:       XMLAmount{Value: t.Payable, CurrencyID: currency},
+	}
+	if t.Rounding != 0 {
+		mt.PayableRoundingAmount = &XMLAmount{Value: t.Rounding, CurrencyID: currency}
+	}
+	for _, ac := range t.AllowanceCharges {
+		if ac.Charge {
+			mt.ChargeTotalAmount = &XMLAmount{Value: t.ChargeTotal, CurrencyID: currency}
+		} else {
+			mt.AllowanceTotalAmount = &XMLAmount{Value: t.AllowanceTotal, CurrencyID: currency}
 		}
+	}
+	return mt
+}
 
-		taxCat := xmlTaxCategory{
-			ID:        categoryID,
-			Name:      categoryName,
-			Percent:   taxRate,
-			TaxScheme: xmlTaxScheme{ID: "VAT"},
-		}
+// now returns the time the dates are derived from.
+func (inv *Invoice) now() time.Time {
+	if inv.Now != nil {
+		return inv.Now()
+	}
+	return time.Now()
+}
 
-		// Add exemption reason for intra-community supply
-		if categoryID == "K" {
-			if line.TaxExemptionCode != "" {
-				taxCat.TaxExemptionReasonCode = line.TaxExemptionCode
-			} else {
-				taxCat.TaxExemptionReasonCode = "VATEX-EU-IC"
-			}
-			if line.TaxExemptionReason != "" {
-				taxCat.TaxExemptionReason = line.TaxExemptionReason
-			} else {
-				taxCat.TaxExemptionReason = "Intra-community supply"
-			}
-		}
+// currency returns the document currency code, EUR when not set.
+func (inv *Invoice) currency() string {
+	if inv.Currency == "" {
+		return "EUR"
+	}
+	return inv.Currency
+}
 
-		// Add exemption reason for reverse charge
-		if categoryID == "AE" {
-			if line.TaxExemptionCode != "" {
-				taxCat.TaxExemptionReasonCode = line.TaxExemptionCode
-			} else {
-				taxCat.TaxExemptionReasonCode = "VATEX-EU-AE"
-			}
-			if line.TaxExemptionReason != "" {
-				taxCat.TaxExemptionReason = line.TaxExemptionReason
-			} else {
-				taxCat.TaxExemptionReason = "Reverse charge"
-			}
-		}
+func (inv *Invoice) taxScheme() string {
+	if inv.TaxSchemeID == "" {
+		return "VAT"
+	}
+	return inv.TaxSchemeID
+}
 
-		inv.xml.InvoiceLines = append(inv.xml.InvoiceLines, xmlInvoiceLine{
-			ID:                  strconv.Itoa(i + 1),
-			InvoicedQuantity:    xmlQuantity{Value: line.Quantity, UnitCode: "ZZ"},
-			LineExtensionAmount: xmlAmount{Value: lineAmount, CurrencyID: "EUR"},
-			TaxTotal:            xmlTaxTotal{TaxAmount: xmlAmount{Value: tax, CurrencyID: "EUR"}},
-			Item: xmlItem{
-				Name:                  line.Name,
-				Description:           line.Description,
-				ClassifiedTaxCategory: taxCat,
+func (inv *Invoice) addLines(doc *XMLInvoice) error {
+	currency := inv.currency()
+	taxScheme := inv.taxScheme()
+	decimals := minorUnits(currency)
+	doc.InvoiceLines = make([]XMLInvoiceLine, 0, len(inv.Lines))
+	amounts, _ := lineAmounts(inv.Lines, decimals)
+	for i, line := range inv.Lines {
+		unit, err := unitCode(line.UnitCode)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		classifications, err := commodityClassifications(line.Classifications)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		properties, err := additionalItemProperties(line.Attributes)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		price, err := linePrice(line, currency)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		lineAmount := amounts[i]
+		taxCat := line.taxCategory()
+		taxCat.TaxScheme.ID = taxScheme
+		tax := lineAmount.percent(taxCat.rate()).float(decimals)
+
+		doc.InvoiceLines = append(doc.InvoiceLines, XMLInvoiceLine{
+			ID:                  line.id(i),
+			Note:                line.Note,
+			InvoicedQuantity:    XMLQuantity{Value: line.Quantity, UnitCode: unit},
+			LineExtensionAmount: XMLAmount{Value: lineAmount.float(decimals), CurrencyID: currency},
+			AccountingCost:      line.AccountingCost,
+			OrderLineReference:  orderLineReference(line.OrderLineID),
+			DocumentReference:   lineObjectReference(line.ObjectID, line.ObjectIDScheme),
+			TaxTotal:            XMLTaxTotal{TaxAmount: XMLAmount{Value: tax, CurrencyID: currency}},
+			Item: XMLItem{
+				Name:                       line.Name,
+				Description:                line.Description,
+				StandardItemIdentification: standardItemIdentification(line.StandardItemID, line.StandardItemIDScheme),
+				CommodityClassification:    classifications,
+				ClassifiedTaxCategory:      taxCat,
+				AdditionalItemProperty:     properties,
 			},
-			Price: xmlPrice{PriceAmount: xmlAmount{Value: line.Price, CurrencyID: "EUR"}},
+			Price: price,
 		})
 	}
 
-	lineTotal, taxTotal, subtotals := calculateTaxTotals(inv.Lines)
-	total := round(lineTotal + taxTotal)
+	totals, subtotals, allowanceCharges, err := calculateTaxTotals(inv.Lines, inv.AllowanceCharges, currency, inv.taxScheme())
+	if err != nil {
+		return err
+	}
+	inv.totals = totals
+
+	doc.AllowanceCharge = allowanceCharges
 
-	inv.xml.TaxTotal = xmlTaxTotal{
-		TaxAmount:   xmlAmount{Value: taxTotal, CurrencyID: "EUR"},
+	doc.TaxTotal = XMLTaxTotal{
+		TaxAmount:   XMLAmount{Value: totals.Tax, CurrencyID: currency},
 		TaxSubtotal: subtotals,
 	}
 
-	inv.xml.LegalMonetaryTotal = xmlMonetaryTotal{
-		LineExtensionAmount: xmlAmount{Value: lineTotal, CurrencyID: "EUR"},
-		TaxExclusiveAmount:  xmlAmount{Value: lineTotal, CurrencyID: "EUR"},
-		TaxInclusiveAmount:  xmlAmount{Value: total, CurrencyID: "EUR"},
-		PayableAmount:       xmlAmount{Value: total, CurrencyID: "EUR"},
-	}
+	doc.LegalMonetaryTotal = totals.monetaryTotal(currency)
+
+	return nil
 }
 
 type CreditNote struct {
-	xml                      *xmlCreditNote
-	ID                       string
-	CustomizationID          string
-	ProfileID                string
-	SupplierName             string
-	SupplierVat              string
-	SupplierPeppolID         string
-	SupplierAddress          Address
-	CustomerName             string
-	CustomerVat              string
-	CustomerPeppolID         string
-	CustomerAddress          Address
-	DeliveryAddress          *Address   // Optional: required for intra-community supply (BT-80)
-	ActualDeliveryDate       *time.Time // Optional: required for intra-community supply (BT-72)
-	InvoicePeriodStart       *time.Time // Optional: alternative to delivery date for IC supply (BG-14)
-	InvoicePeriodEnd         *time.Time // Optional: alternative to delivery date for IC supply (BG-14)
-	Iban                     string
-	Bic                      string
-	Note                     string
-	Lines                    []InvoiceLine
-	PdfCreditNoteFilename    string
-	PdfCreditNoteData        string
-	PdfCreditNoteDescription string
-}
-
-type xmlCreditNote struct {
+	ID                       string                         `json:"id"`
+	UUID                     string                         `json:"uuid,omitempty"`                   // Optional: universally unique identifier of the document, filled in when generating with GenerateUUID
+	GenerateUUID             bool                           `json:"generateUUID,omitempty"`           // Optional: generate a random UUID when UUID is empty
+	CustomizationID          string                         `json:"customizationID,omitempty"`        // Optional: defaults to PeppolBilling30CustomizationID
+	ProfileID                string                         `json:"profileID,omitempty"`              // Optional: defaults to PeppolBilling30ProfileID
+	NoDefaultSpecification   bool                           `json:"noDefaultSpecification,omitempty"` // Optional: leave empty CustomizationID and ProfileID empty
+	Profile                  Profile                        `json:"profile,omitempty"`                // Optional: CIUS with its default CustomizationID and extra rules, defaults to ProfilePeppol
+	BuyerReference           string                         `json:"buyerReference,omitempty"`         // Optional: buyer reference (BT-10), the Leitweg-ID for XRechnung
+	IncludeUBLBEReference    bool                           `json:"includeUBLBEReference,omitempty"`  // Optional: add the UBL.BE document reference outside ProfileUBLBE, with or without attachments
+	UBLBEDescription         string                         `json:"ublBEDescription,omitempty"`       // Optional: DocumentDescription of the UBL.BE reference, defaults to "CreditNote"
+	SelfBilling              bool                           `json:"selfBilling,omitempty"`            // Optional: issued by the buyer on behalf of the supplier, which stays AccountingSupplierParty
+	Now                      func() time.Time               `json:"-"`                                // Optional: clock for the issue date, defaults to time.Now; pin it for reproducible output
+	CreditNoteTypeCode       string                         `json:"creditNoteTypeCode,omitempty"`     // Optional: UNCL1001 code (BT-3), defaults to 381 (261 with SelfBilling)
+	OriginalInvoiceID        string                         `json:"originalInvoiceID,omitempty"`      // Optional: invoice the credit note corrects (BT-25); a warning is given without it
+	OriginalInvoiceDate      *time.Time                     `json:"originalInvoiceDate,omitempty"`    // Optional: issue date of that invoice (BT-26)
+	RequireOriginalInvoice   bool                           `json:"requireOriginalInvoice,omitempty"` // Optional: fail instead of warning without OriginalInvoiceID
+	SupplierName             string                         `json:"supplierName"`
+	SupplierTradingName      string                         `json:"supplierTradingName,omitempty"` // Optional: seller trading name (BT-28), defaults to SupplierName
+	SupplierVat              string                         `json:"supplierVat"`
+	SupplierPeppolID         string                         `json:"supplierPeppolID"`
+	SupplierEndpointScheme   string                         `json:"supplierEndpointScheme,omitempty"` // Optional: EAS code of the supplier endpoint (BT-34-1), with SupplierEndpointValue
+	SupplierEndpointValue    string                         `json:"supplierEndpointValue,omitempty"`  // Optional: supplier endpoint identifier (BT-34), overrides SupplierPeppolID
+	SupplierAddress          Address                        `json:"supplierAddress"`
+	SupplierContact          Contact                        `json:"supplierContact,omitempty"`          // Optional: seller contact (BG-6)
+	SupplierLegalForm        string                         `json:"supplierLegalForm,omitempty"`        // Optional: seller additional legal information (BT-33)
+	SupplierCompanyID        string                         `json:"supplierCompanyID,omitempty"`        // Optional: seller legal registration identifier (BT-30), e.g. the KBO number
+	SupplierCompanyIDScheme  string                         `json:"supplierCompanyIDScheme,omitempty"`  // Optional: scheme of SupplierCompanyID, e.g. "0208"
+	SupplierAdditionalIDs    []PartyID                      `json:"supplierAdditionalIDs,omitempty"`    // Optional: seller identifiers (BT-29), e.g. a GLN
+	SupplierTaxRegistrations []TaxRegistration              `json:"supplierTaxRegistrations,omitempty"` // Optional: seller tax registrations other than VAT (BT-32)
+	CustomerName             string                         `json:"customerName"`
+	CustomerTradingName      string                         `json:"customerTradingName,omitempty"` // Optional: buyer trading name (BT-45), defaults to CustomerName
+	CustomerVat              string                         `json:"customerVat"`
+	CustomerPeppolID         string                         `json:"customerPeppolID"`
+	CustomerEndpointScheme   string                         `json:"customerEndpointScheme,omitempty"` // Optional: EAS code of the customer endpoint (BT-49-1), with CustomerEndpointValue
+	CustomerEndpointValue    string                         `json:"customerEndpointValue,omitempty"`  // Optional: customer endpoint identifier (BT-49), overrides CustomerPeppolID
+	CustomerAddress          Address                        `json:"customerAddress"`
+	CustomerAdditionalIDs    []PartyID                      `json:"customerAdditionalIDs,omitempty"`    // Optional: buyer identifiers (BT-46), e.g. a GLN
+	DeliveryAddress          *Address                       `json:"deliveryAddress,omitempty"`          // Optional: required for intra-community supply (BT-80)
+	ActualDeliveryDate       *time.Time                     `json:"actualDeliveryDate,omitempty"`       // Optional: required for intra-community supply (BT-72)
+	DeliveryLocationID       string                         `json:"deliveryLocationID,omitempty"`       // Optional: deliver-to location identifier (BT-71), e.g. a GLN
+	DeliveryLocationIDScheme string                         `json:"deliveryLocationIDScheme,omitempty"` // Optional: scheme of DeliveryLocationID, e.g. "0088"
+	DeliveryPartyName        string                         `json:"deliveryPartyName,omitempty"`        // Optional: deliver-to party name (BT-70)
+	InvoicePeriodStart       *time.Time                     `json:"invoicePeriodStart,omitempty"`       // Optional: alternative to delivery date for IC supply (BG-14)
+	InvoicePeriodEnd         *time.Time                     `json:"invoicePeriodEnd,omitempty"`         // Optional: alternative to delivery date for IC supply (BG-14)
+	Iban                     string                         `json:"iban,omitempty"`
+	Bic                      string                         `json:"bic,omitempty"`
+	AccountName              string                         `json:"accountName,omitempty"`       // Optional: payment account name (BT-85), e.g. the account holder
+	BankAccounts             []BankAccount                  `json:"bankAccounts,omitempty"`      // Optional: accounts the buyer can choose from; overrides Iban, Bic and AccountName
+	DirectDebit              *DirectDebit                   `json:"directDebit,omitempty"`       // Optional: collect by direct debit instead of credit transfer
+	PaymentMeansCode         string                         `json:"paymentMeansCode,omitempty"`  // Optional: UNCL4461 code (BT-81), e.g. "58" for SEPA credit transfer; defaults to "30" with an IBAN
+	PaymentMeansName         string                         `json:"paymentMeansName,omitempty"`  // Optional: payment means text (BT-82)
+	Note                     string                         `json:"note,omitempty"`              // Optional: payment terms (BT-20); line breaks start a new cbc:Note
+	PaymentTermsNotes        []string                       `json:"paymentTermsNotes,omitempty"` // Optional: more payment terms, e.g. "2% discount if paid within 10 days"
+	Currency                 string                         `json:"currency,omitempty"`          // Optional: document currency code (BT-5), defaults to EUR
+	TaxSchemeID              string                         `json:"taxSchemeID,omitempty"`       // Optional: tax scheme of the parties and tax categories, e.g. "GST"; defaults to "VAT"
+	Lines                    []InvoiceLine                  `json:"lines"`
+	AllowanceCharges         []AllowanceCharge              `json:"allowanceCharges,omitempty"`      // Optional: document level allowances (BG-20) and charges (BG-21)
+	OrderReferenceID         string                         `json:"orderReferenceID,omitempty"`      // Optional: shortcut for OrderReference.PurchaseOrderID
+	OrderReference           *OrderRef                      `json:"orderReference,omitempty"`        // Optional: order reference (BT-13/BT-14), defaults to the credit note ID
+	RequireOrderReference    bool                           `json:"requireOrderReference,omitempty"` // Optional: fail instead of warning when a line has an OrderLineID without purchase order reference
+	RequireSameExemption     bool                           `json:"requireSameExemption,omitempty"`  // Optional: fail instead of warning when lines of one VAT category and rate have different exemption reasons
+	PdfCreditNoteFilename    string                         `json:"pdfCreditNoteFilename,omitempty"`
+	PdfCreditNoteData        string                         `json:"pdfCreditNoteData,omitempty"`
+	PdfCreditNoteDescription string                         `json:"pdfCreditNoteDescription,omitempty"`
+	Attachments              []Attachment                   `json:"attachments,omitempty"`        // Optional: supporting documents (BG-24) after the PDF, see AddAttachmentFromBytes and AddExternalReference
+	Extensions               []Extension                    `json:"extensions,omitempty"`         // Optional: UBL extensions, written verbatim in ext:UBLExtensions before all other elements
+	MaxAttachmentBytes       int                            `json:"maxAttachmentBytes,omitempty"` // Optional: largest attachment or PDF before base64 encoding; defaults to 10 MB, negative for no limit
+	TextFilters              []TextFilter                   `json:"-"`                            // Optional: applied to free-text fields before generation
+	ReceiverQuirks           ReceiverQuirks                 `json:"-"`                            // Optional: receiver specific tweaks, applied just before marshalling
+	BeforeMarshal            func(doc *XMLCreditNote) error `json:"-"`                            // Optional: changes the low-level model after the ReceiverQuirks; an error aborts generation
+	XMLDeclaration           XMLDeclaration                 `json:"xmlDeclaration,omitempty"`     // Optional: the <?xml ...?> declaration, defaults to version 1.0 in UTF-8
+	Namespaces               XMLNamespaces                  `json:"namespaces,omitempty"`         // Optional: namespace prefixes, defaults to the document namespace as default namespace, "cac" and "cbc"
+	warnings                 []string
+	totals                   Totals
+	effective                effectiveValues
+}
+
+// XMLCreditNote is the low-level model of the generated credit note, like
+// XMLInvoice.
+type XMLCreditNote struct {
 	XMLName                     xml.Name               `xml:"CreditNote"`
 	Xmlns                       string                 `xml:"xmlns,attr"`
 	Cac                         string                 `xml:"xmlns:cac,attr"`
 	Cbc                         string                 `xml:"xmlns:cbc,attr"`
+	Ext                         string                 `xml:"xmlns:ext,attr,omitempty"`
+	UBLExtensions               *XMLUBLExtensions      `xml:"ext:UBLExtensions,omitempty"`
 	CustomizationID             string                 `xml:"cbc:CustomizationID"`
 	ProfileID                   string                 `xml:"cbc:ProfileID"`
 	ID                          string                 `xml:"cbc:ID"`
+	UUID                        string                 `xml:"cbc:UUID,omitempty"`
 	IssueDate                   string                 `xml:"cbc:IssueDate"`
 	CreditNoteTypeCode          string                 `xml:"cbc:CreditNoteTypeCode"`
+	Note                        []string               `xml:"cbc:Note"`
 	DocumentCurrency            string                 `xml:"cbc:DocumentCurrencyCode"`
-	InvoicePeriod               *xmlInvoicePeriod      `xml:"cac:InvoicePeriod,omitempty"`
-	OrderReference              string                 `xml:"cac:OrderReference>cbc:ID"`
-	AdditionalDocumentReference []xmlDocumentReference `xml:"cac:AdditionalDocumentReference,omitempty"`
-	SupplierParty               xmlSupplierParty       `xml:"cac:AccountingSupplierParty"`
-	CustomerParty               xmlCustomerParty       `xml:"cac:AccountingCustomerParty"`
-	Delivery                    *xmlDelivery           `xml:"cac:Delivery,omitempty"`
-	PaymentMeans                xmlPaymentMeans        `xml:"cac:PaymentMeans"`
-	PaymentTerms                xmlPaymentTerms        `xml:"cac:PaymentTerms,omitempty"`
-	TaxTotal                    xmlTaxTotal            `xml:"cac:TaxTotal"`
-	LegalMonetaryTotal          xmlMonetaryTotal       `xml:"cac:LegalMonetaryTotal"`
-	CreditNoteLines             []xmlCreditNoteLine    `xml:"cac:CreditNoteLine"`
-}
-
-type xmlCreditNoteLine struct {
-	ID                  string      `xml:"cbc:ID"`
-	CreditedQuantity    xmlQuantity `xml:"cbc:CreditedQuantity"`
-	LineExtensionAmount xmlAmount   `xml:"cbc:LineExtensionAmount"`
-	Item                xmlItem     `xml:"cac:Item"`
-	Price               xmlPrice    `xml:"cac:Price"`
+	BuyerReference              string                 `xml:"cbc:BuyerReference,omitempty"`
+	InvoicePeriod               *XMLInvoicePeriod      `xml:"cac:InvoicePeriod,omitempty"`
+	OrderReference              *XMLOrderReference     `xml:"cac:OrderReference,omitempty"`
+	BillingReference            []XMLBillingReference  `xml:"cac:BillingReference"`
+	AdditionalDocumentReference []XMLDocumentReference `xml:"cac:AdditionalDocumentReference,omitempty"`
+	Signature                   []XMLSignature         `xml:"cac:Signature"`
+	SupplierParty               XMLSupplierParty       `xml:"cac:AccountingSupplierParty"`
+	CustomerParty               XMLCustomerParty       `xml:"cac:AccountingCustomerParty"`
+	Delivery                    *XMLDelivery           `xml:"cac:Delivery,omitempty"`
+	PaymentMeans                []XMLPaymentMeans      `xml:"cac:PaymentMeans"`
+	PaymentTerms                *XMLPaymentTerms       `xml:"cac:PaymentTerms,omitempty"`
+	AllowanceCharge             []XMLAllowanceCharge   `xml:"cac:AllowanceCharge"`
+	TaxTotal                    XMLTaxTotal            `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal          XMLMonetaryTotal       `xml:"cac:LegalMonetaryTotal"`
+	CreditNoteLines             []XMLCreditNoteLine    `xml:"cac:CreditNoteLine"`
 }
 
+// XMLCreditNoteLine is cac:CreditNoteLine.
+type XMLCreditNoteLine struct {
+	ID                  string                    `xml:"cbc:ID"`
+	Note                string                    `xml:"cbc:Note,omitempty"`
+	CreditedQuantity    XMLQuantity               `xml:"cbc:CreditedQuantity"`
+	LineExtensionAmount XMLAmount                 `xml:"cbc:LineExtensionAmount"`
+	AccountingCost      string                    `xml:"cbc:AccountingCost,omitempty"`
+	OrderLineReference  *XMLOrderLineReference    `xml:"cac:OrderLineReference,omitempty"`
+	DocumentReference   *XMLLineDocumentReference `xml:"cac:DocumentReference,omitempty"`
+	Item                XMLItem                   `xml:"cac:Item"`
+	Price               XMLPrice                  `xml:"cac:Price"`
+}
+
+// GenerateCreditNote generates the credit note as a UBL 2.1 XML document,
+// reproducible like Invoice.Generate.
 func (cn *CreditNote) GenerateCreditNote() ([]byte, error) {
-	cn.xml = &xmlCreditNote{
-		Xmlns:              "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2",
-		Cac:                "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
-		Cbc:                "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
-		CustomizationID:    cn.CustomizationID,
-		ProfileID:          cn.ProfileID,
+	doc, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return nil, err
+	}
+	return doc.Marshal()
+}
+
+// GenerateCreditNoteTo generates the credit note like GenerateCreditNote and
+// writes it to w, like Invoice.GenerateTo.
+func (cn *CreditNote) GenerateCreditNoteTo(w io.Writer) error {
+	doc, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return err
+	}
+	return doc.writeTo(w)
+}
+
+// build returns the XML model of the credit note. Every call starts from
+// scratch, so the credit note can be changed and generated again.
+func (cn *CreditNote) build() (*XMLCreditNote, error) {
+	cn.warnings = nil
+	err := cn.Check()
+	if err != nil {
+		return nil, err
+	}
+	err = documentUUID(&cn.UUID, cn.GenerateUUID)
+	if err != nil {
+		return nil, err
+	}
+	customizationID, profileID, err := specification(cn.CustomizationID, cn.ProfileID, cn.Profile, cn.SelfBilling, cn.NoDefaultSpecification)
+	if err != nil {
+		return nil, err
+	}
+	now := cn.now()
+	doc := &XMLCreditNote{
+		UBLExtensions:      ublExtensions(cn.Extensions),
+		CustomizationID:    customizationID,
+		ProfileID:          profileID,
 		ID:                 cn.ID,
-		IssueDate:          time.Now().Format("2006-01-02"),
-		CreditNoteTypeCode: "381",
-		DocumentCurrency:   "EUR",
-		OrderReference:     cn.ID,
+		UUID:               cn.UUID,
+		IssueDate:          now.Format("2006-01-02"),
+		CreditNoteTypeCode: creditNoteTypeCode(cn.CreditNoteTypeCode, cn.SelfBilling),
+		DocumentCurrency:   cn.currency(),
+		BuyerReference:     cn.BuyerReference,
+	}
+
+	orderRef, err := orderReference(cn.OrderReference, cn.OrderReferenceID, cn.ID)
+	if err != nil {
+		return nil, err
+	}
+	doc.OrderReference = orderRef
+	orderLineWarnings, err := checkOrderLineReferences(cn.Lines, cn.OrderReference, cn.OrderReferenceID, cn.RequireOrderReference)
+	if err != nil {
+		return nil, err
+	}
+	cn.warnings = append(cn.warnings, orderLineWarnings...)
+	exemptionWarnings, err := checkExemptionReasons(cn.Lines, cn.RequireSameExemption)
+	if err != nil {
+		return nil, err
+	}
+	cn.warnings = append(cn.warnings, exemptionWarnings...)
+
+	if cn.OriginalInvoiceID == "" {
+		if cn.RequireOriginalInvoice {
+			return nil, fmt.Errorf("credit note: OriginalInvoiceID required")
+		}
+		cn.warnings = append(cn.warnings, "credit note without OriginalInvoiceID: many buyers reject it")
+	}
+	doc.BillingReference = billingReference(cn.OriginalInvoiceID, cn.OriginalInvoiceDate)
+
+	supplierEndpoint, err := endpointID("SupplierPeppolID", cn.SupplierPeppolID, cn.SupplierEndpointScheme, cn.SupplierEndpointValue)
+	if err != nil {
+		return nil, err
+	}
+	customerEndpoint, err := endpointID("CustomerPeppolID", cn.CustomerPeppolID, cn.CustomerEndpointScheme, cn.CustomerEndpointValue)
+	if err != nil {
+		return nil, err
 	}
 
 	// Clean and validate VAT identifiers
-	supplierVat := cleanVATIdentifier(cn.SupplierVat, cn.SupplierAddress.CountryCode)
-	customerVat := cleanVATIdentifier(cn.CustomerVat, cn.CustomerAddress.CountryCode)
-
-	cn.xml.SupplierParty = xmlSupplierParty{
-		Party: xmlParty{
-			EndpointID: xmlEndpointID{
-				Value:    cn.SupplierPeppolID[5:],
-				SchemeID: cn.SupplierPeppolID[0:4],
-			},
-			PartyName:        cn.SupplierName,
-			RegistrationName: cn.SupplierName,
-			PartyTaxScheme: xmlPartyTaxScheme{
-				CompanyID: supplierVat,
-				TaxScheme: xmlTaxScheme{
-					ID: "VAT",
-				},
+	supplierTaxSchemes, err := supplierTaxSchemes(cn.SupplierVat, cn.SupplierAddress.CountryCode, cn.taxScheme(), cn.SupplierTaxRegistrations, cn.Lines)
+	if err != nil {
+		return nil, err
+	}
+	customerVat, customerTaxScheme, err := customerTaxIdentifier(cn.CustomerVat, cn.CustomerAddress.CountryCode)
+	if err != nil {
+		return nil, err
+	}
+	if cn.TaxSchemeID != "" {
+		customerTaxScheme = cn.TaxSchemeID
+	}
+
+	doc.SupplierParty = XMLSupplierParty{
+		Party: XMLParty{
+			EndpointID:          supplierEndpoint,
+			PartyIdentification: append(partyIdentifications(cn.SupplierAdditionalIDs), creditorIdentification(cn.DirectDebit)...),
+			PartyName:           tradingName(cn.SupplierTradingName, cn.SupplierName),
+			PartyLegalEntity: XMLPartyLegalEntity{
+				RegistrationName: cn.SupplierName,
+				CompanyID:        companyID(cn.SupplierCompanyID, cn.SupplierCompanyIDScheme),
+				CompanyLegalForm: cn.SupplierLegalForm,
 			},
+			PartyTaxScheme: supplierTaxSchemes,
 		},
 	}
 
-	cn.xml.SupplierParty.Party.PostalAddress = xmlPostalAddress{
-		StreetName: cn.SupplierAddress.StreetName,
-		CityName:   cn.SupplierAddress.CityName,
-		PostalZone: cn.SupplierAddress.PostalZone,
-		Country:    xmlCountry{IdentificationCode: cn.SupplierAddress.CountryCode},
-	}
+	doc.SupplierParty.Party.Contact = cn.SupplierContact.xml()
 
-	cn.xml.CustomerParty.Party.PostalAddress = xmlPostalAddress{
-		StreetName: cn.CustomerAddress.StreetName,
-		CityName:   cn.CustomerAddress.CityName,
-		PostalZone: cn.CustomerAddress.PostalZone,
-		Country:    xmlCountry{IdentificationCode: cn.CustomerAddress.CountryCode},
-	}
+	doc.SupplierParty.Party.PostalAddress = cn.SupplierAddress.xml()
 
-	cn.xml.CustomerParty = xmlCustomerParty{
-		Party: xmlParty{
-			EndpointID: xmlEndpointID{
-				Value:    cn.CustomerPeppolID[5:],
-				SchemeID: cn.CustomerPeppolID[0:4],
+	doc.CustomerParty = XMLCustomerParty{
+		Party: XMLParty{
+			EndpointID:          customerEndpoint,
+			PartyIdentification: partyIdentifications(cn.CustomerAdditionalIDs),
+			PartyName:           tradingName(cn.CustomerTradingName, cn.CustomerName),
+			PartyLegalEntity: XMLPartyLegalEntity{
+				RegistrationName: cn.CustomerName,
 			},
-			PartyName:        cn.CustomerName,
-			RegistrationName: cn.CustomerName,
-			PartyTaxScheme: xmlPartyTaxScheme{
+			PartyTaxScheme: []XMLPartyTaxScheme{{
 				CompanyID: customerVat,
-				TaxScheme: xmlTaxScheme{
-					ID: "VAT",
-				},
-			},
-			PostalAddress: xmlPostalAddress{
-				Country: xmlCountry{
-					IdentificationCode: cn.CustomerAddress.CountryCode,
+				TaxScheme: XMLTaxScheme{
+					ID: customerTaxScheme,
 				},
-			},
+			}},
+			PostalAddress: cn.CustomerAddress.xml(),
 		},
 	}
 
 	// Add delivery information if provided (required for intra-community supply)
-	if cn.DeliveryAddress != nil {
-		cn.xml.Delivery = &xmlDelivery{
-			ActualDeliveryDate: "",
-			DeliveryLocation: xmlDeliveryLocation{
-				Address: xmlPostalAddress{
-					StreetName: cn.DeliveryAddress.StreetName,
-					CityName:   cn.DeliveryAddress.CityName,
-					PostalZone: cn.DeliveryAddress.PostalZone,
-					Country: xmlCountry{
-						IdentificationCode: cn.DeliveryAddress.CountryCode,
-					},
-				},
-			},
-		}
-	}
+	doc.Delivery = delivery(cn.DeliveryAddress, cn.ActualDeliveryDate, cn.DeliveryLocationID, cn.DeliveryLocationIDScheme, cn.DeliveryPartyName)
 
-	// Add actual delivery date if provided
-	if cn.ActualDeliveryDate != nil {
-		if cn.xml.Delivery == nil {
-			cn.xml.Delivery = &xmlDelivery{}
-		}
-		cn.xml.Delivery.ActualDeliveryDate = cn.ActualDeliveryDate.Format("2006-01-02")
+	// Add invoicing period if provided (alternative to delivery date)
+	doc.InvoicePeriod, err = invoicePeriod(cn.InvoicePeriodStart, cn.InvoicePeriodEnd)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add invoicing period if provided (alternative to delivery date)
-	if cn.InvoicePeriodStart != nil && cn.InvoicePeriodEnd != nil {
-		cn.xml.InvoicePeriod = &xmlInvoicePeriod{
-			StartDate: cn.InvoicePeriodStart.Format("2006-01-02"),
-			EndDate:   cn.InvoicePeriodEnd.Format("2006-01-02"),
-		}
+	if cn.DirectDebit != nil {
+		doc.PaymentMeans, err = directDebitPaymentMeans(cn.PaymentMeansCode, cn.PaymentMeansName, *cn.DirectDebit, cn.Iban, cn.BankAccounts)
+	} else {
+		doc.PaymentMeans, err = paymentMeans(cn.PaymentMeansCode, cn.PaymentMeansName, bankAccounts(cn.Iban, cn.Bic, cn.AccountName, cn.BankAccounts))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	cn.xml.PaymentMeans = xmlPaymentMeans{
-		PaymentMeansCode: "1",
-		PayeeFinancialAccount: xmlFinancialAccount{
-			ID: cn.Iban,
-			FinancialInstitutionBranch: xmlFinancialInstitutionBranch{
-				ID: cn.Bic,
-			},
-		},
+	doc.PaymentTerms = paymentTerms(cn.Note, cn.PaymentTermsNotes)
+
+	err = cn.Profile.check(profileDocument{
+		BuyerReference: cn.BuyerReference,
+		Supplier:       &doc.SupplierParty.Party,
+		Customer:       &doc.CustomerParty.Party,
+		PaymentMeans:   doc.PaymentMeans,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Ensure PaymentTerms is only included if Note is not empty
-	if cn.Note != "" {
-		cn.xml.PaymentTerms = xmlPaymentTerms{
-			Note: cn.Note,
-		}
+	err = cn.addLines(doc)
+	if err != nil {
+		return nil, err
 	}
 
-	cn.addLines()
+	pdfReferences, err := pdfAttachment{
+		documentID:  cn.ID,
+		filename:    cn.PdfCreditNoteFilename,
+		data:        cn.PdfCreditNoteData,
+		description: cn.PdfCreditNoteDescription,
+		maxBytes:    cn.MaxAttachmentBytes,
+	}.documentReferences("CreditNote")
+	if err != nil {
+		return nil, err
+	}
+	doc.AdditionalDocumentReference = ublBEReference(cn.Profile, cn.IncludeUBLBEReference, cn.UBLBEDescription, "CreditNote")
+	doc.AdditionalDocumentReference = append(doc.AdditionalDocumentReference, pdfReferences...)
+	doc.AdditionalDocumentReference = append(doc.AdditionalDocumentReference, attachmentReferences(cn.Attachments)...)
+	if len(cn.TextFilters) > 0 {
+		cn.warnings = append(cn.warnings, applyTextFilters(cn.TextFilters, doc.freeText())...)
+	}
 
-	if cn.PdfCreditNoteFilename != "" && cn.PdfCreditNoteData == "" {
-		err := cn.addAttachmentFromFile(cn.PdfCreditNoteFilename, "CreditNote")
-		if err != nil {
-			return nil, fmt.Errorf("add attachment failed: %w", err)
-		}
+	quirkWarnings, err := applyQuirks(cn.ReceiverQuirks, doc.quirkDocument(customerEndpoint.participantID()))
+	if err != nil {
+		return nil, err
 	}
-	if cn.PdfCreditNoteData != "" {
-		err := cn.addAttachmentFromData(cn.PdfCreditNoteData, "application/pdf", cn.PdfCreditNoteFilename, cn.PdfCreditNoteDescription)
+	cn.warnings = append(cn.warnings, quirkWarnings...)
+	if cn.BeforeMarshal != nil {
+		err = cn.BeforeMarshal(doc)
 		if err != nil {
-			return nil, fmt.Errorf("add attachment from data: %w", err)
+			return nil, fmt.Errorf("before marshal: %w", err)
 		}
 	}
-	output, err := xml.MarshalIndent(cn.xml, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("xml marshal failed: %w", err)
-	}
-	return []byte(xml.Header + string(output)), nil
+	cn.effective = doc.effectiveValues()
+
+	return doc, nil
 }
 
-func (cn *CreditNote) addAttachmentFromFile(filename, description string) error {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return err
-	}
+// Warnings returns the non-fatal remarks of the last GenerateCreditNote, e.g.
+// the changes made by TextFilters and the ReceiverQuirks applied.
+func (cn *CreditNote) Warnings() []string {
+	return cn.warnings
+}
 
-	mime := http.DetectContentType(data)
-	// using base64 encoding for the embedded binary content
-	encoded := base64.StdEncoding.EncodeToString(data)
+// Totals computes the totals of the credit note like GenerateCreditNote,
+// without generating it.
+func (cn *CreditNote) Totals() (Totals, error) {
+	totals, _, _, err := calculateTaxTotals(cn.Lines, cn.AllowanceCharges, cn.currency(), cn.taxScheme())
+	return totals, err
+}
 
-	return cn.addAttachmentFromData(encoded, mime, filename, description)
+// now returns the time the dates are derived from.
+func (cn *CreditNote) now() time.Time {
+	if cn.Now != nil {
+		return cn.Now()
+	}
+	return time.Now()
 }
 
-func (cn *CreditNote) addAttachmentFromData(encodedData, mime, filename, description string) error {
-	cn.xml.AdditionalDocumentReference = []xmlDocumentReference{
-		{
-			ID:                  "UBL.BE",
-			DocumentDescription: "CommercialInvoice",
-		},
-		{
-			ID:                  cn.ID,
-			DocumentDescription: description,
-			Attachment: []xmlAttachment{
-				{xmlEmbeddedDocumentBinaryObject{
-					Value:    encodedData,
-					MimeCode: mime,
-					Filename: filename,
-				}},
-			},
-		},
+// currency returns the document currency code, EUR when not set.
+func (cn *CreditNote) currency() string {
+	if cn.Currency == "" {
+		return "EUR"
 	}
+	return cn.Currency
+}
 
-	return nil
+func (cn *CreditNote) taxScheme() string {
+	if cn.TaxSchemeID == "" {
+		return "VAT"
+	}
+	return cn.TaxSchemeID
 }
 
-func (cn *CreditNote) addLines() {
+func (cn *CreditNote) addLines(doc *XMLCreditNote) error {
+	currency := cn.currency()
+	taxScheme := cn.taxScheme()
+	decimals := minorUnits(currency)
+	doc.CreditNoteLines = make([]XMLCreditNoteLine, 0, len(cn.Lines))
+	amounts, _ := lineAmounts(cn.Lines, decimals)
 	for i, line := range cn.Lines {
-		lineAmount := round(line.Quantity * line.Price)
-
-		// Default to "S" (Standard rated) if not specified
-		categoryID := line.TaxCategoryID
-		if categoryID == "" {
-			categoryID = "S"
-		}
-		categoryName := line.TaxCategoryName
-		if categoryName == "" {
-			categoryName = "Standard rated"
-		}
-
-		// For intra-community supply (K) and reverse charge (AE), enforce 0% tax rate
-		taxRate := line.TaxPercentage
-		if categoryID == "K" || categoryID == "AE" {
-			taxRate = 0
+		unit, err := unitCode(line.UnitCode)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
 		}
-
-		taxCat := xmlTaxCategory{
-			ID:        categoryID,
-			Name:      categoryName,
-			Percent:   taxRate,
-			TaxScheme: xmlTaxScheme{ID: "VAT"},
+		classifications, err := commodityClassifications(line.Classifications)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
 		}
-
-		// Add exemption reason for intra-community supply
-		if categoryID == "K" {
-			if line.TaxExemptionCode != "" {
-				taxCat.TaxExemptionReasonCode = line.TaxExemptionCode
-			} else {
-				taxCat.TaxExemptionReasonCode = "VATEX-EU-IC"
-			}
-			if line.TaxExemptionReason != "" {
-				taxCat.TaxExemptionReason = line.TaxExemptionReason
-			} else {
-				taxCat.TaxExemptionReason = "Intra-community supply"
-			}
+		properties, err := additionalItemProperties(line.Attributes)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
 		}
-
-		// Add exemption reason for reverse charge
-		if categoryID == "AE" {
-			if line.TaxExemptionCode != "" {
-				taxCat.TaxExemptionReasonCode = line.TaxExemptionCode
-			} else {
-				taxCat.TaxExemptionReasonCode = "VATEX-EU-AE"
-			}
-			if line.TaxExemptionReason != "" {
-				taxCat.TaxExemptionReason = line.TaxExemptionReason
-			} else {
-				taxCat.TaxExemptionReason = "Reverse charge"
-			}
+		price, err := linePrice(line, currency)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
 		}
-
-		cn.xml.CreditNoteLines = append(cn.xml.CreditNoteLines, xmlCreditNoteLine{
-			ID:                  strconv.Itoa(i + 1),
-			CreditedQuantity:    xmlQuantity{Value: line.Quantity, UnitCode: "ZZ"},
-			LineExtensionAmount: xmlAmount{Value: lineAmount, CurrencyID: "EUR"},
-			Item: xmlItem{
-				Name:                  line.Name,
-				Description:           line.Description,
-				ClassifiedTaxCategory: taxCat,
+		lineAmount := amounts[i].float(decimals)
+		taxCat := line.taxCategory()
+		taxCat.TaxScheme.ID = taxScheme
+
+		doc.CreditNoteLines = append(doc.CreditNoteLines, XMLCreditNoteLine{
+			ID:                  line.id(i),
+			Note:                line.Note,
+			CreditedQuantity:    XMLQuantity{Value: line.Quantity, UnitCode: unit},
+			LineExtensionAmount: XMLAmount{Value: lineAmount, CurrencyID: currency},
+			AccountingCost:      line.AccountingCost,
+			OrderLineReference:  orderLineReference(line.OrderLineID),
+			DocumentReference:   lineObjectReference(line.ObjectID, line.ObjectIDScheme),
+			Item: XMLItem{
+				Name:                       line.Name,
+				Description:                line.Description,
+				StandardItemIdentification: standardItemIdentification(line.StandardItemID, line.StandardItemIDScheme),
+				CommodityClassification:    classifications,
+				ClassifiedTaxCategory:      taxCat,
+				AdditionalItemProperty:     properties,
 			},
-			Price: xmlPrice{PriceAmount: xmlAmount{Value: line.Price, CurrencyID: "EUR"}},
+			Price: price,
 		})
 	}
 
-	lineTotal, taxTotal, subtotals := calculateTaxTotals(cn.Lines)
-	total := round(lineTotal + taxTotal)
+	totals, subtotals, allowanceCharges, err := calculateTaxTotals(cn.Lines, cn.AllowanceCharges, currency, cn.taxScheme())
+	if err != nil {
+		return err
+	}
+	cn.totals = totals
+
+	doc.AllowanceCharge = allowanceCharges
 
-	cn.xml.TaxTotal = xmlTaxTotal{
-		TaxAmount:   xmlAmount{Value: taxTotal, CurrencyID: "EUR"},
+	doc.TaxTotal = XMLTaxTotal{
+		TaxAmount:   XMLAmount{Value: totals.Tax, CurrencyID: currency},
 		TaxSubtotal: subtotals,
 	}
 
-	cn.xml.LegalMonetaryTotal = xmlMonetaryTotal{
-		LineExtensionAmount: xmlAmount{Value: lineTotal, CurrencyID: "EUR"},
-		TaxExclusiveAmount:  xmlAmount{Value: lineTotal, CurrencyID: "EUR"},
-		TaxInclusiveAmount:  xmlAmount{Value: total, CurrencyID: "EUR"},
-		PayableAmount:       xmlAmount{Value: total, CurrencyID: "EUR"},
-	}
+	doc.LegalMonetaryTotal = totals.monetaryTotal(currency)
+
+	return nil
 }
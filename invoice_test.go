@@ -1,7 +1,14 @@
 package ubl_test
 
 import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/verscheures/ubl"
 	"github.com/verscheures/ubl/validate"
@@ -94,3 +101,968 @@ func TestNewInvoice(t *testing.T) {
 	// }
 
 }
+
+func newTestInvoice() ubl.Invoice {
+	return ubl.Invoice{
+		ID:               "INV-12345",
+		SupplierName:     "ABC Supplies Ltd",
+		SupplierVat:      "BE0123456789",
+		SupplierPeppolID: "9925:BE0123456789",
+		SupplierAddress: ubl.Address{
+			StreetName:  "123 Supplier Street",
+			CityName:    "Supplier City",
+			PostalZone:  "12345",
+			CountryCode: "BE",
+		},
+		CustomerName:     "XYZ Corp",
+		CustomerVat:      "BE9876543210",
+		CustomerPeppolID: "9925:BE9876543210",
+		CustomerAddress: ubl.Address{
+			StreetName:  "789 Customer Avenue",
+			CityName:    "Customer Town",
+			PostalZone:  "67890",
+			CountryCode: "BE",
+		},
+		Iban: "9999999999",
+		Bic:  "GEBABEBB",
+		Lines: []ubl.InvoiceLine{
+			{
+				Quantity:      10,
+				Price:         100,
+				Name:          "Product A",
+				Description:   "High-quality item",
+				TaxPercentage: 21.0,
+			},
+		},
+	}
+}
+
+func newTestCreditNote() ubl.CreditNote {
+	inv := newTestInvoice()
+	return ubl.CreditNote{
+		ID:               "CN-12345",
+		SupplierName:     inv.SupplierName,
+		SupplierVat:      inv.SupplierVat,
+		SupplierPeppolID: inv.SupplierPeppolID,
+		SupplierAddress:  inv.SupplierAddress,
+		CustomerName:     inv.CustomerName,
+		CustomerVat:      inv.CustomerVat,
+		CustomerPeppolID: inv.CustomerPeppolID,
+		CustomerAddress:  inv.CustomerAddress,
+		Iban:             inv.Iban,
+		Bic:              inv.Bic,
+		Lines:            inv.Lines,
+	}
+}
+
+var betweenTags = regexp.MustCompile(`>\s+<`)
+
+// compact removes the indentation so the output can be searched for nested
+// elements.
+func compact(xmlBytes []byte) string {
+	return betweenTags.ReplaceAllString(string(xmlBytes), "><")
+}
+
+func generateAndValidate(t *testing.T, inv *ubl.Invoice) []byte {
+	t.Helper()
+
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	err = v.ValidateBytes(xmlBytes)
+	if err != nil {
+		t.Error(err)
+	}
+
+	return xmlBytes
+}
+
+func TestSupplierContact(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierContact = ubl.Contact{Name: "Jane Doe", Phone: "+32 2 123 45 67", Email: "jane@abc.example"}
+	xmlBytes := generateAndValidate(t, &inv)
+	for _, want := range []string{
+		"<cbc:Name>Jane Doe</cbc:Name>",
+		"<cbc:Telephone>+32 2 123 45 67</cbc:Telephone>",
+		"<cbc:ElectronicMail>jane@abc.example</cbc:ElectronicMail>",
+	} {
+		if !strings.Contains(string(xmlBytes), want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv = newTestInvoice()
+	inv.SupplierContact = ubl.Contact{Email: "jane@abc.example"}
+	xmlBytes = generateAndValidate(t, &inv)
+	if !strings.Contains(string(xmlBytes), "<cbc:ElectronicMail>jane@abc.example</cbc:ElectronicMail>") {
+		t.Error("expected ElectronicMail in output")
+	}
+	if strings.Contains(string(xmlBytes), "<cbc:Telephone>") {
+		t.Error("did not expect Telephone in output")
+	}
+
+	inv = newTestInvoice()
+	xmlBytes = generateAndValidate(t, &inv)
+	if strings.Contains(string(xmlBytes), "<cac:Contact>") {
+		t.Error("did not expect Contact in output")
+	}
+}
+
+func TestSupplierLegalForm(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierLegalForm = "SARL au capital de 10 000 EUR"
+	xmlBytes := generateAndValidate(t, &inv)
+	if !strings.Contains(string(xmlBytes), "<cbc:CompanyLegalForm>SARL au capital de 10 000 EUR</cbc:CompanyLegalForm>") {
+		t.Error("expected CompanyLegalForm in output")
+	}
+	if strings.Count(string(xmlBytes), "<cbc:CompanyLegalForm>") != 1 {
+		t.Error("expected CompanyLegalForm only on the supplier")
+	}
+}
+
+func TestOrderReference(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  *ubl.OrderRef
+		id   string
+		want []string
+		not  []string
+	}{
+		{
+			name: "default",
+			want: []string{"<cac:OrderReference><cbc:ID>INV-12345</cbc:ID></cac:OrderReference>"},
+			not:  []string{"<cbc:SalesOrderID>"},
+		},
+		{
+			name: "shortcut",
+			id:   "PO-1",
+			want: []string{"<cbc:ID>PO-1</cbc:ID>"},
+			not:  []string{"<cbc:SalesOrderID>"},
+		},
+		{
+			name: "both",
+			ref:  &ubl.OrderRef{PurchaseOrderID: "PO-1", SalesOrderID: "SO-2"},
+			want: []string{"<cbc:ID>PO-1</cbc:ID>", "<cbc:SalesOrderID>SO-2</cbc:SalesOrderID>"},
+		},
+		{
+			name: "purchase order only",
+			ref:  &ubl.OrderRef{PurchaseOrderID: "PO-1"},
+			want: []string{"<cbc:ID>PO-1</cbc:ID>"},
+			not:  []string{"<cbc:SalesOrderID>"},
+		},
+		{
+			name: "sales order only",
+			ref:  &ubl.OrderRef{SalesOrderID: "SO-2"},
+			want: []string{"<cbc:ID>NA</cbc:ID>", "<cbc:SalesOrderID>SO-2</cbc:SalesOrderID>"},
+		},
+		{
+			name: "sales order with shortcut",
+			ref:  &ubl.OrderRef{SalesOrderID: "SO-2"},
+			id:   "PO-1",
+			want: []string{"<cbc:ID>PO-1</cbc:ID>", "<cbc:SalesOrderID>SO-2</cbc:SalesOrderID>"},
+		},
+		{
+			name: "same purchase order as shortcut",
+			ref:  &ubl.OrderRef{PurchaseOrderID: "PO-1"},
+			id:   "PO-1",
+			want: []string{"<cbc:ID>PO-1</cbc:ID>"},
+			not:  []string{"<cbc:SalesOrderID>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := newTestInvoice()
+			inv.OrderReference = tt.ref
+			inv.OrderReferenceID = tt.id
+			xmlBytes := generateAndValidate(t, &inv)
+			for _, want := range tt.want {
+				if !strings.Contains(compact(xmlBytes), want) {
+					t.Errorf("expected %s in output", want)
+				}
+			}
+			for _, not := range tt.not {
+				if strings.Contains(string(xmlBytes), not) {
+					t.Errorf("did not expect %s in output", not)
+				}
+			}
+		})
+	}
+
+	inv := newTestInvoice()
+	inv.OrderReference = &ubl.OrderRef{}
+	_, err := inv.Generate()
+	if err == nil {
+		t.Error("expected an error for an empty order reference")
+	}
+
+	inv.OrderReference = &ubl.OrderRef{PurchaseOrderID: "PO-1", SalesOrderID: "SO-2"}
+	inv.OrderReferenceID = "PO-2"
+	_, err = inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), `purchase order ID "PO-1" differs from OrderReferenceID "PO-2"`) {
+		t.Errorf("expected an error for different purchase order IDs, got %v", err)
+	}
+}
+
+func TestSupplierCompanyID(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierCompanyID = "0123456789"
+	inv.SupplierCompanyIDScheme = "0208"
+	inv.SupplierLegalForm = "BV"
+	xmlBytes := generateAndValidate(t, &inv)
+	want := `<cac:PartyLegalEntity><cbc:RegistrationName>ABC Supplies Ltd</cbc:RegistrationName><cbc:CompanyID schemeID="0208">0123456789</cbc:CompanyID><cbc:CompanyLegalForm>BV</cbc:CompanyLegalForm></cac:PartyLegalEntity>`
+	if !strings.Contains(compact(xmlBytes), want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	inv.SupplierCompanyID = "0123456789"
+	xmlBytes = generateAndValidate(t, &inv)
+	if !strings.Contains(string(xmlBytes), "<cbc:CompanyID>0123456789</cbc:CompanyID>") {
+		t.Error("expected CompanyID without schemeID in output")
+	}
+}
+
+func TestAdditionalPartyIDs(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierAdditionalIDs = []ubl.PartyID{{Value: "5412345000013", SchemeID: "0088"}, {Value: "SUP-1"}}
+	inv.CustomerAdditionalIDs = []ubl.PartyID{{Value: "5400000000003", SchemeID: "0088"}}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+
+	for _, want := range []string{
+		`</cbc:EndpointID><cac:PartyIdentification><cbc:ID schemeID="0088">5412345000013</cbc:ID></cac:PartyIdentification><cac:PartyIdentification><cbc:ID>SUP-1</cbc:ID></cac:PartyIdentification><cac:PartyName>`,
+		`</cbc:EndpointID><cac:PartyIdentification><cbc:ID schemeID="0088">5400000000003</cbc:ID></cac:PartyIdentification><cac:PartyName>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+}
+
+func TestExtendedAddress(t *testing.T) {
+	address := ubl.Address{
+		StreetName:   "Main Street 1",
+		StreetName2:  "Building B",
+		AddressLine3: "3rd floor",
+		CityName:     "Brussels",
+		PostalZone:   "1000",
+		Region:       "Brussels-Capital",
+		CountryCode:  "BE",
+	}
+	inv := newTestInvoice()
+	inv.SupplierAddress = address
+	inv.CustomerAddress = address
+	inv.DeliveryAddress = &address
+	xmlBytes := compact(generateAndValidate(t, &inv))
+
+	want := `<cbc:StreetName>Main Street 1</cbc:StreetName><cbc:AdditionalStreetName>Building B</cbc:AdditionalStreetName><cbc:CityName>Brussels</cbc:CityName><cbc:PostalZone>1000</cbc:PostalZone><cbc:CountrySubentity>Brussels-Capital</cbc:CountrySubentity><cac:AddressLine><cbc:Line>3rd floor</cbc:Line></cac:AddressLine><cac:Country><cbc:IdentificationCode>BE</cbc:IdentificationCode></cac:Country>`
+	if n := strings.Count(xmlBytes, want); n != 3 {
+		t.Errorf("expected the extended address 3 times in output, got %d", n)
+	}
+}
+
+func TestNonEUCustomerTaxIdentifier(t *testing.T) {
+	inv := newTestInvoice()
+	inv.CustomerAddress = ubl.Address{CityName: "Sydney", CountryCode: "AU"}
+	inv.CustomerVat = "51 824 753 556"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:PartyTaxScheme><cbc:CompanyID>51 824 753 556</cbc:CompanyID><cac:TaxScheme><cbc:ID>GST</cbc:ID></cac:TaxScheme></cac:PartyTaxScheme>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	inv.CustomerAddress = ubl.Address{CityName: "Singapore", CountryCode: "SG"}
+	inv.CustomerVat = "197401143C"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want = `<cbc:CompanyID>197401143C</cbc:CompanyID><cac:TaxScheme><cbc:ID>GST</cbc:ID></cac:TaxScheme>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv.CustomerVat = "197401143D"
+	_, err := inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "invalid UEN") {
+		t.Errorf("expected an invalid UEN error, got %v", err)
+	}
+
+	inv.CustomerAddress.CountryCode = "AU"
+	inv.CustomerVat = "51 824 753 557"
+	_, err = inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "invalid ABN") {
+		t.Errorf("expected an invalid ABN error, got %v", err)
+	}
+}
+
+func TestDeliveryLocationAndParty(t *testing.T) {
+	delivered := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	inv := newTestInvoice()
+	inv.DeliveryAddress = &ubl.Address{StreetName: "Dock 4", CityName: "Antwerp", PostalZone: "2000", CountryCode: "BE"}
+	inv.ActualDeliveryDate = &delivered
+	inv.DeliveryLocationID = "5412345000099"
+	inv.DeliveryLocationIDScheme = "0088"
+	inv.DeliveryPartyName = "XYZ Warehouse Antwerp"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:Delivery><cbc:ActualDeliveryDate>2025-03-04</cbc:ActualDeliveryDate><cac:DeliveryLocation><cbc:ID schemeID="0088">5412345000099</cbc:ID><cac:Address><cbc:StreetName>Dock 4</cbc:StreetName>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+	want = `</cac:DeliveryLocation><cac:DeliveryParty><cac:PartyName><cbc:Name>XYZ Warehouse Antwerp</cbc:Name></cac:PartyName></cac:DeliveryParty></cac:Delivery>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	// only the date: no empty location
+	inv = newTestInvoice()
+	inv.ActualDeliveryDate = &delivered
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want = `<cac:Delivery><cbc:ActualDeliveryDate>2025-03-04</cbc:ActualDeliveryDate></cac:Delivery>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "<cac:Delivery>") {
+		t.Error("did not expect Delivery in output")
+	}
+}
+
+func TestInvoicePeriod(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		start, end *time.Time
+		want       string
+	}{
+		{"start only", &start, nil, `<cac:InvoicePeriod><cbc:StartDate>2025-01-01</cbc:StartDate></cac:InvoicePeriod>`},
+		{"end only", nil, &end, `<cac:InvoicePeriod><cbc:EndDate>2025-01-31</cbc:EndDate></cac:InvoicePeriod>`},
+		{"both", &start, &end, `<cac:InvoicePeriod><cbc:StartDate>2025-01-01</cbc:StartDate><cbc:EndDate>2025-01-31</cbc:EndDate></cac:InvoicePeriod>`},
+	}
+	for _, tt := range tests {
+		inv := newTestInvoice()
+		inv.InvoicePeriodStart = tt.start
+		inv.InvoicePeriodEnd = tt.end
+		xmlBytes := compact(generateAndValidate(t, &inv))
+		if !strings.Contains(xmlBytes, tt.want) {
+			t.Errorf("%s: expected %s in output", tt.name, tt.want)
+		}
+	}
+
+	inv := newTestInvoice()
+	inv.InvoicePeriodStart = &end
+	inv.InvoicePeriodEnd = &start
+	_, err := inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "invoice period: end 2025-01-01 before start 2025-01-31") {
+		t.Errorf("expected an inverted period error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.InvoicePeriodStart = &end
+	cn.InvoicePeriodEnd = &start
+	_, err = cn.GenerateCreditNote()
+	if err == nil || !strings.Contains(err.Error(), "invoice period") {
+		t.Errorf("expected an inverted period error for the credit note, got %v", err)
+	}
+}
+
+func TestSupplierWithoutVAT(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierVat = ""
+	inv.Lines[0].TaxCategoryID = "E"
+	inv.Lines[0].TaxCategoryName = "Exempt from tax"
+	inv.Lines[0].TaxPercentage = 0
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	supplier := xmlBytes[strings.Index(xmlBytes, "<cac:AccountingSupplierParty>"):strings.Index(xmlBytes, "</cac:AccountingSupplierParty>")]
+	if strings.Contains(supplier, "<cac:PartyTaxScheme>") {
+		t.Error("did not expect a supplier PartyTaxScheme")
+	}
+	if !strings.Contains(xmlBytes, "<cac:AccountingCustomerParty>") || strings.Count(xmlBytes, "<cac:PartyTaxScheme>") != 1 {
+		t.Error("expected the customer PartyTaxScheme")
+	}
+
+	inv = newTestInvoice()
+	inv.SupplierVat = ""
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "supplier without VAT identifier: line 1 has tax category S, only O or E allowed" {
+		t.Errorf("expected a tax category error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.SupplierVat = ""
+	_, err = cn.GenerateCreditNote()
+	if err == nil || !strings.Contains(err.Error(), "supplier without VAT identifier") {
+		t.Errorf("expected a tax category error for the credit note, got %v", err)
+	}
+}
+
+func TestSupplierTaxRegistrations(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierTaxRegistrations = []ubl.TaxRegistration{{CompanyID: "RSSMRA85T10A562S", SchemeID: "TAX"}}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:PartyTaxScheme><cbc:CompanyID>BE0123456789</cbc:CompanyID><cac:TaxScheme><cbc:ID>VAT</cbc:ID></cac:TaxScheme></cac:PartyTaxScheme>` +
+		`<cac:PartyTaxScheme><cbc:CompanyID>RSSMRA85T10A562S</cbc:CompanyID><cac:TaxScheme><cbc:ID>TAX</cbc:ID></cac:TaxScheme></cac:PartyTaxScheme>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	inv.SupplierTaxRegistrations = []ubl.TaxRegistration{{CompanyID: "BE0123456789", SchemeID: "VAT"}}
+	_, err := inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "use SupplierVat for the VAT identifier") {
+		t.Errorf("expected a VAT registration error, got %v", err)
+	}
+}
+
+func TestTradingName(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierName = "ACME BV"
+	inv.SupplierTradingName = "ACME Tools"
+	inv.CustomerTradingName = "XYZ Store"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cac:PartyName><cbc:Name>ACME Tools</cbc:Name></cac:PartyName>`,
+		`<cac:PartyLegalEntity><cbc:RegistrationName>ACME BV</cbc:RegistrationName>`,
+		`<cac:PartyName><cbc:Name>XYZ Store</cbc:Name></cac:PartyName>`,
+		`<cac:PartyLegalEntity><cbc:RegistrationName>XYZ Corp</cbc:RegistrationName>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	// without trading names the legal names are used for both
+	inv = newTestInvoice()
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cac:PartyName><cbc:Name>ABC Supplies Ltd</cbc:Name></cac:PartyName>`,
+		`<cac:PartyLegalEntity><cbc:RegistrationName>ABC Supplies Ltd</cbc:RegistrationName>`,
+		`<cac:PartyName><cbc:Name>XYZ Corp</cbc:Name></cac:PartyName>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+}
+
+func TestPaymentTermsNotes(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Note = "Payment within 30 days net\nLate payments incur interest"
+	inv.PaymentTermsNotes = []string{"2% discount if paid within 10 days", ""}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:PaymentTerms><cbc:Note>Payment within 30 days net</cbc:Note><cbc:Note>Late payments incur interest</cbc:Note><cbc:Note>2% discount if paid within 10 days</cbc:Note></cac:PaymentTerms>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	inv.PaymentTermsNotes = []string{}
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "PaymentTerms") {
+		t.Error("expected no PaymentTerms without notes")
+	}
+}
+
+func TestNoEmptyPaymentTerms(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(xmlBytes), "cac:PaymentTerms") {
+		t.Error("expected no PaymentTerms on an invoice without note")
+	}
+
+	cn := newTestCreditNote()
+	xmlBytes, err = cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(xmlBytes), "cac:PaymentTerms") {
+		t.Error("expected no PaymentTerms on a credit note without note")
+	}
+
+	cn.Note = "Refund within 14 days"
+	xmlBytes, err = cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(xmlBytes), "<cac:PaymentTerms><cbc:Note>Refund within 14 days</cbc:Note></cac:PaymentTerms>") {
+		t.Error("expected PaymentTerms on a credit note with note")
+	}
+}
+
+func TestNegativeInvoice(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 2, Price: 50, TaxPercentage: 21, Name: "Product A"},
+		{Quantity: -3, Price: 73.37, TaxPercentage: 21, Name: "Product A returned"},
+		{Quantity: -1, Price: 10, TaxPercentage: 0, TaxCategoryID: "Z", TaxCategoryName: "Zero rated", Name: "Deposit returned"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:LineExtensionAmount currencyID="EUR">-220.11</cbc:LineExtensionAmount>`,
+		`<cbc:TaxableAmount currencyID="EUR">-120.11</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">-25.22</cbc:TaxAmount>`,
+		`<cbc:TaxableAmount currencyID="EUR">-10.00</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">0.00</cbc:TaxAmount>`,
+		`<cbc:TaxInclusiveAmount currencyID="EUR">-155.33</cbc:TaxInclusiveAmount>`,
+		`<cbc:PayableAmount currencyID="EUR">-155.33</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+	if strings.Contains(xmlBytes, ">-0<") {
+		t.Error("expected no negative zero in output")
+	}
+}
+
+func TestNegativeCreditNote(t *testing.T) {
+	cn := newTestCreditNote()
+	cn.Lines = []ubl.InvoiceLine{{Quantity: -1, Price: 100, TaxPercentage: 21, Name: "Product A"}}
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:PayableAmount currencyID="EUR">-121.00</cbc:PayableAmount>`) {
+		t.Error("expected a negative payable amount")
+	}
+
+	v, err := validate.NewCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	err = v.ValidateBytes(cnBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNow(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC) }
+	inv := newTestInvoice()
+	inv.Now = now
+	xmlBytes := generateAndValidate(t, &inv)
+	for _, want := range []string{
+		`<cbc:IssueDate>2024-01-31</cbc:IssueDate>`,
+		`<cbc:DueDate>2024-03-01</cbc:DueDate>`,
+	} {
+		if !strings.Contains(string(xmlBytes), want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+	again, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(xmlBytes, again) {
+		t.Error("expected identical output with a pinned clock")
+	}
+
+	view, err := inv.ViewModel("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.IssueDate != "31 January 2024" {
+		t.Errorf("expected the pinned issue date in the view, got %q", view.IssueDate)
+	}
+
+	cn := newTestCreditNote()
+	cn.Now = now
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cnBytes), `<cbc:IssueDate>2024-01-31</cbc:IssueDate>`) {
+		t.Error("expected the pinned issue date in the credit note")
+	}
+}
+
+func TestGenerateTwice(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC) }
+	inv := newTestInvoice()
+	inv.Now = now
+	inv.PdfInvoiceFilename = "invoice_test.pdf"
+	first := generateAndValidate(t, &inv)
+
+	inv.Lines[0].Quantity = 3
+	second := generateAndValidate(t, &inv)
+	if n := strings.Count(string(second), "<cac:InvoiceLine>"); n != len(inv.Lines) {
+		t.Errorf("expected %d lines, got %d", len(inv.Lines), n)
+	}
+	if n := strings.Count(string(second), "<cac:AdditionalDocumentReference>"); n != 1 {
+		t.Errorf("expected 1 attachment, got %d", n)
+	}
+
+	fresh := newTestInvoice()
+	fresh.Now = now
+	fresh.PdfInvoiceFilename = "invoice_test.pdf"
+	fresh.Lines[0].Quantity = 3
+	want := generateAndValidate(t, &fresh)
+	if !bytes.Equal(second, want) {
+		t.Error("expected the regenerated invoice to match a fresh one")
+	}
+
+	inv.Lines[0].Quantity = newTestInvoice().Lines[0].Quantity
+	third := generateAndValidate(t, &inv)
+	if !bytes.Equal(first, third) {
+		t.Error("expected the same output after restoring the line")
+	}
+
+	cn := newTestCreditNote()
+	cn.Now = now
+	cnFirst, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnSecond, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cnFirst, cnSecond) {
+		t.Error("expected identical credit notes")
+	}
+}
+
+func TestGenerateConcurrently(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC) }
+	want := make([][]byte, 100)
+	for i := range want {
+		inv := newTestInvoice()
+		inv.ID = fmt.Sprintf("INV-%03d", i)
+		inv.Now = now
+		inv.TextFilters = ubl.TransliterationFilters()
+		var err error
+		want[i], err = inv.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make([][]byte, len(want))
+	errs := make([]error, len(want))
+	var wg sync.WaitGroup
+	for i := range want {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inv := newTestInvoice()
+			inv.ID = fmt.Sprintf("INV-%03d", i)
+			inv.Now = now
+			inv.TextFilters = ubl.TransliterationFilters()
+			got[i], errs[i] = inv.Generate()
+		}()
+	}
+	wg.Wait()
+	for i := range want {
+		if errs[i] != nil {
+			t.Fatalf("invoice %d: %v", i, errs[i])
+		}
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("invoice %d: expected the same output as a sequential Generate", i)
+		}
+	}
+}
+
+func TestLineNote(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "<cac:InvoiceLine><cbc:ID>1</cbc:ID><cbc:Note>") {
+		t.Error("expected no line note when empty")
+	}
+
+	inv.Lines[0].Note = "replacement under warranty"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want := `<cac:InvoiceLine><cbc:ID>1</cbc:ID><cbc:Note>replacement under warranty</cbc:Note><cbc:InvoicedQuantity`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].Note = "replacement under warranty"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `<cac:CreditNoteLine><cbc:ID>1</cbc:ID><cbc:Note>replacement under warranty</cbc:Note><cbc:CreditedQuantity`
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in credit note output", want)
+	}
+	v, err := validate.NewCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	err = v.ValidateBytes(cnBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLineAccountingCost(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines[0].AccountingCost = "CC-4711"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `</cbc:LineExtensionAmount><cbc:AccountingCost>CC-4711</cbc:AccountingCost><cac:TaxTotal>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+	if n := strings.Count(xmlBytes, "<cbc:AccountingCost>"); n != 1 {
+		t.Errorf("expected the accounting cost on the first line only, got %d", n)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].AccountingCost = "CC-4711"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `</cbc:LineExtensionAmount><cbc:AccountingCost>CC-4711</cbc:AccountingCost><cac:Item>`
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in credit note output", want)
+	}
+	v, err := validate.NewCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	err = v.ValidateBytes(cnBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrderLineReference(t *testing.T) {
+	inv := newTestInvoice()
+	inv.OrderReferenceID = "PO-123"
+	inv.Lines[0].OrderLineID = "10"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:OrderLineReference><cbc:LineID>10</cbc:LineID></cac:OrderLineReference><cac:TaxTotal>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+	if len(inv.Warnings()) != 0 {
+		t.Errorf("expected no warnings, got %v", inv.Warnings())
+	}
+
+	inv.OrderReferenceID = ""
+	generateAndValidate(t, &inv)
+	wantWarning := `line 1: OrderLineID "10" without purchase order reference`
+	if !slices.Contains(inv.Warnings(), wantWarning) {
+		t.Errorf("expected warning %q, got %v", wantWarning, inv.Warnings())
+	}
+
+	inv.RequireOrderReference = true
+	_, err := inv.Generate()
+	if err == nil || err.Error() != wantWarning {
+		t.Errorf("expected an error without purchase order reference, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.OrderReference = &ubl.OrderRef{PurchaseOrderID: "PO-123"}
+	cn.Lines[0].OrderLineID = "10"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `<cac:OrderLineReference><cbc:LineID>10</cbc:LineID></cac:OrderLineReference><cac:Item>`
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in credit note output", want)
+	}
+}
+
+func TestStandardItemID(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "StandardItemIdentification") {
+		t.Error("expected no StandardItemIdentification without an ID")
+	}
+
+	inv.Lines[0].StandardItemID = "4006381333931"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want := `</cbc:Name><cac:StandardItemIdentification><cbc:ID schemeID="0160">4006381333931</cbc:ID></cac:StandardItemIdentification><cac:ClassifiedTaxCategory>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].StandardItemID = "5412345000013"
+	cn.Lines[0].StandardItemIDScheme = "0088"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `<cac:StandardItemIdentification><cbc:ID schemeID="0088">5412345000013</cbc:ID></cac:StandardItemIdentification>`
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in credit note output", want)
+	}
+}
+
+func TestItemAttributes(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines[0].Attributes = []ubl.ItemAttribute{
+		{Name: "Colour", Value: "Black"},
+		{Name: "Serial number", Value: "SN-0042"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `</cac:ClassifiedTaxCategory><cac:AdditionalItemProperty><cbc:Name>Colour</cbc:Name><cbc:Value>Black</cbc:Value></cac:AdditionalItemProperty>` +
+		`<cac:AdditionalItemProperty><cbc:Name>Serial number</cbc:Name><cbc:Value>SN-0042</cbc:Value></cac:AdditionalItemProperty></cac:Item>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].Attributes = []ubl.ItemAttribute{{Name: "Size"}, {Value: "XL"}}
+	_, err := cn.GenerateCreditNote()
+	for _, want := range []string{
+		"Lines[0].Attributes[0]: value required",
+		"Lines[0].Attributes[1]: name required",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestGrossPrice(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines[0].GrossPrice = 125
+	inv.Lines[0].PriceDiscount = 25
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:Price><cbc:PriceAmount currencyID="EUR">100</cbc:PriceAmount><cac:AllowanceCharge><cbc:ChargeIndicator>false</cbc:ChargeIndicator>` +
+		`<cbc:Amount currencyID="EUR">25</cbc:Amount><cbc:BaseAmount currencyID="EUR">125</cbc:BaseAmount></cac:AllowanceCharge></cac:Price>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+	if !strings.Contains(xmlBytes, `<cbc:PayableAmount currencyID="EUR">1210.00</cbc:PayableAmount>`) {
+		t.Error("expected the totals to use the net price")
+	}
+
+	inv.Lines[0].PriceDiscount = 20
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "Lines[0].GrossPrice: gross price 125 minus discount 20 is not the price 100" {
+		t.Errorf("expected a price mismatch, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].PriceDiscount = 5
+	_, err = cn.GenerateCreditNote()
+	if err == nil || err.Error() != "Lines[0].GrossPrice: price discount 5 without gross price" {
+		t.Errorf("expected a missing gross price, got %v", err)
+	}
+}
+
+func TestLineID(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = append(inv.Lines, ubl.InvoiceLine{Quantity: 1, Price: 10, TaxPercentage: 21, Name: "Product B"})
+	inv.Lines[0].LineID = "ERP-10"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cac:InvoiceLine><cbc:ID>ERP-10</cbc:ID>`,
+		`<cac:InvoiceLine><cbc:ID>2</cbc:ID>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv.Lines[0].LineID = "2"
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `Lines[1]: line ID "2" already used by Lines[0]` {
+		t.Errorf("expected a duplicate line ID, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].LineID = "ERP-10"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cac:CreditNoteLine><cbc:ID>ERP-10</cbc:ID>`) {
+		t.Error("expected the credit note line ID")
+	}
+}
+
+func TestNegativeLine(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 4, Price: 25.5, TaxPercentage: 21, Name: "Product A"},
+		{Quantity: 1, Price: 12.4, TaxPercentage: 6, Name: "Product B"},
+		{Quantity: -2, Price: 25.5, TaxPercentage: 21, Name: "Product A returned"},
+	}
+	xmlBytes := generateAndValidate(t, &inv)
+	for _, want := range []string{
+		`<cbc:LineExtensionAmount currencyID="EUR">-51.00</cbc:LineExtensionAmount>`,
+		`<cbc:TaxableAmount currencyID="EUR">51.00</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">10.71</cbc:TaxAmount>`,
+		`<cbc:PayableAmount currencyID="EUR">74.85</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(compact(xmlBytes), want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+	if findings := validate.CheckArithmetic(xmlBytes); len(findings) > 0 {
+		t.Errorf("expected consistent totals, got %v", findings)
+	}
+
+	inv.Lines[2].Price = -25.5
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "Lines[2]: negative quantity and negative price, negate only the quantity" {
+		t.Errorf("expected a double negative error, got %v", err)
+	}
+}
+
+func TestEmptyLineDescription(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines[0].Description = ""
+	xmlBytes := generateAndValidate(t, &inv)
+	if strings.Contains(string(xmlBytes), "cbc:Description") {
+		t.Error("expected no Description element without a description")
+	}
+
+	inv.Lines[0].Name = ""
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "Lines[0].Name: required" {
+		t.Errorf("expected a missing name error, got %v", err)
+	}
+}
+
+func TestLineObjectID(t *testing.T) {
+	inv := newTestInvoice()
+	inv.OrderReferenceID = "PO-123"
+	inv.Lines[0].OrderLineID = "10"
+	inv.Lines[0].ObjectID = "TS-2024-07"
+	inv.Lines[0].ObjectIDScheme = "ABZ"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `</cac:OrderLineReference><cac:DocumentReference><cbc:ID schemeID="ABZ">TS-2024-07</cbc:ID><cbc:DocumentTypeCode>130</cbc:DocumentTypeCode></cac:DocumentReference><cac:TaxTotal>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "<cac:DocumentReference>") {
+		t.Error("expected no line DocumentReference without an object ID")
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].ObjectID = "DN-55"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `<cac:DocumentReference><cbc:ID>DN-55</cbc:ID><cbc:DocumentTypeCode>130</cbc:DocumentTypeCode></cac:DocumentReference><cac:Item>`
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in credit note output", want)
+	}
+}
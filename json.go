@@ -0,0 +1,140 @@
+package ubl
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonDate is a date written in JSON as "2006-01-02", without the time and
+// location of a time.Time.
+type jsonDate time.Time
+
+func newJSONDate(t *time.Time) *jsonDate {
+	if t == nil {
+		return nil
+	}
+	d := jsonDate(*t)
+	return &d
+}
+
+// toTime returns the date at midnight UTC, or nil.
+func (d *jsonDate) toTime() *time.Time {
+	if d == nil {
+		return nil
+	}
+	t := time.Time(*d)
+	return &t
+}
+
+func (d jsonDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(d).Format(time.DateOnly))
+}
+
+func (d *jsonDate) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return fmt.Errorf("date %q: not in the form 2006-01-02", s)
+	}
+	*d = jsonDate(t)
+	return nil
+}
+
+// MarshalJSON writes the invoice with its dates as "2006-01-02", e.g. to
+// store a draft. Now, TextFilters, ReceiverQuirks and BeforeMarshal hold
+// functions and are left out.
+func (inv Invoice) MarshalJSON() ([]byte, error) {
+	type invoice Invoice
+	return json.Marshal(struct {
+		invoice
+		OriginalInvoiceDate *jsonDate `json:"originalInvoiceDate,omitempty"`
+		ActualDeliveryDate  *jsonDate `json:"actualDeliveryDate,omitempty"`
+		InvoicePeriodStart  *jsonDate `json:"invoicePeriodStart,omitempty"`
+		InvoicePeriodEnd    *jsonDate `json:"invoicePeriodEnd,omitempty"`
+	}{
+		invoice(inv),
+		newJSONDate(inv.OriginalInvoiceDate),
+		newJSONDate(inv.ActualDeliveryDate),
+		newJSONDate(inv.InvoicePeriodStart),
+		newJSONDate(inv.InvoicePeriodEnd),
+	})
+}
+
+// UnmarshalJSON reads an invoice written by MarshalJSON. The dates are read
+// as midnight UTC.
+func (inv *Invoice) UnmarshalJSON(data []byte) error {
+	type invoice Invoice
+	v := struct {
+		*invoice
+		OriginalInvoiceDate *jsonDate `json:"originalInvoiceDate,omitempty"`
+		ActualDeliveryDate  *jsonDate `json:"actualDeliveryDate,omitempty"`
+		InvoicePeriodStart  *jsonDate `json:"invoicePeriodStart,omitempty"`
+		InvoicePeriodEnd    *jsonDate `json:"invoicePeriodEnd,omitempty"`
+	}{
+		(*invoice)(inv),
+		newJSONDate(inv.OriginalInvoiceDate),
+		newJSONDate(inv.ActualDeliveryDate),
+		newJSONDate(inv.InvoicePeriodStart),
+		newJSONDate(inv.InvoicePeriodEnd),
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	inv.OriginalInvoiceDate = v.OriginalInvoiceDate.toTime()
+	inv.ActualDeliveryDate = v.ActualDeliveryDate.toTime()
+	inv.InvoicePeriodStart = v.InvoicePeriodStart.toTime()
+	inv.InvoicePeriodEnd = v.InvoicePeriodEnd.toTime()
+	return nil
+}
+
+// MarshalJSON writes the credit note like Invoice.MarshalJSON.
+func (cn CreditNote) MarshalJSON() ([]byte, error) {
+	type creditNote CreditNote
+	return json.Marshal(struct {
+		creditNote
+		OriginalInvoiceDate *jsonDate `json:"originalInvoiceDate,omitempty"`
+		ActualDeliveryDate  *jsonDate `json:"actualDeliveryDate,omitempty"`
+		InvoicePeriodStart  *jsonDate `json:"invoicePeriodStart,omitempty"`
+		InvoicePeriodEnd    *jsonDate `json:"invoicePeriodEnd,omitempty"`
+	}{
+		creditNote(cn),
+		newJSONDate(cn.OriginalInvoiceDate),
+		newJSONDate(cn.ActualDeliveryDate),
+		newJSONDate(cn.InvoicePeriodStart),
+		newJSONDate(cn.InvoicePeriodEnd),
+	})
+}
+
+// UnmarshalJSON reads a credit note written by MarshalJSON, like
+// Invoice.UnmarshalJSON.
+func (cn *CreditNote) UnmarshalJSON(data []byte) error {
+	type creditNote CreditNote
+	v := struct {
+		*creditNote
+		OriginalInvoiceDate *jsonDate `json:"originalInvoiceDate,omitempty"`
+		ActualDeliveryDate  *jsonDate `json:"actualDeliveryDate,omitempty"`
+		InvoicePeriodStart  *jsonDate `json:"invoicePeriodStart,omitempty"`
+		InvoicePeriodEnd    *jsonDate `json:"invoicePeriodEnd,omitempty"`
+	}{
+		(*creditNote)(cn),
+		newJSONDate(cn.OriginalInvoiceDate),
+		newJSONDate(cn.ActualDeliveryDate),
+		newJSONDate(cn.InvoicePeriodStart),
+		newJSONDate(cn.InvoicePeriodEnd),
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	cn.OriginalInvoiceDate = v.OriginalInvoiceDate.toTime()
+	cn.ActualDeliveryDate = v.ActualDeliveryDate.toTime()
+	cn.InvoicePeriodStart = v.InvoicePeriodStart.toTime()
+	cn.InvoicePeriodEnd = v.InvoicePeriodEnd.toTime()
+	return nil
+}
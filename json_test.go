@@ -0,0 +1,109 @@
+package ubl_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestInvoiceJSON(t *testing.T) {
+	issued := time.Date(2025, 3, 10, 14, 30, 0, 0, time.FixedZone("CET", 3600))
+	delivered := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	start, end := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	original := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	inv := newTestInvoice()
+	inv.Now = func() time.Time { return issued }
+	inv.OriginalInvoiceID = "INV-12000"
+	inv.OriginalInvoiceDate = &original
+	inv.ActualDeliveryDate = &delivered
+	inv.InvoicePeriodStart, inv.InvoicePeriodEnd = &start, &end
+	inv.DeliveryAddress = &ubl.Address{StreetName: "Dock 4", CityName: "Antwerp", PostalZone: "2000", CountryCode: "BE"}
+	inv.SupplierContact = ubl.Contact{Name: "Jane Doe", Email: "jane@abc.example"}
+	inv.OrderReference = &ubl.OrderRef{PurchaseOrderID: "PO-77"}
+	inv.PaymentTermsNotes = []string{"2% discount if paid within 10 days"}
+	inv.AllowanceCharges = []ubl.AllowanceCharge{{Reason: "Volume discount", Amount: 50, TaxPercentage: 21}}
+	inv.Lines = append(inv.Lines, ubl.InvoiceLine{
+		Quantity:        2.5,
+		UnitCode:        "HUR",
+		Price:           80.125,
+		TaxPercentage:   21,
+		Name:            "Consulting",
+		Classifications: []ubl.ItemClassification{{Code: "72224000", ListID: "STI"}},
+		Attributes:      []ubl.ItemAttribute{{Name: "Consultant", Value: "J. Doe"}},
+	})
+	inv.PdfInvoiceFilename, inv.PdfInvoiceData = "invoice.pdf", "JVBERi0xLjQ="
+	if err := inv.AddAttachmentFromBytes([]byte("%PDF-1.4 timesheet"), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.AddExternalReference("CONTRACT", "https://abc.example/contracts/7", "Contract"); err != nil {
+		t.Fatal(err)
+	}
+	inv.Extensions = []ubl.Extension{{ID: "routing", Content: []byte(testExtension)}}
+	want := generateAndValidate(t, &inv)
+
+	data, err := json.Marshal(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`"originalInvoiceDate":"2025-01-15"`,
+		`"actualDeliveryDate":"2025-03-01"`,
+		`"invoicePeriodStart":"2025-02-01","invoicePeriodEnd":"2025-02-28"`,
+		`"deliveryAddress":{"streetName":"Dock 4",`,
+		`"supplierPeppolID":"9925:BE0123456789"`,
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("expected %s in %s", s, data)
+		}
+	}
+	if strings.Contains(string(data), `"now"`) || strings.Contains(string(data), `"receiverQuirks"`) {
+		t.Error("expected no functions in the JSON")
+	}
+
+	var got ubl.Invoice
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	got.Now = inv.Now
+	if xmlBytes := must(got.Generate()); !bytes.Equal(xmlBytes, want) {
+		t.Errorf("expected the same invoice after a JSON round trip, got %s", xmlBytes)
+	}
+
+	err = json.Unmarshal([]byte(`{"actualDeliveryDate":"01/03/2025"}`), &got)
+	if err == nil || err.Error() != `date "01/03/2025": not in the form 2006-01-02` {
+		t.Errorf("expected an error for the date, got %v", err)
+	}
+}
+
+func TestCreditNoteJSON(t *testing.T) {
+	original := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	cn := newTestCreditNote()
+	cn.Now = func() time.Time { return time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC) }
+	cn.OriginalInvoiceID, cn.OriginalInvoiceDate = "INV-12345", &original
+	cn.XMLDeclaration = ubl.XMLDeclaration{Standalone: true}
+	if err := cn.AddAttachmentFromBytes([]byte("a;b\n1;2\n"), "lines.csv", "Lines"); err != nil {
+		t.Fatal(err)
+	}
+	want := must(cn.GenerateCreditNote())
+
+	data, err := json.Marshal(&cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ubl.CreditNote
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	got.Now = cn.Now
+	if !bytes.Equal(must(got.GenerateCreditNote()), want) {
+		t.Error("expected the same credit note after a JSON round trip")
+	}
+	if !strings.Contains(string(data), `"originalInvoiceDate":"2025-01-15"`) {
+		t.Errorf("expected the date in %s", data)
+	}
+}
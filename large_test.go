@@ -0,0 +1,83 @@
+package ubl_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+// consolidatedInvoice returns an invoice with n lines, like the monthly
+// invoice of a utility with a line per connection.
+func consolidatedInvoice(n int) ubl.Invoice {
+	inv := newTestInvoice()
+	inv.Lines = make([]ubl.InvoiceLine, n)
+	for i := range inv.Lines {
+		inv.Lines[i] = ubl.InvoiceLine{
+			Quantity:      float64(i%250) + 0.5,
+			UnitCode:      "KWH",
+			Price:         0.2875,
+			TaxPercentage: []float64{21, 6}[i%2],
+			Name:          "Electricity",
+			Description:   "Connection EAN 5414" + strconv.Itoa(100000000+i),
+		}
+	}
+	return inv
+}
+
+func TestManyLines(t *testing.T) {
+	inv := consolidatedInvoice(5000)
+	xmlBytes := generateAndValidate(t, &inv)
+	var buf bytes.Buffer
+	if err := inv.GenerateTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), xmlBytes) {
+		t.Error("expected GenerateTo to write the same bytes as Generate")
+	}
+	if n := bytes.Count(xmlBytes, []byte("<cac:InvoiceLine>")); n != 5000 {
+		t.Errorf("expected 5000 lines, got %d", n)
+	}
+	values := inv.EffectiveValues()
+	if values["Lines[4999].Quantity"] != 249.5 || values["Lines[4999].TaxCategory.Percent"] != 6.0 || values["Lines[4999].UnitCode"] != "KWH" {
+		t.Errorf("expected the values of the last line, got %v", values["Lines[4999].Quantity"])
+	}
+}
+
+// BenchmarkManyLines generates an invoice with 100,000 lines, to a byte
+// slice or to a writer, and fails when generating allocates more than a few
+// times the size of the document: the lines are held once in the document
+// model, the output once more by Generate while its chunks are joined.
+func BenchmarkManyLines(b *testing.B) {
+	inv := consolidatedInvoice(100000)
+	size := len(must(inv.Generate()))
+
+	for _, c := range []struct {
+		name     string
+		generate func() error
+		limit    int // times the document size
+	}{
+		{"Generate", func() error { _, err := inv.Generate(); return err }, 5},
+		{"GenerateTo", func() error { return inv.GenerateTo(io.Discard) }, 3},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			for range b.N {
+				if err := c.generate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			runtime.ReadMemStats(&after)
+			perDocument := float64(after.TotalAlloc-before.TotalAlloc) / float64(b.N) / float64(size)
+			b.ReportMetric(perDocument, "alloc/size")
+			if perDocument > float64(c.limit) {
+				b.Errorf("allocated %.1f times the %d bytes of the document, expected at most %d", perDocument, size, c.limit)
+			}
+		})
+	}
+}
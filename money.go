@@ -0,0 +1,37 @@
+package ubl
+
+import "math"
+
+// money is an amount in minor units of its currency, e.g. cents for EUR.
+// The totals are computed in money, so sums are exact: the public float64
+// amounts are converted once on the way in, with explicit rounding, and once
+// on the way out.
+type money int64
+
+// toMoney converts amount to minor units of a currency with the given number
+// of decimals, rounding half away from zero like roundTo.
+func toMoney(amount float64, decimals int) money {
+	return money(math.Round(amount * math.Pow10(decimals)))
+}
+
+// float returns the amount in major units, e.g. 12.5 for 1250 cents.
+func (m money) float(decimals int) float64 {
+	return float64(m) / math.Pow10(decimals)
+}
+
+// percent returns rate percent of m, rounded to minor units.
+func (m money) percent(rate float64) money {
+	return money(math.Round(float64(m) * rate / 100))
+}
+
+// share returns the part of m in proportion to part/whole, rounded to minor
+// units.
+func (m money) share(part, whole money) money {
+	return money(math.Round(float64(m) * float64(part) / float64(whole)))
+}
+
+// amount returns the line amount (BT-131): quantity times net price, rounded
+// once to minor units. See lineAmounts for lines that include VAT.
+func (line InvoiceLine) amount(decimals int) money {
+	return toMoney(line.Quantity*line.netPrice(decimals), decimals)
+}
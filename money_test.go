@@ -0,0 +1,56 @@
+package ubl_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestManyLinesExactToTheCent(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = nil
+	prices := []struct {
+		price float64
+		cents int64
+	}{{0.1, 10}, {0.2, 20}, {0.7, 70}, {1.15, 115}, {2.675, 268}}
+	var cents21, cents6 int64
+	for i := range 10000 {
+		p := prices[i%len(prices)]
+		rate := 21.0
+		if i%3 == 0 {
+			rate = 6
+			cents6 += p.cents
+		} else {
+			cents21 += p.cents
+		}
+		inv.Lines = append(inv.Lines, ubl.InvoiceLine{Quantity: 1, Price: p.price, TaxPercentage: rate, Name: fmt.Sprintf("Item %d", i)})
+	}
+	tax := (cents21*21+50)/100 + (cents6*6+50)/100
+	wantLines := fmt.Sprintf("%d.%02d", (cents21+cents6)/100, (cents21+cents6)%100)
+	wantPayable := fmt.Sprintf("%d.%02d", (cents21+cents6+tax)/100, (cents21+cents6+tax)%100)
+
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := validate.CheckArithmetic(xmlBytes); len(findings) > 0 {
+		t.Errorf("unexpected findings %v", findings)
+	}
+	var parsed struct {
+		LegalMonetaryTotal struct {
+			LineExtensionAmount string `xml:"LineExtensionAmount"`
+			PayableAmount       string `xml:"PayableAmount"`
+		} `xml:"LegalMonetaryTotal"`
+	}
+	err = xml.Unmarshal(xmlBytes, &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := parsed.LegalMonetaryTotal
+	if mt.LineExtensionAmount != wantLines || mt.PayableAmount != wantPayable {
+		t.Errorf("expected %s lines and %s payable, got %s and %s", wantLines, wantPayable, mt.LineExtensionAmount, mt.PayableAmount)
+	}
+}
@@ -0,0 +1,196 @@
+package ubl
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	invoiceNamespace    = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	creditNoteNamespace = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+	cacNamespace        = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	cbcNamespace        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+)
+
+// XMLNamespaces are the namespace prefixes of the generated document. The
+// zero value writes the document namespace as default namespace and the
+// "cac" and "cbc" prefixes, the usual form. With any prefix set, the
+// document is rewritten from its parsed namespaces, so every element is in
+// the namespace its prefix declares.
+type XMLNamespaces struct {
+	Document string `json:"document,omitempty"` // Optional: prefix of the Invoice or CreditNote namespace, defaults to the default namespace
+	CAC      string `json:"cac,omitempty"`      // Optional: prefix of the aggregate components, defaults to "cac"
+	CBC      string `json:"cbc,omitempty"`      // Optional: prefix of the basic components, defaults to "cbc"
+}
+
+// check checks that the prefixes are XML names and tell the namespaces
+// apart.
+func (n XMLNamespaces) check() error {
+	var errs []error
+	seen := map[string]string{"ext": "the UBL extensions"}
+	for _, p := range []struct{ field, prefix string }{
+		{"Document", n.Document},
+		{"CAC", cmp.Or(n.CAC, "cac")},
+		{"CBC", cmp.Or(n.CBC, "cbc")},
+	} {
+		if p.prefix != "" && !isNCName(p.prefix) {
+			errs = append(errs, fmt.Errorf("Namespaces.%s %q: not a valid prefix", p.field, p.prefix))
+			continue
+		}
+		if first, ok := seen[p.prefix]; ok {
+			errs = append(errs, fmt.Errorf("Namespaces.%s %q: already the prefix of %s", p.field, p.prefix, first))
+			continue
+		}
+		seen[p.prefix] = p.field
+	}
+	return errors.Join(errs...)
+}
+
+// isNCName reports whether s is a name without colon that can be used as
+// prefix: a letter or underscore followed by letters, digits, '.', '-' and
+// '_', not starting with "xml".
+func isNCName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && (r == '.' || r == '-' || '0' <= r && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// rewrite reads the marshalled document from r and writes it to w with the
+// prefixes, after resolving the namespaces of r like any namespace-aware
+// parser. The prefixes are declared on the root element. Namespaces declared
+// below the root, in the content of an extension, keep their prefix and
+// declaration. Text is read token by token, so an attachment is held as a
+// whole once more while it's rewritten.
+func (n XMLNamespaces) rewrite(w io.Writer, r io.Reader, documentNamespace string) error {
+	prefixes := map[string]string{
+		documentNamespace: n.Document,
+		cacNamespace:      cmp.Or(n.CAC, "cac"),
+		cbcNamespace:      cmp.Or(n.CBC, "cbc"),
+		extNamespace:      "ext",
+	}
+	// bindings are the prefixes in scope of the output, prefix to namespace
+	stack := []map[string]string{{"xml": "http://www.w3.org/XML/1998/namespace"}}
+	qname := func(name xml.Name, attr bool) (string, error) {
+		bindings := stack[len(stack)-1]
+		if name.Space == "" && (attr || bindings[""] == "") {
+			return name.Local, nil
+		}
+		prefix, ok := prefixes[name.Space]
+		if !ok || bindings[prefix] != name.Space || attr && prefix == "" {
+			// a prefix declared in an extension
+			ok = false
+			for _, p := range slices.Sorted(maps.Keys(bindings)) {
+				if bindings[p] == name.Space && (p != "" || !attr) {
+					prefix, ok = p, true
+					break
+				}
+			}
+		}
+		switch {
+		case !ok:
+			return "", fmt.Errorf("namespace %q of %s: no prefix", name.Space, name.Local)
+		case prefix == "":
+			return name.Local, nil
+		}
+		return prefix + ":" + name.Local, nil
+	}
+
+	bw := bufio.NewWriter(w)
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			bindings := stack[len(stack)-1]
+			var declarations strings.Builder
+			if len(stack) == 1 {
+				namespaces := []string{documentNamespace, cacNamespace, cbcNamespace}
+				if slices.ContainsFunc(t.Attr, func(attr xml.Attr) bool { return attr.Value == extNamespace }) {
+					namespaces = append(namespaces, extNamespace)
+				}
+				for _, ns := range namespaces {
+					bindings = withPrefix(bindings, prefixes[ns], ns)
+					declarations.WriteString(xmlnsAttr(prefixes[ns], ns))
+				}
+			} else {
+				for _, attr := range t.Attr {
+					prefix, ok := "", attr.Name.Space == "" && attr.Name.Local == "xmlns"
+					if attr.Name.Space == "xmlns" {
+						prefix, ok = attr.Name.Local, true
+					}
+					if ok && bindings[prefix] != attr.Value {
+						bindings = withPrefix(bindings, prefix, attr.Value)
+						declarations.WriteString(xmlnsAttr(prefix, attr.Value))
+					}
+				}
+			}
+			stack = append(stack, bindings)
+
+			name, err := qname(t.Name, false)
+			if err != nil {
+				return err
+			}
+			bw.WriteString("<" + name + declarations.String())
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns", attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					// declared above
+				default:
+					name, err := qname(attr.Name, true)
+					if err != nil {
+						return fmt.Errorf("attribute of %s: %w", t.Name.Local, err)
+					}
+					bw.WriteString(" " + name + `="` + attributeEscaper.Replace(attr.Value) + `"`)
+				}
+			}
+			bw.WriteString(">")
+		case xml.EndElement:
+			name, err := qname(t.Name, false)
+			if err != nil {
+				return err
+			}
+			bw.WriteString("</" + name + ">")
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			textEscaper.WriteString(bw, string(t))
+		}
+	}
+	return bw.Flush()
+}
+
+// xmlnsAttr returns the declaration of the namespace with the prefix.
+func xmlnsAttr(prefix, namespace string) string {
+	if prefix == "" {
+		return ` xmlns="` + attributeEscaper.Replace(namespace) + `"`
+	}
+	return ` xmlns:` + prefix + `="` + attributeEscaper.Replace(namespace) + `"`
+}
+
+// textEscaper and attributeEscaper escape like xml.Encoder, so the text
+// reads the same with any prefixes.
+var (
+	textEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;", "'", "&#39;", "\t", "&#x9;", "\r", "&#xD;")
+	attributeEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;", "'", "&#39;", "\t", "&#x9;", "\r", "&#xD;", "\n", "&#xA;")
+)
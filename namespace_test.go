@@ -0,0 +1,117 @@
+package ubl_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+// resolvedTokens parses a document namespace-aware and lists its elements,
+// attributes and text by namespace URI, leaving out the prefixes and the
+// namespace declarations.
+func resolvedTokens(t *testing.T, data []byte) []string {
+	t.Helper()
+	var tokens []string
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			tokens = append(tokens, "<{"+tok.Name.Space+"}"+tok.Name.Local)
+			for _, attr := range tok.Attr {
+				if attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" {
+					tokens = append(tokens, fmt.Sprintf("@{%s}%s=%q", attr.Name.Space, attr.Name.Local, attr.Value))
+				}
+			}
+		case xml.EndElement:
+			tokens = append(tokens, "</{"+tok.Name.Space+"}"+tok.Name.Local)
+		case xml.CharData:
+			if text := strings.TrimSpace(string(tok)); text != "" {
+				tokens = append(tokens, text)
+			}
+		}
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	inv := newTestInvoice()
+	inv.CustomerName = `Smith & "Sons" <Ltd>`
+	if err := inv.AddAttachmentFromBytes([]byte("%PDF-1.4"), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	want := resolvedTokens(t, generateAndValidate(t, &inv))
+
+	inv.Namespaces = ubl.XMLNamespaces{Document: "inv", CAC: "ac", CBC: "bc"}
+	xmlBytes := generateAndValidate(t, &inv)
+	root := `<inv:Invoice xmlns:inv="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2" xmlns:ac="urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2" xmlns:bc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2">`
+	if !bytes.HasPrefix(xmlBytes, []byte(xml.Header+root)) {
+		t.Errorf("expected the prefixes declared on the root, got %.300s", xmlBytes)
+	}
+	for _, s := range []string{
+		"\n  <bc:ID>INV-12345</bc:ID>\n",
+		"<bc:RegistrationName>Smith &amp; &#34;Sons&#34; &lt;Ltd&gt;</bc:RegistrationName>",
+		`<bc:EmbeddedDocumentBinaryObject mimeCode="application/pdf" filename="timesheet.pdf">`,
+		"</inv:Invoice>",
+	} {
+		if !bytes.Contains(xmlBytes, []byte(s)) {
+			t.Errorf("expected %s in output", s)
+		}
+	}
+	if got := resolvedTokens(t, xmlBytes); !slices.Equal(got, want) {
+		t.Error("expected the same namespaced elements, attributes and text with other prefixes")
+	}
+
+	var buf bytes.Buffer
+	if err := inv.GenerateTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), xmlBytes) {
+		t.Error("expected GenerateTo to write the same bytes as Generate")
+	}
+
+	cn := newTestCreditNote()
+	want = resolvedTokens(t, must(cn.GenerateCreditNote()))
+	cn.Namespaces = ubl.XMLNamespaces{CBC: "b"}
+	cnBytes := must(cn.GenerateCreditNote())
+	if !bytes.Contains(cnBytes, []byte(`<CreditNote xmlns="urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2" xmlns:cac=`)) {
+		t.Error("expected the default namespace and the cac prefix")
+	}
+	if got := resolvedTokens(t, cnBytes); !slices.Equal(got, want) {
+		t.Error("expected the same credit note with another cbc prefix")
+	}
+
+	for _, c := range []struct {
+		namespaces ubl.XMLNamespaces
+		want       string
+	}{
+		{ubl.XMLNamespaces{CAC: "1a"}, `Namespaces.CAC "1a": not a valid prefix`},
+		{ubl.XMLNamespaces{Document: "xmlInv"}, `Namespaces.Document "xmlInv": not a valid prefix`},
+		{ubl.XMLNamespaces{CAC: "cbc"}, `Namespaces.CBC "cbc": already the prefix of CAC`},
+		{ubl.XMLNamespaces{Document: "ubl", CBC: "ubl"}, `Namespaces.CBC "ubl": already the prefix of Document`},
+	} {
+		inv.Namespaces = c.namespaces
+		_, err := inv.Generate()
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%+v: expected %q, got %v", c.namespaces, c.want, err)
+		}
+	}
+}
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
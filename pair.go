@@ -0,0 +1,67 @@
+package ubl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Finding is a problem found by a cross-document check.
+type Finding struct {
+	Check   string // "currency", "supplier", "customer" or "total"
+	Message string
+}
+
+func (f Finding) String() string {
+	return f.Check + ": " + f.Message
+}
+
+// CheckPair checks that a credit note can credit an invoice: same currency,
+// same supplier and customer, and a credited total that doesn't exceed the
+// invoice total. It returns nil when the pair is consistent.
+func CheckPair(inv *Invoice, cn *CreditNote) []Finding {
+	var findings []Finding
+
+	if inv.currency() != cn.currency() {
+		findings = append(findings, Finding{"currency", fmt.Sprintf("invoice in %s, credit note in %s", inv.currency(), cn.currency())})
+	}
+
+	if msg := partyMismatch(
+		cleanVATIdentifier(inv.SupplierVat, inv.SupplierAddress.CountryCode), resolvedParticipantID(inv.SupplierPeppolID, inv.SupplierEndpointScheme, inv.SupplierEndpointValue),
+		cleanVATIdentifier(cn.SupplierVat, cn.SupplierAddress.CountryCode), resolvedParticipantID(cn.SupplierPeppolID, cn.SupplierEndpointScheme, cn.SupplierEndpointValue),
+	); msg != "" {
+		findings = append(findings, Finding{"supplier", msg})
+	}
+
+	if msg := partyMismatch(
+		cleanVATIdentifier(inv.CustomerVat, inv.CustomerAddress.CountryCode), resolvedParticipantID(inv.CustomerPeppolID, inv.CustomerEndpointScheme, inv.CustomerEndpointValue),
+		cleanVATIdentifier(cn.CustomerVat, cn.CustomerAddress.CountryCode), resolvedParticipantID(cn.CustomerPeppolID, cn.CustomerEndpointScheme, cn.CustomerEndpointValue),
+	); msg != "" {
+		findings = append(findings, Finding{"customer", msg})
+	}
+
+	invTotals, err := inv.Totals()
+	if err != nil {
+		return append(findings, Finding{"total", fmt.Sprintf("invoice: %v", err)})
+	}
+	cnTotals, err := cn.Totals()
+	if err != nil {
+		return append(findings, Finding{"total", fmt.Sprintf("credit note: %v", err)})
+	}
+	if cnTotals.Payable > invTotals.Payable {
+		findings = append(findings, Finding{"total", fmt.Sprintf("credit note total %.2f exceeds invoice total %.2f", cnTotals.Payable, invTotals.Payable)})
+	}
+
+	return findings
+}
+
+// partyMismatch compares the VAT identifier and the Peppol ID of a party on
+// both documents and describes the first difference.
+func partyMismatch(invVat, invPeppolID, cnVat, cnPeppolID string) string {
+	if !strings.EqualFold(invVat, cnVat) {
+		return fmt.Sprintf("VAT identifier %q on the invoice, %q on the credit note", invVat, cnVat)
+	}
+	if !strings.EqualFold(invPeppolID, cnPeppolID) {
+		return fmt.Sprintf("Peppol ID %q on the invoice, %q on the credit note", invPeppolID, cnPeppolID)
+	}
+	return ""
+}
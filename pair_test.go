@@ -0,0 +1,39 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestCheckPair(t *testing.T) {
+	inv := newTestInvoice()
+	cn := newTestCreditNote()
+	if findings := ubl.CheckPair(&inv, &cn); len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+
+	cn = newTestCreditNote()
+	cn.Currency = "USD"
+	findings := ubl.CheckPair(&inv, &cn)
+	if len(findings) != 1 || findings[0].String() != "currency: invoice in EUR, credit note in USD" {
+		t.Errorf("expected a currency finding, got %v", findings)
+	}
+
+	cn = newTestCreditNote()
+	cn.Lines = []ubl.InvoiceLine{{Quantity: 11, Price: 100, TaxPercentage: 21, Name: "Product A"}}
+	findings = ubl.CheckPair(&inv, &cn)
+	if len(findings) != 1 || findings[0].String() != "total: credit note total 1331.00 exceeds invoice total 1210.00" {
+		t.Errorf("expected an over-credit finding, got %v", findings)
+	}
+
+	cn = newTestCreditNote()
+	cn.SupplierVat = "BE0999999999"
+	cn.CustomerPeppolID = "0208:0987654321"
+	findings = ubl.CheckPair(&inv, &cn)
+	if len(findings) != 2 || findings[0].Check != "supplier" || findings[1].Check != "customer" ||
+		!strings.Contains(findings[1].Message, `Peppol ID "9925:BE9876543210" on the invoice`) {
+		t.Errorf("expected supplier and customer findings, got %v", findings)
+	}
+}
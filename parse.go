@@ -0,0 +1,748 @@
+package ubl
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MalformedXMLError is returned by ParseInvoice and ParseCreditNote for input
+// that can't be read as XML, e.g. a truncated document.
+type MalformedXMLError struct {
+	Err error // the error of encoding/xml
+}
+
+func (e *MalformedXMLError) Error() string {
+	return "parse: malformed XML: " + e.Err.Error()
+}
+
+func (e *MalformedXMLError) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnsupportedDocumentType is the error for a document that is neither a
+// UBL invoice nor a UBL credit note, e.g. an order. The error of Parse
+// names the root element, a *RootElementError unwraps to it.
+var ErrUnsupportedDocumentType = errors.New("unsupported document type")
+
+// RootElementError is returned by ParseInvoice and ParseCreditNote for a
+// well-formed document with another root element, e.g. a credit note given
+// to ParseInvoice.
+type RootElementError struct {
+	Name     xml.Name     // the root element of the document
+	Expected DocumentType // the root element that was expected
+}
+
+func (e *RootElementError) Error() string {
+	return fmt.Sprintf("parse: root element %s in namespace %q, expected a UBL %s", e.Name.Local, e.Name.Space, e.Expected)
+}
+
+// Unwrap returns ErrUnsupportedDocumentType when the root element is neither
+// a UBL invoice nor a UBL credit note.
+func (e *RootElementError) Unwrap() error {
+	if _, ok := documentType(e.Name); ok {
+		return nil
+	}
+	return ErrUnsupportedDocumentType
+}
+
+// documentRoots are the root elements of the document types.
+var documentRoots = map[DocumentType]xml.Name{
+	DocumentTypeInvoice:    {Space: invoiceNamespace, Local: "Invoice"},
+	DocumentTypeCreditNote: {Space: creditNoteNamespace, Local: "CreditNote"},
+}
+
+// documentType returns the document type of a root element.
+func documentType(name xml.Name) (DocumentType, bool) {
+	for docType, root := range documentRoots {
+		if name == root {
+			return docType, true
+		}
+	}
+	return "", false
+}
+
+// Parse reads a UBL 2.1 invoice or credit note, whichever the root element
+// is, e.g. for inbound Peppol traffic that mixes both. It returns an
+// *Invoice or a *CreditNote, as ParseInvoice and ParseCreditNote do, and its
+// DocumentType. Another root element gives an error that wraps
+// ErrUnsupportedDocumentType and names the element.
+func Parse(r io.Reader) (any, DocumentType, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+	start, err := rootElement(dec)
+	if err != nil {
+		return nil, "", err
+	}
+	docType, ok := documentType(start.Name)
+	if !ok {
+		return nil, "", fmt.Errorf("parse: %w %s in namespace %q", ErrUnsupportedDocumentType, start.Name.Local, start.Name.Space)
+	}
+	doc, err := unmarshalDocument(dec, start)
+	if err != nil {
+		return nil, "", err
+	}
+	if docType == DocumentTypeCreditNote {
+		cn, err := parseCreditNote(doc.CreditNote)
+		if err != nil {
+			return nil, "", err
+		}
+		return cn, docType, nil
+	}
+	inv, err := parseInvoice(doc.Invoice)
+	if err != nil {
+		return nil, "", err
+	}
+	return inv, docType, nil
+}
+
+// ParseInvoice reads a UBL 2.1 invoice, e.g. one received over Peppol, into
+// an Invoice. Generating the result gives back the same document as far as
+// the Invoice fields can express it: elements they don't cover, like the
+// document notes, are skipped and the amounts are computed again from the
+// lines, see Totals, without a payable rounding amount other than the one
+// for prices that include VAT. Allowances and charges are read per tax category, as
+// the document has them. A signature is kept as an extension, which no longer
+// matches once the invoice is changed.
+//
+// Values Generate derives are left empty: a trading name that is the name,
+// line IDs that are the line number, an order reference that is the invoice
+// ID, a creditor identifier that is part of the direct debit and the default
+// specification identifiers and type code. Now returns the issue date, at
+// midnight UTC like all dates; the due date is not kept.
+//
+// Input that isn't well-formed XML gives a *MalformedXMLError, a document
+// that isn't a UBL invoice a *RootElementError.
+func ParseInvoice(r io.Reader) (*Invoice, error) {
+	doc, err := decodeDocument(r, DocumentTypeInvoice)
+	if err != nil {
+		return nil, err
+	}
+	return parseInvoice(doc.Invoice)
+}
+
+// ParseCreditNote reads a UBL 2.1 credit note into a CreditNote, like
+// ParseInvoice. The credited quantities become the line quantities, the
+// invoice reference OriginalInvoiceID and OriginalInvoiceDate.
+func ParseCreditNote(r io.Reader) (*CreditNote, error) {
+	doc, err := decodeDocument(r, DocumentTypeCreditNote)
+	if err != nil {
+		return nil, err
+	}
+	return parseCreditNote(doc.CreditNote)
+}
+
+// decodeDocument reads the document model of a document with the root
+// element of docType, with any namespace prefixes.
+func decodeDocument(r io.Reader, docType DocumentType) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+	start, err := rootElement(dec)
+	if err != nil {
+		return nil, err
+	}
+	if start.Name != documentRoots[docType] {
+		return nil, &RootElementError{Name: start.Name, Expected: docType}
+	}
+	return unmarshalDocument(dec, start)
+}
+
+// rootElement returns the start of the root element, skipping the XML
+// declaration, comments and whitespace before it.
+func rootElement(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, &MalformedXMLError{Err: errors.New("no root element")}
+		}
+		if err != nil {
+			return xml.StartElement{}, decodeError(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// unmarshalDocument reads the document model from the root element start.
+func unmarshalDocument(dec *xml.Decoder, start xml.StartElement) (*Document, error) {
+	doc := &Document{}
+	err := doc.UnmarshalXML(dec, start)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	return doc, nil
+}
+
+// decodeError returns a *MalformedXMLError for an XML syntax error.
+func decodeError(err error) error {
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &MalformedXMLError{Err: err}
+	}
+	return fmt.Errorf("parse: %w", err)
+}
+
+func parseInvoice(x *XMLInvoice) (*Invoice, error) {
+	inv, err := parseHeader(x)
+	if err != nil {
+		return nil, err
+	}
+	if x.InvoiceTypeCode != invoiceTypeCode("", inv.SelfBilling) {
+		inv.InvoiceTypeCode = x.InvoiceTypeCode
+	}
+	for i, line := range x.InvoiceLines {
+		inv.Lines = append(inv.Lines, parseLine(line, i))
+	}
+	if inv.UBLBEDescription == "CommercialInvoice" {
+		inv.UBLBEDescription = ""
+	}
+	return inv, nil
+}
+
+func parseCreditNote(x *XMLCreditNote) (*CreditNote, error) {
+	h, err := parseHeader(&XMLInvoice{
+		UBLExtensions:               x.UBLExtensions,
+		CustomizationID:             x.CustomizationID,
+		ProfileID:                   x.ProfileID,
+		ID:                          x.ID,
+		UUID:                        x.UUID,
+		IssueDate:                   x.IssueDate,
+		DocumentCurrency:            x.DocumentCurrency,
+		BuyerReference:              x.BuyerReference,
+		InvoicePeriod:               x.InvoicePeriod,
+		OrderReference:              x.OrderReference,
+		BillingReference:            x.BillingReference,
+		AdditionalDocumentReference: x.AdditionalDocumentReference,
+		SupplierParty:               x.SupplierParty,
+		CustomerParty:               x.CustomerParty,
+		Delivery:                    x.Delivery,
+		PaymentMeans:                x.PaymentMeans,
+		PaymentTerms:                x.PaymentTerms,
+		AllowanceCharge:             x.AllowanceCharge,
+		TaxTotal:                    x.TaxTotal,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cn := &CreditNote{
+		ID:                       h.ID,
+		UUID:                     h.UUID,
+		CustomizationID:          h.CustomizationID,
+		ProfileID:                h.ProfileID,
+		NoDefaultSpecification:   h.NoDefaultSpecification,
+		Profile:                  h.Profile,
+		BuyerReference:           h.BuyerReference,
+		IncludeUBLBEReference:    h.IncludeUBLBEReference,
+		UBLBEDescription:         h.UBLBEDescription,
+		SelfBilling:              h.SelfBilling,
+		Now:                      h.Now,
+		OriginalInvoiceID:        h.OriginalInvoiceID,
+		OriginalInvoiceDate:      h.OriginalInvoiceDate,
+		SupplierName:             h.SupplierName,
+		SupplierTradingName:      h.SupplierTradingName,
+		SupplierVat:              h.SupplierVat,
+		SupplierPeppolID:         h.SupplierPeppolID,
+		SupplierAddress:          h.SupplierAddress,
+		SupplierContact:          h.SupplierContact,
+		SupplierLegalForm:        h.SupplierLegalForm,
+		SupplierCompanyID:        h.SupplierCompanyID,
+		SupplierCompanyIDScheme:  h.SupplierCompanyIDScheme,
+		SupplierAdditionalIDs:    h.SupplierAdditionalIDs,
+		SupplierTaxRegistrations: h.SupplierTaxRegistrations,
+		CustomerName:             h.CustomerName,
+		CustomerTradingName:      h.CustomerTradingName,
+		CustomerVat:              h.CustomerVat,
+		CustomerPeppolID:         h.CustomerPeppolID,
+		CustomerAddress:          h.CustomerAddress,
+		CustomerAdditionalIDs:    h.CustomerAdditionalIDs,
+		DeliveryAddress:          h.DeliveryAddress,
+		ActualDeliveryDate:       h.ActualDeliveryDate,
+		DeliveryLocationID:       h.DeliveryLocationID,
+		DeliveryLocationIDScheme: h.DeliveryLocationIDScheme,
+		DeliveryPartyName:        h.DeliveryPartyName,
+		InvoicePeriodStart:       h.InvoicePeriodStart,
+		InvoicePeriodEnd:         h.InvoicePeriodEnd,
+		Iban:                     h.Iban,
+		Bic:                      h.Bic,
+		AccountName:              h.AccountName,
+		BankAccounts:             h.BankAccounts,
+		DirectDebit:              h.DirectDebit,
+		PaymentMeansCode:         h.PaymentMeansCode,
+		PaymentMeansName:         h.PaymentMeansName,
+		Note:                     h.Note,
+		Currency:                 h.Currency,
+		TaxSchemeID:              h.TaxSchemeID,
+		AllowanceCharges:         h.AllowanceCharges,
+		OrderReference:           h.OrderReference,
+		Attachments:              h.Attachments,
+		Extensions:               h.Extensions,
+	}
+	if x.CreditNoteTypeCode != creditNoteTypeCode("", cn.SelfBilling) {
+		cn.CreditNoteTypeCode = x.CreditNoteTypeCode
+	}
+	for i, line := range x.CreditNoteLines {
+		cn.Lines = append(cn.Lines, parseLine(XMLInvoiceLine{
+			ID:                  line.ID,
+			Note:                line.Note,
+			InvoicedQuantity:    line.CreditedQuantity,
+			LineExtensionAmount: line.LineExtensionAmount,
+			AccountingCost:      line.AccountingCost,
+			OrderLineReference:  line.OrderLineReference,
+			DocumentReference:   line.DocumentReference,
+			Item:                line.Item,
+			Price:               line.Price,
+		}, i))
+	}
+	if cn.UBLBEDescription == "CreditNote" {
+		cn.UBLBEDescription = ""
+	}
+	return cn, nil
+}
+
+// parseHeader reads everything but the type code and the lines, which
+// invoices and credit notes have in common. A credit note passes its
+// elements in an XMLInvoice and copies the result from the Invoice, like its
+// lines go through parseLine as invoice lines. The UBL.BE description is
+// left as is, its default depends on the document type.
+func parseHeader(x *XMLInvoice) (*Invoice, error) {
+	issueDate, err := parseDate("IssueDate", x.IssueDate)
+	if err != nil {
+		return nil, err
+	}
+	if issueDate == nil {
+		return nil, errors.New("parse: IssueDate: required")
+	}
+	h := &Invoice{
+		ID:             x.ID,
+		UUID:           x.UUID,
+		BuyerReference: x.BuyerReference,
+		SelfBilling:    x.ProfileID == PeppolSelfBilling30ProfileID,
+		Now:            func() time.Time { return *issueDate },
+		Currency:       x.DocumentCurrency,
+		TaxSchemeID:    parseTaxScheme(x.TaxTotal),
+		OrderReference: parseOrderReference(x.OrderReference, x.ID),
+		Extensions:     parseExtensions(x.UBLExtensions),
+	}
+	h.Profile, h.CustomizationID, h.ProfileID, h.NoDefaultSpecification = parseSpecification(x.CustomizationID, x.ProfileID, h.SelfBilling)
+	if len(x.BillingReference) > 0 {
+		ref := x.BillingReference[0].InvoiceDocumentReference
+		h.OriginalInvoiceID = ref.ID
+		h.OriginalInvoiceDate, err = parseDate("BillingReference.IssueDate", ref.IssueDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	payment := parsePaymentMeans(x.PaymentMeans)
+	supplier := parseParty(x.SupplierParty.Party)
+	if payment.directDebit != nil {
+		payment.directDebit.CreditorID, supplier.additionalIDs = creditorID(supplier.additionalIDs)
+	}
+	h.SupplierName = supplier.name
+	h.SupplierTradingName = supplier.tradingName
+	h.SupplierVat, h.SupplierTaxRegistrations = parseSupplierTaxSchemes(x.SupplierParty.Party.PartyTaxScheme, cmp.Or(h.TaxSchemeID, "VAT"))
+	h.SupplierPeppolID = supplier.peppolID
+	h.SupplierAddress = supplier.address
+	h.SupplierContact = supplier.contact
+	h.SupplierLegalForm = supplier.legalForm
+	h.SupplierCompanyID = supplier.companyID
+	h.SupplierCompanyIDScheme = supplier.companyIDScheme
+	h.SupplierAdditionalIDs = supplier.additionalIDs
+
+	customer := parseParty(x.CustomerParty.Party)
+	h.CustomerName = customer.name
+	h.CustomerTradingName = customer.tradingName
+	h.CustomerVat = customer.vat
+	h.CustomerPeppolID = customer.peppolID
+	h.CustomerAddress = customer.address
+	h.CustomerAdditionalIDs = customer.additionalIDs
+
+	delivery, err := parseDelivery(x.Delivery)
+	if err != nil {
+		return nil, err
+	}
+	h.DeliveryAddress = delivery.address
+	h.ActualDeliveryDate = delivery.date
+	h.DeliveryLocationID = delivery.locationID
+	h.DeliveryLocationIDScheme = delivery.locationIDScheme
+	h.DeliveryPartyName = delivery.partyName
+	if p := x.InvoicePeriod; p != nil {
+		h.InvoicePeriodStart, err = parseDate("InvoicePeriod.StartDate", p.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		h.InvoicePeriodEnd, err = parseDate("InvoicePeriod.EndDate", p.EndDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h.Iban = payment.iban
+	h.Bic = payment.bic
+	h.AccountName = payment.accountName
+	h.BankAccounts = payment.accounts
+	h.DirectDebit = payment.directDebit
+	h.PaymentMeansCode = payment.code
+	h.PaymentMeansName = payment.name
+	if x.PaymentTerms != nil {
+		h.Note = strings.Join(x.PaymentTerms.Note, "\n")
+	}
+	h.AllowanceCharges = parseAllowanceCharges(x.AllowanceCharge)
+
+	refs, err := parseDocumentReferences(x.AdditionalDocumentReference)
+	if err != nil {
+		return nil, err
+	}
+	h.IncludeUBLBEReference = refs.ublBE && h.Profile != ProfileUBLBE
+	h.UBLBEDescription = refs.ublBEDescription
+	h.Attachments = refs.attachments
+	return h, nil
+}
+
+// parseDate reads a date in the form 2006-01-02 as midnight UTC, nil when s
+// is empty. field names the element in errors.
+func parseDate(field, s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return nil, fmt.Errorf("parse: %s %q: not in the form 2006-01-02", field, s)
+	}
+	return &t, nil
+}
+
+// parseSpecification returns the profile the CustomizationID belongs to and
+// the identifiers that aren't its defaults, or noDefault when the document
+// has neither.
+func parseSpecification(customizationID, profileID string, selfBilling bool) (profile Profile, customization, process string, noDefault bool) {
+	if customizationID == "" && profileID == "" {
+		return ProfilePeppol, "", "", true
+	}
+	for _, p := range []Profile{ProfileXRechnung, ProfileNLCIUS, ProfileUBLBE} {
+		if customizationID == p.customizationID() {
+			profile = p
+		}
+	}
+	defaultCustomization, defaultProfile, _ := specification("", "", profile, selfBilling, false)
+	if customizationID == defaultCustomization {
+		customizationID = ""
+	}
+	if profileID == defaultProfile {
+		profileID = ""
+	}
+	return profile, customizationID, profileID, false
+}
+
+// parseTaxScheme returns the tax scheme of the VAT breakdown, "" for VAT.
+func parseTaxScheme(total XMLTaxTotal) string {
+	if len(total.TaxSubtotal) == 0 {
+		return ""
+	}
+	scheme := total.TaxSubtotal[0].TaxCategory.TaxScheme.ID
+	if scheme == "VAT" {
+		return ""
+	}
+	return scheme
+}
+
+// parseOrderReference returns the order reference, nil when it is the
+// document ID Generate uses without one.
+func parseOrderReference(ref *XMLOrderReference, documentID string) *OrderRef {
+	if ref == nil || ref.SalesOrderID == "" && ref.ID == documentID {
+		return nil
+	}
+	o := &OrderRef{PurchaseOrderID: ref.ID, SalesOrderID: ref.SalesOrderID}
+	if o.SalesOrderID != "" && o.PurchaseOrderID == "NA" {
+		o.PurchaseOrderID = ""
+	}
+	return o
+}
+
+func parseExtensions(x *XMLUBLExtensions) []Extension {
+	if x == nil {
+		return nil
+	}
+	var extensions []Extension
+	for _, e := range x.UBLExtension {
+		extensions = append(extensions, Extension{ID: e.ID, Content: e.ExtensionContent.Content})
+	}
+	return extensions
+}
+
+// parsedParty is a cac:Party read back.
+type parsedParty struct {
+	name            string
+	tradingName     string
+	vat             string // the first tax identifier
+	peppolID        string
+	address         Address
+	contact         Contact
+	legalForm       string
+	companyID       string
+	companyIDScheme string
+	additionalIDs   []PartyID
+}
+
+func parseParty(p XMLParty) parsedParty {
+	party := parsedParty{
+		name:      p.PartyLegalEntity.RegistrationName,
+		address:   parseAddress(p.PostalAddress),
+		legalForm: p.PartyLegalEntity.CompanyLegalForm,
+	}
+	if p.PartyName != party.name {
+		party.tradingName = p.PartyName
+	}
+	if len(p.PartyTaxScheme) > 0 {
+		party.vat = p.PartyTaxScheme[0].CompanyID
+	}
+	if p.EndpointID.Value != "" {
+		party.peppolID = p.EndpointID.participantID()
+	}
+	if c := p.Contact; c != nil {
+		party.contact = Contact{Name: c.Name, Phone: c.Telephone, Email: c.ElectronicMail}
+	}
+	if id := p.PartyLegalEntity.CompanyID; id != nil {
+		party.companyID, party.companyIDScheme = id.Value, id.SchemeID
+	}
+	for _, id := range p.PartyIdentification {
+		party.additionalIDs = append(party.additionalIDs, PartyID{Value: id.ID.Value, SchemeID: id.ID.SchemeID})
+	}
+	return party
+}
+
+// parseSupplierTaxSchemes returns the VAT identifier and the other tax
+// registrations of the seller, see supplierTaxSchemes.
+func parseSupplierTaxSchemes(schemes []XMLPartyTaxScheme, taxScheme string) (string, []TaxRegistration) {
+	var vat string
+	var registrations []TaxRegistration
+	for _, s := range schemes {
+		if s.TaxScheme.ID == taxScheme && vat == "" {
+			vat = s.CompanyID
+			continue
+		}
+		registrations = append(registrations, TaxRegistration{CompanyID: s.CompanyID, SchemeID: s.TaxScheme.ID})
+	}
+	return vat, registrations
+}
+
+// creditorID returns the SEPA creditor identifier among the party
+// identifiers and the others.
+func creditorID(ids []PartyID) (string, []PartyID) {
+	i := slices.IndexFunc(ids, func(id PartyID) bool { return id.SchemeID == "SEPA" })
+	if i < 0 {
+		return "", ids
+	}
+	id := ids[i].Value
+	ids = slices.Delete(ids, i, i+1)
+	if len(ids) == 0 {
+		ids = nil
+	}
+	return id, ids
+}
+
+func parseAddress(a XMLPostalAddress) Address {
+	addr := Address{
+		StreetName:  a.StreetName,
+		StreetName2: a.AdditionalStreetName,
+		CityName:    a.CityName,
+		PostalZone:  a.PostalZone,
+		Region:      a.CountrySubentity,
+		CountryCode: a.Country.IdentificationCode,
+	}
+	if a.AddressLine != nil {
+		addr.AddressLine3 = a.AddressLine.Line
+	}
+	return addr
+}
+
+// parsedDelivery is a cac:Delivery read back.
+type parsedDelivery struct {
+	address          *Address
+	date             *time.Time
+	locationID       string
+	locationIDScheme string
+	partyName        string
+}
+
+func parseDelivery(d *XMLDelivery) (parsedDelivery, error) {
+	if d == nil {
+		return parsedDelivery{}, nil
+	}
+	date, err := parseDate("Delivery.ActualDeliveryDate", d.ActualDeliveryDate)
+	if err != nil {
+		return parsedDelivery{}, err
+	}
+	delivery := parsedDelivery{date: date}
+	if loc := d.DeliveryLocation; loc != nil {
+		if loc.ID != nil {
+			delivery.locationID, delivery.locationIDScheme = loc.ID.Value, loc.ID.SchemeID
+		}
+		if loc.Address != nil {
+			addr := parseAddress(*loc.Address)
+			delivery.address = &addr
+		}
+	}
+	if d.DeliveryParty != nil {
+		delivery.partyName = d.DeliveryParty.PartyName
+	}
+	return delivery, nil
+}
+
+// parsedPayment are the cac:PaymentMeans read back: a single account in
+// iban, bic and accountName, several in accounts.
+type parsedPayment struct {
+	code        string
+	name        string
+	iban        string
+	bic         string
+	accountName string
+	accounts    []BankAccount
+	directDebit *DirectDebit
+}
+
+func parsePaymentMeans(means []XMLPaymentMeans) parsedPayment {
+	var payment parsedPayment
+	if len(means) == 0 {
+		return payment
+	}
+	payment.code, payment.name = means[0].PaymentMeansCode.Value, means[0].PaymentMeansCode.Name
+	for _, m := range means {
+		if mandate := m.PaymentMandate; mandate != nil && payment.directDebit == nil {
+			payment.directDebit = &DirectDebit{MandateReference: mandate.ID}
+			if mandate.PayerFinancialAccount != nil {
+				payment.directDebit.DebtorIban = mandate.PayerFinancialAccount.ID
+			}
+		}
+		if a := m.PayeeFinancialAccount; a != nil && a.ID != "" {
+			account := BankAccount{Iban: a.ID, Name: a.Name}
+			if a.FinancialInstitutionBranch != nil {
+				account.Bic = a.FinancialInstitutionBranch.ID
+			}
+			payment.accounts = append(payment.accounts, account)
+		}
+	}
+	if len(payment.accounts) == 1 {
+		a := payment.accounts[0]
+		payment.iban, payment.bic, payment.accountName = a.Iban, a.Bic, a.Name
+		payment.accounts = nil
+	}
+	return payment
+}
+
+// parseLine returns line i of the document, for a credit note with the
+// credited quantity in InvoicedQuantity.
+func parseLine(x XMLInvoiceLine, i int) InvoiceLine {
+	cat := x.Item.ClassifiedTaxCategory
+	line := InvoiceLine{
+		Quantity:           x.InvoicedQuantity.Value,
+		Price:              x.Price.PriceAmount.Value,
+		TaxPercentage:      cat.rate(),
+		TaxCategoryID:      cat.ID,
+		TaxCategoryName:    cat.Name,
+		TaxExemptionReason: cat.TaxExemptionReason,
+		TaxExemptionCode:   cat.TaxExemptionReasonCode,
+		UnitCode:           x.InvoicedQuantity.UnitCode,
+		Note:               x.Note,
+		AccountingCost:     x.AccountingCost,
+		Name:               x.Item.Name,
+		Description:        x.Item.Description,
+	}
+	if x.ID != strconv.Itoa(i+1) {
+		line.LineID = x.ID
+	}
+	if x.OrderLineReference != nil {
+		line.OrderLineID = x.OrderLineReference.LineID
+	}
+	if ref := x.DocumentReference; ref != nil {
+		line.ObjectID, line.ObjectIDScheme = ref.ID.Value, ref.ID.SchemeID
+	}
+	if id := x.Item.StandardItemIdentification; id != nil {
+		line.StandardItemID, line.StandardItemIDScheme = id.ID.Value, id.ID.SchemeID
+	}
+	for _, c := range x.Item.CommodityClassification {
+		code := c.ItemClassificationCode
+		line.Classifications = append(line.Classifications, ItemClassification{Code: code.Value, ListID: code.ListID, ListVersionID: code.ListVersionID})
+	}
+	for _, p := range x.Item.AdditionalItemProperty {
+		line.Attributes = append(line.Attributes, ItemAttribute{Name: p.Name, Value: p.Value})
+	}
+	if ac := x.Price.AllowanceCharge; ac != nil {
+		line.GrossPrice = ac.BaseAmount.Value
+		line.PriceDiscount = ac.Amount.Value
+		if ac.ChargeIndicator {
+			line.PriceDiscount = -line.PriceDiscount
+		}
+	}
+	return line
+}
+
+func parseAllowanceCharges(acs []XMLAllowanceCharge) []AllowanceCharge {
+	var result []AllowanceCharge
+	for _, x := range acs {
+		ac := AllowanceCharge{
+			Charge:        x.ChargeIndicator,
+			Reason:        x.AllowanceChargeReason,
+			ReasonCode:    x.AllowanceChargeReasonCode,
+			Amount:        x.Amount.Value,
+			Percentage:    x.MultiplierFactorNumeric,
+			TaxCategoryID: x.TaxCategory.ID,
+			TaxPercentage: x.TaxCategory.rate(),
+		}
+		if ac.Percentage != 0 && x.BaseAmount != nil {
+			ac.BaseAmount = x.BaseAmount.Value
+		}
+		result = append(result, ac)
+	}
+	return result
+}
+
+// parsedReferences are the cac:AdditionalDocumentReference elements read
+// back: the UBL.BE reference and the attachments, the PDF included.
+type parsedReferences struct {
+	ublBE            bool
+	ublBEDescription string
+	attachments      []Attachment
+}
+
+func parseDocumentReferences(refs []XMLDocumentReference) (parsedReferences, error) {
+	var result parsedReferences
+	for _, ref := range refs {
+		if ref.ID.Value == "UBL.BE" && len(ref.Attachment) == 0 && !result.ublBE {
+			result.ublBE, result.ublBEDescription = true, ref.DocumentDescription
+			continue
+		}
+		a := Attachment{
+			ID:               ref.ID.Value,
+			IDScheme:         ref.ID.SchemeID,
+			DocumentTypeCode: ref.DocumentTypeCode,
+			Description:      ref.DocumentDescription,
+		}
+		if len(ref.Attachment) > 0 {
+			switch x := ref.Attachment[0]; {
+			case x.ExternalReference != nil:
+				a.URI = x.ExternalReference.URI
+			case x.EmbeddedDocumentBinaryObject != nil:
+				obj := x.EmbeddedDocumentBinaryObject
+				a.Filename, a.MimeCode = obj.Filename, obj.MimeCode
+				// other producers may wrap the base64 text in lines
+				data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(obj.Value), ""))
+				if err != nil {
+					return parsedReferences{}, fmt.Errorf("parse: attachment %q: %w", a.ID, err)
+				}
+				a.Data = data
+			}
+		}
+		result.attachments = append(result.attachments, a)
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package ubl_test
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+// parsedInvoice returns an invoice with the fields ParseInvoice reads, all
+// in the form it reads them.
+func parsedInvoice(t *testing.T) ubl.Invoice {
+	inv := reproducibleInvoice(t)
+	date := func(day int) *time.Time {
+		d := time.Date(2025, 3, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	inv.Now = func() time.Time { return *date(10) }
+	inv.InvoiceTypeCode = "384"
+	inv.OriginalInvoiceID, inv.OriginalInvoiceDate = "INV-12000", date(1)
+	inv.BuyerReference = "PO-4711"
+	inv.Currency = "EUR"
+	inv.SupplierTradingName = "ABC"
+	inv.SupplierContact = ubl.Contact{Name: "Accounts", Email: "accounts@abc.example"}
+	inv.SupplierCompanyID, inv.SupplierCompanyIDScheme = "0123456789", "0208"
+	inv.SupplierLegalForm = "BV"
+	inv.SupplierAdditionalIDs = []ubl.PartyID{{Value: "5412345000013", SchemeID: "0088"}}
+	inv.SupplierTaxRegistrations = []ubl.TaxRegistration{{CompanyID: "IT12345", SchemeID: "IT:CF"}}
+	inv.CustomerAddress.StreetName2 = "Building B"
+	inv.CustomerAddress.Region = "Antwerp"
+	inv.CustomerAdditionalIDs = []ubl.PartyID{{Value: "5498765000010", SchemeID: "0088"}}
+	inv.DeliveryAddress = &ubl.Address{StreetName: "1 Dock Road", CityName: "Antwerp", PostalZone: "2000", CountryCode: "BE", AddressLine3: "Gate 4"}
+	inv.ActualDeliveryDate = date(5)
+	inv.DeliveryLocationID, inv.DeliveryLocationIDScheme = "5412345000099", "0088"
+	inv.DeliveryPartyName = "XYZ Warehouse"
+	inv.InvoicePeriodStart, inv.InvoicePeriodEnd = date(1), date(31)
+	inv.Iban, inv.Bic = "", ""
+	inv.BankAccounts = []ubl.BankAccount{{Iban: "BE71096123456769", Bic: "GKCCBEBB", Name: "ABC Supplies"}, {Iban: "NL91ABNA0417164300"}}
+	inv.PaymentMeansCode, inv.PaymentMeansName = "58", "SEPA credit transfer"
+	inv.Note = "Payable within 30 days\n2% discount if paid within 10 days"
+	inv.OrderReference = &ubl.OrderRef{PurchaseOrderID: "PO-4711", SalesOrderID: "SO-12"}
+	inv.AllowanceCharges = []ubl.AllowanceCharge{
+		{Reason: "Volume discount", Amount: 25, TaxCategoryID: "S", TaxPercentage: 21},
+		{Charge: true, ReasonCode: "FC", Amount: 4, Percentage: 2, BaseAmount: 200, TaxCategoryID: "S", TaxPercentage: 21},
+	}
+	for i := range inv.Lines {
+		line := &inv.Lines[i]
+		line.TaxCategoryID = cmp.Or(line.TaxCategoryID, "S")
+		line.TaxCategoryName = "Standard rated"
+		line.UnitCode = cmp.Or(line.UnitCode, "ZZ")
+		if line.TaxCategoryID == "E" {
+			line.TaxExemptionCode = "VATEX-EU-132"
+		}
+	}
+	inv.Lines[0].LineID = "A1"
+	inv.Lines[0].OrderLineID = "3"
+	inv.Lines[0].ObjectID, inv.Lines[0].ObjectIDScheme = "TS-2025-03", "AAB"
+	inv.Lines[0].StandardItemID, inv.Lines[0].StandardItemIDScheme = "05412345000020", "0160"
+	inv.Lines[0].Classifications = []ubl.ItemClassification{{Code: "30192000", ListID: "STI"}, {Code: "44121600", ListID: "TST", ListVersionID: "19.0501"}}
+	inv.Lines[0].Attributes = []ubl.ItemAttribute{{Name: "Colour", Value: "Black"}}
+	inv.Lines[0].Note = "Replacement"
+	inv.Lines[0].AccountingCost = "CC-42"
+	inv.Lines[0].GrossPrice, inv.Lines[0].PriceDiscount = 120, 20
+	if err := inv.AddExternalReference("SPEC-1", "https://example.com/spec.pdf", "Specification"); err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func TestParseInvoice(t *testing.T) {
+	inv := parsedInvoice(t)
+	xmlBytes := generateAndValidate(t, &inv)
+
+	parsed, err := ubl.ParseInvoice(bytes.NewReader(xmlBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := must(parsed.Generate()); !bytes.Equal(got, xmlBytes) {
+		t.Errorf("expected the parsed invoice to generate the same document, got %s", got)
+	}
+	if !parsed.Now().Equal(inv.Now()) {
+		t.Errorf("expected Now to return the issue date, got %v", parsed.Now())
+	}
+	parsed.Now, inv.Now = nil, nil
+	compareFields(t, inv, *parsed)
+
+	// with other prefixes, elements the model doesn't know and a direct debit
+	inv = parsedInvoice(t)
+	inv.BankAccounts, inv.PaymentMeansCode, inv.PaymentMeansName = nil, "59", ""
+	inv.DirectDebit = &ubl.DirectDebit{MandateReference: "MANDATE-7", DebtorIban: "BE68539007547034", CreditorID: "BE69ZZZ050D000000008"}
+	xmlBytes = generateAndValidate(t, &inv)
+	inv.Namespaces = ubl.XMLNamespaces{Document: "inv", CAC: "a", CBC: "b"}
+	prefixed := must(inv.Generate())
+	prefixed = bytes.Replace(prefixed, []byte("<b:BuyerReference>"), []byte(`<b:AccountingCost>4217</b:AccountingCost><x:Routing xmlns:x="urn:example:routing"><x:ID>7</x:ID></x:Routing><b:BuyerReference>`), 1)
+	prefixed = bytes.Replace(prefixed, []byte("</a:Item>"), []byte("<a:OriginCountry><b:IdentificationCode>BE</b:IdentificationCode></a:OriginCountry></a:Item>"), 1)
+	parsed, err = ubl.ParseInvoice(bytes.NewReader(prefixed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := must(parsed.Generate()); !bytes.Equal(got, xmlBytes) {
+		t.Errorf("expected the parsed invoice to generate the same document, got %s", got)
+	}
+	if !reflect.DeepEqual(parsed.DirectDebit, inv.DirectDebit) || parsed.SupplierAdditionalIDs[0] != inv.SupplierAdditionalIDs[0] || len(parsed.SupplierAdditionalIDs) != 1 {
+		t.Errorf("expected the direct debit with its creditor identifier, got %+v and %+v", parsed.DirectDebit, parsed.SupplierAdditionalIDs)
+	}
+
+	cn := newTestCreditNote()
+	for _, c := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"truncated", string(xmlBytes[:len(xmlBytes)/2]), "parse: malformed XML: XML syntax error on line"},
+		{"empty", "", "parse: malformed XML: no root element"},
+		{"not XML", "%PDF-1.4", "parse: malformed XML: no root element"},
+		{"unclosed", "<Invoice", "parse: malformed XML: XML syntax error on line 1: unexpected EOF"},
+		{"credit note", string(must(cn.GenerateCreditNote())), `parse: root element CreditNote in namespace "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2", expected a UBL Invoice`},
+		{"order", `<Order xmlns="urn:oasis:names:specification:ubl:schema:xsd:Order-2"><ID>1</ID></Order>`, `parse: root element Order in namespace "urn:oasis:names:specification:ubl:schema:xsd:Order-2", expected a UBL Invoice`},
+		{"issue date", strings.Replace(string(xmlBytes), "<cbc:IssueDate>2025-03-10<", "<cbc:IssueDate>10/03/2025<", 1), `parse: IssueDate "10/03/2025": not in the form 2006-01-02`},
+		{"quantity", strings.Replace(string(xmlBytes), `unitCode="ZZ">3<`, `unitCode="ZZ">three<`, 1), `parse: strconv.ParseFloat: parsing "three": invalid syntax`},
+	} {
+		_, err := ubl.ParseInvoice(strings.NewReader(c.input))
+		if err == nil || !strings.HasPrefix(err.Error(), c.want) {
+			t.Errorf("%s: expected %q, got %v", c.name, c.want, err)
+			continue
+		}
+		var malformed *ubl.MalformedXMLError
+		var root *ubl.RootElementError
+		if errors.As(err, &malformed) != strings.HasPrefix(c.want, "parse: malformed XML") || errors.As(err, &root) != strings.HasPrefix(c.want, "parse: root element") {
+			t.Errorf("%s: unexpected error type %T", c.name, err)
+		}
+	}
+}
+
+func TestParseCreditNote(t *testing.T) {
+	// the same fields as the invoice, with the same JSON keys
+	inv := parsedInvoice(t)
+	var cn ubl.CreditNote
+	if err := json.Unmarshal(must(json.Marshal(inv)), &cn); err != nil {
+		t.Fatal(err)
+	}
+	cn.ID, cn.Now = "CN-12345", inv.Now
+	original := inv.Now().AddDate(0, 0, -9)
+	cn.OriginalInvoiceID, cn.OriginalInvoiceDate = "INV-12345", &original
+	xmlBytes := must(cn.GenerateCreditNote())
+	if strings.Contains(string(xmlBytes), "DueDate") || !strings.Contains(string(xmlBytes), `<cbc:CreditedQuantity unitCode="ZZ">3</cbc:CreditedQuantity>`) {
+		t.Fatalf("expected a credit note without due date, got %s", xmlBytes)
+	}
+
+	parsed, err := ubl.ParseCreditNote(bytes.NewReader(xmlBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := must(parsed.GenerateCreditNote()); !bytes.Equal(got, xmlBytes) {
+		t.Errorf("expected the parsed credit note to generate the same document, got %s", got)
+	}
+	if !parsed.Now().Equal(cn.Now()) {
+		t.Errorf("expected Now to return the issue date, got %v", parsed.Now())
+	}
+	parsed.Now, cn.Now = nil, nil
+	compareFields(t, cn, *parsed)
+
+	// the type code is kept when it isn't the default
+	cn = newTestCreditNote()
+	cn.CreditNoteTypeCode = "396"
+	parsed, err = ubl.ParseCreditNote(bytes.NewReader(must(cn.GenerateCreditNote())))
+	if err != nil || parsed.CreditNoteTypeCode != "396" || parsed.Lines[0].Quantity != 10 {
+		t.Errorf("expected credit note type code 396, got %+v, %v", parsed, err)
+	}
+	cn.CreditNoteTypeCode, cn.SelfBilling = "", true
+	parsed, err = ubl.ParseCreditNote(bytes.NewReader(must(cn.GenerateCreditNote())))
+	if err != nil || !parsed.SelfBilling || parsed.CreditNoteTypeCode != "" || parsed.ProfileID != "" {
+		t.Errorf("expected a self-billed credit note with the default type code, got %+v, %v", parsed, err)
+	}
+
+	want := `parse: root element Invoice in namespace "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2", expected a UBL CreditNote`
+	var root *ubl.RootElementError
+	_, err = ubl.ParseCreditNote(bytes.NewReader(must(inv.Generate())))
+	if !errors.As(err, &root) || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+// compareFields reports the exported fields of the structs want and got
+// that differ.
+func compareFields(t *testing.T, want, got any) {
+	t.Helper()
+	w, g := reflect.ValueOf(want), reflect.ValueOf(got)
+	for i := range w.NumField() {
+		field := w.Type().Field(i)
+		if field.IsExported() && !reflect.DeepEqual(g.Field(i).Interface(), w.Field(i).Interface()) {
+			t.Errorf("%s: expected %+v, got %+v", field.Name, w.Field(i), g.Field(i))
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	inv := newTestInvoice()
+	cn := newTestCreditNote()
+	cn.Namespaces = ubl.XMLNamespaces{Document: "cn"}
+	for _, c := range []struct {
+		data []byte
+		want ubl.DocumentType
+	}{
+		{must(inv.Generate()), ubl.DocumentTypeInvoice},
+		{must(cn.GenerateCreditNote()), ubl.DocumentTypeCreditNote},
+	} {
+		doc, docType, err := ubl.Parse(bytes.NewReader(c.data))
+		if err != nil || docType != c.want {
+			t.Errorf("expected a %s, got %s, %v", c.want, docType, err)
+			continue
+		}
+		switch doc := doc.(type) {
+		case *ubl.Invoice:
+			if docType != ubl.DocumentTypeInvoice || doc.ID != inv.ID {
+				t.Errorf("expected the invoice, got %+v", doc)
+			}
+		case *ubl.CreditNote:
+			if docType != ubl.DocumentTypeCreditNote || doc.ID != cn.ID {
+				t.Errorf("expected the credit note, got %+v", doc)
+			}
+		default:
+			t.Errorf("expected an invoice or credit note, got %T", doc)
+		}
+	}
+
+	order := `<?xml version="1.0" encoding="UTF-8"?>
+<Order xmlns="urn:oasis:names:specification:ubl:schema:xsd:Order-2"><ID>1</ID></Order>`
+	doc, docType, err := ubl.Parse(strings.NewReader(order))
+	want := `parse: unsupported document type Order in namespace "urn:oasis:names:specification:ubl:schema:xsd:Order-2"`
+	if !errors.Is(err, ubl.ErrUnsupportedDocumentType) || err.Error() != want || doc != nil || docType != "" {
+		t.Errorf("expected %q, got %v, %s", want, err, docType)
+	}
+	// an invoice in another namespace is no UBL invoice either
+	_, _, err = ubl.Parse(strings.NewReader(`<Invoice xmlns="urn:example:invoice"/>`))
+	if !errors.Is(err, ubl.ErrUnsupportedDocumentType) {
+		t.Errorf("expected an unsupported document type, got %v", err)
+	}
+	if _, err := ubl.ParseInvoice(strings.NewReader(order)); !errors.Is(err, ubl.ErrUnsupportedDocumentType) {
+		t.Errorf("expected ParseInvoice to report an unsupported document type, got %v", err)
+	}
+	if _, err := ubl.ParseInvoice(bytes.NewReader(must(cn.GenerateCreditNote()))); errors.Is(err, ubl.ErrUnsupportedDocumentType) {
+		t.Errorf("expected a credit note to be a supported document type, got %v", err)
+	}
+	var malformed *ubl.MalformedXMLError
+	if _, _, err := ubl.Parse(strings.NewReader("<Invoice")); !errors.As(err, &malformed) {
+		t.Errorf("expected a malformed XML error, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package ubl
+
+import "fmt"
+
+// uncl4461 are the payment means codes (UNCL4461) allowed by Peppol BIS 3.0.
+var uncl4461 = func() map[string]bool {
+	codes := map[string]bool{"70": true, "ZZZ": true}
+	for _, r := range [][2]int{{1, 68}, {74, 78}, {91, 97}} {
+		for c := r[0]; c <= r[1]; c++ {
+			codes[fmt.Sprint(c)] = true
+		}
+	}
+	return codes
+}()
+
+// paymentMeansCode returns the cbc:PaymentMeansCode element. Without a code,
+// credit transfer (30) is used when there is an IBAN and "instrument not
+// defined" (1) otherwise.
+func paymentMeansCode(code, name, iban string) (XMLPaymentMeansCode, error) {
+	if code == "" {
+		code = "1"
+		if iban != "" {
+			code = "30"
+		}
+	}
+	if !uncl4461[code] {
+		return XMLPaymentMeansCode{}, fmt.Errorf("payment means code %q: not in UNCL4461", code)
+	}
+	return XMLPaymentMeansCode{Value: code, Name: name}, nil
+}
+
+// BankAccount is an account the buyer can pay to (BG-17).
+type BankAccount struct {
+	Iban string `json:"iban"`           // payment account identifier (BT-84)
+	Bic  string `json:"bic,omitempty"`  // Optional: payment service provider identifier (BT-86)
+	Name string `json:"name,omitempty"` // Optional: payment account name (BT-85)
+}
+
+// bankAccounts returns the accounts to emit: BankAccounts when set, the
+// single Iban/Bic/AccountName otherwise.
+func bankAccounts(iban, bic, name string, accounts []BankAccount) []BankAccount {
+	if len(accounts) > 0 {
+		return accounts
+	}
+	return []BankAccount{{Iban: iban, Bic: bic, Name: name}}
+}
+
+// paymentMeans returns one cac:PaymentMeans per bank account, all with the
+// same payment means code.
+func paymentMeans(code, name string, accounts []BankAccount) ([]XMLPaymentMeans, error) {
+	means := make([]XMLPaymentMeans, len(accounts))
+	for i, account := range accounts {
+		if len(accounts) > 1 && account.Iban == "" {
+			return nil, fmt.Errorf("BankAccounts[%d]: IBAN required", i)
+		}
+		meansCode, err := paymentMeansCode(code, name, account.Iban)
+		if err != nil {
+			return nil, err
+		}
+		means[i] = XMLPaymentMeans{
+			PaymentMeansCode: meansCode,
+			PayeeFinancialAccount: &XMLFinancialAccount{
+				ID:   account.Iban,
+				Name: account.Name,
+			},
+		}
+		if account.Bic != "" {
+			means[i].PayeeFinancialAccount.FinancialInstitutionBranch = &XMLFinancialInstitutionBranch{ID: account.Bic}
+		}
+	}
+	return means, nil
+}
+
+// DirectDebit switches the payment means to direct debit (BG-19).
+type DirectDebit struct {
+	MandateReference string `json:"mandateReference"`     // mandate reference identifier (BT-89)
+	DebtorIban       string `json:"debtorIban,omitempty"` // Optional: debited account identifier (BT-91)
+	CreditorID       string `json:"creditorID,omitempty"` // Optional: bank assigned creditor identifier (BT-90), added to the supplier with scheme SEPA
+}
+
+// directDebitPaymentMeans returns the cac:PaymentMeans for a direct debit,
+// with SEPA direct debit (59) as default code. A direct debit can't be
+// combined with an account to transfer to.
+func directDebitPaymentMeans(code, name string, dd DirectDebit, iban string, accounts []BankAccount) ([]XMLPaymentMeans, error) {
+	if iban != "" || len(accounts) > 0 {
+		return nil, fmt.Errorf("direct debit: cannot be combined with a credit transfer account")
+	}
+	if dd.MandateReference == "" {
+		return nil, fmt.Errorf("direct debit: mandate reference required")
+	}
+	if code == "" {
+		code = "59"
+	}
+	meansCode, err := paymentMeansCode(code, name, "")
+	if err != nil {
+		return nil, err
+	}
+	means := XMLPaymentMeans{
+		PaymentMeansCode: meansCode,
+		PaymentMandate:   &XMLPaymentMandate{ID: dd.MandateReference},
+	}
+	if dd.DebtorIban != "" {
+		means.PaymentMandate.PayerFinancialAccount = &XMLFinancialAccount{ID: dd.DebtorIban}
+	}
+	return []XMLPaymentMeans{means}, nil
+}
+
+// creditorIdentification returns the SEPA creditor identifier as
+// cac:PartyIdentification of the supplier, or nil.
+func creditorIdentification(dd *DirectDebit) []XMLPartyIdentification {
+	if dd == nil || dd.CreditorID == "" {
+		return nil
+	}
+	return []XMLPartyIdentification{{ID: XMLIdentifier{Value: dd.CreditorID, SchemeID: "SEPA"}}}
+}
@@ -0,0 +1,162 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestPaymentMeansCode(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cbc:PaymentMeansCode>30</cbc:PaymentMeansCode>`) {
+		t.Error("expected credit transfer (30) by default with an IBAN")
+	}
+
+	inv = newTestInvoice()
+	inv.PaymentMeansCode = "58"
+	inv.PaymentMeansName = "SEPA credit transfer"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want := `<cbc:PaymentMeansCode name="SEPA credit transfer">58</cbc:PaymentMeansCode>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestInvoice()
+	inv.Iban = ""
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cbc:PaymentMeansCode>1</cbc:PaymentMeansCode>`) {
+		t.Error("expected instrument not defined (1) without an IBAN")
+	}
+
+	inv = newTestInvoice()
+	inv.PaymentMeansCode = "99"
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `payment means code "99": not in UNCL4461` {
+		t.Errorf("expected a code list error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.PaymentMeansCode = "ZZZ"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:PaymentMeansCode>ZZZ</cbc:PaymentMeansCode>`) {
+		t.Error("expected the credit note payment means code")
+	}
+}
+
+func TestBankAccounts(t *testing.T) {
+	inv := newTestInvoice()
+	inv.BankAccounts = []ubl.BankAccount{
+		{Iban: "BE71096123456769", Bic: "GKCCBEBB", Name: "ABC Supplies EUR"},
+		{Iban: "GB33BUKB20201555555555", Bic: "BUKBGB22", Name: "ABC Supplies GBP"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if n := strings.Count(xmlBytes, "<cac:PaymentMeans>"); n != 2 {
+		t.Fatalf("expected 2 PaymentMeans, got %d", n)
+	}
+	want := `<cac:PayeeFinancialAccount><cbc:ID>GB33BUKB20201555555555</cbc:ID><cbc:Name>ABC Supplies GBP</cbc:Name><cac:FinancialInstitutionBranch><cbc:ID>BUKBGB22</cbc:ID></cac:FinancialInstitutionBranch></cac:PayeeFinancialAccount>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+	if strings.Contains(xmlBytes, "<cbc:ID>"+inv.Iban+"</cbc:ID>") {
+		t.Error("expected BankAccounts to override Iban")
+	}
+
+	inv = newTestInvoice()
+	inv.BankAccounts = []ubl.BankAccount{{Iban: "BE71096123456769"}, {Name: "no IBAN"}}
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "BankAccounts[1]: IBAN required" {
+		t.Errorf("expected a missing IBAN error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.BankAccounts = []ubl.BankAccount{{Iban: "BE71096123456769"}, {Iban: "GB33BUKB20201555555555"}}
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(cnBytes), "<cac:PaymentMeans>"); n != 2 {
+		t.Errorf("expected 2 credit note PaymentMeans, got %d", n)
+	}
+}
+
+func TestAccountName(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "<cac:PayeeFinancialAccount><cbc:ID>9999999999</cbc:ID><cbc:Name>") {
+		t.Error("expected no account name when empty")
+	}
+
+	inv.AccountName = "ABC Supplies Ltd"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want := `<cbc:ID>9999999999</cbc:ID><cbc:Name>ABC Supplies Ltd</cbc:Name><cac:FinancialInstitutionBranch>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	cn := newTestCreditNote()
+	cn.AccountName = "ABC Supplies Ltd"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), want) {
+		t.Errorf("expected %s in credit note output", want)
+	}
+}
+
+func TestOptionalBic(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cac:FinancialInstitutionBranch><cbc:ID>GEBABEBB</cbc:ID></cac:FinancialInstitutionBranch>`) {
+		t.Error("expected FinancialInstitutionBranch with a BIC")
+	}
+
+	inv.Bic = ""
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "FinancialInstitutionBranch") {
+		t.Error("expected no FinancialInstitutionBranch without a BIC")
+	}
+}
+
+func TestDirectDebit(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Iban = ""
+	inv.Bic = ""
+	inv.DirectDebit = &ubl.DirectDebit{
+		MandateReference: "MANDATE-001",
+		DebtorIban:       "BE71096123456769",
+		CreditorID:       "BE69ZZZ050D000000008",
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cac:PaymentMeans><cbc:PaymentMeansCode>59</cbc:PaymentMeansCode><cac:PaymentMandate><cbc:ID>MANDATE-001</cbc:ID><cac:PayerFinancialAccount><cbc:ID>BE71096123456769</cbc:ID></cac:PayerFinancialAccount></cac:PaymentMandate></cac:PaymentMeans>`,
+		`<cac:PartyIdentification><cbc:ID schemeID="SEPA">BE69ZZZ050D000000008</cbc:ID></cac:PartyIdentification>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+	if strings.Contains(xmlBytes, "PayeeFinancialAccount") {
+		t.Error("expected no PayeeFinancialAccount for a direct debit")
+	}
+
+	inv = newTestInvoice()
+	inv.DirectDebit = &ubl.DirectDebit{MandateReference: "MANDATE-001"}
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "direct debit: cannot be combined with a credit transfer account" {
+		t.Errorf("expected an error mixing direct debit and IBAN, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.Iban = ""
+	cn.DirectDebit = &ubl.DirectDebit{}
+	_, err = cn.GenerateCreditNote()
+	if err == nil || err.Error() != "direct debit: mandate reference required" {
+		t.Errorf("expected a missing mandate error, got %v", err)
+	}
+}
@@ -0,0 +1,461 @@
+package ubl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// EmbedOptions are the options of EmbedInPDF.
+type EmbedOptions struct {
+	Filename     string    // Optional: the name of the embedded file, defaults to "invoice.xml"; Factur-X and ZUGFeRD use "factur-x.xml"
+	Relationship string    // Optional: the AFRelationship of the file to the PDF: "Alternative" (default), "Data", "Source", "Supplement" or "Unspecified"
+	Description  string    // Optional: the description PDF readers show with the file
+	MimeType     string    // Optional: defaults to "text/xml"
+	ModDate      time.Time // Required: the modification date of the file, e.g. the issue date, so the same input gives the same PDF
+}
+
+// afRelationships are the values of AFRelationship in PDF 2.0 and PDF/A-3.
+var afRelationships = []string{"Alternative", "Data", "Source", "Supplement", "Unspecified"}
+
+// EmbedInPDF returns pdf with xmlBytes attached as an associated file, the way
+// hybrid invoices like Factur-X and ZUGFeRD carry their XML: an embedded file
+// with its MIME type, size, date and checksum, in the EmbeddedFiles of the
+// catalog and in its AF array with the AFRelationship of the options. A file
+// with the same name is replaced.
+//
+// The PDF is changed with an incremental update, so its original bytes stay as
+// they are. There is no default for the ModDate of the options: the output
+// only depends on the input, like Generate with a fixed Now clock. A PDF/A-3
+// stays valid, but PDF/A-3 based formats like Factur-X also require their XMP
+// metadata, which is up to the PDF producer. Encrypted PDFs aren't supported.
+func EmbedInPDF(pdf []byte, xmlBytes []byte, opts EmbedOptions) ([]byte, error) {
+	if opts.Filename == "" {
+		opts.Filename = "invoice.xml"
+	}
+	if opts.Relationship == "" {
+		opts.Relationship = "Alternative"
+	}
+	if !slices.Contains(afRelationships, opts.Relationship) {
+		return nil, fmt.Errorf("pdf: AFRelationship %q not one of %v", opts.Relationship, afRelationships)
+	}
+	if opts.MimeType == "" {
+		opts.MimeType = "text/xml"
+	}
+	if opts.ModDate.IsZero() {
+		return nil, errors.New("pdf: ModDate required")
+	}
+
+	f, err := readPDF(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %w", err)
+	}
+	if f.trailer.get("Encrypt") != nil {
+		return nil, errors.New("pdf: encrypted PDFs not supported")
+	}
+	rootRef, ok := f.trailer.get("Root").(pdfRef)
+	if !ok {
+		return nil, errors.New("pdf: no catalog")
+	}
+	catalog, _, err := f.dict(rootRef)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: catalog: %w", err)
+	}
+	size, ok := pdfInt(f.trailer.get("Size"))
+	if !ok {
+		return nil, errors.New("pdf: no Size in the trailer")
+	}
+
+	u := &pdfUpdate{f: f, next: int(size)}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(xmlBytes)
+	zw.Close()
+	checksum := md5.Sum(xmlBytes)
+	file := u.add(pdfStream{
+		dict: pdfDict{
+			{"Type", pdfName("EmbeddedFile")},
+			{"Subtype", pdfName(opts.MimeType)},
+			{"Filter", pdfName("FlateDecode")},
+			{"Length", pdfInteger(compressed.Len())},
+			{"Params", pdfDict{
+				{"Size", pdfInteger(len(xmlBytes))},
+				{"ModDate", pdfDate(opts.ModDate)},
+				{"CheckSum", pdfString(checksum[:])},
+			}},
+		},
+		data: compressed.Bytes(),
+	})
+	spec := pdfDict{
+		{"Type", pdfName("Filespec")},
+		{"F", pdfString(opts.Filename)},
+		{"UF", pdfTextString(opts.Filename)},
+	}
+	if opts.Description != "" {
+		spec.set("Desc", pdfTextString(opts.Description))
+	}
+	spec.set("AFRelationship", pdfName(opts.Relationship))
+	spec.set("EF", pdfDict{{"F", file}, {"UF", file}})
+	specRef := u.add(spec)
+
+	// the name tree of the embedded files, in the name dictionary
+	names, namesRef, err := f.dict(catalog.get("Names"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: names: %w", err)
+	}
+	tree, treeRef, err := f.dict(names.get("EmbeddedFiles"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: embedded files: %w", err)
+	}
+	if tree.get("Kids") != nil {
+		return nil, errors.New("pdf: embedded files with intermediate nodes not supported")
+	}
+	entries, err := f.resolve(tree.get("Names"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: embedded files: %w", err)
+	}
+	list, _ := entries.(pdfArray)
+	key := pdfTextString(opts.Filename)
+	var replaced pdfRef
+	list, replaced = insertName(list, key, specRef)
+	tree.set("Names", list)
+	if treeRef != nil {
+		u.set(*treeRef, tree)
+	} else if names.set("EmbeddedFiles", tree); namesRef != nil {
+		u.set(*namesRef, names)
+	} else {
+		catalog.set("Names", names)
+	}
+
+	// the associated files, without the one replaced
+	af, err := f.resolve(catalog.get("AF"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: AF: %w", err)
+	}
+	files, _ := af.(pdfArray)
+	files = slices.DeleteFunc(slices.Clone(files), func(v any) bool { return v == replaced })
+	files = append(files, specRef)
+	if ref, ok := catalog.get("AF").(pdfRef); ok {
+		u.set(ref, files)
+	} else {
+		catalog.set("AF", files)
+	}
+	u.set(rootRef, catalog)
+	return u.write(), nil
+}
+
+// ExtractFromPDF returns the embedded file of pdf with the given name, like
+// the XML EmbedInPDF attached.
+func ExtractFromPDF(pdf []byte, filename string) ([]byte, error) {
+	f, err := readPDF(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %w", err)
+	}
+	if f.trailer.get("Encrypt") != nil {
+		return nil, errors.New("pdf: encrypted PDFs not supported")
+	}
+	catalog, _, err := f.dict(f.trailer.get("Root"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: catalog: %w", err)
+	}
+	names, _, err := f.dict(catalog.get("Names"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: names: %w", err)
+	}
+	spec, err := f.lookupName(names.get("EmbeddedFiles"), filename, 0)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: embedded files: %w", err)
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("pdf: no embedded file %q", filename)
+	}
+	specDict, _, err := f.dict(spec)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %s: %w", filename, err)
+	}
+	ef, _, err := f.dict(specDict.get("EF"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %s: %w", filename, err)
+	}
+	file := ef.get("UF")
+	if file == nil {
+		file = ef.get("F")
+	}
+	obj, err := f.resolve(file)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %s: %w", filename, err)
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok {
+		return nil, fmt.Errorf("pdf: %s: no embedded file stream", filename)
+	}
+	data, err := f.decode(stream)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %s: %w", filename, err)
+	}
+	return data, nil
+}
+
+// dict resolves a dictionary, and returns its reference if it's an indirect
+// object. A missing dictionary is an empty one.
+func (f *pdfFile) dict(v any) (pdfDict, *pdfRef, error) {
+	obj, err := f.resolve(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ref *pdfRef
+	if r, ok := v.(pdfRef); ok {
+		ref = &r
+	}
+	switch obj := obj.(type) {
+	case pdfDict:
+		return slices.Clone(obj), ref, nil
+	case nil, pdfKeyword:
+		return pdfDict{}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("%T is no dictionary", obj)
+}
+
+// lookupName returns the value of name in a name tree.
+func (f *pdfFile) lookupName(node any, name string, depth int) (any, error) {
+	if depth > 32 {
+		return nil, errors.New("name tree too deep")
+	}
+	dict, _, err := f.dict(node)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.resolve(dict.get("Names"))
+	if err != nil {
+		return nil, err
+	}
+	list, _ := names.(pdfArray)
+	for i := 0; i+1 < len(list); i += 2 {
+		if key, ok := list[i].(pdfString); ok && pdfText(key) == name {
+			return list[i+1], nil
+		}
+	}
+	kids, err := f.resolve(dict.get("Kids"))
+	if err != nil {
+		return nil, err
+	}
+	kidList, _ := kids.(pdfArray)
+	for _, kid := range kidList {
+		v, err := f.lookupName(kid, name, depth+1)
+		if v != nil || err != nil {
+			return v, err
+		}
+	}
+	return nil, nil
+}
+
+// insertName adds key and value to the names of a name tree leaf, in order,
+// and returns the value key had.
+func insertName(list pdfArray, key pdfString, value any) (pdfArray, pdfRef) {
+	list = slices.Clone(list)
+	for i := 0; i+1 < len(list); i += 2 {
+		k, _ := list[i].(pdfString)
+		switch c := bytes.Compare(k, key); {
+		case c == 0:
+			old, _ := list[i+1].(pdfRef)
+			list[i+1] = value
+			return list, old
+		case c > 0:
+			return slices.Insert(list, i, any(key), value), pdfRef{}
+		}
+	}
+	return append(list, key, value), pdfRef{}
+}
+
+func pdfInteger(n int) pdfNumber {
+	return pdfNumber(strconv.Itoa(n))
+}
+
+// pdfTextString returns s as a text string: as is if it's ASCII, else
+// UTF-16BE.
+func pdfTextString(s string) pdfString {
+	for i := range len(s) {
+		if s[i] >= 0x80 {
+			b := pdfString{0xfe, 0xff}
+			for _, u := range utf16.Encode([]rune(s)) {
+				b = append(b, byte(u>>8), byte(u))
+			}
+			return b
+		}
+	}
+	return pdfString(s)
+}
+
+// pdfDate formats t as a PDF date, D:YYYYMMDDHHmmSS+HH'mm'.
+func pdfDate(t time.Time) pdfString {
+	return pdfString(t.Format("D:20060102150405-07'00'"))
+}
+
+// writePDFValue writes an object in PDF syntax.
+func writePDFValue(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case pdfName:
+		b.WriteByte('/')
+		for _, c := range []byte(v) {
+			if c <= ' ' || c >= 0x7f || c == '#' || isPDFDelimiter(c) {
+				fmt.Fprintf(b, "#%02X", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	case pdfNumber:
+		b.WriteString(string(v))
+	case pdfKeyword:
+		b.WriteString(string(v))
+	case pdfRef:
+		fmt.Fprintf(b, "%d %d R", v.num, v.gen)
+	case pdfString:
+		fmt.Fprintf(b, "<%X>", []byte(v))
+	case pdfArray:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writePDFValue(b, e)
+		}
+		b.WriteByte(']')
+	case pdfDict:
+		b.WriteString("<<")
+		for _, e := range v {
+			writePDFValue(b, e.key)
+			b.WriteByte(' ')
+			writePDFValue(b, e.value)
+		}
+		b.WriteString(">>")
+	case pdfStream:
+		writePDFValue(b, v.dict)
+		b.WriteString("\nstream\n")
+		b.Write(v.data)
+		b.WriteString("\nendstream")
+	default:
+		b.WriteString("null")
+	}
+}
+
+// pdfUpdate is an incremental update of a PDF: new and changed objects, with
+// a cross-reference section for them.
+type pdfUpdate struct {
+	f       *pdfFile
+	next    int // the next free object number
+	objects []pdfObject
+}
+
+type pdfObject struct {
+	ref   pdfRef
+	value any
+}
+
+// add adds a new object.
+func (u *pdfUpdate) add(v any) pdfRef {
+	ref := pdfRef{num: u.next}
+	u.next++
+	u.objects = append(u.objects, pdfObject{ref, v})
+	return ref
+}
+
+// set changes an existing object.
+func (u *pdfUpdate) set(ref pdfRef, v any) {
+	u.objects = append(u.objects, pdfObject{ref, v})
+}
+
+// write returns the PDF with the update appended. The cross-reference section
+// is a stream if the last one of the PDF is, so it stays readable for the
+// same readers.
+func (u *pdfUpdate) write() []byte {
+	var b bytes.Buffer
+	b.Write(u.f.data)
+	if !bytes.HasSuffix(u.f.data, []byte("\n")) && !bytes.HasSuffix(u.f.data, []byte("\r")) {
+		b.WriteByte('\n')
+	}
+	offsets := map[pdfRef]int{}
+	for _, obj := range u.objects {
+		offsets[obj.ref] = b.Len()
+		fmt.Fprintf(&b, "%d %d obj\n", obj.ref.num, obj.ref.gen)
+		writePDFValue(&b, obj.value)
+		b.WriteString("\nendobj\n")
+	}
+
+	trailer := pdfDict{{"Size", pdfInteger(u.next)}}
+	for _, key := range []pdfName{"Root", "Info", "ID"} {
+		if v := u.f.trailer.get(key); v != nil {
+			trailer.set(key, v)
+		}
+	}
+	trailer.set("Prev", pdfNumber(strconv.FormatInt(u.f.startxref, 10)))
+
+	refs := make([]pdfRef, 0, len(offsets)+1)
+	for ref := range offsets {
+		refs = append(refs, ref)
+	}
+	if !u.f.xrefStream {
+		slices.SortFunc(refs, func(a, b pdfRef) int { return a.num - b.num })
+		start := b.Len()
+		b.WriteString("xref\n")
+		for i := 0; i < len(refs); {
+			j := i + 1
+			for j < len(refs) && refs[j].num == refs[j-1].num+1 {
+				j++
+			}
+			fmt.Fprintf(&b, "%d %d\n", refs[i].num, j-i)
+			for _, ref := range refs[i:j] {
+				fmt.Fprintf(&b, "%010d %05d n\r\n", offsets[ref], ref.gen)
+			}
+			i = j
+		}
+		b.WriteString("trailer\n")
+		writePDFValue(&b, trailer)
+		fmt.Fprintf(&b, "\nstartxref\n%d\n%%%%EOF\n", start)
+		return b.Bytes()
+	}
+
+	// the cross-reference stream is an object itself
+	self := pdfRef{num: u.next}
+	start := b.Len()
+	offsets[self] = start
+	refs = append(refs, self)
+	slices.SortFunc(refs, func(a, b pdfRef) int { return a.num - b.num })
+	width := 1
+	for start>>(8*width) > 0 {
+		width++
+	}
+	var index pdfArray
+	var data []byte
+	for i := 0; i < len(refs); {
+		j := i + 1
+		for j < len(refs) && refs[j].num == refs[j-1].num+1 {
+			j++
+		}
+		index = append(index, pdfInteger(refs[i].num), pdfInteger(j-i))
+		for _, ref := range refs[i:j] {
+			data = append(data, 1)
+			for k := width - 1; k >= 0; k-- {
+				data = append(data, byte(offsets[ref]>>(8*k)))
+			}
+			data = append(data, byte(ref.gen>>8), byte(ref.gen))
+		}
+		i = j
+	}
+	trailer.set("Size", pdfInteger(u.next+1))
+	dict := append(pdfDict{
+		{"Type", pdfName("XRef")},
+		{"Index", index},
+		{"W", pdfArray{pdfInteger(1), pdfInteger(width), pdfInteger(2)}},
+		{"Length", pdfInteger(len(data))},
+	}, trailer...)
+	fmt.Fprintf(&b, "%d 0 obj\n", self.num)
+	writePDFValue(&b, pdfStream{dict: dict, data: data})
+	fmt.Fprintf(&b, "\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
@@ -0,0 +1,136 @@
+package ubl_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestEmbedInPDF(t *testing.T) {
+	pdf, err := os.ReadFile("invoice_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := newTestInvoice()
+	xmlBytes := generateAndValidate(t, &inv)
+	modDate := time.Date(2025, 3, 10, 14, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	for _, c := range []struct {
+		name, xref string
+		pdf        []byte
+	}{
+		{"xref table", "\nxref\n", pdf},
+		{"xref stream", "/Type /XRef", xrefStreamPDF()},
+	} {
+		out, err := ubl.EmbedInPDF(c.pdf, xmlBytes, ubl.EmbedOptions{Description: "UBL invoice", ModDate: modDate})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.HasPrefix(out, c.pdf) {
+			t.Errorf("%s: expected an incremental update of the PDF", c.name)
+		}
+		update := string(out[len(c.pdf):])
+		for _, want := range []string{
+			"/Type /EmbeddedFile/Subtype /text#2Fxml/Filter /FlateDecode",
+			fmt.Sprintf("/Params <</Size %d/ModDate <%X>", len(xmlBytes), "D:20250310143000+01'00'"),
+			"/Type /Filespec/F <696E766F6963652E786D6C>/UF <696E766F6963652E786D6C>/Desc <55424C20696E766F696365>/AFRelationship /Alternative",
+			"/Type /Catalog",
+			c.xref,
+		} {
+			if !strings.Contains(update, want) {
+				t.Errorf("%s: expected %s in the update, got %s", c.name, want, update)
+			}
+		}
+		got, err := ubl.ExtractFromPDF(out, "invoice.xml")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(got, xmlBytes) {
+			t.Errorf("%s: expected the XML back, got %s", c.name, got)
+		}
+
+		// a second file keeps the first, the same name replaces it
+		source := []byte(`{"id":"INV-12345"}`)
+		out, err = ubl.EmbedInPDF(out, source, ubl.EmbedOptions{Filename: "invoice.json", Relationship: "Source", MimeType: "application/json", ModDate: modDate})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		replaced := bytes.ReplaceAll(xmlBytes, []byte("INV-12345"), []byte("INV-12346"))
+		out, err = ubl.EmbedInPDF(out, replaced, ubl.EmbedOptions{ModDate: modDate})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := must(ubl.ExtractFromPDF(out, "invoice.json")); !bytes.Equal(got, source) {
+			t.Errorf("%s: expected the JSON back, got %s", c.name, got)
+		}
+		if got := must(ubl.ExtractFromPDF(out, "invoice.xml")); !bytes.Equal(got, replaced) {
+			t.Errorf("%s: expected the replaced XML back, got %s", c.name, got)
+		}
+		if af := out[bytes.LastIndex(out, []byte("/AF ")):]; !bytes.HasPrefix(af, []byte("/AF [")) || bytes.Count(af[:bytes.IndexByte(af, ']')], []byte(" R")) != 2 {
+			t.Errorf("%s: expected 2 associated files, got %.40s", c.name, af)
+		}
+	}
+
+	for _, c := range []struct {
+		pdf  []byte
+		opts ubl.EmbedOptions
+		want string
+	}{
+		{pdf, ubl.EmbedOptions{Relationship: "Invoice", ModDate: modDate}, `pdf: AFRelationship "Invoice" not one of [Alternative Data Source Supplement Unspecified]`},
+		{pdf, ubl.EmbedOptions{}, "pdf: ModDate required"},
+		{xmlBytes, ubl.EmbedOptions{ModDate: modDate}, "pdf: not a PDF"},
+		{pdf[:len(pdf)-40], ubl.EmbedOptions{ModDate: modDate}, "pdf: no startxref"},
+	} {
+		_, err := ubl.EmbedInPDF(c.pdf, xmlBytes, c.opts)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("expected %q, got %v", c.want, err)
+		}
+	}
+	if _, err := ubl.ExtractFromPDF(pdf, "invoice.xml"); err == nil || err.Error() != `pdf: no embedded file "invoice.xml"` {
+		t.Errorf("expected an error for a PDF without the file, got %v", err)
+	}
+}
+
+// xrefStreamPDF returns an empty PDF 1.5 with its objects in an object
+// stream and a cross-reference stream with a PNG predictor.
+func xrefStreamPDF() []byte {
+	deflate := func(data []byte) []byte {
+		var b bytes.Buffer
+		zw := zlib.NewWriter(&b)
+		zw.Write(data)
+		zw.Close()
+		return b.Bytes()
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n%\xe2\xe3\xcf\xd3\n")
+	catalog, pages := "<</Type/Catalog/Pages 2 0 R>>", "<</Type/Pages/Kids[]/Count 0>>"
+	header := fmt.Sprintf("1 0 2 %d ", len(catalog)+1)
+	objects := deflate([]byte(header + catalog + "\n" + pages))
+	objStm := b.Len()
+	fmt.Fprintf(&b, "3 0 obj\n<</Type/ObjStm/N 2/First %d/Filter/FlateDecode/Length %d>>\nstream\n", len(header), len(objects))
+	b.Write(objects)
+	b.WriteString("\nendstream\nendobj\n")
+
+	xref := b.Len()
+	rows := [][]byte{{0, 0, 0, 0}, {2, 0, 3, 0}, {2, 0, 3, 1}, {1, byte(objStm >> 8), byte(objStm), 0}, {1, byte(xref >> 8), byte(xref), 0}}
+	var data []byte
+	prev := make([]byte, 4)
+	for _, row := range rows {
+		data = append(data, 2)
+		for i := range row {
+			data = append(data, row[i]-prev[i])
+		}
+		prev = row
+	}
+	data = deflate(data)
+	fmt.Fprintf(&b, "4 0 obj\n<</Type/XRef/Size 5/W[1 2 1]/Root 1 0 R/Filter/FlateDecode/DecodeParms<</Predictor 12/Columns 4>>/Length %d>>\nstream\n", len(data))
+	b.Write(data)
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
@@ -0,0 +1,711 @@
+package ubl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+)
+
+// The PDF objects, as far as EmbedInPDF and ExtractFromPDF need them.
+// Numbers and the keywords true, false and null keep their text, so they
+// are written back as read.
+type (
+	pdfName    string
+	pdfNumber  string
+	pdfKeyword string
+	pdfString  []byte
+	pdfArray   []any
+	pdfDict    []pdfEntry
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte // as in the file, still encoded
+	}
+)
+
+type pdfEntry struct {
+	key   pdfName
+	value any
+}
+
+// get returns the value of key, nil if it's not in the dictionary.
+func (d pdfDict) get(key pdfName) any {
+	for _, e := range d {
+		if e.key == key {
+			return e.value
+		}
+	}
+	return nil
+}
+
+// set sets key to value, in place if the dictionary has key.
+func (d *pdfDict) set(key pdfName, value any) {
+	for i, e := range *d {
+		if e.key == key {
+			(*d)[i].value = value
+			return
+		}
+	}
+	*d = append(*d, pdfEntry{key, value})
+}
+
+// pdfInt returns the integer value of a number.
+func pdfInt(v any) (int64, bool) {
+	n, ok := v.(pdfNumber)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(string(n), 10, 64)
+	return i, err == nil
+}
+
+// pdfText returns a text string as UTF-8: UTF-16BE with a byte order mark,
+// else PDFDocEncoding, read as Latin-1 for the printable characters
+// filenames use.
+func pdfText(s pdfString) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2-1)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// pdfLexer reads PDF objects from data.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips white space and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// regular reads the regular characters of a name, number or keyword.
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// keyword reads the next keyword, e.g. obj or stream.
+func (l *pdfLexer) keyword() string {
+	l.skipSpace()
+	return l.regular()
+}
+
+// value reads the next object.
+func (l *pdfLexer) value() (any, error) {
+	return l.nested(0)
+}
+
+func (l *pdfLexer) nested(depth int) (any, error) {
+	if depth > 64 {
+		return nil, errors.New("objects nested too deeply")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		return pdfName(decodePDFName(l.regular())), nil
+	case c == '(':
+		return l.literalString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		var dict pdfDict
+		for {
+			l.skipSpace()
+			if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.nested(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key %v is no name", key)
+			}
+			value, err := l.nested(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict = append(dict, pdfEntry{name, value})
+		}
+	case c == '<':
+		return l.hexString()
+	case c == '[':
+		l.pos++
+		array := pdfArray{}
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return array, nil
+			}
+			value, err := l.nested(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	case c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.':
+		number := pdfNumber(l.regular())
+		// an integer followed by another and R is a reference
+		save := l.pos
+		if num, ok := pdfInt(number); ok {
+			l.skipSpace()
+			gen, ok := pdfInt(pdfNumber(l.regular()))
+			if ok && l.keyword() == "R" {
+				return pdfRef{int(num), int(gen)}, nil
+			}
+		}
+		l.pos = save
+		return number, nil
+	case isPDFDelimiter(c):
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, l.pos)
+	}
+	return pdfKeyword(l.regular()), nil
+}
+
+// decodePDFName decodes the #xx escapes of a name.
+func decodePDFName(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(n))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+func (l *pdfLexer) literalString() (pdfString, error) {
+	l.pos++
+	var s pdfString
+	for depth := 0; l.pos < len(l.data); l.pos++ {
+		c := l.data[l.pos]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				l.pos++
+				return s, nil
+			}
+			depth--
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			switch e := l.data[l.pos]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// a line continuation
+				if e == '\r' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for i := 0; i < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					l.pos--
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (l *pdfLexer) hexString() (pdfString, error) {
+	l.pos++
+	var digits []byte
+	for ; l.pos < len(l.data); l.pos++ {
+		c := l.data[l.pos]
+		switch {
+		case c == '>':
+			l.pos++
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			s := make(pdfString, len(digits)/2)
+			for i := range s {
+				n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				s[i] = byte(n)
+			}
+			return s, nil
+		case isPDFSpace(c):
+		case c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+			digits = append(digits, c)
+		default:
+			return nil, fmt.Errorf("%q in hex string", c)
+		}
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// pdfFile is a PDF with its cross-reference table, merged from all the
+// sections of its updates.
+type pdfFile struct {
+	data       []byte
+	xref       map[int]pdfXrefEntry
+	trailer    pdfDict // of the last section
+	startxref  int64   // the offset of the last section
+	xrefStream bool    // whether the last section is a cross-reference stream
+	objStms    map[int]pdfObjectStream
+	resolving  map[int]bool
+}
+
+// pdfXrefEntry locates an object: at an offset in the file, or at an index
+// in an object stream.
+type pdfXrefEntry struct {
+	offset int64
+	gen    int
+	stream int // the object stream, 0 for an object in the file
+	index  int
+	free   bool
+}
+
+// pdfObjectStream is a decoded object stream, with its objects from first.
+type pdfObjectStream struct {
+	data  []byte
+	first int
+}
+
+// readPDF reads the cross-reference table of a PDF.
+func readPDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, errors.New("not a PDF")
+	}
+	tail := data[max(0, len(data)-1024):]
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, errors.New("no startxref")
+	}
+	l := &pdfLexer{data: tail, pos: i + len("startxref")}
+	start, ok := pdfInt(pdfNumber(l.keyword()))
+	if !ok {
+		return nil, errors.New("invalid startxref")
+	}
+
+	f := &pdfFile{data: data, xref: map[int]pdfXrefEntry{}, startxref: start, objStms: map[int]pdfObjectStream{}, resolving: map[int]bool{}}
+	seen := map[int64]bool{}
+	for offset, first := start, true; ; first = false {
+		if seen[offset] {
+			return nil, errors.New("cross-reference sections in a loop")
+		}
+		seen[offset] = true
+		trailer, stream, err := f.readXrefSection(offset)
+		if err != nil {
+			return nil, fmt.Errorf("cross-reference section at %d: %w", offset, err)
+		}
+		if first {
+			f.trailer, f.xrefStream = trailer, stream
+		}
+		// a hybrid file has the objects of its streams in a separate section
+		if xrefStm, ok := pdfInt(trailer.get("XRefStm")); ok && !seen[xrefStm] {
+			seen[xrefStm] = true
+			_, _, err = f.readXrefSection(xrefStm)
+			if err != nil {
+				return nil, fmt.Errorf("cross-reference stream at %d: %w", xrefStm, err)
+			}
+		}
+		prev, ok := pdfInt(trailer.get("Prev"))
+		if !ok {
+			return f, nil
+		}
+		offset = prev
+	}
+}
+
+// readXrefSection adds the entries of a cross-reference section that newer
+// sections don't have, and returns its trailer.
+func (f *pdfFile) readXrefSection(offset int64) (pdfDict, bool, error) {
+	if offset < 0 || offset >= int64(len(f.data)) {
+		return nil, false, errors.New("offset out of the file")
+	}
+	add := func(num int, e pdfXrefEntry) {
+		if _, ok := f.xref[num]; !ok {
+			f.xref[num] = e
+		}
+	}
+	l := &pdfLexer{data: f.data, pos: int(offset)}
+	if l.keyword() == "xref" {
+		for {
+			save := l.pos
+			if l.keyword() == "trailer" {
+				trailer, err := l.value()
+				if err != nil {
+					return nil, false, err
+				}
+				dict, ok := trailer.(pdfDict)
+				if !ok {
+					return nil, false, errors.New("trailer is no dictionary")
+				}
+				return dict, false, nil
+			}
+			l.pos = save
+			first, ok1 := pdfInt(pdfNumber(l.keyword()))
+			count, ok2 := pdfInt(pdfNumber(l.keyword()))
+			if !ok1 || !ok2 || count < 0 || count > int64(len(f.data)) {
+				return nil, false, errors.New("invalid subsection")
+			}
+			for i := range count {
+				off, ok1 := pdfInt(pdfNumber(l.keyword()))
+				gen, ok2 := pdfInt(pdfNumber(l.keyword()))
+				kind := l.keyword()
+				if !ok1 || !ok2 || kind != "n" && kind != "f" {
+					return nil, false, errors.New("invalid entry")
+				}
+				add(int(first+i), pdfXrefEntry{offset: off, gen: int(gen), free: kind == "f"})
+			}
+		}
+	}
+
+	obj, err := f.objectAt(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok || stream.dict.get("Type") != pdfName("XRef") {
+		return nil, false, errors.New("no xref table or stream")
+	}
+	data, err := f.decode(stream)
+	if err != nil {
+		return nil, false, err
+	}
+	widths, _ := stream.dict.get("W").(pdfArray)
+	var w [3]int
+	for i := range min(len(widths), 3) {
+		n, _ := pdfInt(widths[i])
+		w[i] = int(n)
+	}
+	if len(widths) != 3 || w[0] < 0 || w[1] < 0 || w[2] < 0 || w[0]+w[1]+w[2] == 0 {
+		return nil, false, errors.New("invalid W")
+	}
+	index, _ := stream.dict.get("Index").(pdfArray)
+	if index == nil {
+		index = pdfArray{pdfNumber("0"), stream.dict.get("Size")}
+	}
+	field := func(b []byte) int64 {
+		var n int64
+		for _, c := range b {
+			n = n<<8 | int64(c)
+		}
+		return n
+	}
+	size := w[0] + w[1] + w[2]
+	for i := 0; i+1 < len(index); i += 2 {
+		first, ok1 := pdfInt(index[i])
+		count, ok2 := pdfInt(index[i+1])
+		if !ok1 || !ok2 || count < 0 {
+			return nil, false, errors.New("invalid Index")
+		}
+		for j := range count {
+			if len(data) < size {
+				return nil, false, errors.New("xref stream too short")
+			}
+			row := data[:size]
+			data = data[size:]
+			kind := int64(1)
+			if w[0] > 0 {
+				kind = field(row[:w[0]])
+			}
+			a, b := field(row[w[0]:w[0]+w[1]]), field(row[w[0]+w[1]:])
+			switch kind {
+			case 0:
+				add(int(first+j), pdfXrefEntry{free: true})
+			case 1:
+				add(int(first+j), pdfXrefEntry{offset: a, gen: int(b)})
+			case 2:
+				add(int(first+j), pdfXrefEntry{stream: int(a), index: int(b)})
+			}
+		}
+	}
+	return stream.dict, true, nil
+}
+
+// object returns the object with number num, null if there is none.
+func (f *pdfFile) object(num int) (any, error) {
+	e, ok := f.xref[num]
+	if !ok || e.free {
+		return pdfKeyword("null"), nil
+	}
+	if f.resolving[num] {
+		return nil, fmt.Errorf("object %d refers to itself", num)
+	}
+	f.resolving[num] = true
+	defer delete(f.resolving, num)
+	if e.stream == 0 {
+		return f.objectAt(e.offset)
+	}
+
+	stm, ok := f.objStms[e.stream]
+	if !ok {
+		obj, err := f.object(e.stream)
+		if err != nil {
+			return nil, err
+		}
+		stream, ok := obj.(pdfStream)
+		if !ok {
+			return nil, fmt.Errorf("object stream %d is no stream", e.stream)
+		}
+		stm.data, err = f.decode(stream)
+		if err != nil {
+			return nil, fmt.Errorf("object stream %d: %w", e.stream, err)
+		}
+		first, _ := pdfInt(stream.dict.get("First"))
+		if first < 0 || first > int64(len(stm.data)) {
+			return nil, fmt.Errorf("object stream %d: invalid First", e.stream)
+		}
+		stm.first = int(first)
+		f.objStms[e.stream] = stm
+	}
+	// the header has pairs of object number and offset from First
+	l := &pdfLexer{data: stm.data[:stm.first]}
+	for i := 0; ; i++ {
+		n, ok1 := pdfInt(pdfNumber(l.keyword()))
+		offset, ok2 := pdfInt(pdfNumber(l.keyword()))
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("object %d not in object stream %d", num, e.stream)
+		}
+		if i == e.index && int(n) == num {
+			if offset < 0 || int64(stm.first)+offset >= int64(len(stm.data)) {
+				return nil, fmt.Errorf("object %d: offset out of object stream %d", num, e.stream)
+			}
+			return (&pdfLexer{data: stm.data, pos: stm.first + int(offset)}).value()
+		}
+	}
+}
+
+// objectAt reads the object at offset: N G obj, its value, and the data of
+// a stream.
+func (f *pdfFile) objectAt(offset int64) (any, error) {
+	if offset < 0 || offset >= int64(len(f.data)) {
+		return nil, fmt.Errorf("offset %d out of the file", offset)
+	}
+	l := &pdfLexer{data: f.data, pos: int(offset)}
+	_, ok1 := pdfInt(pdfNumber(l.keyword()))
+	_, ok2 := pdfInt(pdfNumber(l.keyword()))
+	if !ok1 || !ok2 || l.keyword() != "obj" {
+		return nil, fmt.Errorf("no object at offset %d", offset)
+	}
+	value, err := l.value()
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := value.(pdfDict)
+	if !ok {
+		return value, nil
+	}
+	save := l.pos
+	if l.keyword() != "stream" {
+		l.pos = save
+		return dict, nil
+	}
+	// the data starts after CRLF or LF
+	if bytes.HasPrefix(f.data[l.pos:], []byte("\r\n")) {
+		l.pos += 2
+	} else if l.pos < len(f.data) && f.data[l.pos] == '\n' {
+		l.pos++
+	}
+	length, err := f.resolve(dict.get("Length"))
+	if err != nil {
+		return nil, err
+	}
+	n, ok := pdfInt(length)
+	if !ok || n < 0 || int64(l.pos)+n > int64(len(f.data)) {
+		// a wrong length: up to endstream
+		end := bytes.Index(f.data[l.pos:], []byte("endstream"))
+		if end < 0 {
+			return nil, errors.New("stream without endstream")
+		}
+		n = int64(len(bytes.TrimRight(f.data[l.pos:l.pos+end], "\r\n")))
+	}
+	return pdfStream{dict: dict, data: f.data[l.pos : l.pos+int(n)]}, nil
+}
+
+// resolve returns the object a reference refers to, or v itself.
+func (f *pdfFile) resolve(v any) (any, error) {
+	if ref, ok := v.(pdfRef); ok {
+		return f.object(ref.num)
+	}
+	return v, nil
+}
+
+// decode returns the decoded data of a stream. Only FlateDecode, with or
+// without PNG predictors, is supported.
+func (f *pdfFile) decode(s pdfStream) ([]byte, error) {
+	filter, err := f.resolve(s.dict.get("Filter"))
+	if err != nil {
+		return nil, err
+	}
+	params, err := f.resolve(s.dict.get("DecodeParms"))
+	if err != nil {
+		return nil, err
+	}
+	filters, ok := filter.(pdfArray)
+	if !ok && filter != nil && filter != pdfKeyword("null") {
+		filters = pdfArray{filter}
+	}
+	paramList, ok := params.(pdfArray)
+	if !ok {
+		paramList = pdfArray{params}
+	}
+	data := s.data
+	for i, filter := range filters {
+		if filter != pdfName("FlateDecode") {
+			return nil, fmt.Errorf("filter %v not supported", filter)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		var p any
+		if i < len(paramList) {
+			p, err = f.resolve(paramList[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if dict, ok := p.(pdfDict); ok {
+			data, err = unpredict(data, dict)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
+
+// unpredict undoes the PNG predictors of FlateDecode.
+func unpredict(data []byte, params pdfDict) ([]byte, error) {
+	predictor, ok := pdfInt(params.get("Predictor"))
+	if !ok || predictor == 1 {
+		return data, nil
+	}
+	if predictor < 10 {
+		return nil, fmt.Errorf("predictor %d not supported", predictor)
+	}
+	param := func(key pdfName, def int64) int64 {
+		if n, ok := pdfInt(params.get(key)); ok && n > 0 {
+			return n
+		}
+		return def
+	}
+	colors, bits, columns := param("Colors", 1), param("BitsPerComponent", 8), param("Columns", 1)
+	bpp := max(int(colors*bits/8), 1)
+	rowLen := int((colors*bits*columns + 7) / 8)
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for len(data) > rowLen {
+		kind, row := data[0], data[1:rowLen+1]
+		data = data[rowLen+1:]
+		cur := make([]byte, rowLen)
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 0:
+				cur[i] = row[i]
+			case 1:
+				cur[i] = row[i] + left
+			case 2:
+				cur[i] = row[i] + up
+			case 3:
+				cur[i] = row[i] + byte((int(left)+int(up))/2)
+			case 4:
+				cur[i] = row[i] + paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("PNG filter %d not supported", kind)
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,75 @@
+package ubl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// eas are the electronic address schemes (EAS) Peppol allows for
+// participant identifiers.
+var eas = func() map[string]bool {
+	schemes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		0002 0007 0009 0037 0060 0088 0096 0097 0106 0130 0135 0142 0147 0151
+		0154 0158 0170 0177 0183 0184 0188 0190 0191 0192 0193 0194 0195 0196
+		0198 0199 0200 0201 0202 0203 0204 0205 0208 0209 0210 0211 0212 0213
+		0215 0216 0217 0218 0221 0225 0230 0235 0240 0244
+		9901 9910 9913 9914 9915 9918 9919 9920 9922 9923 9924 9925 9926 9927
+		9928 9929 9930 9931 9932 9933 9934 9935 9936 9937 9938 9939 9940 9941
+		9942 9943 9944 9945 9946 9947 9948 9949 9950 9951 9952 9953 9957 9959
+		AN AQ AS AU EM`) {
+		schemes[code] = true
+	}
+	return schemes
+}()
+
+// endpointID returns the cbc:EndpointID of a party: from the explicit scheme
+// and value when a value is given, from the participant identifier
+// "scheme:value" otherwise. field names the participant identifier in
+// errors, e.g. "SupplierPeppolID".
+func endpointID(field, participantID, scheme, value string) (XMLEndpointID, error) {
+	prefix := strings.TrimSuffix(field, "PeppolID")
+	if value = strings.TrimSpace(value); value != "" {
+		scheme = strings.TrimSpace(scheme)
+		if !eas[scheme] {
+			return XMLEndpointID{}, fmt.Errorf("%sEndpointScheme %q: not in the Peppol EAS code list", prefix, scheme)
+		}
+		return XMLEndpointID{Value: value, SchemeID: scheme}, nil
+	}
+	if scheme != "" {
+		return XMLEndpointID{}, fmt.Errorf("%sEndpointValue: required with %sEndpointScheme", prefix, prefix)
+	}
+
+	participantID = strings.TrimSpace(participantID)
+	if participantID == "" {
+		return XMLEndpointID{}, fmt.Errorf("%s: required", field)
+	}
+	scheme, value, ok := strings.Cut(participantID, ":")
+	if !ok {
+		return XMLEndpointID{}, fmt.Errorf("%s %q: expected scheme:identifier, e.g. 0208:0123456789", field, participantID)
+	}
+	scheme, value = strings.TrimSpace(scheme), strings.TrimSpace(value)
+	if !eas[scheme] {
+		return XMLEndpointID{}, fmt.Errorf("%s %q: scheme %q not in the Peppol EAS code list", field, participantID, scheme)
+	}
+	if value == "" {
+		return XMLEndpointID{}, fmt.Errorf("%s %q: identifier required", field, participantID)
+	}
+	return XMLEndpointID{Value: value, SchemeID: scheme}, nil
+}
+
+// participantID returns the endpoint as Peppol participant identifier
+// "scheme:value".
+func (e XMLEndpointID) participantID() string {
+	return e.SchemeID + ":" + e.Value
+}
+
+// resolvedParticipantID returns the participant identifier the endpoint
+// resolves to, or the trimmed combined identifier when it is malformed.
+func resolvedParticipantID(participantID, scheme, value string) string {
+	e, err := endpointID("PeppolID", participantID, scheme, value)
+	if err != nil {
+		return strings.TrimSpace(participantID)
+	}
+	return e.participantID()
+}
@@ -0,0 +1,56 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPeppolID(t *testing.T) {
+	for _, test := range []struct {
+		id   string
+		want string
+	}{
+		{"BE0123456789", `SupplierPeppolID "BE0123456789": expected scheme:identifier, e.g. 0208:0123456789`},
+		{"208:x", `SupplierPeppolID "208:x": scheme "208" not in the Peppol EAS code list`},
+		{"0208:", `SupplierPeppolID "0208:": identifier required`},
+		{"0208", `SupplierPeppolID "0208": expected scheme:identifier, e.g. 0208:0123456789`},
+		{"", "SupplierPeppolID: required"},
+	} {
+		inv := newTestInvoice()
+		inv.SupplierPeppolID = test.id
+		_, err := inv.Generate()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: expected %q, got %v", test.id, test.want, err)
+		}
+	}
+
+	inv := newTestInvoice()
+	inv.CustomerPeppolID = " 0088 : 5412345000013 "
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cbc:EndpointID schemeID="0088">5412345000013</cbc:EndpointID>`) {
+		t.Error("expected a trimmed customer endpoint")
+	}
+
+	inv = newTestInvoice()
+	inv.SupplierPeppolID = ""
+	inv.SupplierEndpointScheme = "9925"
+	inv.SupplierEndpointValue = "BE0123456789"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cbc:EndpointID schemeID="9925">BE0123456789</cbc:EndpointID>`) {
+		t.Error("expected the explicit supplier endpoint")
+	}
+
+	inv.SupplierEndpointScheme = "99"
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `SupplierEndpointScheme "99": not in the Peppol EAS code list` {
+		t.Errorf("expected an EAS error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.CustomerPeppolID = ""
+	cn.CustomerEndpointScheme = "0208"
+	_, err = cn.GenerateCreditNote()
+	if err == nil || err.Error() != "CustomerEndpointValue: required with CustomerEndpointScheme" {
+		t.Errorf("expected a missing endpoint value, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package ubl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// linePrice returns the cac:Price element of a line, with the price discount
+// as cac:AllowanceCharge when there is a gross price. The net price must be
+// the gross price minus the discount, to half a minor unit.
+func linePrice(line InvoiceLine, currency string) (XMLPrice, error) {
+	decimals := minorUnits(currency)
+	price := XMLPrice{PriceAmount: XMLPriceAmount{Value: line.netPrice(decimals), CurrencyID: currency}}
+	if line.GrossPrice == 0 && line.PriceDiscount == 0 {
+		return price, nil
+	}
+	if line.GrossPrice == 0 {
+		return XMLPrice{}, fmt.Errorf("price discount %v without gross price", line.PriceDiscount)
+	}
+	if line.PriceDiscount < 0 {
+		return XMLPrice{}, fmt.Errorf("negative price discount %v", line.PriceDiscount)
+	}
+	tolerance := math.Pow10(-minorUnits(currency)) / 2
+	if math.Abs(line.GrossPrice-line.PriceDiscount-line.Price) > tolerance {
+		return XMLPrice{}, fmt.Errorf("gross price %v minus discount %v is not the price %v", line.GrossPrice, line.PriceDiscount, line.Price)
+	}
+	price.AllowanceCharge = &XMLPriceAllowanceCharge{
+		Amount:     XMLPriceAmount{Value: line.PriceDiscount, CurrencyID: currency},
+		BaseAmount: XMLPriceAmount{Value: line.GrossPrice, CurrencyID: currency},
+	}
+	if line.PriceIncludesTax {
+		// the discount is the difference of the rounded net prices, so the
+		// net price stays the gross price minus the discount
+		base := line.withoutTax(line.GrossPrice, decimals)
+		price.AllowanceCharge.BaseAmount.Value = base
+		price.AllowanceCharge.Amount.Value = roundTo(base-price.PriceAmount.Value, decimals)
+	}
+	return price, nil
+}
+
+// netPrice returns the price of the line without VAT.
+func (line InvoiceLine) netPrice(decimals int) float64 {
+	return line.withoutTax(line.Price, decimals)
+}
+
+// withoutTax returns price v of the line without VAT. Prices that include
+// VAT are divided by 1 + rate/100 and rounded to the minor units of the
+// currency, so the net price in the document is a plain amount; the rounding
+// difference with the gross amounts is settled by lineAmounts.
+func (line InvoiceLine) withoutTax(v float64, decimals int) float64 {
+	if !line.PriceIncludesTax {
+		return v
+	}
+	return roundTo(v/(1+line.taxCategory().rate()/100), decimals)
+}
+
+// lineAmounts returns the line amounts (BT-131) and the rounding amount
+// (BT-114) that makes the amount due the sum of the gross line amounts.
+//
+// Every line amount is its quantity times its net price, as the document
+// states it (PEPPOL-EN16931-R120). When all lines of a VAT category include
+// VAT, the net amounts and the VAT rounded from them may differ from the gross
+// amounts by a few minor units, e.g. 3 × 9.99 at 21% is 24.78 + 5.20 = 29.98:
+// that difference is the rounding amount.
+func lineAmounts(lines []InvoiceLine, decimals int) ([]money, money) {
+	type category struct {
+		lines, gross money
+		inclusive    bool
+	}
+	amounts := make([]money, len(lines))
+	categories := map[taxKey]*category{}
+	var keys []taxKey
+	for i, line := range lines {
+		amounts[i] = line.amount(decimals)
+		key := taxKey{Rate: line.taxCategory().rate(), CategoryID: line.taxCategory().ID}
+		c := categories[key]
+		if c == nil {
+			c = &category{inclusive: true}
+			categories[key] = c
+			keys = append(keys, key)
+		}
+		c.lines += amounts[i]
+		c.gross += toMoney(line.Quantity*line.Price, decimals)
+		c.inclusive = c.inclusive && line.PriceIncludesTax
+	}
+
+	var rounding money
+	for _, key := range keys {
+		c := categories[key]
+		if c.inclusive {
+			rounding += c.gross - c.lines - c.lines.percent(key.Rate)
+		}
+	}
+	return amounts, rounding
+}
+
+// MarshalXML writes the price with all its decimals: unit prices like
+// 0.04753 EUR/kWh aren't limited to the minor unit of the currency. It never
+// uses scientific notation.
+func (a XMLPriceAmount) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "currencyID"}, Value: a.CurrencyID})
+	return e.EncodeElement(strconv.FormatFloat(a.Value, 'f', -1, 64), start)
+}
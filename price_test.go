@@ -0,0 +1,161 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestPricePrecision(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines[0].Quantity = 1234
+	inv.Lines[0].Price = 0.04753
+	inv.Lines[0].UnitCode = "KWH"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:PriceAmount currencyID="EUR">0.04753</cbc:PriceAmount>`,
+		`<cbc:LineExtensionAmount currencyID="EUR">58.65</cbc:LineExtensionAmount>`,
+		`<cbc:TaxAmount currencyID="EUR">12.32</cbc:TaxAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv.Lines[0].Price = 0.00001
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cbc:PriceAmount currencyID="EUR">0.00001</cbc:PriceAmount>`) {
+		t.Error("expected a small price in plain notation")
+	}
+
+	inv.Lines[0].Price = 0.04753
+	inv.Lines[0].GrossPrice = 0.05
+	inv.Lines[0].PriceDiscount = 0.00247
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want := `<cbc:Amount currencyID="EUR">0.00247</cbc:Amount><cbc:BaseAmount currencyID="EUR">0.05</cbc:BaseAmount>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].Price = 0.04753
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:PriceAmount currencyID="EUR">0.04753</cbc:PriceAmount>`) {
+		t.Error("expected the credit note price with 5 decimals")
+	}
+}
+
+func TestPriceIncludesTax(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 1, Price: 9.99, TaxPercentage: 21, PriceIncludesTax: true, Name: "Paperback"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:LineExtensionAmount currencyID="EUR">8.26</cbc:LineExtensionAmount>`,
+		`<cbc:TaxAmount currencyID="EUR">1.73</cbc:TaxAmount>`,
+		`<cbc:TaxInclusiveAmount currencyID="EUR">9.99</cbc:TaxInclusiveAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv.Lines[0].Quantity = 10
+	inv.Lines[0].GrossPrice = 12.49
+	inv.Lines[0].PriceDiscount = 2.5
+	generateAndValidate(t, &inv)
+	doc, err := inv.GenerateDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 × 8.26 net is 82.60 + 17.35 VAT, 5 cents more than 10 × 9.99
+	if totals := doc.Totals(); totals.LineExtension != 82.6 || totals.TaxInclusive != 99.95 || totals.Rounding != -0.05 || totals.Payable != 99.9 {
+		t.Errorf("expected 82.60 net, 99.95 with VAT, -0.05 rounding and 99.90 due, got %+v", totals)
+	}
+	want := `<cbc:PriceAmount currencyID="EUR">8.26</cbc:PriceAmount><cac:AllowanceCharge><cbc:ChargeIndicator>false</cbc:ChargeIndicator><cbc:Amount currencyID="EUR">2.06</cbc:Amount><cbc:BaseAmount currencyID="EUR">10.32</cbc:BaseAmount>`
+	if !strings.Contains(compact(doc.Bytes()), want) {
+		t.Errorf("expected net prices rounded to cents, %s", want)
+	}
+
+	// three lines of 9.99 have 8.26 net each, and 24.78 + 5.20 VAT is a cent
+	// more than 29.97: every line keeps quantity × price and the rounding
+	// amount takes the cent
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 1, Price: 9.99, TaxPercentage: 21, PriceIncludesTax: true, Name: "Paperback"},
+		{Quantity: 1, Price: 9.99, TaxPercentage: 21, PriceIncludesTax: true, Name: "Paperback"},
+		{Quantity: 1, Price: 9.99, TaxPercentage: 21, PriceIncludesTax: true, Name: "Paperback"},
+	}
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		`<cbc:LineExtensionAmount currencyID="EUR">24.78</cbc:LineExtensionAmount>`,
+		`<cbc:TaxAmount currencyID="EUR">5.20</cbc:TaxAmount>`,
+		`<cbc:TaxInclusiveAmount currencyID="EUR">29.98</cbc:TaxInclusiveAmount><cbc:PayableRoundingAmount currencyID="EUR">-0.01</cbc:PayableRoundingAmount><cbc:PayableAmount currencyID="EUR">29.97</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+	line := `<cbc:LineExtensionAmount currencyID="EUR">8.26</cbc:LineExtensionAmount>`
+	price := `<cbc:PriceAmount currencyID="EUR">8.26</cbc:PriceAmount>`
+	if strings.Count(xmlBytes, line) != 3 || strings.Count(xmlBytes, price) != 3 {
+		t.Errorf("expected quantity × net price on every line, got %s", xmlBytes)
+	}
+
+	// reading the document back keeps the amounts
+	parsed, err := ubl.ParseInvoice(strings.NewReader(xmlBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals, err := parsed.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.LineExtension != 24.78 || totals.TaxInclusive != 29.98 {
+		t.Errorf("expected 24.78 net and 29.98 with VAT after parsing, got %+v", totals)
+	}
+
+	// ten lines of 1.00 are 10 × 0.83 + 1.74 VAT
+	inv.Lines = nil
+	for range 10 {
+		inv.Lines = append(inv.Lines, ubl.InvoiceLine{Quantity: 1, Price: 1, TaxPercentage: 21, PriceIncludesTax: true, Name: "Pen"})
+	}
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if n := strings.Count(xmlBytes, `<cbc:LineExtensionAmount currencyID="EUR">0.83</cbc:LineExtensionAmount>`); n != 10 {
+		t.Errorf("expected 10 lines of 0.83, got %d", n)
+	}
+	want = `<cbc:TaxInclusiveAmount currencyID="EUR">10.04</cbc:TaxInclusiveAmount><cbc:PayableRoundingAmount currencyID="EUR">-0.04</cbc:PayableRoundingAmount><cbc:PayableAmount currencyID="EUR">10.00</cbc:PayableAmount>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	// 8.26 net + 1.73 VAT is a cent short of 10.00
+	inv.Lines = inv.Lines[:1]
+	inv.Lines[0].Price = 10
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want = `<cbc:TaxInclusiveAmount currencyID="EUR">9.99</cbc:TaxInclusiveAmount><cbc:PayableRoundingAmount currencyID="EUR">0.01</cbc:PayableRoundingAmount><cbc:PayableAmount currencyID="EUR">10.00</cbc:PayableAmount>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+}
+
+func TestRoundingEdges(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = nil
+	for i := range 200 {
+		inv.Lines = append(inv.Lines,
+			ubl.InvoiceLine{Quantity: 1, Price: 0.005, TaxPercentage: 21, Name: "Half cent"},
+			ubl.InvoiceLine{Quantity: 3, Price: 0.335, TaxPercentage: 6, Name: "Third"},
+			ubl.InvoiceLine{Quantity: float64(i%7) + 0.5, Price: 1.115, TaxPercentage: 12, Name: "Odd"})
+	}
+	inv.AllowanceCharges = []ubl.AllowanceCharge{{Percentage: 3.333, Reason: "Discount"}, {Charge: true, Amount: 0.005, Reason: "Rounding"}}
+	xmlBytes := generateAndValidate(t, &inv)
+	if findings := validate.CheckArithmetic(xmlBytes); len(findings) > 0 {
+		t.Errorf("unexpected findings %v", findings)
+	}
+}
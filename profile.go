@@ -0,0 +1,258 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Specification identifiers (BT-24) and business process types (BT-23).
+const (
+	PeppolBilling30CustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	PeppolBilling30ProfileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+
+	// Peppol BIS Self-Billing 3.0, for invoices the buyer issues on behalf
+	// of the seller.
+	PeppolSelfBilling30CustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:selfbilling:3.0"
+	PeppolSelfBilling30ProfileID       = "urn:fdc:peppol.eu:2017:poacc:selfbilling:01:1.0"
+
+	// UBLBECustomizationID is the UBL.BE specification identifier, for
+	// documents exchanged with Belgian receivers outside Peppol BIS.
+	UBLBECustomizationID = "urn:cen.eu:en16931:2017#conformant#urn:UBL.BE:1.0.0.20180214"
+
+	// XRechnungCustomizationID is the XRechnung 3.0 specification
+	// identifier, for German public sector buyers.
+	XRechnungCustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:xeinkauf.de:kosit:xrechnung_3.0"
+
+	// NLCIUSCustomizationID is the NLCIUS (SI-UBL 2.0) specification
+	// identifier, for Dutch public sector buyers.
+	NLCIUSCustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:nen.nl:nlcius:v1.0"
+)
+
+// Profile selects the CIUS (core invoice usage specification) a document
+// follows: its default CustomizationID and the extra rules checked by
+// Generate.
+type Profile int
+
+const (
+	ProfilePeppol    Profile = iota // Peppol BIS Billing 3.0, the default
+	ProfileXRechnung                // XRechnung 3.0: Leitweg-ID, seller contact and payment instructions required
+	ProfileNLCIUS                   // NLCIUS: KvK or OIN registration and full addresses for Dutch suppliers
+	ProfileUBLBE                    // UBL.BE: adds the UBL.BE document reference
+)
+
+func (p Profile) customizationID() string {
+	switch p {
+	case ProfileXRechnung:
+		return XRechnungCustomizationID
+	case ProfileNLCIUS:
+		return NLCIUSCustomizationID
+	case ProfileUBLBE:
+		return UBLBECustomizationID
+	default:
+		return PeppolBilling30CustomizationID
+	}
+}
+
+// specification returns the CustomizationID and ProfileID to emit, falling
+// back to the defaults of the profile (or of self-billing) for the empty ones
+// unless noDefault is set. A self-billing document can't use the billing
+// process and vice versa.
+func specification(customizationID, profileID string, profile Profile, selfBilling, noDefault bool) (string, string, error) {
+	if !noDefault {
+		if customizationID == "" {
+			customizationID = profile.customizationID()
+			if selfBilling {
+				customizationID = PeppolSelfBilling30CustomizationID
+			}
+		}
+		if profileID == "" {
+			profileID = PeppolBilling30ProfileID
+			if selfBilling {
+				profileID = PeppolSelfBilling30ProfileID
+			}
+		}
+	}
+	if selfBilling && profileID == PeppolBilling30ProfileID {
+		return "", "", fmt.Errorf("self-billing: ProfileID %q is the billing process", profileID)
+	}
+	if !selfBilling && profileID == PeppolSelfBilling30ProfileID {
+		return "", "", fmt.Errorf("ProfileID %q requires SelfBilling", profileID)
+	}
+	return customizationID, profileID, nil
+}
+
+// invoiceTypeCode returns the UNCL1001 invoice type code (BT-3): the given
+// code, or commercial invoice (380) or self-billed invoice (389).
+func invoiceTypeCode(code string, selfBilling bool) string {
+	if code != "" {
+		return code
+	}
+	if selfBilling {
+		return "389"
+	}
+	return "380"
+}
+
+// creditNoteTypeCode returns the UNCL1001 credit note type code (BT-3): the
+// given code, or credit note (381) or self-billed credit note (261).
+func creditNoteTypeCode(code string, selfBilling bool) string {
+	if code != "" {
+		return code
+	}
+	if selfBilling {
+		return "261"
+	}
+	return "381"
+}
+
+// ublBEReference returns the cac:AdditionalDocumentReference UBL.BE expects
+// before any attachment, when the UBL.BE profile is selected or include is
+// set. It doesn't depend on the attachments: Belgian receivers expect it even
+// without a PDF.
+// The description defaults to defaultDescription, which depends on the
+// document type.
+func ublBEReference(profile Profile, include bool, description, defaultDescription string) []XMLDocumentReference {
+	if profile != ProfileUBLBE && !include {
+		return nil
+	}
+	if description == "" {
+		description = defaultDescription
+	}
+	return []XMLDocumentReference{{ID: XMLIdentifier{Value: "UBL.BE"}, DocumentDescription: description}}
+}
+
+// profileDocument holds the parts of a generated document the profile rules
+// look at.
+type profileDocument struct {
+	BuyerReference string
+	Supplier       *XMLParty
+	Customer       *XMLParty
+	PaymentMeans   []XMLPaymentMeans
+}
+
+// check applies the rules of the profile and reports all violations at once.
+func (p Profile) check(doc profileDocument) error {
+	var errs []error
+	switch p {
+	case ProfileXRechnung:
+		errs = checkXRechnung(doc)
+	case ProfileNLCIUS:
+		errs = checkNLCIUS(doc)
+	default:
+		return nil
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %w", p, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (p Profile) String() string {
+	switch p {
+	case ProfilePeppol:
+		return "peppol"
+	case ProfileXRechnung:
+		return "xrechnung"
+	case ProfileNLCIUS:
+		return "nlcius"
+	case ProfileUBLBE:
+		return "ublbe"
+	default:
+		return fmt.Sprintf("Profile(%d)", int(p))
+	}
+}
+
+func checkXRechnung(doc profileDocument) []error {
+	var errs []error
+	if doc.BuyerReference == "" {
+		errs = append(errs, errors.New("BuyerReference (Leitweg-ID) required (BR-DE-15)"))
+	} else if !validLeitwegID(doc.BuyerReference) {
+		errs = append(errs, fmt.Errorf("BuyerReference %q: not a valid Leitweg-ID", doc.BuyerReference))
+	}
+
+	contact := doc.Supplier.Contact
+	if contact == nil || contact.Name == "" || contact.Telephone == "" || contact.ElectronicMail == "" {
+		errs = append(errs, errors.New("SupplierContact name, phone and email required (BR-DE-2, BR-DE-5 to BR-DE-7)"))
+	}
+	if doc.Supplier.PostalAddress.CityName == "" || doc.Supplier.PostalAddress.PostalZone == "" {
+		errs = append(errs, errors.New("SupplierAddress city and postal zone required (BR-DE-3, BR-DE-4)"))
+	}
+	if doc.Customer.PostalAddress.CityName == "" || doc.Customer.PostalAddress.PostalZone == "" {
+		errs = append(errs, errors.New("CustomerAddress city and postal zone required (BR-DE-8, BR-DE-9)"))
+	}
+
+	for i, pm := range doc.PaymentMeans {
+		switch pm.PaymentMeansCode.Value {
+		case "30", "58":
+			if pm.PayeeFinancialAccount == nil || pm.PayeeFinancialAccount.ID == "" {
+				errs = append(errs, fmt.Errorf("PaymentMeans[%d]: credit transfer requires an IBAN (BR-DE-23)", i))
+			}
+		case "59":
+			if pm.PaymentMandate == nil || pm.PaymentMandate.PayerFinancialAccount == nil {
+				errs = append(errs, fmt.Errorf("PaymentMeans[%d]: direct debit requires the debited account (BR-DE-25)", i))
+			}
+		case "1":
+			errs = append(errs, fmt.Errorf("PaymentMeans[%d]: payment instructions required (BR-DE-1)", i))
+		}
+	}
+	return errs
+}
+
+// nlciusPaymentMeans are the payment means codes NLCIUS allows (BR-NL-13).
+var nlciusPaymentMeans = map[string]bool{"30": true, "31": true, "42": true, "48": true, "49": true, "57": true, "58": true, "59": true}
+
+// checkNLCIUS checks the NLCIUS rules. Most apply only to a supplier or
+// customer in the Netherlands.
+func checkNLCIUS(doc profileDocument) []error {
+	var errs []error
+	supplier := doc.Supplier
+	if supplier.PostalAddress.Country.IdentificationCode == "NL" {
+		id := supplier.PartyLegalEntity.CompanyID
+		if id == nil || (id.SchemeID != "0106" && id.SchemeID != "0190") {
+			errs = append(errs, errors.New("SupplierCompanyID with scheme 0106 (KvK) or 0190 (OIN) required (BR-NL-1)"))
+		}
+		if scheme := supplier.EndpointID.SchemeID; scheme != "0106" && scheme != "0190" {
+			errs = append(errs, fmt.Errorf("SupplierPeppolID: endpoint scheme %s not allowed, 0106 (KvK) or 0190 (OIN) required", scheme))
+		}
+		if !completeAddress(supplier.PostalAddress) {
+			errs = append(errs, errors.New("SupplierAddress street, city and postal zone required (BR-NL-3)"))
+		}
+		for i, pm := range doc.PaymentMeans {
+			if !nlciusPaymentMeans[pm.PaymentMeansCode.Value] {
+				errs = append(errs, fmt.Errorf("PaymentMeans[%d]: payment means code %s not allowed (BR-NL-13)", i, pm.PaymentMeansCode.Value))
+			}
+		}
+	}
+	if doc.Customer.PostalAddress.Country.IdentificationCode == "NL" && !completeAddress(doc.Customer.PostalAddress) {
+		errs = append(errs, errors.New("CustomerAddress street, city and postal zone required (BR-NL-4)"))
+	}
+	return errs
+}
+
+func completeAddress(a XMLPostalAddress) bool {
+	return a.StreetName != "" && a.CityName != "" && a.PostalZone != ""
+}
+
+var leitwegID = regexp.MustCompile(`^[0-9]{2,12}(-[0-9A-Z]{1,30})?-[0-9]{2}$`)
+
+// validLeitwegID checks the format and the ISO 7064 MOD 97-10 check digits of
+// a German Leitweg-ID, e.g. "991-33333TEST-33".
+func validLeitwegID(id string) bool {
+	id = strings.ToUpper(id)
+	if !leitwegID.MatchString(id) {
+		return false
+	}
+	var digits strings.Builder
+	for _, c := range strings.ReplaceAll(id, "-", "") {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprint(&digits, c-'A'+10)
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && n.Mod(n, big.NewInt(97)).Int64() == 1
+}
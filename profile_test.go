@@ -0,0 +1,240 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestDefaultSpecification(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := "<cbc:CustomizationID>" + ubl.PeppolBilling30CustomizationID + "</cbc:CustomizationID><cbc:ProfileID>" + ubl.PeppolBilling30ProfileID + "</cbc:ProfileID>"
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected the Peppol BIS 3.0 defaults, want %s", want)
+	}
+
+	inv = newTestInvoice()
+	inv.CustomizationID = ubl.UBLBECustomizationID
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want = "<cbc:CustomizationID>" + ubl.UBLBECustomizationID + "</cbc:CustomizationID><cbc:ProfileID>" + ubl.PeppolBilling30ProfileID + "</cbc:ProfileID>"
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected the given CustomizationID to be kept, want %s", want)
+	}
+
+	cn := newTestCreditNote()
+	cn.NoDefaultSpecification = true
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), "<cbc:CustomizationID></cbc:CustomizationID><cbc:ProfileID></cbc:ProfileID>") {
+		t.Error("expected no defaults with NoDefaultSpecification")
+	}
+}
+
+func newTestXRechnung() ubl.Invoice {
+	inv := newTestInvoice()
+	inv.Profile = ubl.ProfileXRechnung
+	inv.BuyerReference = "991-33333TEST-33"
+	inv.SupplierAddress.CountryCode = "DE"
+	inv.SupplierVat = "DE123456789"
+	inv.SupplierPeppolID = "9930:DE123456789"
+	inv.SupplierContact = ubl.Contact{Name: "Jane Doe", Phone: "+49 30 1234567", Email: "jane@abc.example"}
+	inv.Iban = "DE89370400440532013000"
+	return inv
+}
+
+func TestXRechnung(t *testing.T) {
+	inv := newTestXRechnung()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		"<cbc:CustomizationID>" + ubl.XRechnungCustomizationID + "</cbc:CustomizationID>",
+		"<cbc:DocumentCurrencyCode>EUR</cbc:DocumentCurrencyCode><cbc:BuyerReference>991-33333TEST-33</cbc:BuyerReference>",
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv = newTestXRechnung()
+	inv.BuyerReference = "991-33333TEST-34"
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `xrechnung: BuyerReference "991-33333TEST-34": not a valid Leitweg-ID` {
+		t.Errorf("expected a Leitweg-ID error, got %v", err)
+	}
+
+	inv = newTestXRechnung()
+	inv.BuyerReference = ""
+	inv.SupplierContact = ubl.Contact{}
+	inv.Iban = ""
+	_, err = inv.Generate()
+	for _, want := range []string{"BR-DE-15", "BR-DE-2", "BR-DE-1"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in error, got %v", want, err)
+		}
+	}
+
+	cn := newTestCreditNote()
+	cn.Profile = ubl.ProfileXRechnung
+	_, err = cn.GenerateCreditNote()
+	if err == nil || !strings.Contains(err.Error(), "BR-DE-15") {
+		t.Errorf("expected the XRechnung rules on credit notes, got %v", err)
+	}
+}
+
+func newTestNLCIUS() ubl.Invoice {
+	inv := newTestInvoice()
+	inv.Profile = ubl.ProfileNLCIUS
+	inv.SupplierVat = "NL123456789B01"
+	inv.SupplierPeppolID = "0106:12345678"
+	inv.SupplierAddress.CountryCode = "NL"
+	inv.SupplierCompanyID = "12345678"
+	inv.SupplierCompanyIDScheme = "0106"
+	inv.Iban = "NL91ABNA0417164300"
+	return inv
+}
+
+func TestNLCIUS(t *testing.T) {
+	inv := newTestNLCIUS()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := "<cbc:CustomizationID>" + ubl.NLCIUSCustomizationID + "</cbc:CustomizationID>"
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv = newTestNLCIUS()
+	inv.SupplierCompanyIDScheme = "0208"
+	_, err := inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "BR-NL-1") {
+		t.Errorf("expected a legal registration error, got %v", err)
+	}
+
+	inv = newTestNLCIUS()
+	inv.SupplierPeppolID = "0190:00000001234567890000"
+	generateAndValidate(t, &inv)
+	inv.SupplierPeppolID = "9944:NL123456789B01"
+	_, err = inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "SupplierPeppolID: endpoint scheme 9944 not allowed, 0106 (KvK) or 0190 (OIN) required") {
+		t.Errorf("expected an endpoint scheme error, got %v", err)
+	}
+
+	inv = newTestNLCIUS()
+	inv.SupplierCompanyID = ""
+	inv.SupplierAddress.StreetName = ""
+	inv.PaymentMeansCode = "1"
+	_, err = inv.Generate()
+	for _, want := range []string{"BR-NL-1", "BR-NL-3", "BR-NL-13"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in error, got %v", want, err)
+		}
+	}
+
+	// the NL rules don't apply to a Belgian supplier
+	inv = newTestInvoice()
+	inv.Profile = ubl.ProfileNLCIUS
+	generateAndValidate(t, &inv)
+}
+
+func TestUBLBEReference(t *testing.T) {
+	ublBE := "<cac:AdditionalDocumentReference><cbc:ID>UBL.BE</cbc:ID><cbc:DocumentDescription>CommercialInvoice</cbc:DocumentDescription></cac:AdditionalDocumentReference>"
+	attachment := `<cbc:EmbeddedDocumentBinaryObject mimeCode="application/pdf" filename="invoice_test.pdf">`
+
+	inv := newTestInvoice()
+	inv.PdfInvoiceFilename = "invoice_test.pdf"
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, ublBE) {
+		t.Error("expected no UBL.BE reference by default")
+	}
+	if !strings.Contains(xmlBytes, attachment) {
+		t.Error("expected the attachment without UBL.BE reference")
+	}
+
+	inv.Profile = ubl.ProfileUBLBE
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, ublBE+"<cac:AdditionalDocumentReference><cbc:ID>INV-12345</cbc:ID>") {
+		t.Error("expected the UBL.BE reference before the attachment with ProfileUBLBE")
+	}
+	if !strings.Contains(xmlBytes, "<cbc:CustomizationID>"+ubl.UBLBECustomizationID+"</cbc:CustomizationID>") {
+		t.Error("expected the UBL.BE CustomizationID with ProfileUBLBE")
+	}
+
+	inv.PdfInvoiceFilename = ""
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, ublBE) || strings.Contains(xmlBytes, "<cac:Attachment>") {
+		t.Error("expected the UBL.BE reference without attachment with ProfileUBLBE")
+	}
+
+	inv.Profile = ubl.ProfilePeppol
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "<cac:AdditionalDocumentReference>") {
+		t.Error("expected no document reference without UBL.BE reference or attachment")
+	}
+	if err := inv.AddAttachmentFromBytes([]byte("%PDF-1.4"), "timesheet.pdf", ""); err != nil {
+		t.Fatal(err)
+	}
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "UBL.BE") {
+		t.Error("expected AddAttachmentFromBytes not to add the UBL.BE reference")
+	}
+
+	cn := newTestCreditNote()
+	cn.PdfCreditNoteFilename = "invoice_test.pdf"
+	cn.IncludeUBLBEReference = true
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), strings.Replace(ublBE, "CommercialInvoice", "CreditNote", 1)) {
+		t.Error("expected the UBL.BE reference with description CreditNote with IncludeUBLBEReference")
+	}
+
+	cn.UBLBEDescription = "CommercialCreditNote"
+	cnBytes, err = cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), "<cbc:ID>UBL.BE</cbc:ID><cbc:DocumentDescription>CommercialCreditNote</cbc:DocumentDescription>") {
+		t.Error("expected the overridden UBL.BE description")
+	}
+}
+
+func TestSelfBilling(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SelfBilling = true
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		"<cbc:CustomizationID>" + ubl.PeppolSelfBilling30CustomizationID + "</cbc:CustomizationID><cbc:ProfileID>" + ubl.PeppolSelfBilling30ProfileID + "</cbc:ProfileID>",
+		"<cbc:InvoiceTypeCode>389</cbc:InvoiceTypeCode>",
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+
+	inv = newTestInvoice()
+	inv.SelfBilling = true
+	inv.ProfileID = ubl.PeppolBilling30ProfileID
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `self-billing: ProfileID "`+ubl.PeppolBilling30ProfileID+`" is the billing process` {
+		t.Errorf("expected a self-billing profile error, got %v", err)
+	}
+
+	inv = newTestInvoice()
+	inv.ProfileID = ubl.PeppolSelfBilling30ProfileID
+	_, err = inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "requires SelfBilling") {
+		t.Errorf("expected a missing SelfBilling error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.SelfBilling = true
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cnBytes), "<cbc:CreditNoteTypeCode>261</cbc:CreditNoteTypeCode>") {
+		t.Error("expected the self-billed credit note type code")
+	}
+}
@@ -0,0 +1,27 @@
+package ubl
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// quantityDecimals is the precision of quantities in the output.
+const quantityDecimals = 4
+
+// formatDecimal formats v in plain notation with at most decimals decimals,
+// without trailing zeros.
+func formatDecimal(v float64, decimals int) string {
+	s := strconv.FormatFloat(roundTo(v, decimals), 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// MarshalXML writes the quantity with up to 4 decimals, never in scientific
+// notation.
+func (q XMLQuantity) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "unitCode"}, Value: q.UnitCode})
+	return e.EncodeElement(formatDecimal(q.Value, quantityDecimals), start)
+}
@@ -0,0 +1,39 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuantityPrecision(t *testing.T) {
+	for _, test := range []struct {
+		quantity float64
+		want     string
+	}{
+		{0.0001, "0.0001"},
+		{1000000.5, "1000000.5"},
+		{2.375, "2.375"},
+		{0.1 + 0.2, "0.3"},
+		{12, "12"},
+		{-1.23456, "-1.2346"},
+	} {
+		inv := newTestInvoice()
+		inv.Lines[0].Quantity = test.quantity
+		inv.Lines[0].Price = 0.01
+		xmlBytes := compact(generateAndValidate(t, &inv))
+		want := `<cbc:InvoicedQuantity unitCode="ZZ">` + test.want + `</cbc:InvoicedQuantity>`
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("%v: expected %s in output", test.quantity, want)
+		}
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].Quantity = 2.375
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:CreditedQuantity unitCode="ZZ">2.375</cbc:CreditedQuantity>`) {
+		t.Error("expected the credit note quantity with 3 decimals")
+	}
+}
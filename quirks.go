@@ -0,0 +1,134 @@
+package ubl
+
+import "fmt"
+
+// Quirk is a receiver specific tweak of a generated document, for receivers
+// that require something the standard doesn't. It is applied just before the
+// document is marshalled.
+type Quirk struct {
+	Name  string
+	Apply func(doc *QuirkDocument) error
+}
+
+// ReceiverQuirks holds the quirks per receiver, keyed by the customer Peppol
+// ID (e.g. "0088:5412345000013"). Set it on the ReceiverQuirks field of
+// Invoice or CreditNote; only the quirks of the customer are applied, in
+// order.
+type ReceiverQuirks map[string][]Quirk
+
+// Register adds quirks for the receiver with the given Peppol ID.
+func (rq ReceiverQuirks) Register(endpointID string, quirks ...Quirk) {
+	rq[endpointID] = append(rq[endpointID], quirks...)
+}
+
+// QuirkDocument gives quirks access to the parts of a document they may
+// change.
+type QuirkDocument struct {
+	endpointID string
+	items      []*XMLItem
+	refs       *[]XMLDocumentReference
+}
+
+// QuirkLine is a line of a QuirkDocument.
+type QuirkLine struct {
+	item *XMLItem
+}
+
+func (l QuirkLine) Name() string {
+	return l.item.Name
+}
+
+func (l QuirkLine) Description() string {
+	return l.item.Description
+}
+
+func (l QuirkLine) SetDescription(description string) {
+	l.item.Description = description
+}
+
+// EndpointID returns the Peppol ID of the receiver.
+func (d *QuirkDocument) EndpointID() string {
+	return d.endpointID
+}
+
+func (d *QuirkDocument) Lines() []QuirkLine {
+	lines := make([]QuirkLine, len(d.items))
+	for i, item := range d.items {
+		lines[i] = QuirkLine{item: item}
+	}
+	return lines
+}
+
+// AddDocumentReference adds an AdditionalDocumentReference without
+// attachment.
+func (d *QuirkDocument) AddDocumentReference(id, description string) {
+	*d.refs = append(*d.refs, XMLDocumentReference{ID: XMLIdentifier{Value: id}, DocumentDescription: description})
+}
+
+// applyQuirks applies the quirks registered for the receiver and returns a
+// warning for every quirk applied.
+func applyQuirks(rq ReceiverQuirks, doc *QuirkDocument) ([]string, error) {
+	var warnings []string
+	for _, q := range rq[doc.endpointID] {
+		err := q.Apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("receiver quirk %q: %w", q.Name, err)
+		}
+		warnings = append(warnings, fmt.Sprintf("receiver quirk %q applied for %s", q.Name, doc.endpointID))
+	}
+	return warnings, nil
+}
+
+func (x *XMLInvoice) quirkDocument(endpointID string) *QuirkDocument {
+	doc := &QuirkDocument{endpointID: endpointID, items: make([]*XMLItem, 0, len(x.InvoiceLines)), refs: &x.AdditionalDocumentReference}
+	for i := range x.InvoiceLines {
+		doc.items = append(doc.items, &x.InvoiceLines[i].Item)
+	}
+	return doc
+}
+
+func (x *XMLCreditNote) quirkDocument(endpointID string) *QuirkDocument {
+	doc := &QuirkDocument{endpointID: endpointID, items: make([]*XMLItem, 0, len(x.CreditNoteLines)), refs: &x.AdditionalDocumentReference}
+	for i := range x.CreditNoteLines {
+		doc.items = append(doc.items, &x.CreditNoteLines[i].Item)
+	}
+	return doc
+}
+
+// GTINInDescriptionQuirk repeats the GTIN of the item in the line
+// description, for receivers that don't read cac:StandardItemIdentification.
+// The GTINs are given by item name.
+func GTINInDescriptionQuirk(gtins map[string]string) Quirk {
+	return Quirk{
+		Name: "gtin-in-description",
+		Apply: func(doc *QuirkDocument) error {
+			for _, line := range doc.Lines() {
+				gtin, ok := gtins[line.Name()]
+				if !ok {
+					continue
+				}
+				description := "GTIN " + gtin
+				if line.Description() != "" {
+					description = line.Description() + " " + description
+				}
+				line.SetDescription(description)
+			}
+			return nil
+		},
+	}
+}
+
+// VendorNumberQuirk adds the vendor number the receiver assigned to the
+// supplier as an AdditionalDocumentReference with ID "VENDOR".
+func VendorNumberQuirk(vendorNumber string) Quirk {
+	return Quirk{
+		Name: "vendor-number",
+		Apply: func(doc *QuirkDocument) error {
+			if vendorNumber == "" {
+				return fmt.Errorf("vendor number required")
+			}
+			doc.AddDocumentReference("VENDOR", vendorNumber)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package ubl_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestReceiverQuirks(t *testing.T) {
+	quirks := ubl.ReceiverQuirks{}
+	quirks.Register("9925:BE9876543210",
+		ubl.GTINInDescriptionQuirk(map[string]string{"Product A": "05412345000013"}),
+		ubl.VendorNumberQuirk("V-4711"),
+	)
+	quirks.Register("0088:5412345000099", ubl.VendorNumberQuirk("OTHER"))
+
+	inv := newTestInvoice()
+	inv.ReceiverQuirks = quirks
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	for _, want := range []string{
+		"<cbc:Description>High-quality item GTIN 05412345000013</cbc:Description>",
+		"<cac:AdditionalDocumentReference><cbc:ID>VENDOR</cbc:ID><cbc:DocumentDescription>V-4711</cbc:DocumentDescription></cac:AdditionalDocumentReference>",
+	} {
+		if !strings.Contains(xmlBytes, want) {
+			t.Errorf("expected %s in output", want)
+		}
+	}
+	if strings.Contains(xmlBytes, "OTHER") {
+		t.Error("did not expect the quirk of another receiver")
+	}
+	if inv.Lines[0].Description != "High-quality item" {
+		t.Error("expected the quirks not to change the Invoice")
+	}
+	warnings := strings.Join(inv.Warnings(), "\n")
+	if !strings.Contains(warnings, `receiver quirk "vendor-number" applied for 9925:BE9876543210`) {
+		t.Errorf("expected a warning for the quirk, got %v", warnings)
+	}
+
+	// no quirks for this receiver
+	inv = newTestInvoice()
+	inv.CustomerPeppolID = "9925:BE1111111111"
+	inv.ReceiverQuirks = quirks
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "VENDOR") || strings.Contains(xmlBytes, "GTIN") {
+		t.Error("did not expect quirks for an unregistered receiver")
+	}
+
+	failing := ubl.Quirk{Name: "failing", Apply: func(*ubl.QuirkDocument) error { return errors.New("boom") }}
+	cn := newTestCreditNote()
+	cn.ReceiverQuirks = ubl.ReceiverQuirks{cn.CustomerPeppolID: {failing}}
+	_, err := cn.GenerateCreditNote()
+	if err == nil || err.Error() != `receiver quirk "failing": boom` {
+		t.Errorf("expected the quirk error, got %v", err)
+	}
+}
+
+func TestBeforeMarshal(t *testing.T) {
+	inv := newTestInvoice()
+	inv.ReceiverQuirks = ubl.ReceiverQuirks{inv.CustomerPeppolID: {ubl.VendorNumberQuirk("V-4711")}}
+	inv.BeforeMarshal = func(doc *ubl.XMLInvoice) error {
+		if len(doc.AdditionalDocumentReference) != 1 {
+			t.Error("expected the hook after the receiver quirks")
+		}
+		doc.Note = append(doc.Note, "Delivered to dock 4")
+		return nil
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, "<cbc:InvoiceTypeCode>380</cbc:InvoiceTypeCode><cbc:Note>Delivered to dock 4</cbc:Note><cbc:DocumentCurrencyCode>") {
+		t.Errorf("expected the note after the type code, got %s", xmlBytes)
+	}
+
+	cn := newTestCreditNote()
+	cn.BeforeMarshal = func(doc *ubl.XMLCreditNote) error { return errors.New("boom") }
+	_, err := cn.GenerateCreditNote()
+	if err == nil || err.Error() != "before marshal: boom" {
+		t.Errorf("expected the hook error, got %v", err)
+	}
+}
+
+func ExampleInvoice_BeforeMarshal() {
+	inv := newTestInvoice()
+	inv.BeforeMarshal = func(doc *ubl.XMLInvoice) error {
+		doc.Note = append(doc.Note, "Goods remain our property until paid in full")
+		return nil
+	}
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		panic(err)
+	}
+	for _, line := range strings.Split(string(xmlBytes), "\n") {
+		if strings.Contains(line, "cbc:Note") {
+			fmt.Println(strings.TrimSpace(line))
+		}
+	}
+	// Output:
+	// <cbc:Note>Goods remain our property until paid in full</cbc:Note>
+}
@@ -0,0 +1,71 @@
+package ubl_test
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+// reproducibleInvoice returns an invoice with everything that could vary
+// between runs: several VAT categories and rates, allowances, attachments,
+// extensions and a clock outside UTC.
+func reproducibleInvoice(t *testing.T) ubl.Invoice {
+	inv := newTestInvoice()
+	inv.UUID = "8a3b5c2e-1f4d-4e6a-9b7c-0d2e4f6a8b1c"
+	inv.Now = func() time.Time { return time.Date(2025, 3, 10, 23, 30, 0, 0, time.FixedZone("CET", 3600)) }
+	inv.Lines = append(inv.Lines,
+		ubl.InvoiceLine{Quantity: 3, Price: 19.99, TaxPercentage: 6, Name: "Books"},
+		ubl.InvoiceLine{Quantity: 1.125, UnitCode: "KGM", Price: 7.3333, TaxPercentage: 12, Name: "Catering"},
+		ubl.InvoiceLine{Quantity: 2, Price: 45, TaxCategoryID: "Z", Name: "Export packaging"},
+		ubl.InvoiceLine{Quantity: 1, Price: 250, TaxCategoryID: "E", TaxExemptionReason: "Exempt under article 44", Name: "Training"},
+		ubl.InvoiceLine{Quantity: 4, Price: 12.5, TaxPercentage: 6, Name: "Magazines"},
+	)
+	inv.AllowanceCharges = []ubl.AllowanceCharge{
+		{Reason: "Volume discount", Amount: 25, TaxPercentage: 21},
+		{Reason: "Loyalty discount", Amount: 5, TaxPercentage: 6},
+	}
+	inv.Extensions = []ubl.Extension{{ID: "routing", Content: []byte(testExtension)}}
+	for _, a := range []struct{ name, data string }{
+		{"timesheet.pdf", "%PDF-1.4 timesheet"},
+		{"site.png", "\x89PNG\r\n\x1a\n"},
+		{"delivery.pdf", "%PDF-1.4 delivery note"},
+	} {
+		if err := inv.AddAttachmentFromBytes([]byte(a.data), a.name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return inv
+}
+
+func TestReproducible(t *testing.T) {
+	hashes := map[[32]byte]int{}
+	for range 50 {
+		inv := reproducibleInvoice(t)
+		hashes[sha256.Sum256(must(inv.Generate()))]++
+	}
+	if len(hashes) != 1 {
+		t.Errorf("expected the same bytes for the same invoice, got %d different documents", len(hashes))
+	}
+
+	// the same value, generated again
+	inv := reproducibleInvoice(t)
+	first := sha256.Sum256(generateAndValidate(t, &inv))
+	for range 50 {
+		if sha256.Sum256(must(inv.Generate())) != first {
+			t.Fatal("expected the same bytes when generating the invoice again")
+		}
+	}
+
+	hashes = map[[32]byte]int{}
+	for range 50 {
+		cn := newTestCreditNote()
+		cn.Now = inv.Now
+		cn.Lines = append(cn.Lines, ubl.InvoiceLine{Quantity: 2, Price: 19.99, TaxPercentage: 6, Name: "Books"}, ubl.InvoiceLine{Quantity: 1, Price: 45, TaxCategoryID: "Z", Name: "Export packaging"})
+		hashes[sha256.Sum256(must(cn.GenerateCreditNote()))]++
+	}
+	if len(hashes) != 1 {
+		t.Errorf("expected the same bytes for the same credit note, got %d different documents", len(hashes))
+	}
+}
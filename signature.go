@@ -0,0 +1,501 @@
+package ubl
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The namespaces of an enveloped XAdES signature in UBL.
+const (
+	dsNamespace    = "http://www.w3.org/2000/09/xmldsig#"
+	xadesNamespace = "http://uri.etsi.org/01903/v1.3.2#"
+	sigNamespace   = "urn:oasis:names:specification:ubl:schema:xsd:CommonSignatureComponents-2"
+	sacNamespace   = "urn:oasis:names:specification:ubl:schema:xsd:SignatureAggregateComponents-2"
+	sbcNamespace   = "urn:oasis:names:specification:ubl:schema:xsd:SignatureBasicComponents-2"
+)
+
+// The identifiers and algorithms of the signature.
+const (
+	xadesExtensionURI    = "urn:oasis:names:specification:ubl:dsig:enveloped:xades"
+	excC14NAlgorithm     = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedAlgorithm   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	sha256Algorithm      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	rsaSHA256Algorithm   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	ecdsaSHA256Algorithm = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	signedPropertiesType = "http://uri.etsi.org/01903#SignedProperties"
+)
+
+// dsPrefixes are the prefixes of the signature namespaces in the struct tags.
+var dsPrefixes = map[string]string{
+	dsNamespace:    "ds",
+	xadesNamespace: "xades",
+}
+
+// Sign generates the invoice like Generate with an enveloped XAdES-BES
+// signature made with cert, as some national platforms require. The
+// ds:Signature sits in an extension, referred to by cac:Signature: it signs
+// the document in Exclusive XML Canonicalization form and the XAdES
+// SignedProperties, with the signing time from Now and the certificate.
+// Keys are RSA or ECDSA, with SHA-256. Any change to the output, its
+// encoding included, breaks the signature. RSA signatures are reproducible
+// like Generate; ECDSA signatures are randomized, so only the signed content
+// is. Peppol BIS doesn't allow signatures.
+func (inv *Invoice) Sign(cert tls.Certificate) ([]byte, error) {
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		return nil, err
+	}
+	return signDocument(doc, cert, inv.now())
+}
+
+// SignCreditNote generates the credit note with an enveloped XAdES-BES
+// signature, like Invoice.Sign.
+func (cn *CreditNote) SignCreditNote(cert tls.Certificate) ([]byte, error) {
+	doc, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return nil, err
+	}
+	return signDocument(doc, cert, cn.now())
+}
+
+// signDocument adds the signature extension to the document and signs it.
+func signDocument(doc *Document, cert tls.Certificate, now time.Time) ([]byte, error) {
+	leaf, signer, method, err := signingKey(cert)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	if enc := strings.ToUpper(doc.XMLDeclaration.Encoding); enc != "" && enc != "UTF-8" {
+		return nil, fmt.Errorf("sign: encoding %s not supported, signed documents are UTF-8", doc.XMLDeclaration.Encoding)
+	}
+
+	// the document is marshalled with the extension and without the
+	// ds:Signature, as the enveloped signature transform sees it, and the
+	// signature is inserted in the output
+	name := "Invoice"
+	if doc.CreditNote != nil {
+		name = "CreditNote"
+	}
+	signatureID := "urn:oasis:names:specification:ubl:signature:" + name
+	extension := XMLUBLExtension{
+		ExtensionURI: xadesExtensionURI,
+		ExtensionContent: XMLExtensionContent{Content: []byte(`<sig:UBLDocumentSignatures xmlns:sig="` + sigNamespace +
+			`" xmlns:sac="` + sacNamespace + `" xmlns:sbc="` + sbcNamespace + `"><sac:SignatureInformation>` +
+			`<cbc:ID xmlns:cbc="` + cbcNamespace + `">urn:oasis:names:specification:ubl:signature:1</cbc:ID>` +
+			`<sbc:ReferencedSignatureID>` + signatureID + `</sbc:ReferencedSignatureID>` +
+			`</sac:SignatureInformation></sig:UBLDocumentSignatures>`)},
+	}
+	signature := XMLSignature{ID: signatureID, SignatureMethod: xadesExtensionURI}
+	if x := doc.Invoice; x != nil {
+		x.UBLExtensions = withExtension(x.UBLExtensions, extension)
+		x.Signature = append(x.Signature, signature)
+	}
+	if x := doc.CreditNote; x != nil {
+		x.UBLExtensions = withExtension(x.UBLExtensions, extension)
+		x.Signature = append(x.Signature, signature)
+	}
+	data, err := doc.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	at, err := signatureOffset(data)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	documentDigest, err := canonicalDigest(data, c14nOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+
+	certDigest := sha256.Sum256(leaf.Raw)
+	sig := dsSignature{
+		Ds: dsNamespace,
+		ID: "signature",
+		SignedInfo: dsSignedInfo{
+			Ds:                     dsNamespace,
+			CanonicalizationMethod: dsAlgorithm{excC14NAlgorithm},
+			SignatureMethod:        dsAlgorithm{method},
+		},
+		Certificate: base64.StdEncoding.EncodeToString(leaf.Raw),
+		Object: &dsObject{QualifyingProperties: xadesQualifyingProperties{
+			Xades:  xadesNamespace,
+			Target: "#signature",
+			SignedProperties: xadesSignedProperties{
+				Xades:       xadesNamespace,
+				Ds:          dsNamespace,
+				ID:          "xadesSignedProperties",
+				SigningTime: now.UTC().Format(time.RFC3339),
+				Cert: xadesCert{
+					DigestMethod: dsAlgorithm{sha256Algorithm},
+					DigestValue:  base64.StdEncoding.EncodeToString(certDigest[:]),
+					IssuerName:   leaf.Issuer.String(),
+					SerialNumber: leaf.SerialNumber.String(),
+				},
+			},
+		}},
+	}
+	properties, err := xml.Marshal(sig.Object.QualifyingProperties.SignedProperties)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	propertiesDigest, err := canonicalDigest(properties, c14nOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	sig.SignedInfo.Reference = []dsReference{{
+		ID:           "documentSignedData",
+		Transforms:   []dsAlgorithm{{envelopedAlgorithm}, {excC14NAlgorithm}},
+		DigestMethod: dsAlgorithm{sha256Algorithm},
+		DigestValue:  base64.StdEncoding.EncodeToString(documentDigest),
+	}, {
+		Type:         signedPropertiesType,
+		URI:          "#xadesSignedProperties",
+		Transforms:   []dsAlgorithm{{excC14NAlgorithm}},
+		DigestMethod: dsAlgorithm{sha256Algorithm},
+		DigestValue:  base64.StdEncoding.EncodeToString(propertiesDigest),
+	}}
+
+	signedInfo, err := xml.Marshal(sig.SignedInfo)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	var buf bytes.Buffer
+	err = canonicalize(&buf, bytes.NewReader(signedInfo), c14nOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	value, err := signatureValue(signer, buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	sig.SignatureValue = base64.StdEncoding.EncodeToString(value)
+
+	signed, err := xml.Marshal(sig)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	return slices.Concat(data[:at], signed, data[at:]), nil
+}
+
+// signingKey returns the certificate and private key of cert with the
+// signature method of the key.
+func signingKey(cert tls.Certificate) (*x509.Certificate, crypto.Signer, string, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, nil, "", errors.New("no certificate")
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, "", errors.New("no private key")
+	}
+	var method string
+	switch key := signer.Public().(type) {
+	case *rsa.PublicKey:
+		method = rsaSHA256Algorithm
+	case *ecdsa.PublicKey:
+		method = ecdsaSHA256Algorithm
+	default:
+		return nil, nil, "", fmt.Errorf("%T keys not supported", key)
+	}
+	if !signer.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(leaf.PublicKey) {
+		return nil, nil, "", errors.New("the private key doesn't match the certificate")
+	}
+	return leaf, signer, method, nil
+}
+
+// signatureValue signs the SHA-256 digest of the canonical SignedInfo. An
+// ECDSA signature is r and s of the size of the key, as XML signatures
+// write it.
+func signatureValue(signer crypto.Signer, signedInfo []byte) ([]byte, error) {
+	digest := sha256.Sum256(signedInfo)
+	value, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return value, nil
+	}
+	var rs struct{ R, S *big.Int }
+	_, err = asn1.Unmarshal(value, &rs)
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return append(rs.R.FillBytes(make([]byte, size)), rs.S.FillBytes(make([]byte, size))...), nil
+}
+
+// withExtension returns the extensions with e added.
+func withExtension(extensions *XMLUBLExtensions, e XMLUBLExtension) *XMLUBLExtensions {
+	if extensions == nil {
+		extensions = &XMLUBLExtensions{}
+	}
+	extensions.UBLExtension = append(extensions.UBLExtension, e)
+	return extensions
+}
+
+// signatureOffset returns where the ds:Signature goes in the marshalled
+// document: at the end of the last sac:SignatureInformation of the
+// extensions, the one signDocument added.
+func signatureOffset(data []byte) (int, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	at := -1
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return 0, errors.New("no signature extension")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if end, ok := tok.(xml.EndElement); ok {
+			switch end.Name {
+			case xml.Name{Space: sacNamespace, Local: "SignatureInformation"}:
+				at = int(offset)
+			case xml.Name{Space: extNamespace, Local: "UBLExtensions"}:
+				if at < 0 {
+					return 0, errors.New("no signature extension")
+				}
+				return at, nil
+			}
+		}
+	}
+}
+
+// canonicalDigest returns the SHA-256 digest of data in Exclusive XML
+// Canonicalization form.
+func canonicalDigest(data []byte, opts c14nOptions) ([]byte, error) {
+	h := sha256.New()
+	err := canonicalize(h, bytes.NewReader(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// VerifySignature checks the enveloped XML signature of a document signed
+// with Sign: the digests of the document and the XAdES SignedProperties,
+// the signature value with the key of the certificate in ds:KeyInfo, and
+// that the XAdES signing certificate is that certificate. It checks the
+// first ds:Signature of the document and returns that certificate.
+//
+// A nil error only means the document wasn't changed since it was signed
+// with the key of the returned certificate, which the document brings
+// itself: anyone can sign with a certificate of their own. Check the
+// certificate against the trusted roots, e.g. with cert.Verify, before
+// trusting the signer.
+func VerifySignature(data []byte) (*x509.Certificate, error) {
+	sig, err := readSignature(data)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	der, err := base64.StdEncoding.DecodeString(withoutSpace(sig.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("signature: certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("signature: certificate: %w", err)
+	}
+
+	signedInfo := sig.SignedInfo
+	if signedInfo.CanonicalizationMethod.Algorithm != excC14NAlgorithm {
+		return nil, fmt.Errorf("signature: canonicalization %s not supported", signedInfo.CanonicalizationMethod.Algorithm)
+	}
+	var document bool
+	for _, ref := range signedInfo.Reference {
+		if ref.DigestMethod.Algorithm != sha256Algorithm {
+			return nil, fmt.Errorf("signature: reference %q: digest %s not supported", ref.URI, ref.DigestMethod.Algorithm)
+		}
+		var opts c14nOptions
+		var transforms []dsAlgorithm
+		switch {
+		case ref.URI == "":
+			// the enveloped signature transform leaves out the signature
+			var seen bool
+			opts.exclude = func(name xml.Name, _ []xml.Attr) bool {
+				if seen || name != (xml.Name{Space: dsNamespace, Local: "Signature"}) {
+					return false
+				}
+				seen = true
+				return true
+			}
+			transforms = []dsAlgorithm{{envelopedAlgorithm}, {excC14NAlgorithm}}
+			document = true
+		case strings.HasPrefix(ref.URI, "#"):
+			id := ref.URI[1:]
+			opts.apex = func(_ xml.Name, attrs []xml.Attr) bool {
+				return slices.Contains(attrs, xml.Attr{Name: xml.Name{Local: "Id"}, Value: id})
+			}
+			transforms = []dsAlgorithm{{excC14NAlgorithm}}
+		default:
+			return nil, fmt.Errorf("signature: reference %q not supported", ref.URI)
+		}
+		if !slices.Equal(ref.Transforms, transforms) {
+			return nil, fmt.Errorf("signature: reference %q: transforms %v not supported", ref.URI, ref.Transforms)
+		}
+		digest, err := canonicalDigest(data, opts)
+		if err != nil {
+			return nil, fmt.Errorf("signature: reference %q: %w", ref.URI, err)
+		}
+		if base64.StdEncoding.EncodeToString(digest) != withoutSpace(ref.DigestValue) {
+			return nil, fmt.Errorf("signature: reference %q: digest mismatch, the signed data changed", ref.URI)
+		}
+	}
+	if !document {
+		return nil, errors.New("signature: the document itself isn't signed")
+	}
+
+	canonical, err := canonicalDigest(data, c14nOptions{apex: func(name xml.Name, _ []xml.Attr) bool {
+		return name == xml.Name{Space: dsNamespace, Local: "SignedInfo"}
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	value, err := base64.StdEncoding.DecodeString(withoutSpace(sig.SignatureValue))
+	if err != nil {
+		return nil, fmt.Errorf("signature: value: %w", err)
+	}
+	err = verifySignatureValue(cert.PublicKey, signedInfo.SignatureMethod.Algorithm, canonical, value)
+	if err != nil {
+		return nil, fmt.Errorf("signature: value: %w", err)
+	}
+
+	if sig.Object != nil {
+		signing := sig.Object.QualifyingProperties.SignedProperties.Cert
+		certDigest := sha256.Sum256(cert.Raw)
+		if signing.DigestMethod.Algorithm != sha256Algorithm || withoutSpace(signing.DigestValue) != base64.StdEncoding.EncodeToString(certDigest[:]) {
+			return nil, errors.New("signature: the signing certificate isn't the certificate of the signature")
+		}
+	}
+	return cert, nil
+}
+
+// verifySignatureValue checks the signature value over the SHA-256 digest
+// of the canonical SignedInfo.
+func verifySignatureValue(key crypto.PublicKey, method string, digest, value []byte) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if method != rsaSHA256Algorithm {
+			break
+		}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, value)
+	case *ecdsa.PublicKey:
+		if method != ecdsaSHA256Algorithm {
+			break
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(value) != 2*size || !ecdsa.Verify(key, digest, new(big.Int).SetBytes(value[:size]), new(big.Int).SetBytes(value[size:])) {
+			return errors.New("ecdsa verification error")
+		}
+		return nil
+	}
+	return fmt.Errorf("signature method %s not supported for %T keys", method, key)
+}
+
+// readSignature reads the first ds:Signature of the document.
+func readSignature(data []byte) (*dsSignature, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("no ds:Signature in the document")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name == (xml.Name{Space: dsNamespace, Local: "Signature"}) {
+			var sig dsSignature
+			tokens := &prefixedTokens{dec: dec, start: &start, prefixes: dsPrefixes}
+			err = xml.NewTokenDecoder(tokens).Decode(&sig)
+			return &sig, err
+		}
+	}
+}
+
+// withoutSpace removes the line breaks base64 values may have.
+func withoutSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// dsSignature is ds:Signature, with the XAdES properties Sign writes.
+type dsSignature struct {
+	XMLName        xml.Name     `xml:"ds:Signature"`
+	Ds             string       `xml:"xmlns:ds,attr"`
+	ID             string       `xml:"Id,attr,omitempty"`
+	SignedInfo     dsSignedInfo `xml:"ds:SignedInfo"`
+	SignatureValue string       `xml:"ds:SignatureValue"`
+	Certificate    string       `xml:"ds:KeyInfo>ds:X509Data>ds:X509Certificate"`
+	Object         *dsObject    `xml:"ds:Object,omitempty"`
+}
+
+type dsSignedInfo struct {
+	XMLName                xml.Name      `xml:"ds:SignedInfo"`
+	Ds                     string        `xml:"xmlns:ds,attr"`
+	CanonicalizationMethod dsAlgorithm   `xml:"ds:CanonicalizationMethod"`
+	SignatureMethod        dsAlgorithm   `xml:"ds:SignatureMethod"`
+	Reference              []dsReference `xml:"ds:Reference"`
+}
+
+// dsAlgorithm is an element that names an algorithm, e.g. ds:DigestMethod.
+type dsAlgorithm struct {
+	Algorithm string `xml:"Algorithm,attr"`
+}
+
+type dsReference struct {
+	ID           string        `xml:"Id,attr,omitempty"`
+	Type         string        `xml:"Type,attr,omitempty"`
+	URI          string        `xml:"URI,attr"`
+	Transforms   []dsAlgorithm `xml:"ds:Transforms>ds:Transform"`
+	DigestMethod dsAlgorithm   `xml:"ds:DigestMethod"`
+	DigestValue  string        `xml:"ds:DigestValue"`
+}
+
+type dsObject struct {
+	QualifyingProperties xadesQualifyingProperties `xml:"xades:QualifyingProperties"`
+}
+
+type xadesQualifyingProperties struct {
+	Xades            string                `xml:"xmlns:xades,attr"`
+	Target           string                `xml:"Target,attr"`
+	SignedProperties xadesSignedProperties `xml:"xades:SignedProperties"`
+}
+
+type xadesSignedProperties struct {
+	XMLName     xml.Name  `xml:"xades:SignedProperties"`
+	Xades       string    `xml:"xmlns:xades,attr"`
+	Ds          string    `xml:"xmlns:ds,attr"`
+	ID          string    `xml:"Id,attr"`
+	SigningTime string    `xml:"xades:SignedSignatureProperties>xades:SigningTime"`
+	Cert        xadesCert `xml:"xades:SignedSignatureProperties>xades:SigningCertificate>xades:Cert"`
+}
+
+type xadesCert struct {
+	DigestMethod dsAlgorithm `xml:"xades:CertDigest>ds:DigestMethod"`
+	DigestValue  string      `xml:"xades:CertDigest>ds:DigestValue"`
+	IssuerName   string      `xml:"xades:IssuerSerial>ds:X509IssuerName"`
+	SerialNumber string      `xml:"xades:IssuerSerial>ds:X509SerialNumber"`
+}
@@ -0,0 +1,171 @@
+package ubl_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+// testCertificate returns a self-signed certificate for key.
+func testCertificate(t *testing.T, key crypto.Signer) tls.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(4217),
+		Subject:      pkix.Name{CommonName: "ABC Supplies Ltd", Country: []string{"BE"}},
+		NotBefore:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testCertificate(t, rsaKey)
+	inv := newTestInvoice()
+	inv.Now = func() time.Time { return time.Date(2025, 3, 10, 14, 30, 0, 0, time.FixedZone("CET", 3600)) }
+	if err := inv.AddAttachmentFromBytes(bytes.Repeat([]byte("%PDF-1.4 "), 10000), "timesheet.pdf", "Timesheet"); err != nil {
+		t.Fatal(err)
+	}
+	inv.Extensions = []ubl.Extension{{ID: "routing", Content: []byte(testExtension)}}
+
+	signed, err := inv.Sign(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	if err := v.ValidateBytes(signed); err != nil {
+		t.Errorf("%v in %s", err, signed[:4096])
+	}
+	signer, err := ubl.VerifySignature(signed)
+	if err != nil {
+		t.Error(err)
+	} else if !signer.Equal(cert.Leaf) {
+		t.Errorf("expected the signing certificate, got %v", signer.Subject)
+	}
+	// a valid signature doesn't make the signer trusted
+	roots := x509.NewCertPool()
+	if _, err := signer.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: inv.Now()}); err == nil {
+		t.Error("expected the certificate not to be trusted without its root")
+	}
+	roots.AddCert(cert.Leaf)
+	if _, err := signer.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: inv.Now()}); err != nil {
+		t.Errorf("expected the certificate to be trusted with its root, got %v", err)
+	}
+	for _, s := range []string{
+		"<cac:Signature><cbc:ID>urn:oasis:names:specification:ubl:signature:Invoice</cbc:ID>",
+		"<ext:ExtensionURI>urn:oasis:names:specification:ubl:dsig:enveloped:xades</ext:ExtensionURI>",
+		"<xades:SigningTime>2025-03-10T13:30:00Z</xades:SigningTime>",
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256">`,
+	} {
+		if !strings.Contains(compact(signed), s) {
+			t.Errorf("expected %s in output", s)
+		}
+	}
+	if again := must(inv.Sign(cert)); !bytes.Equal(again, signed) {
+		t.Error("expected the same signed invoice for the same invoice")
+	}
+
+	for _, c := range []struct {
+		name, old, new, want string
+	}{
+		{"amount", "<cbc:PayableAmount currencyID=\"EUR\">1210.00<", "<cbc:PayableAmount currencyID=\"EUR\">12100.00<", `signature: reference "": digest mismatch, the signed data changed`},
+		{"signing time", "2025-03-10T13:30:00Z", "2025-03-11T13:30:00Z", `signature: reference "#xadesSignedProperties": digest mismatch, the signed data changed`},
+		{"reference", `URI="#xadesSignedProperties"`, `URI="#xadesSignedProperties" Id="x"`, "signature: value: crypto/rsa: verification error"},
+	} {
+		tampered := bytes.Replace(signed, []byte(c.old), []byte(c.new), 1)
+		if bytes.Equal(tampered, signed) {
+			t.Fatalf("%s: %s not in the output", c.name, c.old)
+		}
+		signer, err := ubl.VerifySignature(tampered)
+		if err == nil || err.Error() != c.want || signer != nil {
+			t.Errorf("%s: expected %q, got %v", c.name, c.want, err)
+		}
+	}
+	if _, err := ubl.VerifySignature(must(inv.Generate())); err == nil || err.Error() != "signature: no ds:Signature in the document" {
+		t.Errorf("expected an error for an unsigned invoice, got %v", err)
+	}
+}
+
+func TestSignCreditNote(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn := newTestCreditNote()
+	cn.Namespaces = ubl.XMLNamespaces{Document: "cn", CAC: "a", CBC: "b"}
+	signed, err := cn.SignCreditNote(testCertificate(t, ecKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+	if err := v.ValidateBytes(signed); err != nil {
+		t.Errorf("%v in %s", err, signed)
+	}
+	if _, err := ubl.VerifySignature(signed); err != nil {
+		t.Error(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := testCertificate(t, ecKey)
+	mismatched.PrivateKey = otherKey
+	cn.Namespaces = ubl.XMLNamespaces{}
+	for _, c := range []struct {
+		cert tls.Certificate
+		want string
+	}{
+		{testCertificate(t, edKey), "sign: ed25519.PublicKey keys not supported"},
+		{mismatched, "sign: the private key doesn't match the certificate"},
+		{tls.Certificate{}, "sign: no certificate"},
+	} {
+		_, err := cn.SignCreditNote(c.cert)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("expected %q, got %v", c.want, err)
+		}
+	}
+	cn.XMLDeclaration = ubl.XMLDeclaration{Encoding: "ISO-8859-1"}
+	_, err = cn.SignCreditNote(testCertificate(t, ecKey))
+	if err == nil || err.Error() != "sign: encoding ISO-8859-1 not supported, signed documents are UTF-8" {
+		t.Errorf("expected an error for the encoding, got %v", err)
+	}
+}
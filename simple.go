@@ -0,0 +1,109 @@
+package ubl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Party bundles the details of a supplier or customer for SimpleInvoice.
+type Party struct {
+	Name     string
+	Vat      string
+	Address  Address
+	PeppolID string // "<scheme>:<value>", e.g. "9925:BE0123456789"
+}
+
+// SimpleLine is a single invoice line for SimpleInvoice.
+type SimpleLine struct {
+	Name       string
+	Quantity   float64
+	Price      float64
+	VatPercent float64
+}
+
+// SimpleInvoice creates an Invoice for the simplest B2B case: one supplier,
+// one customer, a few lines and payment by credit transfer to iban.
+//
+// The Peppol BIS Billing 3.0 customization and profile are used, lines with a
+// VAT percentage get category S (standard rated) and lines without one get
+// category Z (zero rated). All problems with the input are reported together
+// in the returned error.
+func SimpleInvoice(id string, supplier, customer Party, lines []SimpleLine, iban string) (*Invoice, error) {
+	var errs []error
+	if strings.TrimSpace(id) == "" {
+		errs = append(errs, errors.New("id: required"))
+	}
+	errs = append(errs, supplier.check("supplier")...)
+	errs = append(errs, customer.check("customer")...)
+	if len(lines) == 0 {
+		errs = append(errs, errors.New("lines: at least one line required"))
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line.Name) == "" {
+			errs = append(errs, fmt.Errorf("lines[%d].Name: required", i))
+		}
+		if line.Quantity == 0 {
+			errs = append(errs, fmt.Errorf("lines[%d].Quantity: must not be zero", i))
+		}
+		if line.VatPercent < 0 || line.VatPercent > 100 {
+			errs = append(errs, fmt.Errorf("lines[%d].VatPercent: %v is not between 0 and 100", i, line.VatPercent))
+		}
+	}
+	if strings.TrimSpace(iban) == "" {
+		errs = append(errs, errors.New("iban: required"))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("simple invoice: %w", errors.Join(errs...))
+	}
+
+	inv := &Invoice{
+		ID:               id,
+		CustomizationID:  PeppolBilling30CustomizationID,
+		ProfileID:        PeppolBilling30ProfileID,
+		SupplierName:     supplier.Name,
+		SupplierVat:      supplier.Vat,
+		SupplierPeppolID: supplier.PeppolID,
+		SupplierAddress:  supplier.Address,
+		CustomerName:     customer.Name,
+		CustomerVat:      customer.Vat,
+		CustomerPeppolID: customer.PeppolID,
+		CustomerAddress:  customer.Address,
+		Iban:             iban,
+	}
+
+	for _, line := range lines {
+		l := InvoiceLine{
+			Name:          line.Name,
+			Quantity:      line.Quantity,
+			Price:         line.Price,
+			TaxPercentage: line.VatPercent,
+			TaxCategoryID: "S",
+		}
+		if line.VatPercent == 0 {
+			l.TaxCategoryID = "Z"
+			l.TaxCategoryName = "Zero rated"
+		}
+		inv.Lines = append(inv.Lines, l)
+	}
+
+	return inv, nil
+}
+
+func (p Party) check(prefix string) []error {
+	var errs []error
+	if strings.TrimSpace(p.Name) == "" {
+		errs = append(errs, fmt.Errorf("%s.Name: required", prefix))
+	}
+	if strings.TrimSpace(p.Vat) == "" {
+		errs = append(errs, fmt.Errorf("%s.Vat: required", prefix))
+	}
+	if len(p.Address.CountryCode) != 2 {
+		errs = append(errs, fmt.Errorf("%s.Address.CountryCode: two letter ISO 3166-1 code required, got %q", prefix, p.Address.CountryCode))
+	}
+	_, err := endpointID(prefix+".PeppolID", p.PeppolID, "", "")
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,60 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestSimpleInvoice(t *testing.T) {
+	supplier := ubl.Party{Name: "ABC Supplies Ltd", Vat: "BE0123456789", PeppolID: "9925:BE0123456789", Address: ubl.Address{StreetName: "123 Supplier Street", CityName: "Supplier City", PostalZone: "12345", CountryCode: "BE"}}
+	customer := ubl.Party{Name: "XYZ Corp", Vat: "BE9876543210", PeppolID: "9925:BE9876543210", Address: ubl.Address{CountryCode: "BE"}}
+	lines := []ubl.SimpleLine{{Name: "Product A", Quantity: 10, Price: 100, VatPercent: 21}, {Name: "Product B", Quantity: 1, Price: 50}}
+
+	inv, err := ubl.SimpleInvoice("INV-1", supplier, customer, lines, "BE71096123456769")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	err = v.ValidateBytes(xmlBytes)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimpleInvoiceMissingFields(t *testing.T) {
+	_, err := ubl.SimpleInvoice("", ubl.Party{Name: "ABC Supplies Ltd"}, ubl.Party{}, nil, "")
+	if err == nil {
+		t.Fatal("expected an error but did not receive one")
+	}
+
+	for _, want := range []string{
+		"id: required",
+		"supplier.Vat: required",
+		"supplier.Address.CountryCode",
+		"supplier.PeppolID",
+		"customer.Name: required",
+		"lines: at least one line required",
+		"iban: required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "supplier.Name") {
+		t.Errorf("did not expect an error for supplier.Name, got %v", err)
+	}
+}
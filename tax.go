@@ -0,0 +1,195 @@
+package ubl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// vatex are the VAT exemption reason codes (VATEX).
+var vatex = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		VATEX-EU-79-C VATEX-EU-132 VATEX-EU-132-1A VATEX-EU-132-1B
+		VATEX-EU-132-1C VATEX-EU-132-1D VATEX-EU-132-1E VATEX-EU-132-1F
+		VATEX-EU-132-1G VATEX-EU-132-1H VATEX-EU-132-1I VATEX-EU-132-1J
+		VATEX-EU-132-1K VATEX-EU-132-1L VATEX-EU-132-1M VATEX-EU-132-1N
+		VATEX-EU-132-1O VATEX-EU-132-1P VATEX-EU-132-1Q VATEX-EU-143
+		VATEX-EU-143-1A VATEX-EU-143-1B VATEX-EU-143-1C VATEX-EU-143-1D
+		VATEX-EU-143-1E VATEX-EU-143-1F VATEX-EU-143-1FA VATEX-EU-143-1G
+		VATEX-EU-143-1H VATEX-EU-143-1I VATEX-EU-143-1J VATEX-EU-143-1K
+		VATEX-EU-143-1L VATEX-EU-148 VATEX-EU-148-A VATEX-EU-148-B
+		VATEX-EU-148-C VATEX-EU-148-D VATEX-EU-148-E VATEX-EU-148-F
+		VATEX-EU-148-G VATEX-EU-151 VATEX-EU-151-1A VATEX-EU-151-1AA
+		VATEX-EU-151-1B VATEX-EU-151-1C VATEX-EU-151-1D VATEX-EU-151-1E
+		VATEX-EU-309 VATEX-EU-AE VATEX-EU-D VATEX-EU-F VATEX-EU-G VATEX-EU-I
+		VATEX-EU-IC VATEX-EU-J VATEX-EU-O VATEX-FR-FRANCHISE VATEX-FR-CNWVAT`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// exemptCategories are the tax categories that carry a VAT exemption reason
+// (BR-E-10, BR-AE-10, BR-IC-10, BR-G-10, BR-O-10). The other categories
+// must not have one (e.g. BR-S-10, BR-Z-10).
+var exemptCategories = map[string]bool{"E": true, "AE": true, "K": true, "G": true, "O": true}
+
+// zeroRateCategories are the tax categories that never charge VAT.
+var zeroRateCategories = map[string]bool{"K": true, "AE": true, "G": true, "O": true}
+
+// taxExemptionDefaults are the exemption reason code and text used for the
+// categories that have a usual one. Exempt supplies (E) fall under many
+// articles, so they only get a generic text to meet BR-E-10.
+var taxExemptionDefaults = map[string]struct{ code, reason string }{
+	"E":  {"", "Exempt from VAT"},
+	"K":  {"VATEX-EU-IC", "Intra-community supply"},
+	"AE": {"VATEX-EU-AE", "Reverse charge"},
+	"G":  {"VATEX-EU-G", "Export outside the EU"},
+	"O":  {"VATEX-EU-O", "Not subject to VAT"},
+}
+
+// taxCategory returns the tax category of the line with the defaults
+// applied: category S, rate 0 for the categories without VAT and the usual
+// exemption reason.
+func (line InvoiceLine) taxCategory() XMLTaxCategory {
+	id := cmp.Or(line.TaxCategoryID, "S")
+	rate := line.TaxPercentage
+	if zeroRateCategories[id] {
+		rate = 0
+	}
+	cat := XMLTaxCategory{
+		ID:        id,
+		Name:      cmp.Or(line.TaxCategoryName, "Standard rated"),
+		Percent:   taxPercent(id, rate),
+		TaxScheme: XMLTaxScheme{ID: "VAT"},
+	}
+	if exemptCategories[cat.ID] {
+		defaults := taxExemptionDefaults[cat.ID]
+		cat.TaxExemptionReasonCode = cmp.Or(line.TaxExemptionCode, defaults.code)
+		cat.TaxExemptionReason = cmp.Or(line.TaxExemptionReason, defaults.reason)
+	}
+	return cat
+}
+
+// taxPercent returns the cbc:Percent of a tax category, or nil for category
+// O: services outside the scope of VAT have no rate (BR-O-05, BR-O-09).
+func taxPercent(categoryID string, rate float64) *float64 {
+	if categoryID == "O" {
+		return nil
+	}
+	return &rate
+}
+
+// rate returns the VAT rate of the category, 0 when it has none.
+func (c XMLTaxCategory) rate() float64 {
+	if c.Percent == nil {
+		return 0
+	}
+	return *c.Percent
+}
+
+// checkTaxExemption checks the exemption reason of the line against its tax
+// category and the VATEX code list.
+func (line InvoiceLine) checkTaxExemption() error {
+	cat := line.taxCategory()
+	if !exemptCategories[cat.ID] {
+		if line.TaxExemptionCode != "" || line.TaxExemptionReason != "" {
+			return fmt.Errorf("no exemption reason allowed for tax category %s", cat.ID)
+		}
+		return nil
+	}
+	if cat.TaxExemptionReasonCode != "" && !vatex[cat.TaxExemptionReasonCode] {
+		return fmt.Errorf("exemption code %q: not in the VATEX code list", cat.TaxExemptionReasonCode)
+	}
+	return nil
+}
+
+// checkExemptionReasons reports the lines with another exemption reason than
+// an earlier line of the same category and rate: the VAT breakdown has one
+// reason per category and rate, that of the first line. They are warnings,
+// or an error when required.
+func checkExemptionReasons(lines []InvoiceLine, require bool) ([]string, error) {
+	firstLines := map[taxKey]int{}
+	var warnings []string
+	var errs []error
+	for i, line := range lines {
+		cat := line.taxCategory()
+		key := taxKey{Rate: cat.rate(), CategoryID: cat.ID}
+		first, ok := firstLines[key]
+		if !ok {
+			firstLines[key] = i
+			continue
+		}
+		firstCat := lines[first].taxCategory()
+		if firstCat.TaxExemptionReasonCode == cat.TaxExemptionReasonCode && firstCat.TaxExemptionReason == cat.TaxExemptionReason {
+			continue
+		}
+		msg := fmt.Sprintf("lines %d and %d: tax category %s at %v%% with different exemption reasons", first+1, i+1, cat.ID, cat.rate())
+		warnings = append(warnings, msg+", the VAT breakdown uses the first")
+		errs = append(errs, errors.New(msg))
+	}
+	if require {
+		return nil, errors.Join(errs...)
+	}
+	return warnings, nil
+}
+
+// categoryUse returns the first line or allowance/charge with the given tax
+// category, e.g. "Lines[2]", or "" when the document doesn't use it.
+func categoryUse(category string, lines []InvoiceLine, acs []AllowanceCharge) string {
+	for i, line := range lines {
+		if line.taxCategory().ID == category {
+			return fmt.Sprintf("Lines[%d]", i)
+		}
+	}
+	for i, ac := range acs {
+		if ac.TaxCategoryID == category {
+			return fmt.Sprintf("AllowanceCharges[%d]", i)
+		}
+	}
+	return ""
+}
+
+// checkIntraCommunity checks the fields an intra-community supply needs
+// (BR-IC-02, BR-IC-11, BR-IC-12), field being the first line or
+// allowance/charge in category K.
+func (f requiredFields) checkIntraCommunity(field string) []error {
+	var errs []error
+	if f.SupplierVat == "" {
+		errs = append(errs, fmt.Errorf("SupplierVat: required for intra-community supply, %s has tax category K (BR-IC-02)", field))
+	}
+	if f.CustomerVat == "" {
+		errs = append(errs, fmt.Errorf("CustomerVat: required for intra-community supply, %s has tax category K (BR-IC-02)", field))
+	}
+	if f.DeliveryDate == nil && f.PeriodStart == nil && f.PeriodEnd == nil {
+		errs = append(errs, fmt.Errorf("ActualDeliveryDate: required for intra-community supply without invoice period, %s has tax category K (BR-IC-11)", field))
+	}
+	if f.DeliveryAddress == nil || f.DeliveryAddress.CountryCode == "" {
+		errs = append(errs, fmt.Errorf("DeliveryAddress.CountryCode: required for intra-community supply, %s has tax category K (BR-IC-12)", field))
+	}
+	return errs
+}
+
+// checkOutsideScope checks that a document with a line or allowance/charge
+// in category O uses no other category (BR-O-11 to BR-O-14). Allowances and
+// charges without a category are split over the line categories, so they
+// are fine.
+func checkOutsideScope(lines []InvoiceLine, acs []AllowanceCharge) []error {
+	first := categoryUse("O", lines, acs)
+	if first == "" {
+		return nil
+	}
+	var errs []error
+	for i, line := range lines {
+		if id := line.taxCategory().ID; id != "O" {
+			errs = append(errs, fmt.Errorf("Lines[%d]: tax category %s not allowed with category O of %s (BR-O-11)", i, id, first))
+		}
+	}
+	for i, ac := range acs {
+		if ac.TaxCategoryID != "" && ac.TaxCategoryID != "O" {
+			errs = append(errs, fmt.Errorf("AllowanceCharges[%d]: tax category %s not allowed with category O of %s (BR-O-12, BR-O-13)", i, ac.TaxCategoryID, first))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,213 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestTaxExemption(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 1, Price: 100, TaxPercentage: 21, Name: "Product A"},
+		{Quantity: 2, Price: 50, TaxCategoryID: "E", TaxCategoryName: "Exempt", Name: "Medical care",
+			TaxExemptionCode: "VATEX-EU-132-1C", TaxExemptionReason: "Medical care"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	reason := `<cbc:TaxExemptionReasonCode>VATEX-EU-132-1C</cbc:TaxExemptionReasonCode><cbc:TaxExemptionReason>Medical care</cbc:TaxExemptionReason>`
+	if n := strings.Count(xmlBytes, reason); n != 2 {
+		t.Errorf("expected the exemption reason on the line and in the breakdown, got %d", n)
+	}
+	want := `<cbc:TaxableAmount currencyID="EUR">100.00</cbc:TaxableAmount><cbc:TaxAmount currencyID="EUR">0.00</cbc:TaxAmount><cac:TaxCategory><cbc:ID>E</cbc:ID><cbc:Name>Exempt</cbc:Name><cbc:Percent>0</cbc:Percent>` + reason
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv.Lines[1].TaxExemptionCode = "VATEX-EU-999"
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `Lines[1]: exemption code "VATEX-EU-999": not in the VATEX code list` {
+		t.Errorf("expected a VATEX error, got %v", err)
+	}
+
+	inv.Lines[1].TaxExemptionCode = ""
+	inv.Lines[0].TaxExemptionReason = "Medical care"
+	_, err = inv.Generate()
+	if err == nil || err.Error() != "Lines[0]: no exemption reason allowed for tax category S" {
+		t.Errorf("expected an error for an exemption reason on S, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines = []ubl.InvoiceLine{
+		{Quantity: 1, Price: 10, TaxCategoryID: "E", Name: "A", TaxExemptionReason: "Medical care"},
+		{Quantity: 1, Price: 10, TaxCategoryID: "E", Name: "B", TaxExemptionReason: "Education"},
+	}
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:Percent>0</cbc:Percent><cbc:TaxExemptionReason>Medical care</cbc:TaxExemptionReason>`) {
+		t.Error("expected the exemption reason of the first line in the breakdown")
+	}
+	if w := cn.Warnings(); len(w) == 0 || w[0] != "lines 1 and 2: tax category E at 0% with different exemption reasons, the VAT breakdown uses the first" {
+		t.Errorf("expected a warning for different exemption reasons, got %q", w)
+	}
+
+	cn.RequireSameExemption = true
+	_, err = cn.GenerateCreditNote()
+	if err == nil || err.Error() != "lines 1 and 2: tax category E at 0% with different exemption reasons" {
+		t.Errorf("expected an error for different exemption reasons, got %v", err)
+	}
+}
+
+func TestTaxExemptionDefaultReason(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 1, Price: 100, TaxPercentage: 21, Name: "Product A"},
+		{Quantity: 1, Price: 80, TaxCategoryID: "E", TaxCategoryName: "Exempt", Name: "Training"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cbc:ID>E</cbc:ID><cbc:Name>Exempt</cbc:Name><cbc:Percent>0</cbc:Percent><cbc:TaxExemptionReason>Exempt from VAT</cbc:TaxExemptionReason>`
+	if n := strings.Count(xmlBytes, want); n != 2 {
+		t.Errorf("expected the default exemption reason on the line and in the breakdown, got %d", n)
+	}
+	if strings.Contains(xmlBytes, "<cbc:TaxExemptionReasonCode>") {
+		t.Error("expected no default exemption code for category E")
+	}
+
+	inv.Lines[1].TaxExemptionCode = "VATEX-EU-132-1I"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	want = `<cbc:TaxExemptionReasonCode>VATEX-EU-132-1I</cbc:TaxExemptionReasonCode><cbc:TaxExemptionReason>Exempt from VAT</cbc:TaxExemptionReason>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+}
+
+func TestReverseCharge(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 10, Price: 45, TaxCategoryID: "AE", TaxPercentage: 21, TaxCategoryName: "Reverse charge", Name: "Scaffolding"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cac:TaxCategory><cbc:ID>AE</cbc:ID><cbc:Name>Reverse charge</cbc:Name><cbc:Percent>0</cbc:Percent><cbc:TaxExemptionReasonCode>VATEX-EU-AE</cbc:TaxExemptionReasonCode><cbc:TaxExemptionReason>Reverse charge</cbc:TaxExemptionReason>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+	if !strings.Contains(xmlBytes, `<cbc:PayableAmount currencyID="EUR">450.00</cbc:PayableAmount>`) {
+		t.Error("expected no VAT charged on a reverse charge line")
+	}
+
+	inv.CustomerVat = ""
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "CustomerVat: required for reverse charge, Lines[0] has tax category AE (BR-AE-02)" {
+		t.Errorf("expected an error for reverse charge without customer VAT, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	inv := newTestInvoice()
+	inv.CustomerVat = ""
+	inv.CustomerAddress.CountryCode = "US"
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 2, Price: 300, TaxCategoryID: "G", TaxPercentage: 21, TaxCategoryName: "Export", Name: "Machine part",
+			TaxExemptionReason: "Export to the United States"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	want := `<cbc:TaxAmount currencyID="EUR">0.00</cbc:TaxAmount><cac:TaxCategory><cbc:ID>G</cbc:ID><cbc:Name>Export</cbc:Name><cbc:Percent>0</cbc:Percent><cbc:TaxExemptionReasonCode>VATEX-EU-G</cbc:TaxExemptionReasonCode><cbc:TaxExemptionReason>Export to the United States</cbc:TaxExemptionReason>`
+	if !strings.Contains(xmlBytes, want) {
+		t.Errorf("expected %s in output", want)
+	}
+
+	inv.SupplierVat = ""
+	_, err := inv.Generate()
+	if err == nil || err.Error() != "SupplierVat: required for export, Lines[0] has tax category G (BR-G-02)" {
+		t.Errorf("expected an error for export without supplier VAT, got %v", err)
+	}
+}
+
+func TestOutsideScope(t *testing.T) {
+	inv := newTestInvoice()
+	inv.SupplierVat = ""
+	inv.CustomerVat = ""
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 1, Price: 250, TaxCategoryID: "O", TaxCategoryName: "Not subject to VAT", Name: "Membership fee"},
+	}
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "<cbc:Percent>") {
+		t.Error("expected no cbc:Percent for category O")
+	}
+	want := `<cbc:TaxExemptionReasonCode>VATEX-EU-O</cbc:TaxExemptionReasonCode><cbc:TaxExemptionReason>Not subject to VAT</cbc:TaxExemptionReason>`
+	if n := strings.Count(xmlBytes, want); n != 2 {
+		t.Errorf("expected the exemption reason on the line and in the breakdown, got %d", n)
+	}
+
+	inv.Lines = append(inv.Lines, ubl.InvoiceLine{Quantity: 1, Price: 10, TaxPercentage: 21, Name: "Magazine"})
+	_, err := inv.Generate()
+	if err == nil || !strings.Contains(err.Error(), "Lines[1]: tax category S not allowed with category O of Lines[0] (BR-O-11)") {
+		t.Errorf("expected an error for mixing category O with S, got %v", err)
+	}
+}
+
+func TestIntraCommunitySupply(t *testing.T) {
+	delivered := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	inv := newTestInvoice()
+	inv.CustomerVat = "NL123456789B01"
+	inv.CustomerAddress.CountryCode = "NL"
+	inv.DeliveryAddress = &ubl.Address{CityName: "Rotterdam", CountryCode: "NL"}
+	inv.ActualDeliveryDate = &delivered
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 4, Price: 125, TaxCategoryID: "K", TaxCategoryName: "Intra-community supply", Name: "Pallet racking"},
+	}
+	generateAndValidate(t, &inv)
+
+	inv.DeliveryAddress = nil
+	inv.ActualDeliveryDate = nil
+	inv.CustomerVat = ""
+	_, err := inv.Generate()
+	want := `CustomerVat: required for intra-community supply, Lines[0] has tax category K (BR-IC-02)
+ActualDeliveryDate: required for intra-community supply without invoice period, Lines[0] has tax category K (BR-IC-11)
+DeliveryAddress.CountryCode: required for intra-community supply, Lines[0] has tax category K (BR-IC-12)`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected the intra-community errors, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.InvoicePeriodStart = &delivered
+	cn.DeliveryAddress = &ubl.Address{CountryCode: "NL"}
+	cn.Lines = []ubl.InvoiceLine{{Quantity: 1, Price: 125, TaxCategoryID: "K", Name: "Pallet racking"}}
+	_, err = cn.GenerateCreditNote()
+	if err != nil {
+		t.Errorf("expected an invoice period to replace the delivery date, got %v", err)
+	}
+}
+
+func TestTaxSchemeID(t *testing.T) {
+	inv := newTestInvoice()
+	inv.TaxSchemeID = "GST"
+	inv.Currency = "AUD"
+	inv.SupplierVat = "AU53004085616"
+	inv.SupplierAddress.CountryCode = "AU"
+	inv.CustomerVat = "51 824 753 556"
+	inv.CustomerAddress = ubl.Address{CityName: "Sydney", CountryCode: "AU"}
+	inv.Lines[0].TaxPercentage = 10
+	inv.AllowanceCharges = []ubl.AllowanceCharge{{Charge: true, Amount: 5, Reason: "Freight", TaxCategoryID: "S", TaxPercentage: 10}}
+	xmlBytes, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := compact(xmlBytes)
+	if n := strings.Count(out, `<cac:TaxScheme><cbc:ID>GST</cbc:ID></cac:TaxScheme>`); n != 5 {
+		t.Errorf("expected GST for both parties, the line, the charge and the breakdown, got %d", n)
+	}
+	if strings.Contains(out, `<cbc:ID>VAT</cbc:ID>`) {
+		t.Error("expected no VAT tax scheme")
+	}
+
+	inv.TaxSchemeID = " "
+	_, err = inv.Generate()
+	if err == nil || err.Error() != `TaxSchemeID " ": white space in the tax scheme, leave it empty for VAT` {
+		t.Errorf("expected an error for a blank tax scheme, got %v", err)
+	}
+}
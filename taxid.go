@@ -0,0 +1,120 @@
+package ubl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// euVATCountries are the countries where VAT identifiers carry the EU
+// country prefix (with EL for Greece and XI for Northern Ireland).
+var euVATCountries = map[string]bool{
+	"AT": true, "BE": true, "BG": true, "CY": true, "CZ": true, "DE": true,
+	"DK": true, "EE": true, "EL": true, "ES": true, "FI": true, "FR": true,
+	"GR": true, "HR": true, "HU": true, "IE": true, "IT": true, "LT": true,
+	"LU": true, "LV": true, "MT": true, "NL": true, "PL": true, "PT": true,
+	"RO": true, "SE": true, "SI": true, "SK": true, "XI": true,
+}
+
+// gstCountries use a goods and services tax instead of VAT (Peppol PINT).
+var gstCountries = map[string]bool{
+	"AU": true, "NZ": true, "SG": true,
+}
+
+// customerTaxIdentifier returns the buyer tax identifier and its tax scheme.
+// EU VAT identifiers are normalized with cleanVATIdentifier, identifiers from
+// outside the EU are used verbatim and checked where a check digit is defined
+// (Australian ABN, Singapore UEN).
+func customerTaxIdentifier(id, countryCode string) (string, string, error) {
+	if euVATCountries[countryCode] {
+		return cleanVATIdentifier(id, countryCode), "VAT", nil
+	}
+
+	id = strings.TrimSpace(id)
+	scheme := "VAT"
+	if gstCountries[countryCode] {
+		scheme = "GST"
+	}
+
+	if id == "" {
+		return id, scheme, nil
+	}
+
+	switch countryCode {
+	case "AU":
+		if !validABN(id) {
+			return "", "", fmt.Errorf("customer tax identifier: invalid ABN %q", id)
+		}
+	case "SG":
+		if !validUEN(id) {
+			return "", "", fmt.Errorf("customer tax identifier: invalid UEN %q", id)
+		}
+	}
+
+	return id, scheme, nil
+}
+
+// validABN checks an Australian Business Number: 11 digits, the first
+// decremented by one, weighted sum divisible by 89.
+func validABN(abn string) bool {
+	abn = strings.ReplaceAll(abn, " ", "")
+	if len(abn) != 11 {
+		return false
+	}
+	weights := []int{10, 1, 3, 5, 7, 9, 11, 13, 15, 17, 19}
+	sum := 0
+	for i, c := range abn {
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if i == 0 {
+			d--
+		}
+		sum += d * weights[i]
+	}
+	return sum%89 == 0
+}
+
+// validUEN checks a Singapore Unique Entity Number in one of its three
+// formats: businesses (nnnnnnnnX), local companies (yyyynnnnnX) and other
+// entities (TyyPQnnnnX).
+func validUEN(uen string) bool {
+	uen = strings.ToUpper(strings.ReplaceAll(uen, " ", ""))
+	digits := func(s string) bool {
+		for _, c := range s {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	weightedSum := func(s string, weights []int, value func(byte) int) int {
+		sum := 0
+		for i := range weights {
+			sum += value(s[i]) * weights[i]
+		}
+		return sum
+	}
+	digit := func(c byte) int { return int(c - '0') }
+
+	switch {
+	case len(uen) == 9 && digits(uen[:8]):
+		sum := weightedSum(uen, []int{10, 4, 9, 3, 8, 2, 7, 1}, digit)
+		return uen[8] == "XMKECAWLJDB"[sum%11]
+	case len(uen) == 10 && digits(uen[:9]):
+		sum := weightedSum(uen, []int{10, 8, 6, 4, 9, 7, 5, 3, 1}, digit)
+		return uen[9] == "ZKCMDNERGWH"[sum%11]
+	case len(uen) == 10 && (uen[0] == 'R' || uen[0] == 'S' || uen[0] == 'T') && digits(uen[1:3]) && digits(uen[5:9]):
+		const alphabet = "ABCDEFGHJKLMNPQRSTUVWX0123456789"
+		for i := 0; i < 9; i++ {
+			if strings.IndexByte(alphabet, uen[i]) < 0 {
+				return false
+			}
+		}
+		sum := weightedSum(uen, []int{4, 3, 5, 3, 10, 2, 2, 5, 7}, func(c byte) int {
+			return strings.IndexByte(alphabet, c)
+		})
+		return uen[9] == alphabet[((sum-5)%11+11)%11]
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package ubl
+
+import "testing"
+
+func TestValidABN(t *testing.T) {
+	for abn, want := range map[string]bool{
+		"51 824 753 556": true,
+		"53004085616":    true,
+		"51 824 753 557": false,
+		"5182475355":     false,
+		"5182475355A":    false,
+	} {
+		if got := validABN(abn); got != want {
+			t.Errorf("validABN(%q): expected %v, got %v", abn, want, got)
+		}
+	}
+}
+
+func TestValidUEN(t *testing.T) {
+	for uen, want := range map[string]bool{
+		"00192200M":  true,
+		"197401143C": true,
+		"S16FC0121D": true,
+		"T01FC6132D": true,
+		"00192200N":  false,
+		"197401143D": false,
+		"S16FC0121E": false,
+		"12345":      false,
+	} {
+		if got := validUEN(uen); got != want {
+			t.Errorf("validUEN(%q): expected %v, got %v", uen, want, got)
+		}
+	}
+}
+
+func TestCustomerTaxIdentifier(t *testing.T) {
+	tests := []struct {
+		id, country, want, scheme string
+	}{
+		{"BE0123456789", "BE", "BE0123456789", "VAT"},
+		{"NL123456789B01", "NL", "NL123456789B01", "VAT"},
+		{"GR123456789", "GR", "EL123456789", "VAT"},
+		{"GB123456789", "GB", "GB123456789", "VAT"},
+		{"CHE-123.456.788 MWST", "CH", "CHE-123.456.788 MWST", "VAT"},
+		{"NO123456785MVA", "NO", "NO123456785MVA", "VAT"},
+		{"123456789", "US", "123456789", "VAT"},
+		{" 12-3456789 ", "US", "12-3456789", "VAT"},
+		{"27AAPFU0939F1ZV", "IN", "27AAPFU0939F1ZV", "VAT"},
+		{"51 824 753 556", "AU", "51 824 753 556", "GST"},
+		{"00192200M", "SG", "00192200M", "GST"},
+		{"123-456-789", "NZ", "123-456-789", "GST"},
+	}
+	for _, tt := range tests {
+		got, scheme, err := customerTaxIdentifier(tt.id, tt.country)
+		if err != nil || got != tt.want || scheme != tt.scheme {
+			t.Errorf("customerTaxIdentifier(%q, %q): expected %q %s, got %q %s %v", tt.id, tt.country, tt.want, tt.scheme, got, scheme, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package ubl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TextFilter rewrites free-text values (names, descriptions, notes,
+// addresses) before they are written to the document. Filters are opt-in
+// through the TextFilters field of Invoice and CreditNote and are applied in
+// order; every change is recorded as a warning.
+type TextFilter struct {
+	Name    string
+	Replace func(string) string
+}
+
+// SmartQuotesFilter replaces typographic quotes by ASCII quotes.
+func SmartQuotesFilter() TextFilter {
+	r := strings.NewReplacer(
+		"\u2018", "'", "\u2019", "'", "\u201A", "'", "\u201B", "'", "\u2032", "'",
+		"\u201C", `"`, "\u201D", `"`, "\u201E", `"`, "\u201F", `"`, "\u2033", `"`,
+	)
+	return TextFilter{Name: "smart-quotes", Replace: r.Replace}
+}
+
+// NonBreakingSpaceFilter replaces non-breaking spaces by regular spaces.
+func NonBreakingSpaceFilter() TextFilter {
+	r := strings.NewReplacer("\u00A0", " ", "\u2007", " ", "\u202F", " ")
+	return TextFilter{Name: "non-breaking-space", Replace: r.Replace}
+}
+
+// DashFilter replaces hyphen and dash variants by an ASCII hyphen-minus.
+func DashFilter() TextFilter {
+	r := strings.NewReplacer(
+		"\u2010", "-", "\u2011", "-", "\u2012", "-", "\u2013", "-",
+		"\u2014", "-", "\u2015", "-", "\u2212", "-",
+	)
+	return TextFilter{Name: "dash", Replace: r.Replace}
+}
+
+// TransliterationFilters returns the built-in filters for text pasted from
+// word processors: smart quotes, non-breaking spaces and dashes.
+func TransliterationFilters() []TextFilter {
+	return []TextFilter{SmartQuotesFilter(), NonBreakingSpaceFilter(), DashFilter()}
+}
+
+type freeTextField struct {
+	path  string
+	value *string
+}
+
+// applyTextFilters runs the filters over the fields and returns a warning
+// for every change a filter made.
+func applyTextFilters(filters []TextFilter, fields []freeTextField) []string {
+	var warnings []string
+	for _, filter := range filters {
+		for _, field := range fields {
+			filtered := filter.Replace(*field.value)
+			if filtered != *field.value {
+				warnings = append(warnings, fmt.Sprintf("text filter %q changed %s", filter.Name, field.path))
+				*field.value = filtered
+			}
+		}
+	}
+	return warnings
+}
+
+func (p *XMLParty) freeText(prefix string) []freeTextField {
+	fields := []freeTextField{
+		{prefix + ".PartyName", &p.PartyName},
+		{prefix + ".PartyLegalEntity.RegistrationName", &p.PartyLegalEntity.RegistrationName},
+		{prefix + ".PartyLegalEntity.CompanyLegalForm", &p.PartyLegalEntity.CompanyLegalForm},
+	}
+	fields = append(fields, p.PostalAddress.freeText(prefix+".PostalAddress")...)
+	if p.Contact != nil {
+		fields = append(fields, freeTextField{prefix + ".Contact.Name", &p.Contact.Name})
+	}
+	return fields
+}
+
+func (a *XMLPostalAddress) freeText(prefix string) []freeTextField {
+	fields := []freeTextField{
+		{prefix + ".StreetName", &a.StreetName},
+		{prefix + ".AdditionalStreetName", &a.AdditionalStreetName},
+		{prefix + ".CityName", &a.CityName},
+		{prefix + ".CountrySubentity", &a.CountrySubentity},
+	}
+	if a.AddressLine != nil {
+		fields = append(fields, freeTextField{prefix + ".AddressLine.Line", &a.AddressLine.Line})
+	}
+	return fields
+}
+
+func (i *XMLItem) freeText(prefix string) []freeTextField {
+	fields := []freeTextField{
+		{prefix + ".Name", &i.Name},
+		{prefix + ".Description", &i.Description},
+	}
+	for j := range i.AdditionalItemProperty {
+		fields = append(fields, freeTextField{prefix + ".AdditionalItemProperty[" + strconv.Itoa(j) + "].Value", &i.AdditionalItemProperty[j].Value})
+	}
+	return fields
+}
+
+func (d *XMLDelivery) freeText() []freeTextField {
+	if d == nil {
+		return nil
+	}
+	var fields []freeTextField
+	if d.DeliveryLocation != nil && d.DeliveryLocation.Address != nil {
+		fields = append(fields, d.DeliveryLocation.Address.freeText("Delivery.DeliveryLocation.Address")...)
+	}
+	if d.DeliveryParty != nil {
+		fields = append(fields, freeTextField{"Delivery.DeliveryParty.PartyName", &d.DeliveryParty.PartyName})
+	}
+	return fields
+}
+
+func documentReferencesFreeText(refs []XMLDocumentReference) []freeTextField {
+	var fields []freeTextField
+	for i := range refs {
+		fields = append(fields, freeTextField{"AdditionalDocumentReference[" + strconv.Itoa(i) + "].DocumentDescription", &refs[i].DocumentDescription})
+	}
+	return fields
+}
+
+func allowanceChargesFreeText(acs []XMLAllowanceCharge) []freeTextField {
+	var fields []freeTextField
+	for i := range acs {
+		fields = append(fields, freeTextField{"AllowanceCharge[" + strconv.Itoa(i) + "].AllowanceChargeReason", &acs[i].AllowanceChargeReason})
+	}
+	return fields
+}
+
+func (p *XMLPaymentTerms) freeText() []freeTextField {
+	if p == nil {
+		return nil
+	}
+	var fields []freeTextField
+	for i := range p.Note {
+		fields = append(fields, freeTextField{"PaymentTerms.Note[" + strconv.Itoa(i) + "]", &p.Note[i]})
+	}
+	return fields
+}
+
+func paymentMeansFreeText(means []XMLPaymentMeans) []freeTextField {
+	var fields []freeTextField
+	for i := range means {
+		if means[i].PayeeFinancialAccount != nil {
+			fields = append(fields, freeTextField{"PaymentMeans[" + strconv.Itoa(i) + "].PayeeFinancialAccount.Name", &means[i].PayeeFinancialAccount.Name})
+		}
+	}
+	return fields
+}
+
+func (x *XMLInvoice) freeText() []freeTextField {
+	fields := documentReferencesFreeText(x.AdditionalDocumentReference)
+	fields = append(fields, x.SupplierParty.Party.freeText("AccountingSupplierParty")...)
+	fields = append(fields, x.CustomerParty.Party.freeText("AccountingCustomerParty")...)
+	fields = append(fields, x.Delivery.freeText()...)
+	fields = append(fields, paymentMeansFreeText(x.PaymentMeans)...)
+	fields = append(fields, x.PaymentTerms.freeText()...)
+	fields = append(fields, allowanceChargesFreeText(x.AllowanceCharge)...)
+	for i := range x.InvoiceLines {
+		fields = append(fields, freeTextField{"InvoiceLine[" + strconv.Itoa(i) + "].Note", &x.InvoiceLines[i].Note})
+		fields = append(fields, x.InvoiceLines[i].Item.freeText("InvoiceLine["+strconv.Itoa(i)+"].Item")...)
+	}
+	return fields
+}
+
+func (x *XMLCreditNote) freeText() []freeTextField {
+	fields := documentReferencesFreeText(x.AdditionalDocumentReference)
+	fields = append(fields, x.SupplierParty.Party.freeText("AccountingSupplierParty")...)
+	fields = append(fields, x.CustomerParty.Party.freeText("AccountingCustomerParty")...)
+	fields = append(fields, x.Delivery.freeText()...)
+	fields = append(fields, paymentMeansFreeText(x.PaymentMeans)...)
+	fields = append(fields, x.PaymentTerms.freeText()...)
+	fields = append(fields, allowanceChargesFreeText(x.AllowanceCharge)...)
+	for i := range x.CreditNoteLines {
+		fields = append(fields, freeTextField{"CreditNoteLine[" + strconv.Itoa(i) + "].Note", &x.CreditNoteLines[i].Note})
+		fields = append(fields, x.CreditNoteLines[i].Item.freeText("CreditNoteLine["+strconv.Itoa(i)+"].Item")...)
+	}
+	return fields
+}
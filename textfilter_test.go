@@ -0,0 +1,67 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestTextFilters(t *testing.T) {
+	tests := []struct {
+		filter ubl.TextFilter
+		in     string
+		want   string
+	}{
+		{ubl.SmartQuotesFilter(), "Bob\u2019s \u2018best\u2019 \u201Cwidget\u201D \u201Elow\u201C", `Bob's 'best' "widget" "low"`},
+		{ubl.NonBreakingSpaceFilter(), "10\u00A0kg\u202Fnet", "10 kg net"},
+		{ubl.DashFilter(), "A\u2013B \u2014 C\u2212D\u2011E", "A-B - C-D-E"},
+		{ubl.DashFilter(), "plain ascii - text", "plain ascii - text"},
+	}
+	for _, tt := range tests {
+		got := tt.filter.Replace(tt.in)
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.filter.Name, tt.want, got)
+		}
+	}
+}
+
+func TestTextFiltersGenerate(t *testing.T) {
+	name := "Bob\u2019s\u00A0widget \u2013 large"
+
+	// off by default
+	inv := newTestInvoice()
+	inv.Lines[0].Name = name
+	xmlBytes := generateAndValidate(t, &inv)
+	if !strings.Contains(string(xmlBytes), name) {
+		t.Error("expected the name to be unchanged without filters")
+	}
+	if len(inv.Warnings()) != 0 {
+		t.Errorf("expected no warnings, got %v", inv.Warnings())
+	}
+
+	inv = newTestInvoice()
+	inv.Lines[0].Name = name
+	inv.TextFilters = ubl.TransliterationFilters()
+	upper := ubl.TextFilter{Name: "upper", Replace: strings.ToUpper}
+	inv.TextFilters = append(inv.TextFilters, upper)
+	xmlBytes = generateAndValidate(t, &inv)
+	if !strings.Contains(string(xmlBytes), "<cbc:Name>BOB&#39;S WIDGET - LARGE</cbc:Name>") {
+		t.Errorf("expected the filtered name in output")
+	}
+	if inv.Lines[0].Name != name {
+		t.Error("expected the filters not to change the Invoice")
+	}
+
+	warnings := strings.Join(inv.Warnings(), "\n")
+	for _, want := range []string{
+		`text filter "smart-quotes" changed InvoiceLine[0].Item.Name`,
+		`text filter "non-breaking-space" changed InvoiceLine[0].Item.Name`,
+		`text filter "dash" changed InvoiceLine[0].Item.Name`,
+		`text filter "upper" changed AccountingSupplierParty.PartyName`,
+	} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("expected warning %q, got %v", want, warnings)
+		}
+	}
+}
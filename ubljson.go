@@ -0,0 +1,174 @@
+package ubl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// GenerateJSON generates the invoice like Generate in the OASIS UBL JSON
+// alternative representation (UBL 2.1 JSON v2.0): every element is an array
+// of objects named as in the XML, with its text in "_" and its attributes,
+// e.g. "PayableAmount":[{"_":"1210.00","currencyID":"EUR"}]. The document
+// namespaces are given in "_D", "_S" and "_B". Extensions hold XML of their
+// own and are not supported.
+func (inv *Invoice) GenerateJSON() ([]byte, error) {
+	doc, err := inv.BuildDocument()
+	if err != nil {
+		return nil, err
+	}
+	return ublJSON(doc)
+}
+
+// GenerateCreditNoteJSON generates the credit note in the UBL JSON
+// representation, like Invoice.GenerateJSON.
+func (cn *CreditNote) GenerateCreditNoteJSON() ([]byte, error) {
+	doc, err := cn.BuildCreditNoteDocument()
+	if err != nil {
+		return nil, err
+	}
+	return ublJSON(doc)
+}
+
+func ublJSON(doc *Document) ([]byte, error) {
+	if doc.Invoice != nil && doc.Invoice.UBLExtensions != nil || doc.CreditNote != nil && doc.CreditNote.UBLExtensions != nil {
+		return nil, errors.New("ubl json: extensions not supported")
+	}
+	// the usual UTF-8 output, the JSON has no prefixes
+	doc.XMLDeclaration, doc.Namespaces = XMLDeclaration{Omit: true}, XMLNamespaces{}
+	data, err := doc.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	root, err := readJSONElement(xml.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("ubl json: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	w := bufio.NewWriter(&buf)
+	w.WriteString(`{"_D":`)
+	writeJSONString(w, root.name.Space)
+	w.WriteString(`,"_S":`)
+	writeJSONString(w, cacNamespace)
+	w.WriteString(`,"_B":`)
+	writeJSONString(w, cbcNamespace)
+	w.WriteString(",")
+	writeJSONString(w, root.name.Local)
+	w.WriteString(":[")
+	root.write(w)
+	w.WriteString("]}")
+	err = w.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonElement is an element of the document as written in UBL JSON.
+type jsonElement struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	text     []byte
+	children []*jsonElement
+}
+
+// readJSONElement reads the next element and its content from dec.
+func readJSONElement(dec *xml.Decoder) (*jsonElement, error) {
+	var stack []*jsonElement
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("no document element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &jsonElement{name: t.Name}
+			for _, attr := range t.Attr {
+				if attr.Name.Space != "xmlns" && !(attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					e.attrs = append(e.attrs, attr)
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return e, nil
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				e := stack[len(stack)-1]
+				e.text = append(e.text, t...)
+			}
+		}
+	}
+}
+
+// write writes the element as a JSON object: its attributes, then its text
+// in "_" or its children grouped by name in order of appearance.
+func (e *jsonElement) write(w *bufio.Writer) {
+	w.WriteString("{")
+	first := true
+	comma := func() {
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+	}
+	if len(e.children) == 0 {
+		comma()
+		w.WriteString(`"_":`)
+		writeJSONString(w, string(e.text))
+	}
+	for _, attr := range e.attrs {
+		comma()
+		writeJSONString(w, attr.Name.Local)
+		w.WriteString(":")
+		writeJSONString(w, attr.Value)
+	}
+	var names []string
+	groups := map[string][]*jsonElement{}
+	for _, child := range e.children {
+		if _, ok := groups[child.name.Local]; !ok {
+			names = append(names, child.name.Local)
+		}
+		groups[child.name.Local] = append(groups[child.name.Local], child)
+	}
+	for _, name := range names {
+		comma()
+		writeJSONString(w, name)
+		w.WriteString(":[")
+		for i, child := range groups[name] {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			child.write(w)
+		}
+		w.WriteString("]")
+	}
+	w.WriteString("}")
+}
+
+// writeJSONString writes s as a JSON string, without escaping <, > and & as
+// json.Marshal does for HTML.
+func writeJSONString(w *bufio.Writer, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// a string always encodes
+	enc.Encode(s)
+	w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,125 @@
+package ubl_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/verscheures/ubl"
+)
+
+// ublValue is a leaf in UBL JSON, with the attributes used in the test.
+type ublValue []struct {
+	Value      string `json:"_"`
+	CurrencyID string `json:"currencyID"`
+	SchemeID   string `json:"schemeID"`
+}
+
+type ublParty []struct {
+	EndpointID       ublValue
+	PartyLegalEntity []struct{ RegistrationName ublValue }
+	PartyTaxScheme   []struct{ CompanyID ublValue }
+}
+
+func TestGenerateJSON(t *testing.T) {
+	inv := newTestInvoice()
+	if err := inv.AddStandardLine("Product B", 2, 50, 21); err != nil {
+		t.Fatal(err)
+	}
+	xmlBytes := generateAndValidate(t, &inv)
+	data, err := inv.GenerateJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Invoice []struct {
+			ID                      ublValue
+			AccountingSupplierParty []struct{ Party ublParty }
+			AccountingCustomerParty []struct{ Party ublParty }
+			TaxTotal                []struct {
+				TaxAmount   ublValue
+				TaxSubtotal []struct{ TaxableAmount, TaxAmount ublValue }
+			}
+			LegalMonetaryTotal []struct{ TaxExclusiveAmount, PayableAmount ublValue }
+			InvoiceLine        []struct{ LineExtensionAmount ublValue }
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+	var namespaces map[string]any
+	if err := json.Unmarshal(data, &namespaces); err != nil {
+		t.Fatal(err)
+	}
+	if namespaces["_D"] != "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2" || namespaces["_B"] != "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2" {
+		t.Errorf("expected the namespaces, got %v and %v", namespaces["_D"], namespaces["_B"])
+	}
+
+	var want struct {
+		ID       string `xml:"ID"`
+		Supplier struct {
+			EndpointID       xmlValue `xml:"EndpointID"`
+			RegistrationName string   `xml:"PartyLegalEntity>RegistrationName"`
+			CompanyID        string   `xml:"PartyTaxScheme>CompanyID"`
+		} `xml:"AccountingSupplierParty>Party"`
+		Customer struct {
+			RegistrationName string `xml:"PartyLegalEntity>RegistrationName"`
+		} `xml:"AccountingCustomerParty>Party"`
+		TaxAmount          xmlValue   `xml:"TaxTotal>TaxAmount"`
+		TaxExclusiveAmount xmlValue   `xml:"LegalMonetaryTotal>TaxExclusiveAmount"`
+		PayableAmount      xmlValue   `xml:"LegalMonetaryTotal>PayableAmount"`
+		LineAmounts        []xmlValue `xml:"InvoiceLine>LineExtensionAmount"`
+	}
+	if err := xml.Unmarshal(xmlBytes, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	got := doc.Invoice[0]
+	supplier := got.AccountingSupplierParty[0].Party[0]
+	for _, c := range []struct {
+		name     string
+		got      ublValue
+		want     string
+		currency string
+	}{
+		{"ID", got.ID, want.ID, ""},
+		{"supplier endpoint", supplier.EndpointID, want.Supplier.EndpointID.Value, ""},
+		{"supplier name", supplier.PartyLegalEntity[0].RegistrationName, want.Supplier.RegistrationName, ""},
+		{"supplier VAT", supplier.PartyTaxScheme[0].CompanyID, want.Supplier.CompanyID, ""},
+		{"customer name", got.AccountingCustomerParty[0].Party[0].PartyLegalEntity[0].RegistrationName, want.Customer.RegistrationName, ""},
+		{"tax amount", got.TaxTotal[0].TaxAmount, want.TaxAmount.Value, want.TaxAmount.CurrencyID},
+		{"tax exclusive amount", got.LegalMonetaryTotal[0].TaxExclusiveAmount, want.TaxExclusiveAmount.Value, want.TaxExclusiveAmount.CurrencyID},
+		{"payable amount", got.LegalMonetaryTotal[0].PayableAmount, want.PayableAmount.Value, want.PayableAmount.CurrencyID},
+		{"line 2 amount", got.InvoiceLine[1].LineExtensionAmount, want.LineAmounts[1].Value, want.LineAmounts[1].CurrencyID},
+	} {
+		if len(c.got) != 1 || c.got[0].Value != c.want || c.got[0].CurrencyID != c.currency {
+			t.Errorf("%s: expected %q %s, got %+v", c.name, c.want, c.currency, c.got)
+		}
+	}
+	if want.PayableAmount.Value != "1331.00" || supplier.EndpointID[0].SchemeID != want.Supplier.EndpointID.SchemeID {
+		t.Errorf("expected the amounts and schemes of the XML, got %+v", got.LegalMonetaryTotal)
+	}
+
+	cn := newTestCreditNote()
+	data, err = cn.GenerateCreditNoteJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var creditNote struct{ CreditNote []struct{ ID ublValue } }
+	if err := json.Unmarshal(data, &creditNote); err != nil || creditNote.CreditNote[0].ID[0].Value != "CN-12345" {
+		t.Errorf("expected the credit note ID, got %+v, %v", creditNote, err)
+	}
+
+	inv.Extensions = []ubl.Extension{{Content: []byte(testExtension)}}
+	_, err = inv.GenerateJSON()
+	if err == nil || err.Error() != "ubl json: extensions not supported" {
+		t.Errorf("expected an error for extensions, got %v", err)
+	}
+}
+
+type xmlValue struct {
+	Value      string `xml:",chardata"`
+	CurrencyID string `xml:"currencyID,attr"`
+	SchemeID   string `xml:"schemeID,attr"`
+}
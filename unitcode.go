@@ -0,0 +1,75 @@
+package ubl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unitCodes are the UNECE Recommendation 20 unit codes. The series codes
+// (A10 to Q99) are added as ranges, which accepts a few codes that aren't
+// assigned; the other codes are listed one by one.
+var unitCodes = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		10 11 13 14 15 20 21 22 24 27 28 33 34 35 37 38 40 41 56 57 58 59 60
+		61 74 77 80 81 85 87 89 91 1I 2A 2B 2C 2G 2H 2I 2J 2K 2L 2M 2N 2P 2Q
+		2R 2U 2X 2Y 2Z 3B 3C 4C 4G 4H 4K 4L 4M 4N 4O 4P 4Q 4R 4T 4U 4W 4X 5A
+		5B 5E 5J
+		AA AB ACR ACT AD AE AH AI AK AL AMH AMP ANN APZ AQ AS ASM ASU ATM AWG
+		AY AZ B1 BAR BB BFT BHP BIL BLD BLL BP BPM BQL BTU BUA BUI C0 CCT CDL
+		CEL CEN CG CGM CKG CLF CLT CMK CMQ CMT CNP CNT COU CTG CTM CTN CUR CWA
+		CWI D03 D04 D1 DAA DAD DAY DB DBM DBW DD DEC DG DJ DLT DMA DMK DMO DMQ
+		DMT DN DPC DPR DPT DRA DRI DRL DT DTN DWT DZN DZP E01 E07 E08 E09 EA
+		EB EQ FAH FAR FBM FC FF FH FIT FL FNU FOT FP FR FS FTK FTQ GB GBQ GDW GE GF GFI GGR GIA
+		GIC GII GIP GJ GL GLD GLI GLL GM GO GP GQ GRM GRN GRO GV GWH HA HAD HBA
+		HBX HC HDW HEA HGM HH HIU HJ HKM HLT HM HMO HMQ HMT HPA HTZ HUR HWE IA
+		IE INH INK INQ ISD IU IUG IV JE JK JM JNT JOU JPS JWL K1 KA KAT KB KBA
+		KCC KDW KEL KGM KGS KHY KHZ KI KIC KIP KJ KJO KL KLK KLX KMA KMH KMK
+		KMQ KMT KNI KNM KNS KNT KO KPA KPH KPO KPP KR KSD KSH KT KTN KUR KVA
+		KVR KVT KW KWH KWN KWO KWS KWT KWY KX LA LAC LBR LBT LD LEF LF LH LK LM
+		LN LO LP LPA LR LS LTN LTR LUB LUM LUX LY M1 MAH MAL MAM MAR MAW MBE
+		MBF MBR MC MCU MD MGM MHZ MIK MIL MIN MIO MIU MKD MKM MKW MLD MLT MMK
+		MMQ MMT MND MNJ MON MPA MQD MQH MQM MQS MQW MRD MRM MRW MSK MTK MTQ MTR
+		MTS MTZ MVA MWH N1 NA NAR NCL NEW NF NIL NIU NL NM3 NMI NMP NPT NT NTU
+		NU NX OA ODE ODG ODK ODM OHM ON OPM OT OZA OZI P1 PAL PD PFL PGL PI PLA
+		PO PQ PR PS PTD PTI PTL PTN QA QAN QB QR QTD QTI QTL QTR R1 R9 RH RM
+		ROM RP RPM RPS RT S3 S4 SAN SCO SCR SEC SET SG SIE SM3 SMI SQ SQR SR
+		STC STI STK STL STN STW SW SX SYR T0 T3 TAH TAN TI TIC TIP TKM TMS TNE
+		TP TPI TPR TQD TRL TST TTS U1 U2 UB UC VA VLT VP W2 WA WB WCD WE WEB
+		WEE WG WHR WM WSD WTT X1 YDK YDQ YRD Z11 ZP ZZ`) {
+		codes[code] = true
+	}
+	for _, series := range "ABCDEFGHJKLMNPQ" {
+		for n := 10; n <= 99; n++ {
+			codes[fmt.Sprintf("%c%02d", series, n)] = true
+		}
+	}
+	return codes
+}()
+
+// unitCode returns the unit code of a line quantity: "ZZ" (mutually
+// defined) when empty, else a UNECE Rec 20 code or a Rec 21 package code
+// prefixed with X (e.g. "XBX" for box).
+func unitCode(code string) (string, error) {
+	if code == "" {
+		return "ZZ", nil
+	}
+	if unitCodes[code] || isRec21(code) {
+		return code, nil
+	}
+	return "", fmt.Errorf("unit code %q: not in UNECE Rec 20 or Rec 21", code)
+}
+
+// isRec21 reports whether code has the form of an X-prefixed Rec 21 package
+// code: X followed by two digits or upper case letters.
+func isRec21(code string) bool {
+	if len(code) != 3 || code[0] != 'X' {
+		return false
+	}
+	for _, c := range code[1:] {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package ubl_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnitCode(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, `<cbc:InvoicedQuantity unitCode="ZZ">`) {
+		t.Error("expected ZZ without a unit code")
+	}
+
+	for _, code := range []string{"HUR", "KGM", "H87", "C62", "KMT", "XBX"} {
+		inv = newTestInvoice()
+		inv.Lines[0].UnitCode = code
+		xmlBytes = compact(generateAndValidate(t, &inv))
+		if !strings.Contains(xmlBytes, `<cbc:InvoicedQuantity unitCode="`+code+`">`) {
+			t.Errorf("expected unit code %s in output", code)
+		}
+	}
+
+	inv = newTestInvoice()
+	inv.Lines[0].UnitCode = "hours"
+	_, err := inv.Generate()
+	if err == nil || err.Error() != `Lines[0].UnitCode: unit code "hours": not in UNECE Rec 20 or Rec 21` {
+		t.Errorf("expected a code list error, got %v", err)
+	}
+
+	cn := newTestCreditNote()
+	cn.Lines[0].UnitCode = "HUR"
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact(cnBytes), `<cbc:CreditedQuantity unitCode="HUR">`) {
+		t.Error("expected the credit note unit code")
+	}
+}
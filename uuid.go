@@ -0,0 +1,29 @@
+package ubl
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", fmt.Errorf("generate UUID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// documentUUID fills in a new UUID when generate is set and there is none
+// yet, so regenerating a document keeps its UUID.
+func documentUUID(uuid *string, generate bool) error {
+	if !generate || *uuid != "" {
+		return nil
+	}
+	var err error
+	*uuid, err = newUUID()
+	return err
+}
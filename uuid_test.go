@@ -0,0 +1,51 @@
+package ubl_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestUUID(t *testing.T) {
+	inv := newTestInvoice()
+	xmlBytes := compact(generateAndValidate(t, &inv))
+	if strings.Contains(xmlBytes, "cbc:UUID") {
+		t.Error("expected no UUID by default")
+	}
+
+	inv.UUID = "6f1e3c8a-0b3f-4d2a-9c1e-2f4b5a6c7d8e"
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !strings.Contains(xmlBytes, "<cbc:ID>INV-12345</cbc:ID><cbc:UUID>6f1e3c8a-0b3f-4d2a-9c1e-2f4b5a6c7d8e</cbc:UUID>") {
+		t.Error("expected the UUID right after the ID")
+	}
+
+	inv = newTestInvoice()
+	inv.GenerateUUID = true
+	xmlBytes = compact(generateAndValidate(t, &inv))
+	if !uuidV4.MatchString(inv.UUID) {
+		t.Fatalf("expected a v4 UUID on the invoice, got %q", inv.UUID)
+	}
+	if !strings.Contains(xmlBytes, "<cbc:UUID>"+inv.UUID+"</cbc:UUID>") {
+		t.Error("expected the generated UUID in the output")
+	}
+	uuid := inv.UUID
+	generateAndValidate(t, &inv)
+	if inv.UUID != uuid {
+		t.Error("expected the UUID to be kept when generating again")
+	}
+
+	cn := newTestCreditNote()
+	cn.GenerateUUID = true
+	cnBytes, err := cn.GenerateCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uuidV4.MatchString(cn.UUID) || cn.UUID == uuid {
+		t.Errorf("expected a new v4 UUID on the credit note, got %q", cn.UUID)
+	}
+	if !strings.Contains(compact(cnBytes), "<cbc:ID>CN-12345</cbc:ID><cbc:UUID>"+cn.UUID+"</cbc:UUID>") {
+		t.Error("expected the UUID right after the credit note ID")
+	}
+}
@@ -0,0 +1,262 @@
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/verscheures/ubl"
+)
+
+// ArithmeticFinding describes one EN16931 calculation rule (BR-CO-10 to
+// BR-CO-17) that does not hold for a document.
+type ArithmeticFinding = ubl.ArithmeticFinding
+
+// ArithmeticError is returned by ValidateBytes and Validate when
+// CheckArithmetic reports findings, joined with the schema error if there is
+// one: use errors.As to get it.
+type ArithmeticError struct {
+	Findings []ArithmeticFinding
+}
+
+func (e ArithmeticError) Error() string {
+	msgs := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		msgs[i] = f.Message
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type arithAmount struct {
+	Value string `xml:",chardata"`
+}
+
+func (a *arithAmount) float() float64 {
+	if a == nil {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(strings.TrimSpace(a.Value), 64)
+	return f
+}
+
+// optional returns the amount, or nil when the document doesn't have it.
+func (a *arithAmount) optional() *float64 {
+	if a == nil {
+		return nil
+	}
+	f := a.float()
+	return &f
+}
+
+type arithAllowanceCharge struct {
+	ChargeIndicator string      `xml:"ChargeIndicator"`
+	Amount          arithAmount `xml:"Amount"`
+}
+
+type arithTaxTotal struct {
+	TaxAmount   arithAmount `xml:"TaxAmount"`
+	TaxSubtotal []struct {
+		TaxableAmount arithAmount `xml:"TaxableAmount"`
+		TaxAmount     arithAmount `xml:"TaxAmount"`
+		TaxCategory   struct {
+			ID      string       `xml:"ID"`
+			Percent *arithAmount `xml:"Percent"`
+		} `xml:"TaxCategory"`
+	} `xml:"TaxSubtotal"`
+}
+
+type arithLine struct {
+	LineExtensionAmount arithAmount `xml:"LineExtensionAmount"`
+}
+
+type arithDocument struct {
+	DocumentCurrencyCode string                 `xml:"DocumentCurrencyCode"`
+	AllowanceCharge      []arithAllowanceCharge `xml:"AllowanceCharge"`
+	TaxTotal             []arithTaxTotal        `xml:"TaxTotal"`
+	LegalMonetaryTotal   struct {
+		LineExtensionAmount   arithAmount  `xml:"LineExtensionAmount"`
+		TaxExclusiveAmount    arithAmount  `xml:"TaxExclusiveAmount"`
+		TaxInclusiveAmount    arithAmount  `xml:"TaxInclusiveAmount"`
+		AllowanceTotalAmount  *arithAmount `xml:"AllowanceTotalAmount"`
+		ChargeTotalAmount     *arithAmount `xml:"ChargeTotalAmount"`
+		PrepaidAmount         *arithAmount `xml:"PrepaidAmount"`
+		PayableRoundingAmount *arithAmount `xml:"PayableRoundingAmount"`
+		PayableAmount         arithAmount  `xml:"PayableAmount"`
+	} `xml:"LegalMonetaryTotal"`
+	InvoiceLines    []arithLine `xml:"InvoiceLine"`
+	CreditNoteLines []arithLine `xml:"CreditNoteLine"`
+}
+
+// CheckArithmetic recomputes the EN16931 calculation rules of an Invoice or
+// CreditNote and reports every rule that is off, with the exact delta, see
+// ubl.CheckAmounts. A document that cannot be parsed yields no findings; the
+// schema validation reports those.
+func CheckArithmetic(doc []byte) []ArithmeticFinding {
+	return checkArithmetic(bytes.NewReader(doc))
+}
+
+func checkArithmetic(doc io.Reader) []ArithmeticFinding {
+	var d arithDocument
+	if err := xml.NewDecoder(newBinaryObjectFilter(doc)).Decode(&d); err != nil {
+		return nil
+	}
+
+	mt := d.LegalMonetaryTotal
+	a := ubl.DocumentAmounts{
+		Currency:       strings.TrimSpace(d.DocumentCurrencyCode),
+		LineExtension:  mt.LineExtensionAmount.float(),
+		AllowanceTotal: mt.AllowanceTotalAmount.optional(),
+		ChargeTotal:    mt.ChargeTotalAmount.optional(),
+		TaxExclusive:   mt.TaxExclusiveAmount.float(),
+		TaxInclusive:   mt.TaxInclusiveAmount.float(),
+		Prepaid:        mt.PrepaidAmount.optional(),
+		Rounding:       mt.PayableRoundingAmount.optional(),
+		Payable:        mt.PayableAmount.float(),
+	}
+	for _, line := range append(d.InvoiceLines, d.CreditNoteLines...) {
+		a.Lines = append(a.Lines, line.LineExtensionAmount.float())
+	}
+	for _, ac := range d.AllowanceCharge {
+		if strings.TrimSpace(ac.ChargeIndicator) == "true" {
+			a.Charges = append(a.Charges, ac.Amount.float())
+		} else {
+			a.Allowances = append(a.Allowances, ac.Amount.float())
+		}
+	}
+	// the VAT total in document currency is the one carrying the breakdown
+	for _, tt := range d.TaxTotal {
+		if len(tt.TaxSubtotal) == 0 {
+			continue
+		}
+		a.Tax = tt.TaxAmount.float()
+		a.Subtotals = a.Subtotals[:0]
+		for _, st := range tt.TaxSubtotal {
+			a.Subtotals = append(a.Subtotals, ubl.TaxSubtotalAmounts{
+				CategoryID: st.TaxCategory.ID,
+				Percent:    st.TaxCategory.Percent.optional(),
+				Taxable:    st.TaxableAmount.float(),
+				Tax:        st.TaxAmount.float(),
+			})
+		}
+	}
+	return ubl.CheckAmounts(a)
+}
+
+// binaryObjectFilter drops the content of EmbeddedDocumentBinaryObject
+// elements while reading, so the decoder never buffers an attachment. With
+// content set, the dropped text is checked and its line breaks are kept.
+type binaryObjectFilter struct {
+	r           *bufio.Reader
+	content     *binaryContent
+	skipping    bool
+	ended       bool
+	inTag       bool
+	name        []byte
+	nameDone    bool
+	closing     bool
+	selfClosing bool
+	quote       byte
+}
+
+func newBinaryObjectFilter(r io.Reader) *binaryObjectFilter {
+	return &binaryObjectFilter{r: bufio.NewReader(r)}
+}
+
+func (f *binaryObjectFilter) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if f.content != nil && f.content.newlines > 0 {
+			p[n] = '\n'
+			n++
+			f.content.newlines--
+			continue
+		}
+		if f.ended {
+			f.ended = false
+			p[n] = '<'
+			n++
+			f.startTag()
+			continue
+		}
+		if f.skipping {
+			text, err := f.r.ReadSlice('<')
+			if err == nil {
+				text = text[:len(text)-1]
+				f.skipping, f.ended = false, true
+			}
+			if f.content != nil {
+				f.content.write(text)
+				if f.ended {
+					f.content.end()
+				}
+			}
+			if err == nil || err == bufio.ErrBufferFull {
+				continue
+			}
+			return n, err
+		}
+
+		c, err := f.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		p[n] = c
+		n++
+		f.track(c)
+	}
+	return n, nil
+}
+
+func (f *binaryObjectFilter) startTag() {
+	f.inTag = true
+	f.name = f.name[:0]
+	f.nameDone, f.closing, f.selfClosing = false, false, false
+}
+
+func (f *binaryObjectFilter) track(c byte) {
+	if !f.inTag {
+		if c == '<' {
+			f.startTag()
+		}
+		return
+	}
+	if f.quote != 0 {
+		if c == f.quote {
+			f.quote = 0
+		}
+		return
+	}
+
+	switch c {
+	case '"', '\'':
+		f.quote = c
+	case '>':
+		f.inTag = false
+		name := f.name
+		if f.content != nil && len(name) > 0 && (name[0] == '!' || name[0] == '?' && string(name) != "?xml") {
+			// comments, CDATA sections, a DOCTYPE and processing
+			// instructions may hide markup from the filter
+			f.content.invalid = true
+		}
+		if i := bytes.LastIndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		f.skipping = !f.closing && !f.selfClosing && string(name) == "EmbeddedDocumentBinaryObject"
+	case '/':
+		if len(f.name) == 0 {
+			f.closing = true
+		} else {
+			f.selfClosing = true
+		}
+	case ' ', '\t', '\r', '\n':
+		f.nameDone = len(f.name) > 0
+	default:
+		f.selfClosing = false
+		if !f.nameDone {
+			f.name = append(f.name, c)
+		}
+	}
+}
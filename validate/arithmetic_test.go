@@ -0,0 +1,180 @@
+package validate_test
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestCheckArithmetic(t *testing.T) {
+	base, err := os.ReadFile("testdata/invoice_base_correct.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := validate.CheckArithmetic(base)
+	if len(findings) != 0 {
+		t.Fatalf("expected no findings, got %v", findings)
+	}
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		rule    string
+		message string
+		delta   float64
+	}{
+		{
+			name: "line extension",
+			old:  `<cbc:LineExtensionAmount currencyID="EUR">1300</cbc:LineExtensionAmount>`,
+			new:  `<cbc:LineExtensionAmount currencyID="EUR">1300.01</cbc:LineExtensionAmount>`,
+			rule: "BR-CO-10",
+		},
+		{
+			name:    "charge total",
+			old:     `<cbc:ChargeTotalAmount currencyID="EUR">25</cbc:ChargeTotalAmount>`,
+			new:     `<cbc:ChargeTotalAmount currencyID="EUR">25.01</cbc:ChargeTotalAmount>`,
+			rule:    "BR-CO-12",
+			message: "BR-CO-12 off by 0.01: sum of charges 25.00 ≠ chargeTotal 25.01",
+		},
+		{
+			name:    "tax exclusive",
+			old:     `<cbc:TaxExclusiveAmount currencyID="EUR">1325</cbc:TaxExclusiveAmount>`,
+			new:     `<cbc:TaxExclusiveAmount currencyID="EUR">1324.99</cbc:TaxExclusiveAmount>`,
+			rule:    "BR-CO-13",
+			message: "BR-CO-13 off by 0.01: lines 1300.00 − allowances 0.00 + charges 25.00 ≠ taxExclusive 1324.99",
+		},
+		{
+			name: "tax amount",
+			old:  `<cbc:TaxAmount currencyID="EUR">331.25</cbc:TaxAmount>`,
+			new:  `<cbc:TaxAmount currencyID="EUR">331.26</cbc:TaxAmount>`,
+			rule: "BR-CO-14",
+		},
+		{
+			name: "tax inclusive",
+			old:  `<cbc:TaxInclusiveAmount currencyID="EUR">1656.25</cbc:TaxInclusiveAmount>`,
+			new:  `<cbc:TaxInclusiveAmount currencyID="EUR">1656.24</cbc:TaxInclusiveAmount>`,
+			rule: "BR-CO-15",
+		},
+		{
+			name:    "payable",
+			old:     `<cbc:PayableAmount currencyID="EUR">1656.25</cbc:PayableAmount>`,
+			new:     `<cbc:PayableAmount currencyID="EUR">1656.26</cbc:PayableAmount>`,
+			rule:    "BR-CO-16",
+			message: "BR-CO-16 off by 0.01: taxInclusive 1656.25 − prepaid 0.00 + rounding 0.00 ≠ payable 1656.26",
+		},
+		{
+			name:    "subtotal tax",
+			old:     `<cbc:TaxableAmount currencyID="EUR">1325</cbc:TaxableAmount>`,
+			new:     `<cbc:TaxableAmount currencyID="EUR">1325.08</cbc:TaxableAmount>`,
+			rule:    "BR-CO-17",
+			message: "BR-CO-17 off by 0.02: category S taxable 1325.08 × 25% ≠ tax 331.25",
+			delta:   0.02,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(string(base), tt.old) {
+				t.Fatalf("test data does not contain %s", tt.old)
+			}
+			doc := []byte(strings.Replace(string(base), tt.old, tt.new, 1))
+
+			var found *validate.ArithmeticFinding
+			for _, f := range validate.CheckArithmetic(doc) {
+				if f.Rule == tt.rule {
+					found = &f
+				}
+			}
+			if found == nil {
+				t.Fatalf("expected a %s finding", tt.rule)
+			}
+			delta := cmp.Or(tt.delta, 0.01)
+			if d := found.Delta(); d != delta && d != -delta {
+				t.Errorf("expected a delta of %v, got %v", delta, d)
+			}
+			if tt.message != "" && found.Message != tt.message {
+				t.Errorf("expected %q, got %q", tt.message, found.Message)
+			}
+		})
+	}
+}
+
+func TestValidateArithmetic(t *testing.T) {
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	base, err := os.ReadFile("testdata/invoice_base_correct.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := strings.Replace(string(base),
+		`<cbc:PayableAmount currencyID="EUR">1656.25</cbc:PayableAmount>`,
+		`<cbc:PayableAmount currencyID="EUR">1656.26</cbc:PayableAmount>`, 1)
+
+	err = v.ValidateBytes([]byte(doc))
+	arithErr, ok := err.(validate.ArithmeticError)
+	if !ok {
+		t.Fatalf("expected an ArithmeticError, got %v", err)
+	}
+	if len(arithErr.Findings) != 1 || arithErr.Findings[0].Rule != "BR-CO-16" {
+		t.Errorf("expected a single BR-CO-16 finding, got %v", arithErr.Findings)
+	}
+
+	// a schema error is reported too
+	missing, err := os.ReadFile("testdata/invoice_missing_element.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = strings.Replace(string(missing),
+		`<cbc:PayableAmount currencyID="EUR">1656.25</cbc:PayableAmount>`,
+		`<cbc:PayableAmount currencyID="EUR">1656.26</cbc:PayableAmount>`, 1)
+	path := filepath.Join(t.TempDir(), "invoice.xml")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{v.ValidateBytes([]byte(doc)), v.Validate(path)} {
+		if !errors.As(err, &arithErr) || !strings.Contains(err.Error(), "This element is not expected") {
+			t.Errorf("expected the calculation and the schema error, got %v", err)
+		}
+	}
+}
+
+// TestCheckArithmeticHalfUp checks documents whose VAT is exactly half a cent
+// before rounding, which float arithmetic rounds down, e.g. 4.75 × 6% = 0.285.
+func TestCheckArithmeticHalfUp(t *testing.T) {
+	supplier := ubl.Party{Name: "ABC Supplies Ltd", Vat: "BE0123456789", PeppolID: "9925:BE0123456789", Address: ubl.Address{CountryCode: "BE"}}
+	customer := ubl.Party{Name: "XYZ Corp", Vat: "BE9876543210", PeppolID: "9925:BE9876543210", Address: ubl.Address{CountryCode: "BE"}}
+	for _, c := range []struct {
+		price, rate float64
+		tax         string
+	}{
+		{4.75, 6, "0.29"},
+		{11.50, 9, "1.04"},
+		{14.50, 7, "1.02"},
+		{16.75, 6, "1.01"},
+	} {
+		inv, err := ubl.SimpleInvoice("INV-12345", supplier, customer, []ubl.SimpleLine{{Name: "Product A", Quantity: 1, Price: c.price, VatPercent: c.rate}}, "BE71096123456769")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := validate.GenerateInvoice(inv)
+		if err != nil {
+			t.Errorf("%v at %v%%: %v", c.price, c.rate, err)
+			continue
+		}
+		if want := `<cbc:TaxAmount currencyID="EUR">` + c.tax + `</cbc:TaxAmount>`; !strings.Contains(string(data), want) {
+			t.Errorf("%v at %v%%: expected %s in output", c.price, c.rate, want)
+		}
+	}
+}
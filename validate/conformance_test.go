@@ -0,0 +1,331 @@
+package validate_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+// The conformance test runs the package over a snapshot of the Peppol BIS
+// Billing 3.0 validation artefacts, fetched with
+// testdata/conformance/fetch.sh. It is skipped unless UBL_CONFORMANCE_DIR
+// points to the snapshot.
+//
+// The examples are complete documents and must pass Validate, and ubl.Parse
+// must read them: without panicking, into the document type, ID and number
+// of lines listed in testdata/conformance/expected.txt. The unit tests
+// are test sets of (mostly partial) documents, each with the rules it must
+// pass or fail; only the calculation rules are checked for those, the other
+// rules are Schematron rules this package does not implement.
+
+// conformanceRules are the testbed rules implemented by CheckArithmetic.
+var conformanceRules = map[string]bool{
+	"BR-CO-10": true,
+	"BR-CO-11": true,
+	"BR-CO-12": true,
+	"BR-CO-13": true,
+	"BR-CO-14": true,
+	"BR-CO-15": true,
+	"BR-CO-16": true,
+	"BR-CO-17": true,
+}
+
+type conformanceAssert struct {
+	Success []string `xml:"success"`
+	Warning []string `xml:"warning"`
+	Error   []string `xml:"error"`
+	Fatal   []string `xml:"fatal"`
+}
+
+type conformanceTest struct {
+	assert conformanceAssert
+	doc    []byte
+}
+
+func TestConformance(t *testing.T) {
+	dir := os.Getenv("UBL_CONFORMANCE_DIR")
+	if dir == "" {
+		t.Skip("UBL_CONFORMANCE_DIR not set, see testdata/conformance/fetch.sh")
+	}
+	expected, err := readExpectedOutcomes("testdata/conformance/expected.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	examples, err := filepath.Glob(filepath.Join(dir, "examples", "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(examples) == 0 {
+		t.Fatalf("no examples in %s", dir)
+	}
+	for _, path := range examples {
+		key := relPath(dir, path)
+		want, ok := expected[key]
+		if !ok {
+			t.Logf("%s: not in expected.txt, only checked for validity", key)
+			want.verdict = "valid"
+		}
+		if want.verdict == "skip" {
+			continue
+		}
+		err := v.Validate(path)
+		if got := verdict(err); got != want.verdict {
+			t.Errorf("%s: expected %s, got %s (%v)", key, want.verdict, got, err)
+		}
+		if want.verdict == "valid" {
+			checkParse(t, key, path, want)
+		}
+	}
+
+	var checked, skipped int
+	err = filepath.WalkDir(filepath.Join(dir, "unit"), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".xml" {
+			return err
+		}
+		tests, err := readTestSet(path)
+		if err != nil {
+			t.Errorf("%s: %v", relPath(dir, path), err)
+			return nil
+		}
+		for i, test := range tests {
+			key := fmt.Sprintf("%s#%d", relPath(dir, path), i+1)
+			if expected[key].verdict == "skip" {
+				skipped++
+				continue
+			}
+			if !checkUnitTest(t, key, test) {
+				skipped++
+				continue
+			}
+			checked++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d examples, %d unit tests checked, %d skipped", len(examples), checked, skipped)
+}
+
+// TestConformanceParse runs the parser pass of TestConformance over the base
+// example that is kept in testdata, so it is checked without the snapshot.
+func TestConformanceParse(t *testing.T) {
+	checkParse(t, "invoice_base_correct.xml", "testdata/invoice_base_correct.xml", outcome{
+		verdict:      "valid",
+		documentType: ubl.DocumentTypeInvoice,
+		id:           "Snippet1",
+		lines:        2,
+	})
+}
+
+// checkParse parses a valid example with ubl.Parse and compares the result
+// with the expected outcome. Every example must at least have an ID, a line
+// and the names of both parties; the document type, ID and number of lines
+// are compared when they are listed.
+func checkParse(t *testing.T, key, path string, want outcome) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("%s: parse panicked: %v", key, r)
+		}
+	}()
+	doc, docType, err := ubl.Parse(f)
+	if err != nil {
+		t.Errorf("%s: parse: %v", key, err)
+		return
+	}
+
+	var id, supplier, customer string
+	var lines int
+	switch doc := doc.(type) {
+	case *ubl.Invoice:
+		id, supplier, customer, lines = doc.ID, doc.SupplierName, doc.CustomerName, len(doc.Lines)
+	case *ubl.CreditNote:
+		id, supplier, customer, lines = doc.ID, doc.SupplierName, doc.CustomerName, len(doc.Lines)
+	}
+	if id == "" || supplier == "" || customer == "" || lines == 0 {
+		t.Errorf("%s: expected an ID, the party names and lines, got %q, %q, %q and %d lines", key, id, supplier, customer, lines)
+	}
+	if want.documentType != "" && docType != want.documentType {
+		t.Errorf("%s: expected a %s, got a %s", key, want.documentType, docType)
+	}
+	if want.id != "" && id != want.id {
+		t.Errorf("%s: expected ID %q, got %q", key, want.id, id)
+	}
+	if want.lines != 0 && lines != want.lines {
+		t.Errorf("%s: expected %d lines, got %d", key, want.lines, lines)
+	}
+}
+
+// checkUnitTest compares the findings of CheckArithmetic with the rules of
+// the test. It reports false when the test asserts none of the rules
+// implemented.
+func checkUnitTest(t *testing.T, key string, test conformanceTest) bool {
+	t.Helper()
+
+	found := map[string]bool{}
+	for _, f := range validate.CheckArithmetic(test.doc) {
+		found[f.Rule] = true
+	}
+
+	covered := false
+	for _, rule := range test.assert.Success {
+		if conformanceRules[rule] {
+			covered = true
+			if found[rule] {
+				t.Errorf("%s: expected %s to pass", key, rule)
+			}
+		}
+	}
+	for _, rule := range append(test.assert.Error, test.assert.Fatal...) {
+		if conformanceRules[rule] {
+			covered = true
+			if !found[rule] {
+				t.Errorf("%s: expected %s to fail", key, rule)
+			}
+		}
+	}
+	return covered
+}
+
+func verdict(err error) string {
+	if err != nil {
+		return "invalid"
+	}
+	return "valid"
+}
+
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// outcome is the expected outcome of an example or unit test. The document
+// type, ID and number of lines are those ubl.Parse reads from a valid
+// example; they are left empty when they aren't listed.
+type outcome struct {
+	verdict      string
+	documentType ubl.DocumentType
+	id           string
+	lines        int
+}
+
+// readExpectedOutcomes reads the expected outcomes, keyed by path.
+func readExpectedOutcomes(path string) (map[string]outcome, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	expected := map[string]outcome{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 5 {
+			return nil, fmt.Errorf("%s: expected an outcome and at most the document type, ID and lines for %s", path, fields[0])
+		}
+		want := outcome{verdict: fields[1]}
+		switch want.verdict {
+		case "valid", "invalid", "skip":
+		default:
+			return nil, fmt.Errorf("%s: unknown outcome %q for %s", path, fields[1], fields[0])
+		}
+		if len(fields) > 2 {
+			want.documentType = ubl.DocumentType(fields[2])
+			switch want.documentType {
+			case ubl.DocumentTypeInvoice, ubl.DocumentTypeCreditNote:
+			default:
+				return nil, fmt.Errorf("%s: unknown document type %q for %s", path, fields[2], fields[0])
+			}
+		}
+		if len(fields) > 3 && fields[3] != "-" {
+			want.id = fields[3]
+		}
+		if len(fields) > 4 {
+			want.lines, err = strconv.Atoi(fields[4])
+			if err != nil {
+				return nil, fmt.Errorf("%s: lines of %s: %w", path, fields[0], err)
+			}
+		}
+		expected[fields[0]] = want
+	}
+	return expected, scanner.Err()
+}
+
+// readTestSet splits a testbed test set into its tests. Each test holds an
+// assert element followed by the document, which is returned as is.
+func readTestSet(path string) ([]conformanceTest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tests []conformanceTest
+	var current *conformanceTest
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case start.Name.Local == "test":
+			tests = append(tests, conformanceTest{})
+			current = &tests[len(tests)-1]
+		case current == nil:
+			// testSet and its own assert
+		case start.Name.Local == "assert":
+			err := d.DecodeElement(&current.assert, &start)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			err := d.Skip()
+			if err != nil {
+				return nil, err
+			}
+			current.doc = data[offset:d.InputOffset()]
+			current = nil
+		}
+	}
+	return tests, nil
+}
@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// ErrMalformed is returned by Validate for a document that is not well-formed
+// XML. Its message is the one ValidateBytes returns for such documents.
+var ErrMalformed = errors.New("Malformed xml document")
+
+// withoutAttachments returns the document with the content of its
+// EmbeddedDocumentBinaryObject elements left out but their line breaks
+// kept, which the schema validates with the same results as the original.
+// It reports false when an attachment isn't plain base64 or the document
+// has markup the filter doesn't follow, e.g. a CDATA section; the schema
+// must then see the original.
+func withoutAttachments(doc io.Reader) ([]byte, bool, error) {
+	content := &binaryContent{}
+	f := newBinaryObjectFilter(doc)
+	f.content = content
+
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(f)
+	if err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), !content.invalid, nil
+}
+
+// binaryContent checks the attachment text binaryObjectFilter drops and
+// counts its line breaks. Only unwrapped or line wrapped base64 with its
+// padding at the end passes; anything else marks it invalid.
+type binaryContent struct {
+	newlines int
+	cr       bool
+	count    int
+	pad      int
+	quantum  [4]byte
+	invalid  bool
+}
+
+func (c *binaryContent) write(text []byte) {
+	for _, ch := range text {
+		switch {
+		case ch == '\n':
+			if !c.cr {
+				c.newlines++
+			}
+			c.cr = false
+			continue
+		case ch == '\r':
+			c.newlines++
+			c.cr = true
+			continue
+		}
+		c.cr = false
+
+		switch {
+		case ch == '=':
+			c.pad++
+		case c.pad == 0 && (ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '+' || ch == '/'):
+		default:
+			c.invalid = true
+			continue
+		}
+		c.quantum[c.count%4] = ch
+		c.count++
+	}
+}
+
+// end checks the length and padding of an attachment's text.
+func (c *binaryContent) end() {
+	switch {
+	case c.count%4 != 0 || c.pad > 2:
+		c.invalid = true
+	case c.pad > 0:
+		_, err := base64.StdEncoding.Strict().DecodeString(string(c.quantum[:]))
+		if err != nil {
+			c.invalid = true
+		}
+	}
+	c.count, c.pad, c.cr = 0, 0, false
+}
@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/verscheures/ubl"
+)
+
+// GenerateInvoice generates the invoice like Invoice.Generate and validates it
+// like ValidateBytes: the calculation rules and the UBL 2.1 XSD. The bytes are
+// returned with the validation error too, to inspect or store them. Unlike
+// ValidateBytes it doesn't print the schema errors, they are only returned.
+//
+// The validator is shared by all calls and kept for the life of the process,
+// so the schema is compiled once, on first use. It lives in this package
+// rather than in ubl so that generating without validating needs no cgo.
+func GenerateInvoice(inv *ubl.Invoice) ([]byte, error) {
+	data, err := inv.Generate()
+	if err != nil {
+		return nil, err
+	}
+	return data, validateGenerated(data, "invoice")
+}
+
+// GenerateCreditNote generates and validates the credit note, like
+// GenerateInvoice.
+func GenerateCreditNote(cn *ubl.CreditNote) ([]byte, error) {
+	data, err := cn.GenerateCreditNote()
+	if err != nil {
+		return nil, err
+	}
+	return data, validateGenerated(data, "credit note")
+}
+
+// shared is the validator of GenerateInvoice and GenerateCreditNote. It is
+// safe for concurrent use and compiles each schema once.
+var shared struct {
+	once sync.Once
+	v    *Validate
+	err  error
+}
+
+func validateGenerated(data []byte, kind string) error {
+	shared.once.Do(func() {
+		shared.v, shared.err = New()
+	})
+	if shared.err != nil {
+		return fmt.Errorf("validator: %w", shared.err)
+	}
+	if err := shared.v.validateBytes(data, nil); err != nil {
+		return fmt.Errorf("generated %s invalid: %w", kind, err)
+	}
+	return nil
+}
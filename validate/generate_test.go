@@ -0,0 +1,104 @@
+package validate_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+	"github.com/verscheures/ubl/validate"
+)
+
+func TestGenerateInvoice(t *testing.T) {
+	supplier := ubl.Party{Name: "ABC Supplies Ltd", Vat: "BE0123456789", PeppolID: "9925:BE0123456789", Address: ubl.Address{CountryCode: "BE"}}
+	customer := ubl.Party{Name: "XYZ Corp", Vat: "BE9876543210", PeppolID: "9925:BE9876543210", Address: ubl.Address{CountryCode: "BE"}}
+	inv, err := ubl.SimpleInvoice("INV-12345", supplier, customer, []ubl.SimpleLine{{Name: "Product A", Quantity: 10, Price: 100, VatPercent: 21}}, "BE71096123456769")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.Now = func() time.Time { return time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC) }
+	want, err := inv.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the validator is shared, also between goroutines
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := validate.GenerateInvoice(inv)
+			if err != nil || !bytes.Equal(data, want) {
+				t.Errorf("expected the generated invoice, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	inv.BeforeMarshal = func(doc *ubl.XMLInvoice) error {
+		doc.IssueDate = "10/03/2025"
+		return nil
+	}
+	var data []byte
+	out := captureStdout(t, func() {
+		data, err = validate.GenerateInvoice(inv)
+	})
+	if out != "" {
+		t.Errorf("expected the validation error only returned, got output %q", out)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "generated invoice invalid: ") {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+	if !strings.Contains(string(data), "<cbc:IssueDate>10/03/2025</cbc:IssueDate>") {
+		t.Errorf("expected the invalid invoice with the error, got %s", data)
+	}
+
+	cn := &ubl.CreditNote{
+		ID:               "CN-12345",
+		SupplierName:     inv.SupplierName,
+		SupplierVat:      inv.SupplierVat,
+		SupplierPeppolID: inv.SupplierPeppolID,
+		SupplierAddress:  inv.SupplierAddress,
+		CustomerName:     inv.CustomerName,
+		CustomerVat:      inv.CustomerVat,
+		CustomerPeppolID: inv.CustomerPeppolID,
+		CustomerAddress:  inv.CustomerAddress,
+		Iban:             inv.Iban,
+		Lines:            inv.Lines,
+	}
+	if _, err := validate.GenerateCreditNote(cn); err != nil {
+		t.Error(err)
+	}
+	cn.BeforeMarshal = func(doc *ubl.XMLCreditNote) error {
+		doc.IssueDate = "10/03/2025"
+		return nil
+	}
+	if _, err := validate.GenerateCreditNote(cn); err == nil || !strings.HasPrefix(err.Error(), "generated credit note invalid: ") {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = stdout
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
@@ -0,0 +1,26 @@
+#!/bin/sh
+# Fetches a snapshot of the Peppol BIS Billing 3.0 validation artefacts for
+# the conformance test:
+#
+#   ./fetch.sh /tmp/peppol-testbed [ref]
+#   UBL_CONFORMANCE_DIR=/tmp/peppol-testbed go test -run Conformance ./validate
+#
+# ref is a tag or branch of github.com/OpenPEPPOL/peppol-bis-invoice-3 and
+# defaults to master. Pin a release tag to keep the verdicts reproducible.
+set -eu
+
+dir=${1:?usage: fetch.sh dir [ref]}
+ref=${2:-master}
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL "https://github.com/OpenPEPPOL/peppol-bis-invoice-3/archive/$ref.tar.gz" | tar -xz -C "$tmp"
+src=$(echo "$tmp"/peppol-bis-invoice-3-*)
+
+mkdir -p "$dir/examples" "$dir/unit"
+cp "$src"/rules/examples/*.xml "$dir/examples/"
+for unit in "$src"/rules/unit-*; do
+	cp -R "$unit" "$dir/unit/"
+done
+echo "$ref" > "$dir/REF"
@@ -1,11 +1,16 @@
 package validate
 
 import (
+	"bytes"
 	"embed"
+	"encoding/xml"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 
 	xsdvalidate "github.com/terminalstatic/go-xsd-validate"
 )
@@ -13,65 +18,259 @@ import (
 //go:embed "xsd"
 var xsdFiles embed.FS
 
+// Document types, named after the root element of the UBL document. Any
+// root element with a schema in xsd/maindoc is supported.
+const (
+	Invoice             = "Invoice"
+	CreditNote          = "CreditNote"
+	Order               = "Order"
+	DespatchAdvice      = "DespatchAdvice"
+	ApplicationResponse = "ApplicationResponse"
+)
+
+// Validate validates UBL documents against the UBL 2.1 XSD. The schema of a
+// document type is compiled on first use, unless it was preloaded.
 type Validate struct {
-	xsdhandler *xsdvalidate.XsdHandler
-	xsdPath    string
+	mu          sync.Mutex
+	xsdhandlers map[string]*xsdvalidate.XsdHandler
+	xsdPath     string
+	freed       bool
+}
+
+// SchemaInfo describes a compiled schema.
+type SchemaInfo struct {
+	DocumentType string
+	Path         string
 }
 
-func New() (*Validate, error) {
+// New returns a validator that compiles the schemas lazily. The given
+// document types are compiled right away.
+func New(preload ...string) (*Validate, error) {
 	xsdPath, err := extractXSDs()
 	if err != nil {
 		return nil, fmt.Errorf("extracting XSD's: %w", err)
 	}
 
-	err = xsdvalidate.Init()
+	err = initLibxml()
 	if err != nil {
 		return nil, err
 	}
 
-	fullpath := filepath.Join(xsdPath, "maindoc/UBL-Invoice-2.1.xsd")
-
 	v := &Validate{}
 	v.xsdPath = xsdPath
-	v.xsdhandler, err = xsdvalidate.NewXsdHandlerUrl(fullpath, xsdvalidate.ParsErrVerbose)
-	return v, err
+	v.xsdhandlers = make(map[string]*xsdvalidate.XsdHandler)
+
+	for _, docType := range preload {
+		_, err = v.handler(docType)
+		if err != nil {
+			v.Free()
+			return nil, err
+		}
+	}
+
+	return v, nil
 }
 
+// NewInvoice returns a validator with the Invoice schema preloaded.
+func NewInvoice() (*Validate, error) {
+	return New(Invoice)
+}
+
+// NewCreditNote returns a validator with the CreditNote schema preloaded.
+func NewCreditNote() (*Validate, error) {
+	return New(CreditNote)
+}
+
+// Free releases the schemas that were compiled and the extracted XSD files.
 func (v *Validate) Free() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.freed {
+		return
+	}
+	v.freed = true
+
 	_ = os.RemoveAll(v.xsdPath)
-	v.xsdhandler.Free()
-	xsdvalidate.Cleanup()
+	for docType, h := range v.xsdhandlers {
+		h.Free()
+		delete(v.xsdhandlers, docType)
+	}
+	cleanupLibxml()
 }
 
-func (v *Validate) Validate(filename string) error {
+// libxml2 is initialized once for all validators in use.
+var libxml struct {
+	sync.Mutex
+	users int
+}
+
+func initLibxml() error {
+	libxml.Lock()
+	defer libxml.Unlock()
+
+	if libxml.users == 0 {
+		err := xsdvalidate.Init()
+		if err != nil {
+			return err
+		}
+	}
+	libxml.users++
+	return nil
+}
+
+func cleanupLibxml() {
+	libxml.Lock()
+	defer libxml.Unlock()
+
+	libxml.users--
+	if libxml.users == 0 {
+		xsdvalidate.Cleanup()
+	}
+}
+
+// SchemaInfo returns the schemas compiled so far, by ValidateBytes or
+// Validate, sorted by document type.
+func (v *Validate) SchemaInfo() []SchemaInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var infos []SchemaInfo
+	for docType := range v.xsdhandlers {
+		infos = append(infos, SchemaInfo{
+			DocumentType: docType,
+			Path:         v.schemaPath(docType),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].DocumentType < infos[j].DocumentType })
+	return infos
+}
+
+func (v *Validate) schemaPath(docType string) string {
+	return filepath.Join(v.xsdPath, "maindoc", "UBL-"+docType+"-2.1.xsd")
+}
+
+// handler returns the compiled schema for docType, compiling it when needed.
+func (v *Validate) handler(docType string) (*xsdvalidate.XsdHandler, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if h, ok := v.xsdhandlers[docType]; ok {
+		return h, nil
+	}
+
+	err := extractFile(v.xsdPath, "maindoc", "UBL-"+docType+"-2.1.xsd")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no schema for document type %q", docType)
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	h, err := xsdvalidate.NewXsdHandlerUrl(v.schemaPath(docType), xsdvalidate.ParsErrVerbose)
+	if err != nil {
+		return nil, err
+	}
+	v.xsdhandlers[docType] = h
+	return h, nil
+}
+
+// Validate validates the document in a file like ValidateBytes does, with
+// the same results, but without its attachments in memory.
+//
+// Memory: ValidateBytes holds the document three times, the Go slice, the
+// copy handed to libxml2 and the libxml2 document tree. Validate reads the
+// file as a stream and leaves out the content of embedded attachments,
+// keeping their line breaks, so all three hold the document without them.
+// Attachments that aren't plain base64, which the schema may reject, and
+// documents with CDATA sections, comments or a DOCTYPE are read whole, like
+// ValidateBytes does.
+//
+// Malformed documents return ErrMalformed.
+func (v *Validate) Validate(filename string) error {
 	xmlFile, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
 	defer xmlFile.Close()
-	inXml, err := ioutil.ReadAll(xmlFile)
+
+	doc, ok, err := withoutAttachments(xmlFile)
 	if err != nil {
 		return err
 	}
+	if !ok {
+		doc, err = os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+	}
 
-	return v.ValidateBytes(inXml)
+	err = v.ValidateBytes(doc)
+	if _, malformed := err.(xsdvalidate.XmlParserError); malformed {
+		return ErrMalformed
+	}
+	return err
 }
 
+// ValidateBytes checks the calculation rules with CheckArithmetic and
+// validates the document against the XSD of its root element. Both checks
+// run, so a calculation error doesn't hide a schema error.
 func (v *Validate) ValidateBytes(xml []byte) error {
-	err := v.xsdhandler.ValidateMem(xml, xsdvalidate.ValidErrDefault)
-	if err != nil {
-		switch err.(type) {
-		case xsdvalidate.ValidationError:
-			fmt.Println(err)
-			fmt.Printf("Error in line: %d\n", err.(xsdvalidate.ValidationError).Errors[0].Line)
-			fmt.Println(err.(xsdvalidate.ValidationError).Errors[0].Message)
-		default:
-			fmt.Println(err)
+	return v.validateBytes(xml, printValidationError)
+}
+
+// validateBytes is ValidateBytes with the schema errors passed to report,
+// when not nil, e.g. to validate without printing them.
+func (v *Validate) validateBytes(xml []byte, report func(error)) error {
+	findings := checkArithmetic(bytes.NewReader(xml))
+
+	xsdhandler, err := v.handler(detectRoot(bytes.NewReader(xml)))
+	if err == nil {
+		err = xsdhandler.ValidateMem(xml, xsdvalidate.ValidErrDefault)
+		if err != nil && report != nil {
+			report(err)
 		}
 	}
+	return joinFindings(findings, err)
+}
 
-	return err
+// joinFindings returns the ArithmeticError for the findings joined with the
+// schema error. Either one alone is returned as is, e.g. ErrMalformed.
+func joinFindings(findings []ArithmeticFinding, err error) error {
+	switch {
+	case len(findings) == 0:
+		return err
+	case err == nil:
+		return ArithmeticError{Findings: findings}
+	}
+	return errors.Join(ArithmeticError{Findings: findings}, err)
+}
+
+func printValidationError(err error) {
+	switch err.(type) {
+	case xsdvalidate.ValidationError:
+		fmt.Println(err)
+		fmt.Printf("Error in line: %d\n", err.(xsdvalidate.ValidationError).Errors[0].Line)
+		fmt.Println(err.(xsdvalidate.ValidationError).Errors[0].Message)
+	default:
+		fmt.Println(err)
+	}
+}
+
+// detectRoot returns the local name of the root element. When the document
+// can't be read up to the root element, it falls back to Invoice and leaves
+// the reporting to the schema validation.
+func detectRoot(doc io.Reader) string {
+	d := xml.NewDecoder(doc)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return Invoice
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local
+		}
+	}
 }
 
 func extractXSDs() (string, error) {
@@ -80,7 +279,7 @@ func extractXSDs() (string, error) {
 		return "", fmt.Errorf("create temp dir for xsd: %w", err)
 	}
 
-	err = extractPath(tempDir, "maindoc")
+	err = os.Mkdir(filepath.Join(tempDir, "maindoc"), 0755)
 	if err != nil {
 		return "", err
 	}
@@ -107,11 +306,7 @@ func extractPath(tempDir, subpath string) error {
 	}
 
 	for _, entry := range entries {
-		content, err := xsdFiles.ReadFile(filepath.Join(p, entry.Name()))
-		if err != nil {
-			return err
-		}
-		err = os.WriteFile(filepath.Join(tempDir, subpath, entry.Name()), content, 0644)
+		err = extractFile(tempDir, subpath, entry.Name())
 		if err != nil {
 			return err
 		}
@@ -119,3 +314,11 @@ func extractPath(tempDir, subpath string) error {
 
 	return nil
 }
+
+func extractFile(tempDir, subpath, name string) error {
+	content, err := xsdFiles.ReadFile(filepath.Join("xsd", subpath, name))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(tempDir, subpath, name), content, 0644)
+}
@@ -1,6 +1,9 @@
 package validate_test
 
 import (
+	"bufio"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -36,3 +39,230 @@ func TestValidate(t *testing.T) {
 		t.Errorf("expected 'This element is not expected' but got %v", err)
 	}
 }
+
+func TestSchemaLoading(t *testing.T) {
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	if len(v.SchemaInfo()) != 0 {
+		t.Errorf("expected no compiled schemas, got %v", v.SchemaInfo())
+	}
+
+	err = v.Validate("testdata/invoice_base_correct.xml")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = v.Validate("testdata/invoice_base_correct.xml")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	infos := v.SchemaInfo()
+	if len(infos) != 1 || infos[0].DocumentType != validate.Invoice {
+		t.Errorf("expected only the Invoice schema, got %v", infos)
+	}
+
+	err = v.ValidateBytes([]byte(`<Unknown xmlns="urn:example"/>`))
+	if err == nil || !strings.Contains(err.Error(), `no schema for document type "Unknown"`) {
+		t.Errorf("expected a missing schema error, got %v", err)
+	}
+}
+
+func TestSchemaPreload(t *testing.T) {
+	v, err := validate.NewCreditNote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	infos := v.SchemaInfo()
+	if len(infos) != 1 || infos[0].DocumentType != validate.CreditNote {
+		t.Errorf("expected only the CreditNote schema, got %v", infos)
+	}
+
+	v2, err := validate.New(validate.Invoice, validate.DespatchAdvice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v2.Free()
+
+	infos = v2.SchemaInfo()
+	if len(infos) != 2 || infos[0].DocumentType != validate.DespatchAdvice || infos[1].DocumentType != validate.Invoice {
+		t.Errorf("expected the DespatchAdvice and Invoice schemas, got %v", infos)
+	}
+}
+
+// writeLargeDocument writes the base invoice with an embedded attachment of
+// size bytes of base64 text and returns its path.
+func writeLargeDocument(tb testing.TB, size int) string {
+	tb.Helper()
+
+	base, err := os.ReadFile("testdata/invoice_base_correct.xml")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	head, tail, ok := strings.Cut(string(base), "<cac:AccountingSupplierParty>")
+	if !ok {
+		tb.Fatal("no AccountingSupplierParty in base document")
+	}
+
+	path := filepath.Join(tb.TempDir(), "large.xml")
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString(head)
+	w.WriteString(`<cac:AdditionalDocumentReference><cbc:ID>large</cbc:ID><cac:Attachment>`)
+	w.WriteString(`<cbc:EmbeddedDocumentBinaryObject mimeCode="application/pdf" filename="large.pdf">`)
+	chunk := strings.Repeat("QUJD", 1024)
+	for written := 0; written < size; written += len(chunk) {
+		w.WriteString(chunk)
+	}
+	w.WriteString(`</cbc:EmbeddedDocumentBinaryObject></cac:Attachment></cac:AdditionalDocumentReference>`)
+	w.WriteString("<cac:AccountingSupplierParty>" + tail)
+	err = w.Flush()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func sameResult(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Error() == b.Error()
+}
+
+func TestValidateFileMatchesBytes(t *testing.T) {
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	paths := []string{
+		"testdata/invoice_base_correct.xml",
+		"testdata/invoice_syntax_error.xml",
+		"testdata/invoice_missing_element.xml",
+		writeLargeDocument(t, 1<<20),
+	}
+	for _, path := range paths {
+		doc, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileErr := v.Validate(path)
+		bytesErr := v.ValidateBytes(doc)
+		if !sameResult(fileErr, bytesErr) {
+			t.Errorf("%s: Validate returned %v, ValidateBytes %v", filepath.Base(path), fileErr, bytesErr)
+		}
+	}
+
+	err = v.Validate("testdata/invoice_syntax_error.xml")
+	if err != validate.ErrMalformed {
+		t.Errorf("expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestValidateFileAttachmentContent(t *testing.T) {
+	v, err := validate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Free()
+
+	base, err := os.ReadFile("testdata/invoice_base_correct.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, tail, ok := strings.Cut(string(base), "<cac:AccountingSupplierParty>")
+	if !ok {
+		t.Fatal("no AccountingSupplierParty in base document")
+	}
+	// an element the schema rejects after the attachment, so the line
+	// numbers of the errors are compared too
+	invalidTail := strings.Replace(tail, "<cbc:PayableAmount ", "<cbc:PayableAmountX ", 1)
+	invalidTail = strings.Replace(invalidTail, "</cbc:PayableAmount>", "</cbc:PayableAmountX>", 1)
+
+	for name, content := range map[string]string{
+		"wrapped":        strings.Repeat("QUJD\n", 10) + "QQ==",
+		"crlf":           strings.Repeat("QUJD\r\n", 10) + "QUI=\r\n",
+		"empty":          "",
+		"not base64":     "QUJD\nQ!JD",
+		"bad padding":    "QQ=A",
+		"padding bits":   "QR==",
+		"short":          "QUJ",
+		"character refs": "QUJD&#10;QUJD",
+	} {
+		for _, rest := range []string{tail, invalidTail} {
+			doc := head + `<cac:AdditionalDocumentReference><cbc:ID>a</cbc:ID><cac:Attachment>` +
+				`<cbc:EmbeddedDocumentBinaryObject mimeCode="application/pdf" filename="a.pdf">` + content +
+				`</cbc:EmbeddedDocumentBinaryObject></cac:Attachment></cac:AdditionalDocumentReference>` +
+				"<cac:AccountingSupplierParty>" + rest
+			path := filepath.Join(t.TempDir(), "doc.xml")
+			err := os.WriteFile(path, []byte(doc), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileErr := v.Validate(path)
+			bytesErr := v.ValidateBytes([]byte(doc))
+			if !sameResult(fileErr, bytesErr) {
+				t.Errorf("%s: Validate returned %v, ValidateBytes %v", name, fileErr, bytesErr)
+			}
+		}
+	}
+}
+
+// BenchmarkValidateLarge compares the memory use of ValidateBytes and
+// Validate for a 100 MB document. Run with -benchmem: ValidateBytes allocates
+// the document in Go (and copies it to libxml2), Validate only the document
+// without its attachment.
+func BenchmarkValidateLarge(b *testing.B) {
+	path := writeLargeDocument(b, 100<<20)
+
+	v, err := validate.New(validate.Invoice)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer v.Free()
+
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fileErr, bytesErr := v.Validate(path), v.ValidateBytes(doc)
+	if !sameResult(fileErr, bytesErr) {
+		b.Fatalf("Validate returned %v, ValidateBytes %v", fileErr, bytesErr)
+	}
+	doc = nil
+
+	b.Run("bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			doc, err := os.ReadFile(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			err = v.ValidateBytes(doc)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("file", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			err := v.Validate(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package ubl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InvoiceView holds the display-ready values of an invoice, e.g. for the
+// "your invoice is ready" email. All values are formatted strings so the view
+// can be used in html/template as is.
+type InvoiceView struct {
+	ID               string
+	IssueDate        string
+	DueDate          string
+	PaymentReference string // empty, the document has no payment ID (BT-83)
+	SupplierName     string
+	CustomerName     string
+	Iban             string
+	Lines            []LineView
+	TaxBreakdown     []TaxRowView
+	LineTotal        string
+	AllowanceTotal   string // empty when there are no allowances
+	ChargeTotal      string // empty when there are no charges
+	TaxExclusive     string
+	Tax              string
+	Payable          string
+}
+
+type LineView struct {
+	Name        string
+	Description string
+	Quantity    string
+	Price       string
+	Amount      string
+	TaxPercent  string
+}
+
+type TaxRowView struct {
+	CategoryID string
+	Percent    string
+	Taxable    string
+	Tax        string
+}
+
+// locale holds the date and number formats of a language.
+type locale struct {
+	months         [12]string
+	dateFormat     string // with %d for the day, %s for the month and %d for the year
+	decimal        string
+	thousands      string
+	currencyBefore bool
+	percentSpace   bool
+}
+
+var locales = map[string]locale{
+	"en": {
+		months:         [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		dateFormat:     "%d %s %d",
+		decimal:        ".",
+		thousands:      ",",
+		currencyBefore: true,
+	},
+	"nl": {
+		months:         [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		dateFormat:     "%d %s %d",
+		decimal:        ",",
+		thousands:      ".",
+		currencyBefore: true,
+	},
+	"fr": {
+		months:       [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		dateFormat:   "%d %s %d",
+		decimal:      ",",
+		thousands:    "\u202f",
+		percentSpace: true,
+	},
+	"de": {
+		months:     [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		dateFormat: "%d. %s %d",
+		decimal:    ",",
+		thousands:  ".",
+	},
+}
+
+// lookupLocale returns the locale for a language tag like "nl-BE", falling
+// back from the region to the language and finally to English.
+func lookupLocale(tag string) locale {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	if l, ok := locales[strings.ToLower(lang)]; ok {
+		return l
+	}
+	return locales["en"]
+}
+
+func (l locale) date(t time.Time) string {
+	return fmt.Sprintf(l.dateFormat, t.Day(), l.months[t.Month()-1], t.Year())
+}
+
+// number formats v with the given number of decimals, or with as many as
+// needed when decimals is negative.
+func (l locale) number(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.thousands)
+		}
+		b.WriteRune(c)
+	}
+	if fraction != "" {
+		b.WriteString(l.decimal)
+		b.WriteString(fraction)
+	}
+	return sign + b.String()
+}
+
+// amount formats v with the currency symbol, separated by a non-breaking
+// space so the amount isn't wrapped.
+func (l locale) amount(v float64, currency string) string {
+	return l.money(v, minorUnits(currency), currency)
+}
+
+// price formats a unit price like amount but at its full precision, e.g.
+// € 0.04753, with at least the minor units of the currency.
+func (l locale) price(v float64, currency string) string {
+	decimals := minorUnits(currency)
+	_, fraction, _ := strings.Cut(strconv.FormatFloat(v, 'f', -1, 64), ".")
+	return l.money(v, max(decimals, len(fraction)), currency)
+}
+
+func (l locale) money(v float64, decimals int, currency string) string {
+	symbol := currency
+	if currency == "EUR" {
+		symbol = "€"
+	}
+	if l.currencyBefore {
+		return symbol + "\u00a0" + l.number(v, decimals)
+	}
+	return l.number(v, decimals) + "\u00a0" + symbol
+}
+
+func (l locale) percent(v float64) string {
+	if l.percentSpace {
+		return l.number(v, -1) + "\u00a0%"
+	}
+	return l.number(v, -1) + "%"
+}
+
+// ViewModel returns the display-ready values of the invoice, with dates and
+// numbers formatted for the language tag (e.g. "en", "nl-BE"). Unknown
+// languages are formatted as English. The amounts are computed like Generate
+// computes them.
+func (inv *Invoice) ViewModel(lang string) (InvoiceView, error) {
+	currency := inv.currency()
+	decimals := minorUnits(currency)
+	totals, err := inv.Totals()
+	if err != nil {
+		return InvoiceView{}, err
+	}
+
+	l := lookupLocale(lang)
+	now := inv.now()
+	view := InvoiceView{
+		ID:           inv.ID,
+		IssueDate:    l.date(now),
+		DueDate:      l.date(now.AddDate(0, 0, 30)),
+		SupplierName: inv.SupplierName,
+		CustomerName: inv.CustomerName,
+		Iban:         bankAccounts(inv.Iban, inv.Bic, inv.AccountName, inv.BankAccounts)[0].Iban,
+		LineTotal:    l.amount(totals.LineExtension, currency),
+		TaxExclusive: l.amount(totals.TaxExclusive, currency),
+		Tax:          l.amount(totals.Tax, currency),
+		Payable:      l.amount(totals.Payable, currency),
+	}
+	for _, ac := range totals.AllowanceCharges {
+		if ac.Charge {
+			view.ChargeTotal = l.amount(totals.ChargeTotal, currency)
+		} else {
+			view.AllowanceTotal = l.amount(totals.AllowanceTotal, currency)
+		}
+	}
+
+	amounts, _ := lineAmounts(inv.Lines, decimals)
+	for i, line := range inv.Lines {
+		view.Lines = append(view.Lines, LineView{
+			Name:        line.Name,
+			Description: line.Description,
+			Quantity:    l.number(line.Quantity, -1),
+			Price:       l.price(line.netPrice(decimals), currency),
+			Amount:      l.amount(amounts[i].float(decimals), currency),
+			TaxPercent:  l.percent(line.taxCategory().rate()),
+		})
+	}
+
+	for _, row := range totals.Breakdown {
+		view.TaxBreakdown = append(view.TaxBreakdown, TaxRowView{
+			CategoryID: row.CategoryID,
+			Percent:    l.percent(row.Percent),
+			Taxable:    l.amount(row.TaxableAmount, currency),
+			Tax:        l.amount(row.TaxAmount, currency),
+		})
+	}
+
+	return view, nil
+}
@@ -0,0 +1,95 @@
+package ubl_test
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verscheures/ubl"
+)
+
+func TestViewModel(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 2.5, Price: 1234.5, TaxPercentage: 21, Name: "Consulting"},
+	}
+	inv.Now = func() time.Time { return time.Date(2025, 3, 9, 23, 59, 59, 0, time.UTC) }
+
+	tests := []struct {
+		lang     string
+		date     string
+		quantity string
+		price    string
+		payable  string
+		percent  string
+	}{
+		{"en", "9 March 2025", "2.5", "€\u00a01,234.50", "€\u00a03,734.36", "21%"},
+		{"nl-BE", "", "2,5", "€\u00a01.234,50", "€\u00a03.734,36", "21%"},
+	}
+	for _, tt := range tests {
+		view, err := inv.ViewModel(tt.lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.date != "" && view.IssueDate != tt.date {
+			t.Errorf("%s: expected issue date %q, got %q", tt.lang, tt.date, view.IssueDate)
+		}
+		line := view.Lines[0]
+		if line.Quantity != tt.quantity || line.Price != tt.price || line.TaxPercent != tt.percent {
+			t.Errorf("%s: unexpected line %+v", tt.lang, line)
+		}
+		if view.Payable != tt.payable {
+			t.Errorf("%s: expected payable %q, got %q", tt.lang, tt.payable, view.Payable)
+		}
+		if view.PaymentReference != "" {
+			t.Errorf("%s: expected no payment reference, got %q", tt.lang, view.PaymentReference)
+		}
+	}
+
+	view, err := inv.ViewModel("nl-BE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.IssueDate != "9 maart 2025" || view.DueDate != "8 april 2025" {
+		t.Errorf("expected issue date 9 maart 2025 and due date 8 april 2025, got %q and %q", view.IssueDate, view.DueDate)
+	}
+	if len(view.TaxBreakdown) != 1 || view.TaxBreakdown[0].Taxable != "€\u00a03.086,25" || view.TaxBreakdown[0].Tax != "€\u00a0648,11" {
+		t.Errorf("unexpected tax breakdown %+v", view.TaxBreakdown)
+	}
+
+	tmpl := template.Must(template.New("mail").Parse(`{{.ID}}: {{.Payable}}`))
+	var b strings.Builder
+	err = tmpl.Execute(&b, view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "INV-12345: €\u00a03.734,36" {
+		t.Errorf("unexpected template output %q", b.String())
+	}
+}
+
+func TestViewModelLines(t *testing.T) {
+	inv := newTestInvoice()
+	inv.Lines = []ubl.InvoiceLine{
+		{Quantity: 10000, Price: 0.04753, TaxPercentage: 21, Name: "Messages"},
+		{Quantity: 1, Price: 100, TaxPercentage: 21, TaxCategoryID: "AE", Name: "Installation"},
+	}
+
+	view, err := inv.ViewModel("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := view.Lines[0].Price; got != "€\u00a00.04753" {
+		t.Errorf("expected the price at full precision, got %q", got)
+	}
+	if got := view.Lines[0].Amount; got != "€\u00a0475.30" {
+		t.Errorf("expected amount €\u00a0475.30, got %q", got)
+	}
+	if got := view.Lines[1].Price; got != "€\u00a0100.00" {
+		t.Errorf("expected price €\u00a0100.00, got %q", got)
+	}
+	if got := view.Lines[1].TaxPercent; got != "0%" {
+		t.Errorf("expected reverse charge line at 0%%, got %q", got)
+	}
+}
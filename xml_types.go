@@ -2,175 +2,370 @@ package ubl
 
 import "encoding/xml"
 
-type xmlInvoice struct {
+// XMLInvoice is the low-level model of the generated invoice, as marshalled
+// to XML. It only has the elements the package writes, with unexported types
+// below the root, and changes with the package: prefer the Invoice fields and
+// use it for what they don't cover, see Invoice.BeforeMarshal.
+type XMLInvoice struct {
 	XMLName                     xml.Name               `xml:"Invoice"`
 	Xmlns                       string                 `xml:"xmlns,attr"`
 	Cac                         string                 `xml:"xmlns:cac,attr"`
 	Cbc                         string                 `xml:"xmlns:cbc,attr"`
+	Ext                         string                 `xml:"xmlns:ext,attr,omitempty"`
+	UBLExtensions               *XMLUBLExtensions      `xml:"ext:UBLExtensions,omitempty"`
 	CustomizationID             string                 `xml:"cbc:CustomizationID"`
 	ProfileID                   string                 `xml:"cbc:ProfileID"`
 	ID                          string                 `xml:"cbc:ID"`
+	UUID                        string                 `xml:"cbc:UUID,omitempty"`
 	IssueDate                   string                 `xml:"cbc:IssueDate"`
 	DueDate                     string                 `xml:"cbc:DueDate"`
 	InvoiceTypeCode             string                 `xml:"cbc:InvoiceTypeCode"`
+	Note                        []string               `xml:"cbc:Note"`
 	DocumentCurrency            string                 `xml:"cbc:DocumentCurrencyCode"`
 	BuyerReference              string                 `xml:"cbc:BuyerReference,omitempty"`
-	InvoicePeriod               *xmlInvoicePeriod      `xml:"cac:InvoicePeriod,omitempty"`
-	OrderReference              string                 `xml:"cac:OrderReference>cbc:ID"`
-	AdditionalDocumentReference []xmlDocumentReference `xml:"cac:AdditionalDocumentReference"`
-	SupplierParty               xmlSupplierParty       `xml:"cac:AccountingSupplierParty"`
-	CustomerParty               xmlCustomerParty       `xml:"cac:AccountingCustomerParty"`
-	Delivery                    *xmlDelivery           `xml:"cac:Delivery,omitempty"`
-	PaymentMeans                xmlPaymentMeans        `xml:"cac:PaymentMeans"`
-	PaymentTerms                xmlPaymentTerms        `xml:"cac:PaymentTerms"`
-	TaxTotal                    xmlTaxTotal            `xml:"cac:TaxTotal"`
-	LegalMonetaryTotal          xmlMonetaryTotal       `xml:"cac:LegalMonetaryTotal"`
-	InvoiceLines                []xmlInvoiceLine       `xml:"cac:InvoiceLine"`
+	InvoicePeriod               *XMLInvoicePeriod      `xml:"cac:InvoicePeriod,omitempty"`
+	OrderReference              *XMLOrderReference     `xml:"cac:OrderReference,omitempty"`
+	BillingReference            []XMLBillingReference  `xml:"cac:BillingReference"`
+	AdditionalDocumentReference []XMLDocumentReference `xml:"cac:AdditionalDocumentReference"`
+	Signature                   []XMLSignature         `xml:"cac:Signature"`
+	SupplierParty               XMLSupplierParty       `xml:"cac:AccountingSupplierParty"`
+	CustomerParty               XMLCustomerParty       `xml:"cac:AccountingCustomerParty"`
+	Delivery                    *XMLDelivery           `xml:"cac:Delivery,omitempty"`
+	PaymentMeans                []XMLPaymentMeans      `xml:"cac:PaymentMeans"`
+	PaymentTerms                *XMLPaymentTerms       `xml:"cac:PaymentTerms,omitempty"`
+	AllowanceCharge             []XMLAllowanceCharge   `xml:"cac:AllowanceCharge"`
+	TaxTotal                    XMLTaxTotal            `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal          XMLMonetaryTotal       `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLines                []XMLInvoiceLine       `xml:"cac:InvoiceLine"`
 }
 
-type xmlDocumentReference struct {
-	ID                  string          `xml:"cbc:ID"`
-	DocumentDescription string          `xml:"cbc:DocumentDescription"`
-	Attachment          []xmlAttachment `xml:"cac:Attachment"`
+// XMLOrderReference is cac:OrderReference.
+type XMLOrderReference struct {
+	ID           string `xml:"cbc:ID"`
+	SalesOrderID string `xml:"cbc:SalesOrderID,omitempty"`
 }
 
-type xmlAttachment struct {
-	EmbeddedDocumentBinaryObject xmlEmbeddedDocumentBinaryObject `xml:"cbc:EmbeddedDocumentBinaryObject"`
+// XMLOrderLineReference is cac:OrderLineReference.
+type XMLOrderLineReference struct {
+	LineID string `xml:"cbc:LineID"`
 }
 
-type xmlEmbeddedDocumentBinaryObject struct {
+// XMLLineDocumentReference is cac:DocumentReference of a line.
+type XMLLineDocumentReference struct {
+	ID               XMLIdentifier `xml:"cbc:ID"`
+	DocumentTypeCode string        `xml:"cbc:DocumentTypeCode"`
+}
+
+// XMLBillingReference is cac:BillingReference.
+type XMLBillingReference struct {
+	InvoiceDocumentReference XMLInvoiceDocumentReference `xml:"cac:InvoiceDocumentReference"`
+}
+
+// XMLInvoiceDocumentReference is cac:InvoiceDocumentReference.
+type XMLInvoiceDocumentReference struct {
+	ID        string `xml:"cbc:ID"`
+	IssueDate string `xml:"cbc:IssueDate,omitempty"`
+}
+
+// XMLDocumentReference is cac:AdditionalDocumentReference.
+type XMLDocumentReference struct {
+	ID                  XMLIdentifier   `xml:"cbc:ID"`
+	DocumentTypeCode    string          `xml:"cbc:DocumentTypeCode,omitempty"`
+	DocumentDescription string          `xml:"cbc:DocumentDescription,omitempty"`
+	Attachment          []XMLAttachment `xml:"cac:Attachment"`
+}
+
+// XMLAttachment is cac:Attachment.
+type XMLAttachment struct {
+	EmbeddedDocumentBinaryObject *XMLEmbeddedDocumentBinaryObject `xml:"cbc:EmbeddedDocumentBinaryObject,omitempty"`
+	ExternalReference            *XMLExternalReference            `xml:"cac:ExternalReference,omitempty"`
+}
+
+// XMLExternalReference is cac:ExternalReference.
+type XMLExternalReference struct {
+	URI string `xml:"cbc:URI"`
+}
+
+// XMLEmbeddedDocumentBinaryObject holds either base64 Value or raw Data,
+// which MarshalXML encodes.
+type XMLEmbeddedDocumentBinaryObject struct {
 	Value    string `xml:",chardata"`
+	Data     []byte `xml:"-"`
 	MimeCode string `xml:"mimeCode,attr"`
 	Filename string `xml:"filename,attr"`
 }
 
-type xmlSupplierParty struct {
-	Party xmlParty `xml:"cac:Party"`
+// XMLSignature is cac:Signature, which refers to a signature in the
+// extensions.
+type XMLSignature struct {
+	ID              string `xml:"cbc:ID"`
+	SignatureMethod string `xml:"cbc:SignatureMethod,omitempty"`
 }
 
-type xmlCustomerParty struct {
-	Party xmlParty `xml:"cac:Party"`
+// XMLSupplierParty is cac:AccountingSupplierParty.
+type XMLSupplierParty struct {
+	Party XMLParty `xml:"cac:Party"`
 }
 
-type xmlEndpointID struct {
+// XMLCustomerParty is cac:AccountingCustomerParty.
+type XMLCustomerParty struct {
+	Party XMLParty `xml:"cac:Party"`
+}
+
+// XMLEndpointID is cbc:EndpointID.
+type XMLEndpointID struct {
 	Value    string `xml:",chardata"`
 	SchemeID string `xml:"schemeID,attr"`
 }
 
-type xmlParty struct {
-	EndpointID       xmlEndpointID     `xml:"cbc:EndpointID"`
-	PartyName        string            `xml:"cac:PartyName>cbc:Name"`
-	PostalAddress    xmlPostalAddress  `xml:"cac:PostalAddress"`
-	PartyTaxScheme   xmlPartyTaxScheme `xml:"cac:PartyTaxScheme"`
-	RegistrationName string            `xml:"cac:PartyLegalEntity>cbc:RegistrationName"`
+// XMLIdentifier is an identifier with its schemeID, e.g. cbc:ID or cbc:CompanyID.
+type XMLIdentifier struct {
+	Value    string `xml:",chardata"`
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+}
+
+// XMLPartyIdentification is cac:PartyIdentification.
+type XMLPartyIdentification struct {
+	ID XMLIdentifier `xml:"cbc:ID"`
+}
+
+// XMLParty is cac:Party.
+type XMLParty struct {
+	EndpointID          XMLEndpointID            `xml:"cbc:EndpointID"`
+	PartyIdentification []XMLPartyIdentification `xml:"cac:PartyIdentification"`
+	PartyName           string                   `xml:"cac:PartyName>cbc:Name"`
+	PostalAddress       XMLPostalAddress         `xml:"cac:PostalAddress"`
+	PartyTaxScheme      []XMLPartyTaxScheme      `xml:"cac:PartyTaxScheme"`
+	PartyLegalEntity    XMLPartyLegalEntity      `xml:"cac:PartyLegalEntity"`
+	Contact             *XMLContact              `xml:"cac:Contact,omitempty"`
+}
+
+// XMLPartyLegalEntity is cac:PartyLegalEntity.
+type XMLPartyLegalEntity struct {
+	RegistrationName string         `xml:"cbc:RegistrationName"`
+	CompanyID        *XMLIdentifier `xml:"cbc:CompanyID,omitempty"`
+	CompanyLegalForm string         `xml:"cbc:CompanyLegalForm,omitempty"`
 }
 
-type xmlPostalAddress struct {
-	StreetName string     `xml:"cbc:StreetName,omitempty"`
-	CityName   string     `xml:"cbc:CityName,omitempty"`
-	PostalZone string     `xml:"cbc:PostalZone,omitempty"`
-	Country    xmlCountry `xml:"cac:Country,omitempty"`
+// XMLContact is cac:Contact.
+type XMLContact struct {
+	Name           string `xml:"cbc:Name,omitempty"`
+	Telephone      string `xml:"cbc:Telephone,omitempty"`
+	ElectronicMail string `xml:"cbc:ElectronicMail,omitempty"`
 }
 
-type xmlPartyTaxScheme struct {
+// XMLPostalAddress is cac:PostalAddress, also used for cac:Address.
+type XMLPostalAddress struct {
+	StreetName           string          `xml:"cbc:StreetName,omitempty"`
+	AdditionalStreetName string          `xml:"cbc:AdditionalStreetName,omitempty"`
+	CityName             string          `xml:"cbc:CityName,omitempty"`
+	PostalZone           string          `xml:"cbc:PostalZone,omitempty"`
+	CountrySubentity     string          `xml:"cbc:CountrySubentity,omitempty"`
+	AddressLine          *XMLAddressLine `xml:"cac:AddressLine,omitempty"`
+	Country              XMLCountry      `xml:"cac:Country,omitempty"`
+}
+
+// XMLAddressLine is cac:AddressLine.
+type XMLAddressLine struct {
+	Line string `xml:"cbc:Line"`
+}
+
+// XMLPartyTaxScheme is cac:PartyTaxScheme.
+type XMLPartyTaxScheme struct {
 	CompanyID string       `xml:"cbc:CompanyID"`
-	TaxScheme xmlTaxScheme `xml:"cac:TaxScheme"`
+	TaxScheme XMLTaxScheme `xml:"cac:TaxScheme"`
 }
 
-type xmlCountry struct {
+// XMLCountry is cac:Country.
+type XMLCountry struct {
 	IdentificationCode string `xml:"cbc:IdentificationCode,omitempty"`
 }
 
-type xmlPaymentMeans struct {
-	PaymentMeansCode      string              `xml:"cbc:PaymentMeansCode"`
-	PayeeFinancialAccount xmlFinancialAccount `xml:"cac:PayeeFinancialAccount"`
+// XMLPaymentMeans is cac:PaymentMeans.
+type XMLPaymentMeans struct {
+	PaymentMeansCode      XMLPaymentMeansCode  `xml:"cbc:PaymentMeansCode"`
+	PayeeFinancialAccount *XMLFinancialAccount `xml:"cac:PayeeFinancialAccount,omitempty"`
+	PaymentMandate        *XMLPaymentMandate   `xml:"cac:PaymentMandate,omitempty"`
+}
+
+// XMLPaymentMandate is cac:PaymentMandate.
+type XMLPaymentMandate struct {
+	ID                    string               `xml:"cbc:ID"`
+	PayerFinancialAccount *XMLFinancialAccount `xml:"cac:PayerFinancialAccount,omitempty"`
 }
 
-type xmlFinancialAccount struct {
-	ID                         string                        `xml:"cbc:ID"`
-	FinancialInstitutionBranch xmlFinancialInstitutionBranch `xml:"cac:FinancialInstitutionBranch"`
+// XMLPaymentMeansCode is cbc:PaymentMeansCode.
+type XMLPaymentMeansCode struct {
+	Value string `xml:",chardata"`
+	Name  string `xml:"name,attr,omitempty"`
 }
 
-type xmlFinancialInstitutionBranch struct {
+// XMLFinancialAccount is cac:PayeeFinancialAccount or cac:PayerFinancialAccount.
+type XMLFinancialAccount struct {
+	ID                         string                         `xml:"cbc:ID"`
+	Name                       string                         `xml:"cbc:Name,omitempty"`
+	FinancialInstitutionBranch *XMLFinancialInstitutionBranch `xml:"cac:FinancialInstitutionBranch,omitempty"`
+}
+
+// XMLFinancialInstitutionBranch is cac:FinancialInstitutionBranch.
+type XMLFinancialInstitutionBranch struct {
 	ID string `xml:"cbc:ID"`
 }
 
-type xmlPaymentTerms struct {
-	Note string `xml:"cbc:Note"`
+// XMLPaymentTerms is cac:PaymentTerms.
+type XMLPaymentTerms struct {
+	Note []string `xml:"cbc:Note"`
+}
+
+// XMLTaxTotal is cac:TaxTotal.
+type XMLTaxTotal struct {
+	TaxAmount   XMLAmount        `xml:"cbc:TaxAmount"`
+	TaxSubtotal []XMLTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+// XMLTaxSubtotal is cac:TaxSubtotal.
+type XMLTaxSubtotal struct {
+	TaxableAmount XMLAmount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     XMLAmount      `xml:"cbc:TaxAmount"`
+	TaxCategory   XMLTaxCategory `xml:"cac:TaxCategory"`
 }
 
-type xmlTaxTotal struct {
-	TaxAmount   xmlAmount        `xml:"cbc:TaxAmount"`
-	TaxSubtotal []xmlTaxSubtotal `xml:"cac:TaxSubtotal"`
+// XMLMonetaryTotal is cac:LegalMonetaryTotal.
+type XMLMonetaryTotal struct {
+	LineExtensionAmount   XMLAmount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount    XMLAmount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount    XMLAmount  `xml:"cbc:TaxInclusiveAmount"`
+	AllowanceTotalAmount  *XMLAmount `xml:"cbc:AllowanceTotalAmount,omitempty"`
+	ChargeTotalAmount     *XMLAmount `xml:"cbc:ChargeTotalAmount,omitempty"`
+	PayableRoundingAmount *XMLAmount `xml:"cbc:PayableRoundingAmount,omitempty"`
+	PayableAmount         XMLAmount  `xml:"cbc:PayableAmount"`
 }
 
-type xmlTaxSubtotal struct {
-	TaxableAmount xmlAmount      `xml:"cbc:TaxableAmount"`
-	TaxAmount     xmlAmount      `xml:"cbc:TaxAmount"`
-	TaxCategory   xmlTaxCategory `xml:"cac:TaxCategory"`
+// XMLAllowanceCharge is cac:AllowanceCharge.
+type XMLAllowanceCharge struct {
+	ChargeIndicator           bool           `xml:"cbc:ChargeIndicator"`
+	AllowanceChargeReasonCode string         `xml:"cbc:AllowanceChargeReasonCode,omitempty"`
+	AllowanceChargeReason     string         `xml:"cbc:AllowanceChargeReason,omitempty"`
+	MultiplierFactorNumeric   float64        `xml:"cbc:MultiplierFactorNumeric,omitempty"`
+	Amount                    XMLAmount      `xml:"cbc:Amount"`
+	BaseAmount                *XMLAmount     `xml:"cbc:BaseAmount,omitempty"`
+	TaxCategory               XMLTaxCategory `xml:"cac:TaxCategory"`
 }
 
-type xmlMonetaryTotal struct {
-	LineExtensionAmount xmlAmount `xml:"cbc:LineExtensionAmount"`
-	TaxExclusiveAmount  xmlAmount `xml:"cbc:TaxExclusiveAmount"`
-	TaxInclusiveAmount  xmlAmount `xml:"cbc:TaxInclusiveAmount"`
-	PayableAmount       xmlAmount `xml:"cbc:PayableAmount"`
+// XMLAmount is an amount with its currencyID, e.g. cbc:TaxAmount.
+type XMLAmount struct {
+	Value      float64 `xml:",chardata"`
+	CurrencyID string  `xml:"currencyID,attr"`
 }
 
-type xmlAmount struct {
+// XMLPriceAmount is a unit price, which keeps its full precision.
+type XMLPriceAmount struct {
 	Value      float64 `xml:",chardata"`
 	CurrencyID string  `xml:"currencyID,attr"`
 }
 
 // Possible values for the unitcode:
 // https://docs.peppol.eu/poacc/billing/3.0/codelist/UNECERec20/
-type xmlQuantity struct {
+type XMLQuantity struct {
 	Value    float64 `xml:",chardata"`
 	UnitCode string  `xml:"unitCode,attr"`
 }
 
-type xmlInvoiceLine struct {
-	ID                  string      `xml:"cbc:ID"`
-	InvoicedQuantity    xmlQuantity `xml:"cbc:InvoicedQuantity"`
-	LineExtensionAmount xmlAmount   `xml:"cbc:LineExtensionAmount"`
-	TaxTotal            xmlTaxTotal `xml:"cac:TaxTotal"`
-	Item                xmlItem     `xml:"cac:Item"`
-	Price               xmlPrice    `xml:"cac:Price"`
+// XMLInvoiceLine is cac:InvoiceLine.
+type XMLInvoiceLine struct {
+	ID                  string                    `xml:"cbc:ID"`
+	Note                string                    `xml:"cbc:Note,omitempty"`
+	InvoicedQuantity    XMLQuantity               `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount XMLAmount                 `xml:"cbc:LineExtensionAmount"`
+	AccountingCost      string                    `xml:"cbc:AccountingCost,omitempty"`
+	OrderLineReference  *XMLOrderLineReference    `xml:"cac:OrderLineReference,omitempty"`
+	DocumentReference   *XMLLineDocumentReference `xml:"cac:DocumentReference,omitempty"`
+	TaxTotal            XMLTaxTotal               `xml:"cac:TaxTotal"`
+	Item                XMLItem                   `xml:"cac:Item"`
+	Price               XMLPrice                  `xml:"cac:Price"`
 }
 
-type xmlItem struct {
-	Description           string         `xml:"cbc:Description"`
-	Name                  string         `xml:"cbc:Name"`
-	ClassifiedTaxCategory xmlTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+// XMLItem is cac:Item.
+type XMLItem struct {
+	Description                string                       `xml:"cbc:Description,omitempty"`
+	Name                       string                       `xml:"cbc:Name"`
+	StandardItemIdentification *XMLItemIdentification       `xml:"cac:StandardItemIdentification,omitempty"`
+	CommodityClassification    []XMLCommodityClassification `xml:"cac:CommodityClassification"`
+	ClassifiedTaxCategory      XMLTaxCategory               `xml:"cac:ClassifiedTaxCategory"`
+	AdditionalItemProperty     []XMLItemProperty            `xml:"cac:AdditionalItemProperty"`
 }
 
-type xmlTaxCategory struct {
+// XMLItemProperty is cac:AdditionalItemProperty.
+type XMLItemProperty struct {
+	Name  string `xml:"cbc:Name"`
+	Value string `xml:"cbc:Value"`
+}
+
+// XMLCommodityClassification is cac:CommodityClassification.
+type XMLCommodityClassification struct {
+	ItemClassificationCode XMLClassificationCode `xml:"cbc:ItemClassificationCode"`
+}
+
+// XMLClassificationCode is cbc:ItemClassificationCode.
+type XMLClassificationCode struct {
+	Value         string `xml:",chardata"`
+	ListID        string `xml:"listID,attr"`
+	ListVersionID string `xml:"listVersionID,attr,omitempty"`
+}
+
+// XMLItemIdentification is cac:StandardItemIdentification.
+type XMLItemIdentification struct {
+	ID XMLIdentifier `xml:"cbc:ID"`
+}
+
+// XMLTaxCategory is cac:TaxCategory, or cac:ClassifiedTaxCategory of an item.
+type XMLTaxCategory struct {
 	ID                     string       `xml:"cbc:ID"`
 	Name                   string       `xml:"cbc:Name,omitempty"`
-	Percent                float64      `xml:"cbc:Percent"`
+	Percent                *float64     `xml:"cbc:Percent,omitempty"`
 	TaxExemptionReasonCode string       `xml:"cbc:TaxExemptionReasonCode,omitempty"`
 	TaxExemptionReason     string       `xml:"cbc:TaxExemptionReason,omitempty"`
-	TaxScheme              xmlTaxScheme `xml:"cac:TaxScheme"`
+	TaxScheme              XMLTaxScheme `xml:"cac:TaxScheme"`
 }
 
-type xmlTaxScheme struct {
+// XMLTaxScheme is cac:TaxScheme.
+type XMLTaxScheme struct {
 	ID string `xml:"cbc:ID"`
 }
 
-type xmlPrice struct {
-	PriceAmount xmlAmount `xml:"cbc:PriceAmount"`
+// XMLPrice is cac:Price.
+type XMLPrice struct {
+	PriceAmount     XMLPriceAmount           `xml:"cbc:PriceAmount"`
+	AllowanceCharge *XMLPriceAllowanceCharge `xml:"cac:AllowanceCharge,omitempty"`
+}
+
+// XMLPriceAllowanceCharge is cac:AllowanceCharge of a price.
+type XMLPriceAllowanceCharge struct {
+	ChargeIndicator bool           `xml:"cbc:ChargeIndicator"`
+	Amount          XMLPriceAmount `xml:"cbc:Amount"`
+	BaseAmount      XMLPriceAmount `xml:"cbc:BaseAmount"`
+}
+
+// XMLInvoicePeriod is cac:InvoicePeriod.
+type XMLInvoicePeriod struct {
+	StartDate string `xml:"cbc:StartDate,omitempty"`
+	EndDate   string `xml:"cbc:EndDate,omitempty"`
 }
 
-type xmlInvoicePeriod struct {
-	StartDate string `xml:"cbc:StartDate"`
-	EndDate   string `xml:"cbc:EndDate"`
+// XMLDelivery is cac:Delivery.
+type XMLDelivery struct {
+	ActualDeliveryDate string               `xml:"cbc:ActualDeliveryDate,omitempty"`
+	DeliveryLocation   *XMLDeliveryLocation `xml:"cac:DeliveryLocation,omitempty"`
+	DeliveryParty      *XMLDeliveryParty    `xml:"cac:DeliveryParty,omitempty"`
 }
 
-type xmlDelivery struct {
-	ActualDeliveryDate string              `xml:"cbc:ActualDeliveryDate,omitempty"`
-	DeliveryLocation   xmlDeliveryLocation `xml:"cac:DeliveryLocation,omitempty"`
+// XMLDeliveryLocation is cac:DeliveryLocation.
+type XMLDeliveryLocation struct {
+	ID      *XMLIdentifier    `xml:"cbc:ID,omitempty"`
+	Address *XMLPostalAddress `xml:"cac:Address,omitempty"`
 }
 
-type xmlDeliveryLocation struct {
-	Address xmlPostalAddress `xml:"cac:Address"`
+// XMLDeliveryParty is cac:DeliveryParty.
+type XMLDeliveryParty struct {
+	PartyName string `xml:"cac:PartyName>cbc:Name"`
 }